@@ -15,39 +15,85 @@ import (
  */
 func initializeFlags() {
 	backupDir = flag.String("backupdir", "", "The absolute path of the directory to which all backup files will be written")
+	bestEffort = flag.Bool("best-effort", false, "Log and skip objects whose DDL cannot be generated instead of aborting the backup")
+	checkPrivileges = flag.Bool("check-privileges", false, "Before starting the backup, check that the current role can read all of the catalog tables, schemas, and tables to be backed up, and exit with a list of any that it cannot")
+	flag.Var(&componentLogLevels, "component-log-level", "Set the log level of one component of the backup, as component=level, e.g. types=debug; components are globals, predata, types, data, postdata, and statistics. --component-log-level can be specified multiple times.")
+	compressMetadata = flag.Bool("compress-metadata", false, "Compress metadata files with gzip")
 	dataOnly = flag.Bool("data-only", false, "Only back up data, do not back up metadata")
 	dbname = flag.String("dbname", "", "The database to be backed up")
 	debug = flag.Bool("debug", false, "Print verbose and debug log messages")
+	dependencyCacheFile = flag.String("dependency-cache", "", "A file in which to cache object dependencies between backups, to be reused if the database catalog has not changed")
+	disableTriggersOnRestore = flag.Bool("disable-triggers-on-restore", false, "Emit statements to disable the foreign key constraint triggers on each table that has them before its data is restored and to re-enable them afterward")
+	emailAttachReport = flag.Bool("email-attach-report", false, "Attach the report file to the email report instead of including it in the body")
+	emailContactsFile = flag.String("email-contacts-file", "", "A file listing the recipients of the email report, to use instead of mail_contacts in $HOME or $GPHOME/bin")
+	emailOnlyOnFailure = flag.Bool("email-only-on-failure", false, "Only send the email report if the backup fails")
+	emailSMTPServer = flag.String("email-smtp-server", "", "The host:port of the SMTP server through which to send the email report with --email-transport smtp")
+	emailSMTPStartTLS = flag.Bool("email-smtp-starttls", false, "Upgrade the connection to the SMTP server with STARTTLS before sending the email report")
+	emailSMTPUser = flag.String("email-smtp-user", "", "The user with which to authenticate to the SMTP server; the password is read from the GPBACKUP_SMTP_PASSWORD environment variable")
+	emailSubject = flag.String("email-subject", utils.DefaultEmailSubjectTemplate, "A template for the subject of the email report, in which {{.Timestamp}}, {{.Hostname}}, and {{.Status}} are replaced by the backup timestamp, the master hostname, and the backup status")
+	emailTransport = flag.String("email-transport", utils.EmailTransportSendmail, "How to send the email report, either sendmail to pipe it to sendmail or smtp to send it to the server given by --email-smtp-server")
+	excludeDefaultResourceGroups = flag.Bool("exclude-default-resource-groups", false, "Do not back up the settings of the built-in default_group and admin_group resource groups, leaving them at their defaults on restore")
 	flag.Var(&excludeSchemas, "exclude-schema", "Do not back up only the specified schema(s). --exclude-schema can be specified multiple times.")
 	excludeTableFile = flag.String("exclude-table-file", "", "A file containing a list of fully-qualified tables to be excluded from the backup")
 	flag.Var(&includeSchemas, "include-schema", "Back up only the specified schema(s). --include-schema can be specified multiple times.")
+	freeSpaceThreshold = flag.Int("free-space-threshold", 0, "Log a warning if free space in the master backup directory falls below this many megabytes during the backup")
+	includeArrayTypes = flag.Bool("include-array-types", false, "For diagnosing catalog problems only: do not exclude automatically-generated array types from the types that are backed up")
 	includeTableFile = flag.String("include-table-file", "", "A file containing a list of fully-qualified tables to be included in the backup")
+	keepAliveInterval = flag.Int("keep-alive-interval", 0, "Issue a trivial query on the master connection whenever it has been idle for this many seconds, e.g. to keep a firewall from dropping it during a long data backup; 0 disables the keep-alive")
 	leafPartitionData = flag.Bool("leaf-partition-data", false, "For partition tables, create one data file per leaf partition instead of one data file for the whole table")
+	linkCurrentLog = flag.Bool("link-current-log", false, "Point a symlink named gpbackup_current.log in the log directory at the log file for this run")
+	logFormat = flag.String("log-format", "text", "The format of log lines, either text or json for one JSON object per line")
+	logPrefixSeparator = flag.String("log-prefix-separator", ":", "The separator to use between fields of the prefix of each log line")
+	metadataBufferSize = flag.Int("metadata-buffer-size", 0, "Buffer writes to metadata files in chunks of this many bytes instead of writing each statement immediately, e.g. for faster writes to a networked filesystem")
 	metadataOnly = flag.Bool("metadata-only", false, "Only back up metadata, do not back up data")
+	noColor = flag.Bool("no-color", false, "Do not color the level of warning and error messages printed to a terminal")
+	noComments = flag.Bool("no-comments", false, "Do not back up comments on database objects")
 	noCompression = flag.Bool("no-compression", false, "Disable compression of data files")
+	objectCountLabelsFile = flag.String("object-count-labels-file", "", "A file of category=label lines giving display labels for the object count categories in the report file, e.g. tables=Tabellen")
 	printVersion = flag.Bool("version", false, "Print version number and exit")
 	quiet = flag.Bool("quiet", false, "Suppress non-warning, non-error log messages")
+	restorePoint = flag.String("restore-point", "", "Create a restore point with this name when the backup starts and record its location in the report, to align the backup with point-in-time recovery (GPDB 6 and later)")
+	revokeRoleMemberships = flag.Bool("revoke-role-memberships", false, "With --globals, revoke each role membership before granting it, so that restoring onto a cluster where the membership already exists leaves it exactly as it was backed up")
+	singleTransactionMetadata = flag.Bool("single-transaction-metadata", false, "Wrap the role statements in the global file and the statements in the pre-data file in a transaction, so that a failed metadata restore is rolled back; tablespaces, the database, and resource queues and groups are created outside it")
+	schemaObjectCounts = flag.Bool("schema-object-counts", false, "Also break down the counts of schema-qualified objects in the report by schema")
+	sortObjectCountsByCount = flag.Bool("sort-object-counts-by-count", false, "List the counts of database objects in the report from most to least numerous instead of alphabetically")
+	strictTypeChecks = flag.Bool("strict-type-checks", false, "Abort the backup if a base type has inconsistent length, alignment, storage, and pass-by-value settings, instead of skipping the type with a warning")
+	flag.Var(&stripDDLClauses, "strip-ddl-clause", "Remove the given text, e.g. \" WITH OIDS\", wherever it appears in the metadata statements that are backed up. --strip-ddl-clause can be specified multiple times.")
+	useSyslog = flag.Bool("syslog", false, "Also write log messages to syslog")
+	syslogOnly = flag.Bool("syslog-only", false, "Write log messages to syslog instead of to a log file; implies --syslog")
+	syslogServer = flag.String("syslog-server", "", "The host:port of a remote syslog server to which to send log messages over UDP, instead of the local syslog server; implies --syslog")
+	backupTimestamp = flag.String("timestamp", "", "Use the specified timestamp, in the format YYYYMMDDHHMMSS, instead of the current time, e.g. to give backups of several databases the same timestamp")
+	updateLatest = flag.Bool("update-latest", false, "After a successful backup, update a \"latest\" pointer in the backup directory to refer to this backup")
 	verbose = flag.Bool("verbose", false, "Print verbose log messages")
 	withStats = flag.Bool("with-stats", false, "Back up query plan statistics")
 }
 
-// This function handles setup that can be done before parsing flags.
+/*
+ * This function parses flags, which must be done before the logger is created
+ * so that the syslog flags can be applied to it.
+ */
 func DoInit() {
-	SetLogger(utils.InitializeLogging("gpbackup", ""))
 	initializeFlags()
-}
-
-func DoFlagValidation() {
 	if len(os.Args) == 1 {
 		flag.PrintDefaults()
 		os.Exit(0)
 	}
 	flag.Parse()
+	SetLogger(utils.InitializeLoggingFromFlags("gpbackup", *useSyslog, *syslogServer, *syslogOnly))
+	utils.WriteExitReport = writeExitReport
+}
+
+func DoFlagValidation() {
 	if *printVersion {
 		fmt.Printf("gpbackup %s\n", version)
 		os.Exit(0)
 	}
 	ValidateFlagCombinations()
+	utils.ValidateLogPrefixSeparator(*logPrefixSeparator)
+	ValidateEmailSubject(*emailSubject)
+	ValidateEmailTransport(*emailTransport, *emailSMTPServer)
+	ValidateTimestamp(*backupTimestamp)
+	ValidateStripDDLClauses(stripDDLClauses)
 	utils.ValidateBackupDir(*backupDir)
 }
 
@@ -55,18 +101,30 @@ func DoFlagValidation() {
 func DoSetup() {
 	SetLoggerVerbosity()
 	logger.Info("Starting backup of database %s", *dbname)
+	connectStart := utils.System.Now()
 	InitializeConnection()
 
 	InitializeFilterLists()
+	InitializeStatementTransform()
 	InitializeBackupReport()
+	LogFilterConfiguration()
+	backupReport.StartTime = connectStart
+	backupReport.StartPhaseAt("connect", connectStart)
 	validateSetup()
 
 	segConfig := utils.GetSegmentConfiguration(connection)
-	timestamp := utils.CurrentTimestamp()
+	timestamp := *backupTimestamp
+	if timestamp == "" {
+		timestamp = utils.CurrentTimestamp()
+	}
 	segPrefix := utils.GetSegPrefix(connection)
-	utils.CreateBackupLockFile(timestamp)
 	globalCluster = utils.NewCluster(segConfig, *backupDir, timestamp, segPrefix)
+	ValidateTimestampIsUnused(globalCluster)
+	utils.CreateBackupLockFile(timestamp)
 	globalCluster.CreateBackupDirectoriesOnAllHosts()
+	globalCluster.VerifyBackupDirectoriesWritableOnAllHosts()
+	backupReport.SegmentCount = globalCluster.GetSegmentCount()
+	backupReport.EndPhase("connect")
 	globalTOC = &utils.TOC{}
 	globalTOC.InitializeEntryMapFromCluster(globalCluster)
 }
@@ -81,12 +139,20 @@ func validateSetup() {
 	ValidateFilterSchemas(connection, includeSchemas)
 	ValidateFilterTables(connection, excludeTables)
 	ValidateFilterTables(connection, includeTables)
+	if *checkPrivileges {
+		ValidatePrivileges(connection, !*metadataOnly)
+	}
 }
 
 func DoBackup() {
 	LogBackupInfo()
 
 	objectCounts = make(map[string]int, 0)
+	InitializeDependencyCache()
+	stopKeepAlive := func() {}
+	if *keepAliveInterval > 0 {
+		stopKeepAlive = connection.StartKeepAlive(time.Duration(*keepAliveInterval) * time.Second)
+	}
 
 	isTableFiltered := len(includeTables) > 0 || len(excludeTables) > 0
 	metadataTables, dataTables, tableDefs := RetrieveAndProcessTables()
@@ -96,30 +162,43 @@ func DoBackup() {
 		} else {
 			backupGlobal(objectCounts)
 			backupPredata(metadataTables, tableDefs, objectCounts)
-			backupPostdata(objectCounts)
+			backupPostdata(metadataTables, objectCounts)
 		}
+		CheckFreeSpace("metadata backup")
 	}
 
 	if !*metadataOnly {
 		backupData(dataTables, tableDefs)
+		CheckFreeSpace("data backup")
 	}
 
 	if *withStats {
 		backupStatistics(metadataTables)
+		CheckFreeSpace("statistics backup")
 	}
 
 	globalTOC.WriteToFile(globalCluster.GetTOCFilePath())
+	backupReport.MetadataEntryCount, backupReport.MetadataBytes = globalTOC.Summary()
+	logger.Info("Wrote %d metadata entries spanning %d bytes", backupReport.MetadataEntryCount, backupReport.MetadataBytes)
+	if dependencyCache != nil {
+		dependencyCache.WriteToFile(*dependencyCacheFile)
+	}
+	stopKeepAlive()
 	connection.Commit()
 }
 
 func backupGlobal(objectCounts map[string]int) {
+	backupReport.StartPhase("globals")
+	defer backupReport.EndPhase("globals")
+	defer logComponent("globals")()
 	globalFilename := globalCluster.GetGlobalFilePath()
 	logger.Info("Writing global database metadata to %s", globalFilename)
 	globalFile := utils.NewFileWithByteCountFromFile(globalFilename)
 	defer globalFile.Close()
 
 	BackupSessionGUCs(globalFile)
-	BackupTablespaces(globalFile, objectCounts)
+	tablespaces := GetTablespaces(connection)
+	BackupTablespaces(globalFile, objectCounts, tablespaces)
 	BackupCreateDatabase(globalFile, objectCounts)
 	BackupDatabaseGUCs(globalFile, objectCounts)
 
@@ -128,19 +207,41 @@ func backupGlobal(objectCounts map[string]int) {
 		if connection.Version.AtLeast("5") {
 			BackupResourceGroups(globalFile, objectCounts)
 		}
+		/*
+		 * Tablespaces, the database, and resource queues and groups cannot be
+		 * created in a transaction block, so only roles are wrapped in one.
+		 */
+		if *singleTransactionMetadata {
+			PrintBeginTransaction(globalFile, globalTOC)
+		}
 		BackupRoles(globalFile, objectCounts)
 		BackupRoleGrants(globalFile, objectCounts)
+		if *singleTransactionMetadata {
+			PrintCommitTransaction(globalFile, globalTOC)
+		}
 	}
+	BackupTablespaceMetadata(globalFile, tablespaces)
 	logger.Info("Global database metadata backup complete")
 }
 
 func backupPredata(tables []Relation, tableDefs map[uint32]TableDefinition, objectCounts map[string]int) {
+	backupReport.StartPhase("predata")
+	defer backupReport.EndPhase("predata")
+	defer logComponent("predata")()
 	predataFilename := globalCluster.GetPredataFilePath()
 	logger.Info("Writing pre-data metadata to %s", predataFilename)
 	predataFile := utils.NewFileWithByteCountFromFile(predataFilename)
 	defer predataFile.Close()
 
+	writePredata(predataFile, tables, tableDefs, objectCounts)
+	logger.Info("Pre-data metadata backup complete")
+}
+
+func writePredata(predataFile *utils.FileWithByteCount, tables []Relation, tableDefs map[uint32]TableDefinition, objectCounts map[string]int) {
 	BackupSessionGUCs(predataFile)
+	if *singleTransactionMetadata {
+		PrintBeginTransaction(predataFile, globalTOC)
+	}
 	BackupSchemas(predataFile, objectCounts)
 
 	procLangs := GetProceduralLanguages(connection)
@@ -187,16 +288,27 @@ func backupPredata(tables []Relation, tableDefs map[uint32]TableDefinition, obje
 	BackupCasts(predataFile, objectCounts)
 	BackupViews(predataFile, objectCounts, relationMetadata)
 	BackupConstraints(predataFile, objectCounts, constraints, conMetadata)
-	logger.Info("Pre-data metadata backup complete")
+	if *disableTriggersOnRestore {
+		BackupDisableTriggers(predataFile, tables)
+	}
+	if *singleTransactionMetadata {
+		PrintCommitTransaction(predataFile, globalTOC)
+	}
 }
 
 func backupTablePredata(tables []Relation, tableDefs map[uint32]TableDefinition, objectCounts map[string]int) {
+	backupReport.StartPhase("predata")
+	defer backupReport.EndPhase("predata")
+	defer logComponent("predata")()
 	predataFilename := globalCluster.GetPredataFilePath()
 	logger.Info("Writing table metadata to %s", predataFilename)
 	predataFile := utils.NewFileWithByteCountFromFile(predataFilename)
 	defer predataFile.Close()
 
 	BackupSessionGUCs(predataFile)
+	if *singleTransactionMetadata {
+		PrintBeginTransaction(predataFile, globalTOC)
+	}
 
 	relationMetadata := GetMetadataForObjectType(connection, TYPE_RELATION)
 
@@ -204,73 +316,115 @@ func backupTablePredata(tables []Relation, tableDefs map[uint32]TableDefinition,
 
 	BackupTables(predataFile, tables, relationMetadata, tableDefs, constraints)
 	BackupConstraints(predataFile, objectCounts, constraints, conMetadata)
+	if *singleTransactionMetadata {
+		PrintCommitTransaction(predataFile, globalTOC)
+	}
 	logger.Info("Table metadata backup complete")
 }
 
 func backupData(tables []Relation, tableDefs map[uint32]TableDefinition) {
+	backupReport.StartPhase("data")
+	defer backupReport.EndPhase("data")
+	defer logComponent("data")()
 	logger.Info("Writing data to file")
 	BackupData(tables, tableDefs)
 	AddTableDataEntriesToTOC(tables, tableDefs)
 	logger.Info("Data backup complete")
 }
 
-func backupPostdata(objectCounts map[string]int) {
+func backupPostdata(tables []Relation, objectCounts map[string]int) {
+	backupReport.StartPhase("postdata")
+	defer backupReport.EndPhase("postdata")
+	defer logComponent("postdata")()
 	postdataFilename := globalCluster.GetPostdataFilePath()
 	logger.Info("Writing post-data metadata to %s", postdataFilename)
 	postdataFile := utils.NewFileWithByteCountFromFile(postdataFilename)
 	defer postdataFile.Close()
 
+	writePostdata(postdataFile, tables, objectCounts)
+	logger.Info("Post-data metadata backup complete")
+}
+
+func writePostdata(postdataFile *utils.FileWithByteCount, tables []Relation, objectCounts map[string]int) {
 	BackupSessionGUCs(postdataFile)
+	if *disableTriggersOnRestore {
+		BackupEnableTriggers(postdataFile, tables)
+	}
 	BackupIndexes(postdataFile, objectCounts)
 	BackupRules(postdataFile, objectCounts)
 	BackupTriggers(postdataFile, objectCounts)
-	logger.Info("Post-data metadata backup complete")
+	if connection.Version.AtLeast("6") {
+		BackupEventTriggers(postdataFile, objectCounts)
+	}
 }
 
 func backupStatistics(tables []Relation) {
+	backupReport.StartPhase("statistics")
+	defer backupReport.EndPhase("statistics")
+	defer logComponent("statistics")()
 	statisticsFilename := globalCluster.GetStatisticsFilePath()
 	logger.Info("Writing query planner statistics to %s", statisticsFilename)
 	statisticsFile := utils.NewFileWithByteCountFromFile(statisticsFilename)
+	defer statisticsFile.Close()
 	BackupStatistics(statisticsFile, tables)
 	logger.Info("Query planner statistics backup complete")
 }
 
 func DoTeardown() {
-	errStr := ""
-	if err := recover(); err != nil {
-		errStr = fmt.Sprintf("%v", err)
-		fmt.Println(err)
-	}
-	errMsg, exitCode := utils.ParseErrorMessage(errStr)
+	exitCode := utils.HandleFatalPanic(recover())
 	if connection != nil {
 		connection.Close()
 	}
 
+	if exitCode == 0 {
+		logger.Info("Backup completed successfully")
+	}
+	os.Exit(exitCode)
+}
+
+func writeExitReport(errMsg string) {
 	/*
 	 * Only create a report file if we fail after the cluster is initialized
 	 * and a backup directory exists in which to create the report file.
 	 */
-	if globalCluster.Timestamp != "" {
-		_, statErr := os.Stat(globalCluster.GetDirForContent(-1))
-		if statErr != nil { // Even if this isn't os.IsNotExist, don't try to write a report file in case of further errors
-			os.Exit(exitCode)
-		}
-		reportFilename := globalCluster.GetReportFilePath()
-		configFilename := globalCluster.GetConfigFilePath()
-		backupReport.WriteReportFile(reportFilename, globalCluster.Timestamp, objectCounts, errMsg)
-		backupReport.WriteConfigFile(configFilename)
-		utils.EmailReport(globalCluster)
-		// We sleep for 1 second to ensure multiple backups do not start within the same second.
-		time.Sleep(1000 * time.Millisecond)
-		timestampLockFile := fmt.Sprintf("/tmp/%s.lck", globalCluster.Timestamp)
-		err := os.Remove(timestampLockFile)
-		if err != nil {
-			logger.Warn("Failed to remove lock file %s.", timestampLockFile)
+	if globalCluster.Timestamp == "" {
+		return
+	}
+	if _, statErr := os.Stat(globalCluster.GetDirForContent(-1)); statErr != nil { // Even if this isn't os.IsNotExist, don't try to write a report file in case of further errors
+		return
+	}
+	reportFilename := globalCluster.GetReportFilePath()
+	configFilename := globalCluster.GetConfigFilePath()
+	backupReport.EndTime = utils.System.Now()
+	if errMsg == "" {
+		if backupSize, err := globalCluster.GetBackupSizeOnAllHosts(); err == nil {
+			backupReport.BackupSizeBytes = backupSize
+		} else {
+			logger.Warn("%s; the backup size will not be reported", err.Error())
 		}
 	}
-
-	if exitCode == 0 {
-		logger.Info("Backup completed successfully")
+	backupReport.StartPhase("report")
+	backupReport.WriteReportFile(reportFilename, globalCluster.Timestamp, objectCounts, errMsg)
+	backupReport.WriteJSONReport(globalCluster.GetJSONReportFilePath(), globalCluster.Timestamp, objectCounts, errMsg)
+	backupReport.EndPhase("report")
+	backupReport.WriteConfigFile(configFilename)
+	UpdateLatestBackupPointer(errMsg)
+	utils.EmailReport(globalCluster, utils.EmailConfig{
+		SubjectTemplate: *emailSubject,
+		Transport:       *emailTransport,
+		SMTPAddress:     *emailSMTPServer,
+		StartTLS:        *emailSMTPStartTLS,
+		Username:        *emailSMTPUser,
+		Password:        utils.System.Getenv("GPBACKUP_SMTP_PASSWORD"),
+		OnlyOnFailure:   *emailOnlyOnFailure,
+		ContactsFile:    *emailContactsFile,
+		AttachReport:    *emailAttachReport,
+	})
+	// We sleep for 1 second to ensure multiple backups do not start within the same second.
+	time.Sleep(1000 * time.Millisecond)
+	timestampLockFile := fmt.Sprintf("/tmp/%s.lck", globalCluster.Timestamp)
+	err := os.Remove(timestampLockFile)
+	if err != nil {
+		logger.Warn("Failed to remove lock file %s.", timestampLockFile)
 	}
-	os.Exit(exitCode)
 }
@@ -11,6 +11,7 @@ import (
 
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
 
+	"github.com/greenplum-db/gpbackup/backup"
 	"github.com/greenplum-db/gpbackup/testutils"
 	"github.com/greenplum-db/gpbackup/utils"
 	. "github.com/onsi/ginkgo"
@@ -52,6 +53,12 @@ func TestBackup(t *testing.T) {
 var _ = BeforeSuite(func() {
 	connection, mock, logger, stdout, stderr, logfile = testutils.SetupTestEnvironment()
 	baseVersion = connection.Version
+	backup.SetNoComments(false)
+	backup.SetDisableTriggersOnRestore(false)
+	backup.SetExcludeDefaultResourceGroups(false)
+	backup.SetRevokeRoleMemberships(false)
+	backup.SetSingleTransactionMetadata(false)
+	backup.SetStrictTypeChecks(false)
 })
 
 var _ = BeforeEach(func() {
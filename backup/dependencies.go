@@ -1,10 +1,15 @@
 package backup
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
 )
 
 func SortFunctionsAndTypesAndTablesInDependencyOrder(functions []Function, types []Type, tables []Relation) []Sortable {
@@ -95,6 +100,58 @@ func SortViews(views []View) []View {
 	return views
 }
 
+/*
+ * A GRANT ... GRANTED BY grantor requires the grantor to hold the admin option
+ * on the role being granted, so each membership is ordered after the membership
+ * that grants the role to its grantor, if that membership is in the backup.
+ * Unlike TopologicalSort, this does not error out on a cycle; any memberships
+ * left over are appended in their original order.
+ */
+func SortRoleMembers(roleMembers []RoleMember) []RoleMember {
+	type grant struct {
+		role   string
+		member string
+	}
+	indexes := make(map[grant]int, len(roleMembers))
+	for i, roleMember := range roleMembers {
+		indexes[grant{roleMember.Role, roleMember.Member}] = i
+	}
+	inDegrees := make([]int, len(roleMembers))
+	isDependentOn := make(map[int][]int, 0)
+	for i, roleMember := range roleMembers {
+		if dep, ok := indexes[grant{roleMember.Role, roleMember.Grantor}]; ok && dep != i {
+			inDegrees[i]++
+			isDependentOn[dep] = append(isDependentOn[dep], i)
+		}
+	}
+	queue := make([]int, 0)
+	for i := range roleMembers {
+		if inDegrees[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	sorted := make([]RoleMember, 0, len(roleMembers))
+	visited := make([]bool, len(roleMembers))
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		sorted = append(sorted, roleMembers[i])
+		visited[i] = true
+		for _, dep := range isDependentOn[i] {
+			inDegrees[dep]--
+			if inDegrees[dep] == 0 {
+				queue = append(queue, dep)
+			}
+		}
+	}
+	for i, roleMember := range roleMembers {
+		if !visited[i] {
+			sorted = append(sorted, roleMember)
+		}
+	}
+	return sorted
+}
+
 func TopologicalSort(slice []Sortable) []Sortable {
 	inDegrees := make(map[string]int, 0)
 	dependencyIndexes := make(map[string]int, 0)
@@ -139,3 +196,113 @@ func TopologicalSort(slice []Sortable) []Sortable {
 	}
 	return sorted
 }
+
+/*
+ * The dependency cache stores the results of the dependency queries run by the
+ * Construct*Dependencies functions so that frequent backups of a database whose
+ * catalog has not changed need not re-scan pg_depend every time.  Results are
+ * keyed by a hash of the query text, so that a query that differs in any way
+ * (e.g. due to a different schema filter or table list) will never reuse
+ * another query's results, and the whole cache is discarded unless its catalog
+ * marker matches that of the database being backed up.
+ */
+type DependencyCache struct {
+	CatalogMarker string
+	Results       map[string]string
+}
+
+func NewDependencyCache(catalogMarker string) *DependencyCache {
+	return &DependencyCache{CatalogMarker: catalogMarker, Results: make(map[string]string, 0)}
+}
+
+/*
+ * The catalog marker combines the database name and version with a hash of the
+ * xmin and ctid of every row in each catalog table read by the dependency
+ * queries.  Any DDL that could change a dependency inserts, updates, or deletes
+ * a row in one of these tables, which adds or removes an (xmin, ctid) pair and
+ * so changes the marker, whatever the xid of the transaction that made it.
+ * Freezing or moving a tuple may also change the marker, which merely causes an
+ * unnecessary cache miss.
+ */
+func GetCatalogMarker(connection *utils.DBConn) string {
+	catalogTables := []string{"pg_class", "pg_depend", "pg_namespace", "pg_proc", "pg_rewrite", "pg_type"}
+	markers := []string{connection.DBName, connection.Version.VersionString}
+	for _, catalogTable := range catalogTables {
+		query := fmt.Sprintf(`
+SELECT md5(array_to_string(ARRAY(
+	SELECT textin(xidout(xmin)) || ':' || textin(tidout(ctid))
+	FROM pg_catalog.%s
+	ORDER BY 1
+), ',')) AS string;`, catalogTable)
+		markers = append(markers, fmt.Sprintf("%s=%s", catalogTable, SelectString(connection, query)))
+	}
+	return strings.Join(markers, ",")
+}
+
+/*
+ * If the cache file does not exist, cannot be read, or was written for a
+ * different catalog marker, an empty cache is returned and all dependencies
+ * are recomputed.
+ */
+func ReadDependencyCache(filename string, catalogMarker string) *DependencyCache {
+	emptyCache := NewDependencyCache(catalogMarker)
+	fileHandle, err := utils.System.OpenFileRead(filename, os.O_RDONLY, 0644)
+	if err != nil {
+		logger.Verbose("No dependency cache found at %s; dependencies will be recomputed", filename)
+		return emptyCache
+	}
+	defer fileHandle.Close()
+	contents, err := ioutil.ReadAll(fileHandle)
+	if err != nil {
+		logger.Warn("Unable to read dependency cache %s; dependencies will be recomputed", filename)
+		return emptyCache
+	}
+	cache := &DependencyCache{}
+	err = yaml.Unmarshal(contents, cache)
+	if err != nil || cache.Results == nil {
+		logger.Warn("Unable to parse dependency cache %s; dependencies will be recomputed", filename)
+		return emptyCache
+	}
+	if cache.CatalogMarker != catalogMarker {
+		logger.Verbose("Catalog has changed since dependency cache %s was written; dependencies will be recomputed", filename)
+		return emptyCache
+	}
+	logger.Verbose("Using dependency cache %s", filename)
+	return cache
+}
+
+func (cache *DependencyCache) WriteToFile(filename string) {
+	fileHandle, err := utils.System.OpenFileWrite(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Warn("Unable to write dependency cache %s: %s", filename, err.Error())
+		return
+	}
+	defer fileHandle.Close()
+	cacheContents, _ := yaml.Marshal(cache)
+	utils.MustPrintBytes(fileHandle, cacheContents)
+}
+
+/*
+ * This function is a drop-in replacement for connection.Select in dependency
+ * queries that uses the dependency cache, if one is in use.
+ */
+func selectDependencies(connection *utils.DBConn, results interface{}, query string) {
+	if dependencyCache == nil {
+		err := connection.Select(results, query)
+		utils.CheckError(err)
+		return
+	}
+	queryHash := fmt.Sprintf("%x", sha256.Sum256([]byte(query)))
+	if cachedResults, ok := dependencyCache.Results[queryHash]; ok {
+		err := yaml.Unmarshal([]byte(cachedResults), results)
+		if err == nil {
+			return
+		}
+		logger.Warn("Unable to parse cached dependencies; dependencies will be recomputed")
+	}
+	err := connection.Select(results, query)
+	utils.CheckError(err)
+	resultContents, err := yaml.Marshal(results)
+	utils.CheckError(err)
+	dependencyCache.Results[queryHash] = string(resultContents)
+}
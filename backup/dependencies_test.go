@@ -2,6 +2,9 @@ package backup_test
 
 import (
 	"database/sql/driver"
+	"io/ioutil"
+	"os"
+	"path"
 
 	"github.com/greenplum-db/gpbackup/backup"
 	"github.com/greenplum-db/gpbackup/testutils"
@@ -265,6 +268,85 @@ var _ = Describe("backup/dependencies tests", func() {
 			Expect(types[0].DependsUpon).To(Equal([]string{"public.builtin"}))
 		})
 	})
+	Describe("DependencyCache", func() {
+		var cacheDir string
+		header := []string{"oid", "referencedobject"}
+		BeforeEach(func() {
+			testutils.SetDBVersion(connection, "5.0.0")
+			function1.Oid = 1
+			cacheDir, _ = ioutil.TempDir("", "dependency_cache")
+		})
+		AfterEach(func() {
+			backup.SetDependencyCache(nil)
+			os.RemoveAll(cacheDir)
+		})
+		It("reuses cached dependencies instead of querying again", func() {
+			backup.SetDependencyCache(backup.NewDependencyCache("marker"))
+			functionRows := sqlmock.NewRows(header).AddRow([]driver.Value{"1", "public.type"}...)
+			mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(functionRows)
+
+			functions := backup.ConstructFunctionDependencies(connection, []backup.Function{function1})
+			Expect(functions[0].DependsUpon).To(Equal([]string{"public.type"}))
+			functions = backup.ConstructFunctionDependencies(connection, []backup.Function{function1})
+			Expect(functions[0].DependsUpon).To(Equal([]string{"public.type"}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("reads a cache written by a previous backup if the catalog marker is unchanged", func() {
+			cacheFile := path.Join(cacheDir, "dependencies.yaml")
+			cache := backup.NewDependencyCache("marker")
+			backup.SetDependencyCache(cache)
+			functionRows := sqlmock.NewRows(header).AddRow([]driver.Value{"1", "public.type"}...)
+			mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(functionRows)
+			backup.ConstructFunctionDependencies(connection, []backup.Function{function1})
+			cache.WriteToFile(cacheFile)
+
+			backup.SetDependencyCache(backup.ReadDependencyCache(cacheFile, "marker"))
+			functions := backup.ConstructFunctionDependencies(connection, []backup.Function{function1})
+
+			Expect(functions[0].DependsUpon).To(Equal([]string{"public.type"}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("recomputes dependencies if the catalog marker has changed", func() {
+			cacheFile := path.Join(cacheDir, "dependencies.yaml")
+			cache := backup.NewDependencyCache("marker")
+			backup.SetDependencyCache(cache)
+			functionRows := sqlmock.NewRows(header).AddRow([]driver.Value{"1", "public.type"}...)
+			mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(functionRows)
+			backup.ConstructFunctionDependencies(connection, []backup.Function{function1})
+			cache.WriteToFile(cacheFile)
+
+			newCache := backup.ReadDependencyCache(cacheFile, "newmarker")
+			Expect(newCache.CatalogMarker).To(Equal("newmarker"))
+			Expect(newCache.Results).To(BeEmpty())
+			backup.SetDependencyCache(newCache)
+			functionRows = sqlmock.NewRows(header).AddRow([]driver.Value{"1", "public.othertype"}...)
+			mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(functionRows)
+			functions := backup.ConstructFunctionDependencies(connection, []backup.Function{function1})
+
+			Expect(functions[0].DependsUpon).To(Equal([]string{"public.othertype"}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not reuse cached dependencies for a different query", func() {
+			backup.SetDependencyCache(backup.NewDependencyCache("marker"))
+			functionRows := sqlmock.NewRows(header).AddRow([]driver.Value{"1", "public.type"}...)
+			mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(functionRows)
+			backup.ConstructFunctionDependencies(connection, []backup.Function{function1})
+
+			type3.Oid = 1
+			type3.Type = "d"
+			domainRows := sqlmock.NewRows(header).AddRow([]driver.Value{"1", "public.builtin"}...)
+			mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(domainRows)
+			types := backup.ConstructDomainDependencies(connection, []backup.Type{type3})
+
+			Expect(types[0].DependsUpon).To(Equal([]string{"public.builtin"}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an empty cache if the cache file does not exist", func() {
+			cache := backup.ReadDependencyCache(path.Join(cacheDir, "nonexistent.yaml"), "marker")
+			Expect(cache.CatalogMarker).To(Equal("marker"))
+			Expect(cache.Results).To(BeEmpty())
+		})
+	})
 	Describe("ConstructFunctionAndTypeAndTableMetadataMap", func() {
 		It("composes metadata maps for functions, types, and tables into one map", func() {
 			funcMap := backup.MetadataMap{1: backup.ObjectMetadata{Comment: "function"}}
@@ -292,4 +374,34 @@ var _ = Describe("backup/dependencies tests", func() {
 			Expect(views[2].FQN()).To(Equal("public.view3"))
 		})
 	})
+	Describe("SortRoleMembers", func() {
+		It("orders a chain of role memberships so each grantor is granted the role before granting it", func() {
+			grantToUser3 := backup.RoleMember{Role: "role1", Member: "user3", Grantor: "user2", IsAdmin: false}
+			grantToUser2 := backup.RoleMember{Role: "role1", Member: "user2", Grantor: "user1", IsAdmin: true}
+			grantToUser1 := backup.RoleMember{Role: "role1", Member: "user1", Grantor: "gpadmin", IsAdmin: true}
+			roleMembers := []backup.RoleMember{grantToUser3, grantToUser2, grantToUser1}
+
+			roleMembers = backup.SortRoleMembers(roleMembers)
+
+			Expect(roleMembers).To(Equal([]backup.RoleMember{grantToUser1, grantToUser2, grantToUser3}))
+		})
+		It("does not reorder memberships whose grantors do not depend on other memberships", func() {
+			grantRole1 := backup.RoleMember{Role: "role1", Member: "user1", Grantor: "gpadmin", IsAdmin: false}
+			grantRole2 := backup.RoleMember{Role: "role2", Member: "user1", Grantor: "gpadmin", IsAdmin: false}
+			roleMembers := []backup.RoleMember{grantRole1, grantRole2}
+
+			roleMembers = backup.SortRoleMembers(roleMembers)
+
+			Expect(roleMembers).To(Equal([]backup.RoleMember{grantRole1, grantRole2}))
+		})
+		It("keeps all memberships if there is a cycle of grantors", func() {
+			grantToUser1 := backup.RoleMember{Role: "role1", Member: "user1", Grantor: "user2", IsAdmin: true}
+			grantToUser2 := backup.RoleMember{Role: "role1", Member: "user2", Grantor: "user1", IsAdmin: true}
+			roleMembers := []backup.RoleMember{grantToUser1, grantToUser2}
+
+			roleMembers = backup.SortRoleMembers(roleMembers)
+
+			Expect(roleMembers).To(Equal([]backup.RoleMember{grantToUser1, grantToUser2}))
+		})
+	})
 })
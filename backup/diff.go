@@ -0,0 +1,191 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/greenplum-db/gpbackup/utils"
+)
+
+/*
+ * This file contains functions for comparing the metadata in a backup with the
+ * metadata that would be backed up from the live database now, e.g. to detect
+ * unintended schema changes since the backup was taken.
+ */
+
+/*
+ * A MetadataDiff holds the statements for one object that differ between a
+ * backup and the live database.  BackupStatement is empty for an object that
+ * has been added since the backup, and LiveStatement is empty for an object
+ * that has been dropped since the backup.
+ */
+type MetadataDiff struct {
+	Schema          string
+	Name            string
+	ObjectType      string
+	BackupStatement string
+	LiveStatement   string
+}
+
+/*
+ * This generates the predata and postdata metadata for the live database into
+ * memory, using the same functions as a backup, and returns the statement for
+ * each object.  The TOC, object counts, and report of the current backup are
+ * left untouched.
+ */
+func GenerateLiveMetadataStatements() []utils.StatementWithType {
+	savedTOC, savedObjectCounts, savedReport := globalTOC, objectCounts, backupReport
+	defer func() {
+		globalTOC, objectCounts, backupReport = savedTOC, savedObjectCounts, savedReport
+	}()
+	globalTOC = &utils.TOC{}
+	globalTOC.InitializeEntryMap("global", "predata", "postdata", "statistics")
+	objectCounts = make(map[string]int, 0)
+	backupReport = &utils.Report{}
+
+	metadataTables, _, tableDefs := RetrieveAndProcessTables()
+	predataBuffer := bytes.NewBuffer([]byte{})
+	predataFile := utils.NewFileWithByteCount(predataBuffer)
+	predataFile.Filename = "predata"
+	writePredata(predataFile, metadataTables, tableDefs, objectCounts)
+	predataFile.Close()
+	postdataBuffer := bytes.NewBuffer([]byte{})
+	postdataFile := utils.NewFileWithByteCount(postdataBuffer)
+	postdataFile.Filename = "postdata"
+	writePostdata(postdataFile, metadataTables, objectCounts)
+	postdataFile.Close()
+
+	statements := globalTOC.GetAllSQLStatements("predata", bytes.NewReader(predataBuffer.Bytes()))
+	return append(statements, globalTOC.GetAllSQLStatements("postdata", bytes.NewReader(postdataBuffer.Bytes()))...)
+}
+
+/*
+ * This reads the statement for each object in the predata and postdata files of
+ * the backup in the given cluster.
+ */
+func GetBackupMetadataStatements(cluster utils.Cluster) []utils.StatementWithType {
+	toc := utils.NewTOC(cluster.GetTOCFilePath())
+	toc.InitializeEntryMapFromCluster(cluster)
+	predataFilename := cluster.GetPredataFilePath()
+	predataFile := utils.MustOpenMetadataFileForReading(predataFilename)
+	defer predataFile.Close()
+	postdataFilename := cluster.GetPostdataFilePath()
+	postdataFile := utils.MustOpenMetadataFileForReading(postdataFilename)
+	defer postdataFile.Close()
+	statements := toc.GetAllSQLStatements(predataFilename, predataFile)
+	return append(statements, toc.GetAllSQLStatements(postdataFilename, postdataFile)...)
+}
+
+/*
+ * This returns the differences between the metadata of the backup in the given
+ * cluster and the metadata of the live database.
+ */
+func DiffBackupMetadataWithLiveDatabase(cluster utils.Cluster) []MetadataDiff {
+	return DiffMetadataStatements(GetBackupMetadataStatements(cluster), GenerateLiveMetadataStatements())
+}
+
+/*
+ * This groups the statements on each side by object, and returns a diff for
+ * each object whose statements differ, in the order in which the objects appear
+ * in the backup followed by objects that only appear in the live database.
+ * Leading and trailing whitespace is ignored when comparing statements.
+ */
+func DiffMetadataStatements(backupStatements []utils.StatementWithType, liveStatements []utils.StatementWithType) []MetadataDiff {
+	backupKeys, backupByKey := groupStatementsByObject(backupStatements)
+	liveKeys, liveByKey := groupStatementsByObject(liveStatements)
+
+	diffs := make([]MetadataDiff, 0)
+	for _, key := range backupKeys {
+		backup := backupByKey[key]
+		live := liveByKey[key]
+		if strings.TrimSpace(backup.Statement) != strings.TrimSpace(live.Statement) {
+			diffs = append(diffs, MetadataDiff{Schema: backup.Schema, Name: backup.Name, ObjectType: backup.ObjectType,
+				BackupStatement: backup.Statement, LiveStatement: live.Statement})
+		}
+	}
+	for _, key := range liveKeys {
+		if _, ok := backupByKey[key]; !ok {
+			live := liveByKey[key]
+			diffs = append(diffs, MetadataDiff{Schema: live.Schema, Name: live.Name, ObjectType: live.ObjectType, LiveStatement: live.Statement})
+		}
+	}
+	return diffs
+}
+
+/*
+ * Objects are keyed by type and name, and the statements of an object with more
+ * than one entry, such as the session GUCs at the top of each file, are joined
+ * so that they are compared together.
+ */
+func groupStatementsByObject(statements []utils.StatementWithType) ([]string, map[string]utils.StatementWithType) {
+	keys := make([]string, 0)
+	byKey := make(map[string]utils.StatementWithType, 0)
+	for _, statement := range statements {
+		key := fmt.Sprintf("%s %s", statement.ObjectType, utils.FQN(statement.Schema, statement.Name))
+		if grouped, ok := byKey[key]; ok {
+			grouped.Statement += statement.Statement
+			byKey[key] = grouped
+		} else {
+			keys = append(keys, key)
+			byKey[key] = statement
+		}
+	}
+	return keys, byKey
+}
+
+func (diff MetadataDiff) ObjectName() string {
+	return utils.FQN(diff.Schema, diff.Name)
+}
+
+/*
+ * This formats the diff as a unified diff of the statement lines, without hunk
+ * headers, as each object's statements are short enough to show in full.
+ */
+func (diff MetadataDiff) UnifiedDiff() string {
+	backupLines := statementLines(diff.BackupStatement)
+	liveLines := statementLines(diff.LiveStatement)
+
+	// lcs[i][j] is the length of the longest common subsequence of backupLines[i:] and liveLines[j:]
+	lcs := make([][]int, len(backupLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(liveLines)+1)
+	}
+	for i := len(backupLines) - 1; i >= 0; i-- {
+		for j := len(liveLines) - 1; j >= 0; j-- {
+			if backupLines[i] == liveLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	objectStr := fmt.Sprintf("%s %s", diff.ObjectType, diff.ObjectName())
+	lines := []string{fmt.Sprintf("--- backup %s", objectStr), fmt.Sprintf("+++ live %s", objectStr)}
+	i, j := 0, 0
+	for i < len(backupLines) || j < len(liveLines) {
+		if i < len(backupLines) && j < len(liveLines) && backupLines[i] == liveLines[j] {
+			lines = append(lines, " "+backupLines[i])
+			i++
+			j++
+		} else if j == len(liveLines) || (i < len(backupLines) && lcs[i+1][j] >= lcs[i][j+1]) {
+			lines = append(lines, "-"+backupLines[i])
+			i++
+		} else {
+			lines = append(lines, "+"+liveLines[j])
+			j++
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func statementLines(statement string) []string {
+	statement = strings.TrimSpace(statement)
+	if statement == "" {
+		return []string{}
+	}
+	return strings.Split(statement, "\n")
+}
@@ -0,0 +1,97 @@
+package backup_test
+
+import (
+	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backup/diff tests", func() {
+	sessionGUCs := utils.StatementWithType{ObjectType: "SESSION GUCS", Statement: "SET client_encoding = 'UTF8';\n"}
+	schema := utils.StatementWithType{Name: "public", ObjectType: "SCHEMA", Statement: "\n\nCREATE SCHEMA public;\n"}
+	table := utils.StatementWithType{Schema: "public", Name: "foo", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.foo (\n\ti integer\n) DISTRIBUTED BY (i);\n"}
+	tableWithColumn := utils.StatementWithType{Schema: "public", Name: "foo", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.foo (\n\ti integer,\n\tj text\n) DISTRIBUTED BY (i);\n"}
+	enumType := utils.StatementWithType{Schema: "public", Name: "mood", ObjectType: "TYPE", Statement: "\n\nCREATE TYPE public.mood AS ENUM (\n\t'happy'\n);\n"}
+
+	Describe("DiffMetadataStatements", func() {
+		It("returns no diffs for identical metadata", func() {
+			statements := []utils.StatementWithType{sessionGUCs, schema, table}
+
+			diffs := backup.DiffMetadataStatements(statements, statements)
+
+			Expect(diffs).To(BeEmpty())
+		})
+		It("returns a diff for a table with an added column", func() {
+			diffs := backup.DiffMetadataStatements([]utils.StatementWithType{sessionGUCs, schema, table}, []utils.StatementWithType{sessionGUCs, schema, tableWithColumn})
+
+			Expect(diffs).To(Equal([]backup.MetadataDiff{
+				{Schema: "public", Name: "foo", ObjectType: "TABLE", BackupStatement: table.Statement, LiveStatement: tableWithColumn.Statement},
+			}))
+		})
+		It("returns a diff for an added type after the diffs for objects in the backup", func() {
+			diffs := backup.DiffMetadataStatements([]utils.StatementWithType{schema, table}, []utils.StatementWithType{schema, enumType, tableWithColumn})
+
+			Expect(diffs).To(Equal([]backup.MetadataDiff{
+				{Schema: "public", Name: "foo", ObjectType: "TABLE", BackupStatement: table.Statement, LiveStatement: tableWithColumn.Statement},
+				{Schema: "public", Name: "mood", ObjectType: "TYPE", LiveStatement: enumType.Statement},
+			}))
+		})
+		It("returns a diff for a dropped object", func() {
+			diffs := backup.DiffMetadataStatements([]utils.StatementWithType{schema, enumType}, []utils.StatementWithType{schema})
+
+			Expect(diffs).To(Equal([]backup.MetadataDiff{
+				{Schema: "public", Name: "mood", ObjectType: "TYPE", BackupStatement: enumType.Statement},
+			}))
+		})
+		It("compares all the statements of an object with more than one entry together", func() {
+			postdataGUCs := utils.StatementWithType{ObjectType: "SESSION GUCS", Statement: "SET client_encoding = 'SQL_ASCII';\n"}
+
+			diffs := backup.DiffMetadataStatements([]utils.StatementWithType{sessionGUCs, sessionGUCs}, []utils.StatementWithType{sessionGUCs, postdataGUCs})
+
+			Expect(diffs).To(HaveLen(1))
+			Expect(diffs[0].BackupStatement).To(Equal(sessionGUCs.Statement + sessionGUCs.Statement))
+			Expect(diffs[0].LiveStatement).To(Equal(sessionGUCs.Statement + postdataGUCs.Statement))
+		})
+	})
+	Describe("UnifiedDiff", func() {
+		It("shows an added column as a changed line and an added line", func() {
+			diff := backup.MetadataDiff{Schema: "public", Name: "foo", ObjectType: "TABLE", BackupStatement: table.Statement, LiveStatement: tableWithColumn.Statement}
+
+			Expect(diff.UnifiedDiff()).To(Equal(`--- backup TABLE public.foo
++++ live TABLE public.foo
+ CREATE TABLE public.foo (
+-	i integer
++	i integer,
++	j text
+ ) DISTRIBUTED BY (i);
+`))
+		})
+		It("shows every line of an added type as added", func() {
+			diff := backup.MetadataDiff{Schema: "public", Name: "mood", ObjectType: "TYPE", LiveStatement: enumType.Statement}
+
+			Expect(diff.UnifiedDiff()).To(Equal(`--- backup TYPE public.mood
++++ live TYPE public.mood
++CREATE TYPE public.mood AS ENUM (
++	'happy'
++);
+`))
+		})
+		It("names an object without a schema by its name alone", func() {
+			diff := backup.MetadataDiff{Name: "public", ObjectType: "SCHEMA", BackupStatement: schema.Statement}
+
+			Expect(diff.UnifiedDiff()).To(Equal(`--- backup SCHEMA public
++++ live SCHEMA public
+-CREATE SCHEMA public;
+`))
+		})
+		It("quotes the name of an object that needs quoting", func() {
+			diff := backup.MetadataDiff{Schema: "Some Schema", Name: "foo", ObjectType: "TABLE", BackupStatement: "\n\nCREATE TABLE \"Some Schema\".foo (i integer);\n"}
+
+			Expect(diff.UnifiedDiff()).To(HavePrefix(`--- backup TABLE "Some Schema".foo
++++ live TABLE "Some Schema".foo
+`))
+		})
+	})
+})
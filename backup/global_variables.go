@@ -11,43 +11,89 @@ import "github.com/greenplum-db/gpbackup/utils"
  * Non-flag variables
  */
 var (
-	backupReport  *utils.Report
-	connection    *utils.DBConn
-	globalCluster utils.Cluster
-	globalTOC     *utils.TOC
-	logger        *utils.Logger
-	objectCounts  map[string]int
-	version       string
+	backupReport    *utils.Report
+	buildCommit     string
+	buildDate       string
+	connection      *utils.DBConn
+	dependencyCache *DependencyCache
+	globalCluster   utils.Cluster
+	globalTOC       *utils.TOC
+	logger          *utils.Logger
+	lowSpaceWarned  bool
+	objectCounts    map[string]int
+	restorePointLSN string
+	version         string
 )
 
 /*
  * Command-line flags
  */
 var (
-	backupDir         *string
-	backupGlobals     *bool
-	dataOnly          *bool
-	dbname            *string
-	debug             *bool
-	excludeSchemas    utils.ArrayFlags
-	excludeTableFile  *string
-	excludeTables     utils.ArrayFlags
-	includeSchemas    utils.ArrayFlags
-	includeTableFile  *string
-	includeTables     utils.ArrayFlags
-	leafPartitionData *bool
-	metadataOnly      *bool
-	noCompression     *bool
-	printVersion      *bool
-	quiet             *bool
-	verbose           *bool
-	withStats         *bool
+	backupDir                    *string
+	backupGlobals                *bool
+	backupTimestamp              *string
+	bestEffort                   *bool
+	checkPrivileges              *bool
+	componentLogLevels           utils.ArrayFlags
+	compressMetadata             *bool
+	dataOnly                     *bool
+	dbname                       *string
+	debug                        *bool
+	dependencyCacheFile          *string
+	disableTriggersOnRestore     *bool
+	emailAttachReport            *bool
+	emailContactsFile            *string
+	emailOnlyOnFailure           *bool
+	emailSMTPServer              *string
+	emailSMTPStartTLS            *bool
+	emailSMTPUser                *string
+	emailSubject                 *string
+	emailTransport               *string
+	excludeDefaultResourceGroups *bool
+	excludeSchemas               utils.ArrayFlags
+	excludeTableFile             *string
+	excludeTables                utils.ArrayFlags
+	freeSpaceThreshold           *int
+	includeArrayTypes            *bool
+	includeSchemas               utils.ArrayFlags
+	includeTableFile             *string
+	includeTables                utils.ArrayFlags
+	keepAliveInterval            *int
+	leafPartitionData            *bool
+	linkCurrentLog               *bool
+	logFormat                    *string
+	logPrefixSeparator           *string
+	metadataBufferSize           *int
+	metadataOnly                 *bool
+	noColor                      *bool
+	noComments                   *bool
+	noCompression                *bool
+	objectCountLabelsFile        *string
+	printVersion                 *bool
+	quiet                        *bool
+	restorePoint                 *string
+	revokeRoleMemberships        *bool
+	schemaObjectCounts           *bool
+	singleTransactionMetadata    *bool
+	sortObjectCountsByCount      *bool
+	strictTypeChecks             *bool
+	stripDDLClauses              utils.ArrayFlags
+	syslogOnly                   *bool
+	syslogServer                 *string
+	updateLatest                 *bool
+	useSyslog                    *bool
+	verbose                      *bool
+	withStats                    *bool
 )
 
 /*
  * Setter functions
  */
 
+func SetBestEffort(which bool) {
+	bestEffort = &which
+}
+
 func SetConnection(conn *utils.DBConn) {
 	connection = conn
 }
@@ -56,10 +102,31 @@ func SetCluster(cluster utils.Cluster) {
 	globalCluster = cluster
 }
 
+func SetDependencyCache(cache *DependencyCache) {
+	dependencyCache = cache
+}
+
+func SetDisableTriggersOnRestore(which bool) {
+	disableTriggersOnRestore = &which
+}
+
+func SetExcludeDefaultResourceGroups(which bool) {
+	excludeDefaultResourceGroups = &which
+}
+
 func SetExcludeSchemas(schemas []string) {
 	excludeSchemas = schemas
 }
 
+func SetFreeSpaceThreshold(megabytes int) {
+	freeSpaceThreshold = &megabytes
+	lowSpaceWarned = false
+}
+
+func SetIncludeArrayTypes(which bool) {
+	includeArrayTypes = &which
+}
+
 func SetIncludeSchemas(schemas []string) {
 	includeSchemas = schemas
 }
@@ -76,14 +143,46 @@ func SetLeafPartitionData(which bool) {
 	leafPartitionData = &which
 }
 
+func SetNoComments(which bool) {
+	noComments = &which
+}
+
 func SetLogger(log *utils.Logger) {
 	logger = log
 }
 
+func SetReport(report *utils.Report) {
+	backupReport = report
+}
+
+func SetRestorePoint(name string) {
+	restorePoint = &name
+}
+
+func SetRevokeRoleMemberships(which bool) {
+	revokeRoleMemberships = &which
+}
+
+func SetSingleTransactionMetadata(which bool) {
+	singleTransactionMetadata = &which
+}
+
+func SetStrictTypeChecks(which bool) {
+	strictTypeChecks = &which
+}
+
+func SetStripDDLClauses(clauses utils.ArrayFlags) {
+	stripDDLClauses = clauses
+}
+
 func SetTOC(toc *utils.TOC) {
 	globalTOC = toc
 }
 
+func SetUpdateLatest(which bool) {
+	updateLatest = &which
+}
+
 func SetVersion(v string) {
 	version = v
 }
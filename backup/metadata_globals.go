@@ -52,39 +52,163 @@ func print4OnlySessionGUCs(metadataFile *utils.FileWithByteCount, toc *utils.TOC
 	toc.AddMetadataEntry("", "", "GPDB4 SESSION GUCS", start, metadataFile)
 }
 
+/*
+ * These wrap metadata statements in a transaction, so that if one of them fails
+ * on restore all of them are rolled back.  BEGIN and COMMIT get TOC entries of
+ * their own, so that restore executes them in order like any other statement.
+ */
+func PrintBeginTransaction(metadataFile *utils.FileWithByteCount, toc *utils.TOC) {
+	start := metadataFile.ByteCount
+	metadataFile.MustPrintf("\n\nBEGIN;")
+	toc.AddMetadataEntry("", "", "BEGIN TRANSACTION", start, metadataFile)
+}
+
+func PrintCommitTransaction(metadataFile *utils.FileWithByteCount, toc *utils.TOC) {
+	start := metadataFile.ByteCount
+	metadataFile.MustPrintf("\n\nCOMMIT;")
+	toc.AddMetadataEntry("", "", "COMMIT TRANSACTION", start, metadataFile)
+}
+
+/*
+ * A database in the source cluster's default tablespace gets no TABLESPACE
+ * clause, so that it is created in the default tablespace of the target
+ * cluster whatever that tablespace is named.
+ */
 func PrintCreateDatabaseStatement(globalFile *utils.FileWithByteCount, toc *utils.TOC, db Database, dbMetadata MetadataMap) {
 	dbname := db.Name
 	start := globalFile.ByteCount
 	globalFile.MustPrintf("\n\nCREATE DATABASE %s", dbname)
-	if db.Tablespace != "pg_default" {
+	defaultTablespace := db.DefaultTablespace
+	if defaultTablespace == "" {
+		defaultTablespace = "pg_default"
+	}
+	if db.Tablespace != defaultTablespace {
 		globalFile.MustPrintf(" TABLESPACE %s", db.Tablespace)
 	}
+	globalFile.MustPrintf("%s", databaseLocaleClause(db))
 	globalFile.MustPrintf(";")
-	toc.AddMetadataEntry("", dbname, "DATABASE", start, globalFile)
+	toc.AddMetadataEntry("", utils.FQN("", dbname), "DATABASE", start, globalFile)
 	start = globalFile.ByteCount
 	PrintObjectMetadata(globalFile, dbMetadata[db.Oid], dbname, "DATABASE")
 	if globalFile.ByteCount > start {
-		toc.AddMetadataEntry("", dbname, "DATABASE METADATA", start, globalFile)
+		toc.AddMetadataEntry("", utils.FQN("", dbname), "DATABASE METADATA", start, globalFile)
+	}
+}
+
+/*
+ * A database whose collation differs from that of template1 can only be
+ * created from template0, so we use it whenever locale settings are present.
+ */
+func databaseLocaleClause(db Database) string {
+	if db.Collate == "" && db.CType == "" {
+		return ""
+	}
+	clause := " TEMPLATE template0"
+	if db.Collate != "" {
+		clause += fmt.Sprintf(" LC_COLLATE %s", quoteLiteral(db.Collate))
+	}
+	if db.CType != "" {
+		clause += fmt.Sprintf(" LC_CTYPE %s", quoteLiteral(db.CType))
 	}
+	return clause
 }
 
-func PrintDatabaseGUCs(globalFile *utils.FileWithByteCount, toc *utils.TOC, gucs []string, dbname string) {
+func PrintDatabaseGUCs(globalFile *utils.FileWithByteCount, toc *utils.TOC, gucs []GUC, dbname string) {
 	for _, guc := range gucs {
 		start := globalFile.ByteCount
-		globalFile.MustPrintf("\nALTER DATABASE %s %s;", dbname, guc)
-		toc.AddMetadataEntry("", dbname, "DATABASE GUC", start, globalFile)
+		globalFile.MustPrintf("\nALTER DATABASE %s %s;", dbname, guc.SetClause())
+		toc.AddMetadataEntry("", utils.FQN("", dbname), "DATABASE GUC", start, globalFile)
 	}
 }
 
+/*
+ * These settings take a list of names, each of which is stored quoted as an
+ * identifier if necessary, e.g. search_path = "$user", public.  Each element
+ * is printed as a separate string literal, which the server quotes again as an
+ * identifier if necessary when the setting is applied.  All other settings are
+ * printed as a single string literal.
+ */
+var listGUCs = map[string]bool{
+	"local_preload_libraries":   true,
+	"search_path":               true,
+	"session_preload_libraries": true,
+	"shared_preload_libraries":  true,
+	"temp_tablespaces":          true,
+}
+
+/*
+ * String literals are printed with only single quotes escaped, because
+ * standard_conforming_strings is turned on in the session GUCs of every
+ * metadata file.
+ */
+func quoteLiteral(value string) string {
+	return fmt.Sprintf("'%s'", strings.Replace(value, "'", "''", -1))
+}
+
+func (guc GUC) SetClause() string {
+	if !listGUCs[guc.Name] || guc.Value == "" {
+		return fmt.Sprintf("SET %s TO %s", guc.Name, quoteLiteral(guc.Value))
+	}
+	elements := splitGUCList(guc.Value)
+	for i, element := range elements {
+		elements[i] = quoteLiteral(element)
+	}
+	return fmt.Sprintf("SET %s TO %s", guc.Name, strings.Join(elements, ", "))
+}
+
+/*
+ * This splits the value of a list setting on commas outside of double quotes,
+ * removing whitespace around each element and the double quotes around any
+ * quoted element, in which a doubled double quote stands for a double quote.
+ */
+func splitGUCList(value string) []string {
+	elements := make([]string, 0)
+	element := ""
+	inQuotes := false
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+		switch {
+		case char == '"' && inQuotes && i+1 < len(value) && value[i+1] == '"':
+			element += `"`
+			i++
+		case char == '"':
+			inQuotes = !inQuotes
+		case char == ',' && !inQuotes:
+			elements = append(elements, element)
+			element = ""
+		case (char == ' ' || char == '\t' || char == '\n') && !inQuotes:
+		default:
+			element += string(char)
+		}
+	}
+	return append(elements, element)
+}
+
+/*
+ * The catalog stores an ACTIVE_STATEMENTS of -1 for a queue with no limit on
+ * active statements, and there is no separate "unset" value.  An omitted
+ * ACTIVE_STATEMENTS means no limit for CREATE, but leaves the existing limit in
+ * place for ALTER, so pg_default always gets the value explicitly.  Any other
+ * value, including 0, is printed as-is.
+ */
+const unlimitedActiveStatements = -1
+
 func PrintCreateResourceQueueStatements(globalFile *utils.FileWithByteCount, toc *utils.TOC, resQueues []ResourceQueue, resQueueMetadata MetadataMap) {
 	for _, resQueue := range resQueues {
 		start := globalFile.ByteCount
+		action := "CREATE"
+		if resQueue.Name == "pg_default" {
+			action = "ALTER"
+		}
 		attributes := []string{}
-		if resQueue.ActiveStatements != -1 {
+		if resQueue.ActiveStatements != unlimitedActiveStatements || action == "ALTER" {
 			attributes = append(attributes, fmt.Sprintf("ACTIVE_STATEMENTS=%d", resQueue.ActiveStatements))
 		}
 		maxCostFloat, maxCostErr := strconv.ParseFloat(resQueue.MaxCost, 64)
-		utils.CheckError(maxCostErr)
+		if maxCostErr != nil {
+			HandleObjectError("RESOURCE QUEUE", resQueue.Name, maxCostErr)
+			continue
+		}
 		if maxCostFloat > -1 {
 			attributes = append(attributes, fmt.Sprintf("MAX_COST=%s", resQueue.MaxCost))
 		}
@@ -92,7 +216,10 @@ func PrintCreateResourceQueueStatements(globalFile *utils.FileWithByteCount, toc
 			attributes = append(attributes, "COST_OVERCOMMIT=TRUE")
 		}
 		minCostFloat, minCostErr := strconv.ParseFloat(resQueue.MinCost, 64)
-		utils.CheckError(minCostErr)
+		if minCostErr != nil {
+			HandleObjectError("RESOURCE QUEUE", resQueue.Name, minCostErr)
+			continue
+		}
 		if minCostFloat > 0 {
 			attributes = append(attributes, fmt.Sprintf("MIN_COST=%s", resQueue.MinCost))
 		}
@@ -102,13 +229,9 @@ func PrintCreateResourceQueueStatements(globalFile *utils.FileWithByteCount, toc
 		if resQueue.MemoryLimit != "-1" {
 			attributes = append(attributes, fmt.Sprintf("MEMORY_LIMIT='%s'", resQueue.MemoryLimit))
 		}
-		action := "CREATE"
-		if resQueue.Name == "pg_default" {
-			action = "ALTER"
-		}
 		globalFile.MustPrintf("\n\n%s RESOURCE QUEUE %s WITH (%s);", action, resQueue.Name, strings.Join(attributes, ", "))
 		PrintObjectMetadata(globalFile, resQueueMetadata[resQueue.Oid], resQueue.Name, "RESOURCE QUEUE")
-		toc.AddMetadataEntry("", resQueue.Name, "RESOURCE QUEUE", start, globalFile)
+		toc.AddMetadataEntry("", utils.FQN("", resQueue.Name), "RESOURCE QUEUE", start, globalFile)
 	}
 }
 
@@ -122,6 +245,9 @@ func PrintCreateResourceGroupStatements(globalFile *utils.FileWithByteCount, toc
 		start := uint64(0)
 
 		if resGroup.Name == "default_group" || resGroup.Name == "admin_group" {
+			if *excludeDefaultResourceGroups {
+				continue
+			}
 			resGroupList := []resGroupStruct{
 				{"CPU_RATE_LIMIT", resGroup.CPURateLimit},
 				{"MEMORY_LIMIT", resGroup.MemoryLimit},
@@ -133,7 +259,7 @@ func PrintCreateResourceGroupStatements(globalFile *utils.FileWithByteCount, toc
 				start = globalFile.ByteCount
 				globalFile.MustPrintf("\n\nALTER RESOURCE GROUP %s SET %s %d;", resGroup.Name, property.setting, property.value)
 				PrintObjectMetadata(globalFile, resGroupMetadata[resGroup.Oid], resGroup.Name, "RESOURCE GROUP")
-				toc.AddMetadataEntry("", resGroup.Name, "RESOURCE GROUP", start, globalFile)
+				toc.AddMetadataEntry("", utils.FQN("", resGroup.Name), "RESOURCE GROUP", start, globalFile)
 			}
 		} else {
 			start = globalFile.ByteCount
@@ -145,7 +271,7 @@ func PrintCreateResourceGroupStatements(globalFile *utils.FileWithByteCount, toc
 			attributes = append(attributes, fmt.Sprintf("CONCURRENCY=%d", resGroup.Concurrency))
 			globalFile.MustPrintf("\n\nCREATE RESOURCE GROUP %s WITH (%s);", resGroup.Name, strings.Join(attributes, ", "))
 			PrintObjectMetadata(globalFile, resGroupMetadata[resGroup.Oid], resGroup.Name, "RESOURCE GROUP")
-			toc.AddMetadataEntry("", resGroup.Name, "RESOURCE GROUP", start, globalFile)
+			toc.AddMetadataEntry("", utils.FQN("", resGroup.Name), "RESOURCE GROUP", start, globalFile)
 		}
 	}
 }
@@ -184,6 +310,15 @@ func PrintCreateRoleStatements(globalFile *utils.FileWithByteCount, toc *utils.T
 		} else {
 			attrs = append(attrs, "NOLOGIN")
 		}
+
+		if connection.Version.AtLeast("6") {
+			if role.Replication {
+				attrs = append(attrs, "REPLICATION")
+			} else {
+				attrs = append(attrs, "NOREPLICATION")
+			}
+		}
+
 		if role.ConnectionLimit != -1 {
 			attrs = append(attrs, fmt.Sprintf("CONNECTION LIMIT %d", role.ConnectionLimit))
 		}
@@ -229,32 +364,75 @@ ALTER ROLE %s WITH %s;`, role.Name, role.Name, strings.Join(attrs, " "))
 
 		if len(role.TimeConstraints) != 0 {
 			for _, timeConstraint := range role.TimeConstraints {
-				globalFile.MustPrintf("\nALTER ROLE %s DENY BETWEEN DAY %d TIME '%s' AND DAY %d TIME '%s';", role.Name, timeConstraint.StartDay, timeConstraint.StartTime, timeConstraint.EndDay, timeConstraint.EndTime)
+				for _, interval := range timeConstraint.NormalizedIntervals() {
+					globalFile.MustPrintf("\nALTER ROLE %s DENY BETWEEN DAY %d TIME '%s' AND DAY %d TIME '%s';", role.Name, interval.StartDay, interval.StartTime, interval.EndDay, interval.EndTime)
+				}
 			}
 		}
+		for _, config := range role.Configs {
+			globalFile.MustPrintf("\nALTER ROLE %s %s;", role.Name, config.SetClause())
+		}
 		PrintObjectMetadata(globalFile, roleMetadata[role.Oid], role.Name, "ROLE")
-		toc.AddMetadataEntry("", role.Name, "ROLE", start, globalFile)
+		toc.AddMetadataEntry("", utils.FQN("", role.Name), "ROLE", start, globalFile)
 	}
 }
 
+/*
+ * A time constraint whose start falls after its end in the week, such as one
+ * from Saturday night into Sunday morning, wraps around the end of the week.
+ * It is split at midnight between Saturday and Sunday into two constraints
+ * that each run forward in the week, which together deny the same times.
+ */
+func (constraint TimeConstraint) NormalizedIntervals() []TimeConstraint {
+	wrapsAround := constraint.StartDay > constraint.EndDay ||
+		(constraint.StartDay == constraint.EndDay && constraint.StartTime > constraint.EndTime)
+	if !wrapsAround {
+		return []TimeConstraint{constraint}
+	}
+	untilEndOfWeek := constraint
+	untilEndOfWeek.EndDay = 6
+	untilEndOfWeek.EndTime = "24:00:00"
+	fromStartOfWeek := constraint
+	fromStartOfWeek.StartDay = 0
+	fromStartOfWeek.StartTime = "00:00:00"
+	return []TimeConstraint{untilEndOfWeek, fromStartOfWeek}
+}
+
 func PrintRoleMembershipStatements(globalFile *utils.FileWithByteCount, toc *utils.TOC, roleMembers []RoleMember) {
 	globalFile.MustPrintln("\n")
 	for _, roleMember := range roleMembers {
 		start := globalFile.ByteCount
+		if *revokeRoleMemberships {
+			globalFile.MustPrintf("\nREVOKE %s FROM %s;", roleMember.Role, roleMember.Member)
+		}
 		globalFile.MustPrintf("\nGRANT %s TO %s", roleMember.Role, roleMember.Member)
 		if roleMember.IsAdmin {
 			globalFile.MustPrintf(" WITH ADMIN OPTION")
 		}
 		globalFile.MustPrintf(" GRANTED BY %s;", roleMember.Grantor)
-		toc.AddMetadataEntry("", roleMember.Member, "ROLE GRANT", start, globalFile)
+		toc.AddMetadataEntry("", utils.FQN("", roleMember.Member), "ROLE GRANT", start, globalFile)
 	}
 }
 
-func PrintCreateTablespaceStatements(globalFile *utils.FileWithByteCount, toc *utils.TOC, tablespaces []Tablespace, tablespaceMetadata MetadataMap) {
+func PrintCreateTablespaceStatements(globalFile *utils.FileWithByteCount, toc *utils.TOC, tablespaces []Tablespace) {
 	for _, tablespace := range tablespaces {
 		start := globalFile.ByteCount
 		globalFile.MustPrintf("\n\nCREATE TABLESPACE %s FILESPACE %s;", tablespace.Tablespace, tablespace.Filespace)
+		toc.AddMetadataEntry("", utils.FQN("", tablespace.Tablespace), "TABLESPACE", start, globalFile)
+	}
+}
+
+/*
+ * Tablespaces are created before roles, so that a database can be created in
+ * one, but their owners and privileges may refer to any role.  Their metadata
+ * is therefore printed in separate TOC entries after roles are created.
+ */
+func PrintTablespaceMetadata(globalFile *utils.FileWithByteCount, toc *utils.TOC, tablespaces []Tablespace, tablespaceMetadata MetadataMap) {
+	for _, tablespace := range tablespaces {
+		start := globalFile.ByteCount
 		PrintObjectMetadata(globalFile, tablespaceMetadata[tablespace.Oid], tablespace.Tablespace, "TABLESPACE")
-		toc.AddMetadataEntry("", tablespace.Tablespace, "TABLESPACE", start, globalFile)
+		if globalFile.ByteCount > start {
+			toc.AddMetadataEntry("", utils.FQN("", tablespace.Tablespace), "TABLESPACE METADATA", start, globalFile)
+		}
 	}
 }
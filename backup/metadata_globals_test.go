@@ -3,8 +3,10 @@ package backup_test
 import (
 	"github.com/greenplum-db/gpbackup/backup"
 	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/greenplum-db/gpbackup/utils"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("backup/metadata_globals tests", func() {
@@ -38,6 +40,26 @@ SET standard_conforming_strings = on;
 SET default_with_oids = false;`)
 		})
 	})
+	Describe("PrintBeginTransaction and PrintCommitTransaction", func() {
+		It("prints BEGIN and COMMIT in their own TOC entries", func() {
+			backup.PrintBeginTransaction(backupfile, toc)
+			backup.PrintCommitTransaction(backupfile, toc)
+			testutils.ExpectEntry(toc.GlobalEntries, 0, "", "", "BEGIN TRANSACTION")
+			testutils.ExpectEntry(toc.GlobalEntries, 1, "", "", "COMMIT TRANSACTION")
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `BEGIN;`, `COMMIT;`)
+		})
+		It("leaves a CREATE DATABASE statement printed before BEGIN outside the transaction", func() {
+			db := backup.Database{Oid: 1, Name: "testdb", Tablespace: "pg_default"}
+			backup.PrintCreateDatabaseStatement(backupfile, toc, db, backup.MetadataMap{})
+			backup.PrintBeginTransaction(backupfile, toc)
+			backup.PrintCreateRoleStatements(backupfile, toc, []backup.Role{{Oid: 1, Name: "testrole", Inherit: true, ConnectionLimit: -1}}, backup.MetadataMap{})
+			backup.PrintCommitTransaction(backupfile, toc)
+			testutils.ExpectEntry(toc.GlobalEntries, 0, "", "testdb", "DATABASE")
+			testutils.ExpectEntry(toc.GlobalEntries, 1, "", "", "BEGIN TRANSACTION")
+			testutils.ExpectEntry(toc.GlobalEntries, 2, "", "testrole", "ROLE")
+			testutils.ExpectEntry(toc.GlobalEntries, 3, "", "", "COMMIT TRANSACTION")
+		})
+	})
 	Describe("PrintCreateDatabaseStatement", func() {
 		It("prints a basic CREATE DATABASE statement", func() {
 			db := backup.Database{Oid: 1, Name: "testdb", Tablespace: "pg_default"}
@@ -77,29 +99,98 @@ GRANT TEMPORARY,CONNECT ON DATABASE testdb TO testrole;`)
 			backup.PrintCreateDatabaseStatement(backupfile, toc, db, emptyMetadataMap)
 			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE DATABASE testdb TABLESPACE test_tablespace;`)
 		})
+		It("prints a CREATE DATABASE statement without a TABLESPACE for a renamed default tablespace", func() {
+			db := backup.Database{Oid: 1, Name: "testdb", Tablespace: "main_space", DefaultTablespace: "main_space"}
+			emptyMetadataMap := backup.MetadataMap{}
+			backup.PrintCreateDatabaseStatement(backupfile, toc, db, emptyMetadataMap)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE DATABASE testdb;`)
+		})
+		It("prints a CREATE DATABASE statement with a TABLESPACE named pg_default if the default tablespace was renamed", func() {
+			db := backup.Database{Oid: 1, Name: "testdb", Tablespace: "pg_default", DefaultTablespace: "main_space"}
+			emptyMetadataMap := backup.MetadataMap{}
+			backup.PrintCreateDatabaseStatement(backupfile, toc, db, emptyMetadataMap)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE DATABASE testdb TABLESPACE pg_default;`)
+		})
+		It("prints a CREATE DATABASE statement with collation settings", func() {
+			db := backup.Database{Oid: 1, Name: "testdb", Tablespace: "pg_default", Collate: "en_US.utf8", CType: "C"}
+			emptyMetadataMap := backup.MetadataMap{}
+			backup.PrintCreateDatabaseStatement(backupfile, toc, db, emptyMetadataMap)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE DATABASE testdb TEMPLATE template0 LC_COLLATE 'en_US.utf8' LC_CTYPE 'C';`)
+		})
+		It("escapes single quotes in collation settings", func() {
+			db := backup.Database{Oid: 1, Name: "testdb", Tablespace: "pg_default", Collate: "it's_locale", CType: "it's_locale"}
+			emptyMetadataMap := backup.MetadataMap{}
+			backup.PrintCreateDatabaseStatement(backupfile, toc, db, emptyMetadataMap)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE DATABASE testdb TEMPLATE template0 LC_COLLATE 'it''s_locale' LC_CTYPE 'it''s_locale';`)
+		})
 	})
 	Describe("PrintDatabaseGUCs", func() {
 		dbname := "testdb"
-		defaultOidGUC := "SET default_with_oids TO 'true'"
-		searchPathGUC := "SET search_path TO 'pg_catalog, public'"
-		defaultStorageGUC := "SET gp_default_storage_options TO 'appendonly=true,blocksize=32768'"
+		defaultOidGUC := backup.GUC{Name: "default_with_oids", Value: "true"}
+		searchPathGUC := backup.GUC{Name: "search_path", Value: "pg_catalog, public"}
+		defaultStorageGUC := backup.GUC{Name: "gp_default_storage_options", Value: "appendonly=true,blocksize=32768"}
 
 		It("prints single database GUC", func() {
-			gucs := []string{defaultOidGUC}
+			gucs := []backup.GUC{defaultOidGUC}
 
 			backup.PrintDatabaseGUCs(backupfile, toc, gucs, dbname)
 			testutils.ExpectEntry(toc.GlobalEntries, 0, "", "testdb", "DATABASE GUC")
 			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `ALTER DATABASE testdb SET default_with_oids TO 'true';`)
 		})
+		It("prints a database GUC setting the default tablespace", func() {
+			gucs := []backup.GUC{{Name: "default_tablespace", Value: "test_tablespace"}}
+
+			backup.PrintDatabaseGUCs(backupfile, toc, gucs, dbname)
+			testutils.ExpectEntry(toc.GlobalEntries, 0, "", "testdb", "DATABASE GUC")
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `ALTER DATABASE testdb SET default_tablespace TO 'test_tablespace';`)
+		})
+		It("prints a database GUC set to an empty string", func() {
+			gucs := []backup.GUC{{Name: "default_tablespace", Value: ""}}
+
+			backup.PrintDatabaseGUCs(backupfile, toc, gucs, dbname)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `ALTER DATABASE testdb SET default_tablespace TO '';`)
+		})
 		It("prints multiple database GUCs", func() {
-			gucs := []string{defaultOidGUC, searchPathGUC, defaultStorageGUC}
+			gucs := []backup.GUC{defaultOidGUC, searchPathGUC, defaultStorageGUC}
 
 			backup.PrintDatabaseGUCs(backupfile, toc, gucs, dbname)
 			testutils.AssertBufferContents(toc.GlobalEntries, buffer,
 				`ALTER DATABASE testdb SET default_with_oids TO 'true';`,
-				`ALTER DATABASE testdb SET search_path TO 'pg_catalog, public';`,
+				`ALTER DATABASE testdb SET search_path TO 'pg_catalog', 'public';`,
 				`ALTER DATABASE testdb SET gp_default_storage_options TO 'appendonly=true,blocksize=32768';`)
 		})
+		It("quotes a database GUC value containing spaces and special characters", func() {
+			gucs := []backup.GUC{
+				{Name: "DateStyle", Value: "ISO, MDY"},
+				{Name: "application_name", Value: "it's a test; DROP TABLE foo"},
+			}
+
+			backup.PrintDatabaseGUCs(backupfile, toc, gucs, dbname)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer,
+				`ALTER DATABASE testdb SET DateStyle TO 'ISO, MDY';`,
+				`ALTER DATABASE testdb SET application_name TO 'it''s a test; DROP TABLE foo';`)
+		})
+		It("quotes each element of a list GUC containing quoted identifiers", func() {
+			gucs := []backup.GUC{{Name: "search_path", Value: `"$user", "My Schema",public, "quote""d", "comma,schema"`}}
+
+			backup.PrintDatabaseGUCs(backupfile, toc, gucs, dbname)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `ALTER DATABASE testdb SET search_path TO '$user', 'My Schema', 'public', 'quote"d', 'comma,schema';`)
+		})
+	})
+	Describe("GetDatabaseSearchPath", func() {
+		It("returns the database default search_path", func() {
+			gucs := []backup.GUC{{Name: "default_with_oids", Value: "true"}, {Name: "search_path", Value: `"My Schema", public`}}
+
+			searchPath, ok := backup.GetDatabaseSearchPath(gucs)
+			Expect(ok).To(BeTrue())
+			Expect(searchPath).To(Equal(`"My Schema", public`))
+		})
+		It("reports that no search_path is set", func() {
+			gucs := []backup.GUC{{Name: "default_with_oids", Value: "true"}}
+
+			_, ok := backup.GetDatabaseSearchPath(gucs)
+			Expect(ok).To(BeFalse())
+		})
 	})
 	Describe("PrintCreateResourceQueueStatements", func() {
 		var emptyResQueueMetadata = map[uint32]backup.ObjectMetadata{}
@@ -128,6 +219,30 @@ GRANT TEMPORARY,CONNECT ON DATABASE testdb TO testrole;`)
 			backup.PrintCreateResourceQueueStatements(backupfile, toc, resQueues, emptyResQueueMetadata)
 			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE RESOURCE QUEUE "everythingQueue" WITH (ACTIVE_STATEMENTS=7, MAX_COST=32.80, COST_OVERCOMMIT=TRUE, MIN_COST=1.34, PRIORITY=LOW, MEMORY_LIMIT='2GB');`)
 		})
+		It("omits ACTIVE_STATEMENTS from a CREATE for a queue with no active statement limit", func() {
+			unlimitedQueue := backup.ResourceQueue{Oid: 1, Name: "unlimited_queue", ActiveStatements: -1, MaxCost: "10.00", CostOvercommit: false, MinCost: "0.00", Priority: "medium", MemoryLimit: "-1"}
+
+			backup.PrintCreateResourceQueueStatements(backupfile, toc, []backup.ResourceQueue{unlimitedQueue}, emptyResQueueMetadata)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE RESOURCE QUEUE unlimited_queue WITH (MAX_COST=10.00);`)
+		})
+		It("prints ACTIVE_STATEMENTS for a queue with an active statement limit of 0", func() {
+			pausedQueue := backup.ResourceQueue{Oid: 1, Name: "paused_queue", ActiveStatements: 0, MaxCost: "-1.00", CostOvercommit: false, MinCost: "0.00", Priority: "medium", MemoryLimit: "-1"}
+
+			backup.PrintCreateResourceQueueStatements(backupfile, toc, []backup.ResourceQueue{pausedQueue}, emptyResQueueMetadata)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE RESOURCE QUEUE paused_queue WITH (ACTIVE_STATEMENTS=0);`)
+		})
+		It("prints ACTIVE_STATEMENTS for a queue with a positive active statement limit", func() {
+			limitedQueue := backup.ResourceQueue{Oid: 1, Name: "limited_queue", ActiveStatements: 3, MaxCost: "-1.00", CostOvercommit: false, MinCost: "0.00", Priority: "medium", MemoryLimit: "-1"}
+
+			backup.PrintCreateResourceQueueStatements(backupfile, toc, []backup.ResourceQueue{limitedQueue}, emptyResQueueMetadata)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE RESOURCE QUEUE limited_queue WITH (ACTIVE_STATEMENTS=3);`)
+		})
+		It("prints ACTIVE_STATEMENTS=-1 when altering pg_default to have no active statement limit", func() {
+			defaultQueue := backup.ResourceQueue{Oid: 1, Name: "pg_default", ActiveStatements: -1, MaxCost: "10.00", CostOvercommit: false, MinCost: "0.00", Priority: "medium", MemoryLimit: "-1"}
+
+			backup.PrintCreateResourceQueueStatements(backupfile, toc, []backup.ResourceQueue{defaultQueue}, emptyResQueueMetadata)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `ALTER RESOURCE QUEUE pg_default WITH (ACTIVE_STATEMENTS=-1, MAX_COST=10.00);`)
+		})
 		It("prints a resource queue with a comment", func() {
 			commentQueue := backup.ResourceQueue{Oid: 1, Name: `"commentQueue"`, ActiveStatements: 1, MaxCost: "-1.00", CostOvercommit: false, MinCost: "0.00", Priority: "medium", MemoryLimit: "-1"}
 			resQueues := []backup.ResourceQueue{commentQueue}
@@ -138,6 +253,32 @@ GRANT TEMPORARY,CONNECT ON DATABASE testdb TO testrole;`)
 
 COMMENT ON RESOURCE QUEUE "commentQueue" IS 'This is a resource queue comment.';`)
 		})
+		Context("a resource queue whose DDL cannot be generated", func() {
+			badQueue := backup.ResourceQueue{Oid: 1, Name: "bad_queue", ActiveStatements: 1, MaxCost: "not a number", CostOvercommit: false, MinCost: "0.00", Priority: "medium", MemoryLimit: "-1"}
+			goodQueue := backup.ResourceQueue{Oid: 2, Name: "good_queue", ActiveStatements: 1, MaxCost: "-1.00", CostOvercommit: false, MinCost: "0.00", Priority: "medium", MemoryLimit: "-1"}
+			var report *utils.Report
+			BeforeEach(func() {
+				report = &utils.Report{}
+				backup.SetReport(report)
+			})
+			AfterEach(func() {
+				backup.SetBestEffort(false)
+			})
+			It("aborts the backup by default", func() {
+				backup.SetBestEffort(false)
+				defer testutils.ShouldPanicWithMessage(`Unable to generate DDL for RESOURCE QUEUE bad_queue: strconv.ParseFloat: parsing "not a number": invalid syntax`)
+				backup.PrintCreateResourceQueueStatements(backupfile, toc, []backup.ResourceQueue{badQueue, goodQueue}, emptyResQueueMetadata)
+			})
+			It("skips the resource queue in best-effort mode", func() {
+				backup.SetBestEffort(true)
+				backup.PrintCreateResourceQueueStatements(backupfile, toc, []backup.ResourceQueue{badQueue, goodQueue}, emptyResQueueMetadata)
+				Expect(toc.GlobalEntries).To(HaveLen(1))
+				testutils.ExpectEntry(toc.GlobalEntries, 0, "", "good_queue", "RESOURCE QUEUE")
+				testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE RESOURCE QUEUE good_queue WITH (ACTIVE_STATEMENTS=1);`)
+				Expect(report.SkippedObjects).To(Equal([]string{"RESOURCE QUEUE bad_queue"}))
+				Expect(string(stdout.Contents())).To(ContainSubstring(`Skipping RESOURCE QUEUE bad_queue: strconv.ParseFloat: parsing "not a number": invalid syntax`))
+			})
+		})
 		It("prints ALTER statement for pg_default resource queue", func() {
 			pgDefault := backup.ResourceQueue{Oid: 1, Name: "pg_default", ActiveStatements: 1, MaxCost: "-1.00", CostOvercommit: false, MinCost: "0.00", Priority: "medium", MemoryLimit: "-1"}
 			resQueues := []backup.ResourceQueue{pgDefault}
@@ -171,6 +312,20 @@ COMMENT ON RESOURCE QUEUE "commentQueue" IS 'This is a resource queue comment.';
 				`ALTER RESOURCE GROUP default_group SET MEMORY_SPILL_RATIO 30;`,
 				`ALTER RESOURCE GROUP default_group SET CONCURRENCY 15;`)
 		})
+		It("does not print ALTER statements for built-in resource groups when excluding them", func() {
+			backup.SetExcludeDefaultResourceGroups(true)
+			defer backup.SetExcludeDefaultResourceGroups(false)
+			defaultGroup := backup.ResourceGroup{Oid: 1, Name: "default_group", CPURateLimit: 10, MemoryLimit: 20, Concurrency: 15, MemorySharedQuota: 25, MemorySpillRatio: 30}
+			adminGroup := backup.ResourceGroup{Oid: 2, Name: "admin_group", CPURateLimit: 10, MemoryLimit: 10, Concurrency: 10, MemorySharedQuota: 50, MemorySpillRatio: 20}
+			someGroup := backup.ResourceGroup{Oid: 3, Name: "some_group", CPURateLimit: 10, MemoryLimit: 20, Concurrency: 15, MemorySharedQuota: 25, MemorySpillRatio: 30}
+			resGroups := []backup.ResourceGroup{adminGroup, defaultGroup, someGroup}
+
+			backup.PrintCreateResourceGroupStatements(backupfile, toc, resGroups, emptyResGroupMetadata)
+			Expect(toc.GlobalEntries).To(HaveLen(1))
+			testutils.ExpectEntry(toc.GlobalEntries, 0, "", "some_group", "RESOURCE GROUP")
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer,
+				`CREATE RESOURCE GROUP some_group WITH (CPU_RATE_LIMIT=10, MEMORY_LIMIT=20, MEMORY_SHARED_QUOTA=25, MEMORY_SPILL_RATIO=30, CONCURRENCY=15);`)
+		})
 	})
 	Describe("PrintCreateRoleStatements", func() {
 		testrole1 := backup.Role{
@@ -246,6 +401,65 @@ ALTER ROLE "testRole2" DENY BETWEEN DAY 0 TIME '13:30:00' AND DAY 3 TIME '14:30:
 ALTER ROLE "testRole2" DENY BETWEEN DAY 5 TIME '00:00:00' AND DAY 5 TIME '24:00:00';
 
 COMMENT ON ROLE "testRole2" IS 'This is a role comment.';`)
+		})
+		It("prints a role with a same-day time constraint unchanged", func() {
+			sameDayRole := testrole1
+			sameDayRole.TimeConstraints = []backup.TimeConstraint{{Oid: 1, StartDay: 3, StartTime: "09:00:00", EndDay: 3, EndTime: "17:30:00"}}
+
+			backup.PrintCreateRoleStatements(backupfile, toc, []backup.Role{sameDayRole}, backup.MetadataMap{})
+			testutils.ExpectRegexp(buffer, `ALTER ROLE testrole1 DENY BETWEEN DAY 3 TIME '09:00:00' AND DAY 3 TIME '17:30:00';`)
+			testutils.NotExpectRegexp(buffer, "DENY")
+		})
+		It("splits a time constraint spanning Saturday night into Sunday at the end of the week", func() {
+			weekendRole := testrole1
+			weekendRole.TimeConstraints = []backup.TimeConstraint{{Oid: 1, StartDay: 6, StartTime: "22:00:00", EndDay: 0, EndTime: "02:00:00"}}
+
+			backup.PrintCreateRoleStatements(backupfile, toc, []backup.Role{weekendRole}, backup.MetadataMap{})
+			testutils.ExpectRegexp(buffer, `ALTER ROLE testrole1 DENY BETWEEN DAY 6 TIME '22:00:00' AND DAY 6 TIME '24:00:00';
+ALTER ROLE testrole1 DENY BETWEEN DAY 0 TIME '00:00:00' AND DAY 0 TIME '02:00:00';`)
+		})
+		It("splits a same-day time constraint that wraps around the whole week", func() {
+			wrappingRole := testrole1
+			wrappingRole.TimeConstraints = []backup.TimeConstraint{{Oid: 1, StartDay: 2, StartTime: "18:00:00", EndDay: 2, EndTime: "06:00:00"}}
+
+			backup.PrintCreateRoleStatements(backupfile, toc, []backup.Role{wrappingRole}, backup.MetadataMap{})
+			testutils.ExpectRegexp(buffer, `ALTER ROLE testrole1 DENY BETWEEN DAY 2 TIME '18:00:00' AND DAY 6 TIME '24:00:00';
+ALTER ROLE testrole1 DENY BETWEEN DAY 0 TIME '00:00:00' AND DAY 2 TIME '06:00:00';`)
+		})
+		It("prints a role with configuration settings", func() {
+			configRole := testrole1
+			configRole.Configs = []backup.GUC{{Name: "search_path", Value: "public, pg_catalog"}, {Name: "work_mem", Value: "256MB"}}
+			backup.PrintCreateRoleStatements(backupfile, toc, []backup.Role{configRole}, backup.MetadataMap{})
+
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE ROLE testrole1;
+ALTER ROLE testrole1 WITH NOSUPERUSER NOINHERIT NOCREATEROLE NOCREATEDB NOLOGIN RESOURCE QUEUE pg_default RESOURCE GROUP default_group;
+ALTER ROLE testrole1 SET search_path TO 'public', 'pg_catalog';
+ALTER ROLE testrole1 SET work_mem TO '256MB';`)
+		})
+		It("prints a role with REPLICATION in GPDB 6", func() {
+			testutils.SetDBVersion(connection, "6.0.0")
+			replicationRole := testrole1
+			replicationRole.Replication = true
+			backup.PrintCreateRoleStatements(backupfile, toc, []backup.Role{replicationRole}, backup.MetadataMap{})
+
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE ROLE testrole1;
+ALTER ROLE testrole1 WITH NOSUPERUSER NOINHERIT NOCREATEROLE NOCREATEDB NOLOGIN REPLICATION RESOURCE QUEUE pg_default RESOURCE GROUP default_group;`)
+		})
+		It("prints a role with NOREPLICATION in GPDB 6", func() {
+			testutils.SetDBVersion(connection, "6.0.0")
+			backup.PrintCreateRoleStatements(backupfile, toc, []backup.Role{testrole1}, backup.MetadataMap{})
+
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE ROLE testrole1;
+ALTER ROLE testrole1 WITH NOSUPERUSER NOINHERIT NOCREATEROLE NOCREATEDB NOLOGIN NOREPLICATION RESOURCE QUEUE pg_default RESOURCE GROUP default_group;`)
+		})
+		It("does not print REPLICATION before GPDB 6", func() {
+			testutils.SetDBVersion(connection, "5.0.0")
+			replicationRole := testrole1
+			replicationRole.Replication = true
+			backup.PrintCreateRoleStatements(backupfile, toc, []backup.Role{replicationRole}, backup.MetadataMap{})
+
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE ROLE testrole1;
+ALTER ROLE testrole1 WITH NOSUPERUSER NOINHERIT NOCREATEROLE NOCREATEDB NOLOGIN RESOURCE QUEUE pg_default RESOURCE GROUP default_group;`)
 		})
 		It("prints multiple roles", func() {
 			emptyMetadataMap := backup.MetadataMap{}
@@ -272,27 +486,66 @@ ALTER ROLE "testRole2" DENY BETWEEN DAY 5 TIME '00:00:00' AND DAY 5 TIME '24:00:
 			backup.PrintRoleMembershipStatements(backupfile, toc, []backup.RoleMember{roleWith})
 			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `GRANT group TO rolewith WITH ADMIN OPTION GRANTED BY grantor;`)
 		})
+		It("quotes the member name in the TOC entry if necessary", func() {
+			roleNeedsQuoting := backup.RoleMember{Role: "group", Member: "Role Member", Grantor: "grantor", IsAdmin: false}
+			backup.PrintRoleMembershipStatements(backupfile, toc, []backup.RoleMember{roleNeedsQuoting})
+			testutils.ExpectEntry(toc.GlobalEntries, 0, "", `"Role Member"`, "ROLE GRANT")
+		})
 		It("prints multiple roles", func() {
 			backup.PrintRoleMembershipStatements(backupfile, toc, []backup.RoleMember{roleWith, roleWithout})
 			testutils.AssertBufferContents(toc.GlobalEntries, buffer,
 				`GRANT group TO rolewith WITH ADMIN OPTION GRANTED BY grantor;`,
 				`GRANT group TO rolewithout GRANTED BY grantor;`)
 		})
+		It("revokes each membership before granting it when revoking role memberships", func() {
+			backup.SetRevokeRoleMemberships(true)
+			defer backup.SetRevokeRoleMemberships(false)
+			backup.PrintRoleMembershipStatements(backupfile, toc, []backup.RoleMember{roleWith, roleWithout})
+			Expect(toc.GlobalEntries).To(HaveLen(2))
+			testutils.ExpectEntry(toc.GlobalEntries, 0, "", "rolewith", "ROLE GRANT")
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer,
+				`REVOKE group FROM rolewith;
+GRANT group TO rolewith WITH ADMIN OPTION GRANTED BY grantor;`,
+				`REVOKE group FROM rolewithout;
+GRANT group TO rolewithout GRANTED BY grantor;`)
+		})
 	})
 	Describe("PrintCreateTablespaceStatements", func() {
 		expectedTablespace := backup.Tablespace{Oid: 1, Tablespace: "test_tablespace", Filespace: "test_filespace"}
 		It("prints a basic tablespace", func() {
-			emptyMetadataMap := backup.MetadataMap{}
-			backup.PrintCreateTablespaceStatements(backupfile, toc, []backup.Tablespace{expectedTablespace}, emptyMetadataMap)
+			backup.PrintCreateTablespaceStatements(backupfile, toc, []backup.Tablespace{expectedTablespace})
 			testutils.ExpectEntry(toc.GlobalEntries, 0, "", "test_tablespace", "TABLESPACE")
 			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE TABLESPACE test_tablespace FILESPACE test_filespace;`)
 		})
+	})
+	Describe("PrintTablespaceMetadata", func() {
+		expectedTablespace := backup.Tablespace{Oid: 1, Tablespace: "test_tablespace", Filespace: "test_filespace"}
+		It("prints nothing for a tablespace with no metadata", func() {
+			backup.PrintTablespaceMetadata(backupfile, toc, []backup.Tablespace{expectedTablespace}, backup.MetadataMap{})
+			Expect(toc.GlobalEntries).To(BeEmpty())
+			Expect(string(buffer.Contents())).To(BeEmpty())
+		})
+		It("prints a tablespace comment", func() {
+			tablespaceMetadataMap := testutils.DefaultMetadataMap("TABLESPACE", false, false, true)
+			backup.PrintTablespaceMetadata(backupfile, toc, []backup.Tablespace{expectedTablespace}, tablespaceMetadataMap)
+			testutils.ExpectEntry(toc.GlobalEntries, 0, "", "test_tablespace", "TABLESPACE METADATA")
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `COMMENT ON TABLESPACE test_tablespace IS 'This is a tablespace comment.';`)
+		})
+		It("prints the owner of a tablespace owned by a custom role after creating the role", func() {
+			customRole := backup.Role{Oid: 1, Name: "tablespace_owner", Inherit: true, ConnectionLimit: -1}
+			tablespaceMetadataMap := backup.MetadataMap{1: {Owner: "tablespace_owner"}}
+			backup.PrintCreateTablespaceStatements(backupfile, toc, []backup.Tablespace{expectedTablespace})
+			backup.PrintCreateRoleStatements(backupfile, toc, []backup.Role{customRole}, backup.MetadataMap{})
+			backup.PrintTablespaceMetadata(backupfile, toc, []backup.Tablespace{expectedTablespace}, tablespaceMetadataMap)
+			testutils.ExpectEntry(toc.GlobalEntries, 0, "", "test_tablespace", "TABLESPACE")
+			testutils.ExpectEntry(toc.GlobalEntries, 1, "", "tablespace_owner", "ROLE")
+			testutils.ExpectEntry(toc.GlobalEntries, 2, "", "test_tablespace", "TABLESPACE METADATA")
+			Expect(string(buffer.Contents())).To(HaveSuffix("ALTER TABLESPACE test_tablespace OWNER TO tablespace_owner;\n"))
+		})
 		It("prints a tablespace with privileges, an owner, and a comment", func() {
 			tablespaceMetadataMap := testutils.DefaultMetadataMap("TABLESPACE", true, true, true)
-			backup.PrintCreateTablespaceStatements(backupfile, toc, []backup.Tablespace{expectedTablespace}, tablespaceMetadataMap)
-			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `CREATE TABLESPACE test_tablespace FILESPACE test_filespace;
-
-COMMENT ON TABLESPACE test_tablespace IS 'This is a tablespace comment.';
+			backup.PrintTablespaceMetadata(backupfile, toc, []backup.Tablespace{expectedTablespace}, tablespaceMetadataMap)
+			testutils.AssertBufferContents(toc.GlobalEntries, buffer, `COMMENT ON TABLESPACE test_tablespace IS 'This is a tablespace comment.';
 
 
 ALTER TABLESPACE test_tablespace OWNER TO testrole;
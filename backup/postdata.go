@@ -41,3 +41,47 @@ func PrintCreateTriggerStatements(postdataFile *utils.FileWithByteCount, toc *ut
 		toc.AddMetadataEntry(trigger.OwningSchema, trigger.Name, "TRIGGER", start, postdataFile)
 	}
 }
+
+/*
+ * The DISABLE TRIGGERS entries are written at the end of the predata file and
+ * the ENABLE TRIGGERS entries at the start of the postdata file, so that they
+ * bracket the data restore.  Only tables with constraint triggers are passed
+ * in, as no other triggers exist until the postdata file is restored.  They
+ * are separate TOC entries so that a restore can choose whether to run them.
+ */
+func PrintDisableTriggerStatements(predataFile *utils.FileWithByteCount, toc *utils.TOC, tables []Relation) {
+	for _, table := range tables {
+		start := predataFile.ByteCount
+		predataFile.MustPrintf("\n\nALTER TABLE %s DISABLE TRIGGER ALL;", table.ToString())
+		toc.AddMetadataEntry(table.Schema, table.Name, "DISABLE TRIGGERS", start, predataFile)
+	}
+}
+
+func PrintEnableTriggerStatements(postdataFile *utils.FileWithByteCount, toc *utils.TOC, tables []Relation) {
+	for _, table := range tables {
+		start := postdataFile.ByteCount
+		postdataFile.MustPrintf("\n\nALTER TABLE %s ENABLE TRIGGER ALL;", table.ToString())
+		toc.AddMetadataEntry(table.Schema, table.Name, "ENABLE TRIGGERS", start, postdataFile)
+	}
+}
+
+func PrintCreateEventTriggerStatements(postdataFile *utils.FileWithByteCount, toc *utils.TOC, eventTriggers []EventTrigger, eventTriggerMetadata MetadataMap) {
+	enabledStrMap := map[string]string{
+		"D": "DISABLE",
+		"A": "ENABLE ALWAYS",
+		"R": "ENABLE REPLICA",
+	}
+	for _, eventTrigger := range eventTriggers {
+		start := postdataFile.ByteCount
+		postdataFile.MustPrintf("\n\nCREATE EVENT TRIGGER %s\nON %s", eventTrigger.Name, eventTrigger.Event)
+		if eventTrigger.EventTags != "" {
+			postdataFile.MustPrintf("\nWHEN TAG IN (%s)", eventTrigger.EventTags)
+		}
+		postdataFile.MustPrintf("\nEXECUTE PROCEDURE %s();", eventTrigger.FunctionName)
+		if enabledStr, ok := enabledStrMap[eventTrigger.Enabled]; ok {
+			postdataFile.MustPrintf("\nALTER EVENT TRIGGER %s %s;", eventTrigger.Name, enabledStr)
+		}
+		PrintObjectMetadata(postdataFile, eventTriggerMetadata[eventTrigger.Oid], eventTrigger.Name, "EVENT TRIGGER")
+		toc.AddMetadataEntry("", utils.FQN("", eventTrigger.Name), "EVENT TRIGGER", start, postdataFile)
+	}
+}
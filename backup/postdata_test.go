@@ -69,4 +69,55 @@ COMMENT ON RULE testrule ON public.testtable IS 'This is a rule comment.';`)
 COMMENT ON TRIGGER testtrigger ON public.testtable IS 'This is a trigger comment.';`)
 		})
 	})
+	Context("PrintDisableTriggerStatements", func() {
+		It("prints a DISABLE TRIGGERS entry in the predata file for each table", func() {
+			toc, backupfile = testutils.InitializeTestTOC(buffer, "predata")
+			tables := []backup.Relation{backup.BasicRelation("public", "testtable"), backup.BasicRelation("testschema", "testtable2")}
+			backup.PrintDisableTriggerStatements(backupfile, toc, tables)
+			testutils.ExpectEntry(toc.PredataEntries, 0, "public", "testtable", "DISABLE TRIGGERS")
+			testutils.ExpectEntry(toc.PredataEntries, 1, "testschema", "testtable2", "DISABLE TRIGGERS")
+			testutils.AssertBufferContents(toc.PredataEntries, buffer, `ALTER TABLE public.testtable DISABLE TRIGGER ALL;`, `ALTER TABLE testschema.testtable2 DISABLE TRIGGER ALL;`)
+		})
+	})
+	Context("PrintEnableTriggerStatements", func() {
+		It("prints an ENABLE TRIGGERS entry in the postdata file for each table", func() {
+			tables := []backup.Relation{backup.BasicRelation("public", "testtable"), backup.BasicRelation("testschema", "testtable2")}
+			backup.PrintEnableTriggerStatements(backupfile, toc, tables)
+			testutils.ExpectEntry(toc.PostdataEntries, 0, "public", "testtable", "ENABLE TRIGGERS")
+			testutils.ExpectEntry(toc.PostdataEntries, 1, "testschema", "testtable2", "ENABLE TRIGGERS")
+			testutils.AssertBufferContents(toc.PostdataEntries, buffer, `ALTER TABLE public.testtable ENABLE TRIGGER ALL;`, `ALTER TABLE testschema.testtable2 ENABLE TRIGGER ALL;`)
+		})
+	})
+	Context("PrintCreateEventTriggerStatements", func() {
+		It("can print an enabled event trigger", func() {
+			eventTriggers := []backup.EventTrigger{{Oid: 1, Name: "testeventtrigger", Event: "ddl_command_start", FunctionName: "public.abort_any_command", Enabled: "O"}}
+			emptyMetadataMap := backup.MetadataMap{}
+			backup.PrintCreateEventTriggerStatements(backupfile, toc, eventTriggers, emptyMetadataMap)
+			testutils.ExpectEntry(toc.PostdataEntries, 0, "", "testeventtrigger", "EVENT TRIGGER")
+			testutils.AssertBufferContents(toc.PostdataEntries, buffer, `CREATE EVENT TRIGGER testeventtrigger
+ON ddl_command_start
+EXECUTE PROCEDURE public.abort_any_command();`)
+		})
+		It("records a quoted event trigger name in the TOC entry unchanged", func() {
+			eventTriggers := []backup.EventTrigger{{Oid: 1, Name: `"Test Trigger"`, Event: "ddl_command_start", FunctionName: "public.abort_any_command", Enabled: "O"}}
+			emptyMetadataMap := backup.MetadataMap{}
+			backup.PrintCreateEventTriggerStatements(backupfile, toc, eventTriggers, emptyMetadataMap)
+			testutils.ExpectEntry(toc.PostdataEntries, 0, "", `"Test Trigger"`, "EVENT TRIGGER")
+		})
+		It("can print a disabled event trigger with tags, an owner, and a comment", func() {
+			eventTriggers := []backup.EventTrigger{{Oid: 1, Name: "testeventtrigger", Event: "ddl_command_start", FunctionName: "public.abort_any_command", Enabled: "D", EventTags: "'CREATE TABLE', 'DROP TABLE'"}}
+			eventTriggerMetadataMap := backup.MetadataMap{1: {Owner: "testrole", Comment: "This is an event trigger comment."}}
+			backup.PrintCreateEventTriggerStatements(backupfile, toc, eventTriggers, eventTriggerMetadataMap)
+			testutils.AssertBufferContents(toc.PostdataEntries, buffer, `CREATE EVENT TRIGGER testeventtrigger
+ON ddl_command_start
+WHEN TAG IN ('CREATE TABLE', 'DROP TABLE')
+EXECUTE PROCEDURE public.abort_any_command();
+ALTER EVENT TRIGGER testeventtrigger DISABLE;
+
+COMMENT ON EVENT TRIGGER testeventtrigger IS 'This is an event trigger comment.';
+
+
+ALTER EVENT TRIGGER testeventtrigger OWNER TO testrole;`)
+		})
+	})
 })
@@ -236,7 +236,7 @@ func PrintPostCreateTableStatements(predataFile *utils.FileWithByteCount, table
 	PrintObjectMetadata(predataFile, tableMetadata, table.ToString(), "TABLE")
 
 	for _, att := range tableDef.ColumnDefs {
-		if att.Comment != "" {
+		if att.Comment != "" && !*noComments {
 			predataFile.MustPrintf("\n\nCOMMENT ON COLUMN %s.%s IS '%s';\n", table.ToString(), att.Name, att.Comment)
 		}
 	}
@@ -126,7 +126,7 @@ type ACL struct {
 type MetadataMap map[uint32]ObjectMetadata
 
 func PrintObjectMetadata(file *utils.FileWithByteCount, obj ObjectMetadata, objectName string, objectType string, owningTable ...string) {
-	if comment := obj.GetCommentStatement(objectName, objectType, owningTable...); comment != "" {
+	if comment := obj.GetCommentStatement(objectName, objectType, owningTable...); comment != "" && !*noComments {
 		file.MustPrintln(comment)
 	}
 	if owner := obj.GetOwnerStatement(objectName, objectType); owner != "" {
@@ -259,6 +259,9 @@ func (obj ObjectMetadata) GetPrivilegesStatements(objectName string, objectType
 			case "DATABASE":
 				hasAllPrivileges = acl.Create && acl.Temporary && acl.Connect
 				hasAllPrivilegesWithGrant = acl.CreateWithGrant && acl.TemporaryWithGrant && acl.ConnectWithGrant
+			case "DOMAIN", "TYPE":
+				hasAllPrivileges = acl.Usage
+				hasAllPrivilegesWithGrant = acl.UsageWithGrant
 			case "FUNCTION":
 				hasAllPrivileges = acl.Execute
 				hasAllPrivilegesWithGrant = acl.ExecuteWithGrant
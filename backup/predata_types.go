@@ -91,11 +91,11 @@ func PrintCreateBaseTypeStatement(predataFile *utils.FileWithByteCount, toc *uti
 	if base.Storage != "" {
 		switch base.Storage {
 		case "e":
-			predataFile.MustPrintf(",\n\tSTORAGE = extended")
+			predataFile.MustPrintf(",\n\tSTORAGE = external")
 		case "m":
 			predataFile.MustPrintf(",\n\tSTORAGE = main")
 		case "x":
-			predataFile.MustPrintf(",\n\tSTORAGE = external")
+			predataFile.MustPrintf(",\n\tSTORAGE = extended")
 		case "p": // Default case, don't print anything else
 		}
 	}
@@ -105,27 +105,48 @@ func PrintCreateBaseTypeStatement(predataFile *utils.FileWithByteCount, toc *uti
 	if base.Element != "" {
 		predataFile.MustPrintf(",\n\tELEMENT = %s", base.Element)
 	}
-	if base.Delimiter != "" {
+	if base.Delimiter != "" && base.Delimiter != "," {
 		predataFile.MustPrintf(",\n\tDELIMITER = '%s'", base.Delimiter)
 	}
 	predataFile.MustPrintln("\n);")
 	PrintObjectMetadata(predataFile, typeMetadata, typeFQN, "TYPE")
 	toc.AddMetadataEntry(base.Schema, base.Name, "TYPE", start, predataFile)
+	/*
+	 * The array type is created implicitly by CREATE TYPE and cannot be renamed
+	 * directly (ALTER TYPE fails with "cannot alter array type"), so there is
+	 * no statement to restore a non-default array type name.
+	 */
+	if base.ArrayType != "" {
+		logger.Warn("Array type of type %s is named %s, which will not be preserved on restore", typeFQN, utils.MakeFQN(base.Schema, base.ArrayType))
+	}
 }
 
+/*
+ * A composite type with no attributes, which is left when all of its attributes
+ * have been dropped, can only be created in GPDB 6 and later, so in earlier
+ * versions it is skipped rather than printed as invalid DDL.
+ */
 func PrintCreateCompositeTypeStatement(predataFile *utils.FileWithByteCount, toc *utils.TOC, composite Type, typeMetadata ObjectMetadata) {
-	start := predataFile.ByteCount
 	typeFQN := utils.MakeFQN(composite.Schema, composite.Name)
-	predataFile.MustPrintf("\n\nCREATE TYPE %s AS (\n", typeFQN)
-	predataFile.MustPrintln(strings.Join(composite.Attributes, ",\n"))
-	predataFile.MustPrintf(");")
+	if len(composite.Attributes) == 0 && connection.Version.Before("6") {
+		logger.Warn("Skipping composite type %s, as it has no attributes and cannot be created in GPDB versions before 6", typeFQN)
+		return
+	}
+	start := predataFile.ByteCount
+	if len(composite.Attributes) == 0 {
+		predataFile.MustPrintf("\n\nCREATE TYPE %s AS ();", typeFQN)
+	} else {
+		predataFile.MustPrintf("\n\nCREATE TYPE %s AS (\n", typeFQN)
+		predataFile.MustPrintln(strings.Join(composite.Attributes, ",\n"))
+		predataFile.MustPrintf(");")
+	}
 	PrintObjectMetadata(predataFile, typeMetadata, typeFQN, "TYPE")
 	toc.AddMetadataEntry(composite.Schema, composite.Name, "TYPE", start, predataFile)
 }
 
 func PrintCreateEnumTypeStatements(predataFile *utils.FileWithByteCount, toc *utils.TOC, enums []Type, typeMetadata MetadataMap) {
-	start := predataFile.ByteCount
 	for _, enum := range enums {
+		start := predataFile.ByteCount
 		typeFQN := utils.MakeFQN(enum.Schema, enum.Name)
 		predataFile.MustPrintf("\n\nCREATE TYPE %s AS ENUM (\n\t%s\n);\n", typeFQN, enum.EnumLabels)
 		PrintObjectMetadata(predataFile, typeMetadata[enum.Oid], typeFQN, "TYPE")
@@ -46,6 +46,35 @@ COMMENT ON TYPE public.enum_type IS 'This is a type comment.';
 
 ALTER TYPE public.enum_type OWNER TO testrole;`)
 		})
+		It("prints an enum type with an owner and privileges", func() {
+			typeMetadataMap = testutils.DefaultMetadataMap("TYPE", true, true, false)
+			backup.PrintCreateEnumTypeStatements(backupfile, toc, []backup.Type{enumTwo}, typeMetadataMap)
+			testutils.AssertBufferContents(toc.PredataEntries, buffer, `CREATE TYPE public.enum_type AS ENUM (
+	'bar',
+	'baz',
+	'foo'
+);
+
+
+ALTER TYPE public.enum_type OWNER TO testrole;
+
+
+REVOKE ALL ON TYPE public.enum_type FROM PUBLIC;
+REVOKE ALL ON TYPE public.enum_type FROM testrole;
+GRANT ALL ON TYPE public.enum_type TO testrole;`)
+		})
+		It("prints a separate TOC entry for each enum type", func() {
+			enumThree := backup.Type{Oid: 2, Schema: "public", Name: "enum_type2", Type: "e", EnumLabels: "'qux'"}
+			backup.PrintCreateEnumTypeStatements(backupfile, toc, []backup.Type{enumOne, enumThree}, backup.MetadataMap{})
+			testutils.ExpectEntry(toc.PredataEntries, 1, "public", "enum_type2", "TYPE")
+			testutils.AssertBufferContents(toc.PredataEntries, buffer, `CREATE TYPE public.enum_type AS ENUM (
+	'bar',
+	'baz',
+	'foo'
+);`, `CREATE TYPE public.enum_type2 AS ENUM (
+	'qux'
+);`)
+		})
 	})
 	Describe("PrintCreateCompositeTypeStatement", func() {
 		oneAtt := pq.StringArray{"\tfoo integer"}
@@ -60,6 +89,21 @@ ALTER TYPE public.enum_type OWNER TO testrole;`)
 	foo integer
 );`)
 		})
+		It("prints a composite type whose only attribute was dropped with an empty attribute list in GPDB 6", func() {
+			testutils.SetDBVersion(connection, "6.0.0")
+			compType.Attributes = pq.StringArray{}
+			backup.PrintCreateCompositeTypeStatement(backupfile, toc, compType, typeMetadata)
+			testutils.ExpectEntry(toc.PredataEntries, 0, "public", "composite_type", "TYPE")
+			testutils.AssertBufferContents(toc.PredataEntries, buffer, `CREATE TYPE public.composite_type AS ();`)
+		})
+		It("skips a composite type whose only attribute was dropped with a warning before GPDB 6", func() {
+			testutils.SetDBVersion(connection, "5.0.0")
+			compType.Attributes = pq.StringArray{}
+			backup.PrintCreateCompositeTypeStatement(backupfile, toc, compType, typeMetadata)
+			Expect(toc.PredataEntries).To(BeEmpty())
+			Expect(string(buffer.Contents())).To(BeEmpty())
+			Expect(string(stdout.Contents())).To(ContainSubstring("Skipping composite type public.composite_type, as it has no attributes and cannot be created in GPDB versions before 6"))
+		})
 		It("prints a composite type with multiple attributes", func() {
 			compType.Attributes = twoAtts
 			backup.PrintCreateCompositeTypeStatement(backupfile, toc, compType, typeMetadata)
@@ -80,6 +124,19 @@ ALTER TYPE public.enum_type OWNER TO testrole;`)
 COMMENT ON TYPE public.composite_type IS 'This is a type comment.';
 
 
+ALTER TYPE public.composite_type OWNER TO testrole;`)
+		})
+		It("prints a composite type with owner but no comment if comments are excluded", func() {
+			backup.SetNoComments(true)
+			defer backup.SetNoComments(false)
+			compType.Attributes = twoAtts
+			typeMetadata = testutils.DefaultMetadataMap("TYPE", false, true, true)[1]
+			backup.PrintCreateCompositeTypeStatement(backupfile, toc, compType, typeMetadata)
+			testutils.AssertBufferContents(toc.PredataEntries, buffer, `CREATE TYPE public.composite_type AS (
+	foo integer,
+	bar text
+);
+
 ALTER TYPE public.composite_type OWNER TO testrole;`)
 		})
 	})
@@ -109,8 +166,7 @@ ALTER TYPE public.composite_type OWNER TO testrole;`)
 	TYPMOD_IN = modin_fn,
 	TYPMOD_OUT = modout_fn,
 	DEFAULT = '42',
-	ELEMENT = int4,
-	DELIMITER = ','
+	ELEMENT = int4
 );`)
 		})
 		It("prints a base type with all optional arguments provided", func() {
@@ -125,10 +181,28 @@ ALTER TYPE public.composite_type OWNER TO testrole;`)
 	INTERNALLENGTH = 16,
 	PASSEDBYVALUE,
 	ALIGNMENT = int2,
-	STORAGE = extended,
+	STORAGE = external,
 	DEFAULT = '42',
-	ELEMENT = int4,
-	DELIMITER = ','
+	ELEMENT = int4
+);`)
+		})
+		It("prints a base type with a non-default array element delimiter", func() {
+			baseDelimiter := baseSimple
+			baseDelimiter.Delimiter = ";"
+			backup.PrintCreateBaseTypeStatement(backupfile, toc, baseDelimiter, typeMetadata)
+			testutils.AssertBufferContents(toc.PredataEntries, buffer, `CREATE TYPE public.base_type (
+	INPUT = input_fn,
+	OUTPUT = output_fn,
+	DELIMITER = ';'
+);`)
+		})
+		It("does not print the default array element delimiter", func() {
+			baseDelimiter := baseSimple
+			baseDelimiter.Delimiter = ","
+			backup.PrintCreateBaseTypeStatement(backupfile, toc, baseDelimiter, typeMetadata)
+			testutils.AssertBufferContents(toc.PredataEntries, buffer, `CREATE TYPE public.base_type (
+	INPUT = input_fn,
+	OUTPUT = output_fn
 );`)
 		})
 		It("prints a base type with double alignment and main storage", func() {
@@ -140,15 +214,34 @@ ALTER TYPE public.composite_type OWNER TO testrole;`)
 	STORAGE = main
 );`)
 		})
-		It("prints a base type with int4 alignment and external storage", func() {
+		It("prints a base type with int4 alignment and extended storage", func() {
 			backup.PrintCreateBaseTypeStatement(backupfile, toc, basePermTwo, typeMetadata)
 			testutils.AssertBufferContents(toc.PredataEntries, buffer, `CREATE TYPE public.base_type (
 	INPUT = input_fn,
 	OUTPUT = output_fn,
 	ALIGNMENT = int4,
-	STORAGE = external
+	STORAGE = extended
 );`)
 		})
+		It("prints a GPDB 4 base type with only text I/O functions without binary I/O or typmod clauses", func() {
+			testutils.SetDBVersion(connection, "4.3.0")
+			baseGPDB4 := backup.Type{Oid: 1, Schema: "public", Name: "base_type", Type: "b", Input: "input_fn", Output: "output_fn", Receive: "", Send: "", ModIn: "modin_fn", ModOut: "modout_fn", InternalLength: -1, IsPassedByValue: false, Alignment: "c", Storage: "p", DefaultVal: "", Element: "", Delimiter: "", EnumLabels: "", BaseType: "", NotNull: false, Attributes: nil, DependsUpon: nil}
+			backup.PrintCreateBaseTypeStatement(backupfile, toc, baseGPDB4, typeMetadata)
+			testutils.AssertBufferContents(toc.PredataEntries, buffer, `CREATE TYPE public.base_type (
+	INPUT = input_fn,
+	OUTPUT = output_fn
+);`)
+		})
+		It("warns that a non-default array type name will not be preserved", func() {
+			baseArrayType := baseSimple
+			baseArrayType.ArrayType = "__base_type"
+			backup.PrintCreateBaseTypeStatement(backupfile, toc, baseArrayType, typeMetadata)
+			testutils.AssertBufferContents(toc.PredataEntries, buffer, `CREATE TYPE public.base_type (
+	INPUT = input_fn,
+	OUTPUT = output_fn
+);`)
+			Expect(string(stdout.Contents())).To(ContainSubstring("Array type of type public.base_type is named public.__base_type, which will not be preserved on restore"))
+		})
 		It("prints a base type with comment and owner", func() {
 			backup.PrintCreateBaseTypeStatement(backupfile, toc, baseCommentOwner, typeMetadata)
 			testutils.AssertBufferContents(toc.PredataEntries, buffer, `CREATE TYPE public.base_type (
@@ -201,5 +294,18 @@ COMMENT ON DOMAIN public.domain2 IS 'This is a domain comment.';
 
 ALTER DOMAIN public.domain2 OWNER TO testrole;`)
 		})
+		It("prints a domain with an owner and privileges", func() {
+			typeMetadata = testutils.DefaultMetadataMap("DOMAIN", true, true, false)[1]
+			backup.PrintCreateDomainStatement(backupfile, toc, domainTwo, typeMetadata, emptyConstraint)
+			testutils.AssertBufferContents(toc.PredataEntries, buffer, `CREATE DOMAIN public.domain2 AS varchar;
+
+
+ALTER DOMAIN public.domain2 OWNER TO testrole;
+
+
+REVOKE ALL ON DOMAIN public.domain2 FROM PUBLIC;
+REVOKE ALL ON DOMAIN public.domain2 FROM testrole;
+GRANT ALL ON DOMAIN public.domain2 TO testrole;`)
+		})
 	})
 })
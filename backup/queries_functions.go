@@ -513,8 +513,7 @@ AND t.typsend != p.oid%s;`, SchemaFilterClause("n"), modStr)
 
 	results := make([]Dependency, 0)
 	dependencyMap := make(map[uint32][]string, 0)
-	err := connection.Select(&results, query)
-	utils.CheckError(err)
+	selectDependencies(connection, &results, query)
 	for _, dependency := range results {
 		dependencyMap[dependency.Oid] = append(dependencyMap[dependency.Oid], dependency.ReferencedObject)
 	}
@@ -7,8 +7,10 @@ package backup
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
 )
 
 type SessionGUCs struct {
@@ -16,32 +18,62 @@ type SessionGUCs struct {
 	DefaultWithOids string `db:"default_with_oids"`
 }
 
+/*
+ * These settings are printed to the top of every metadata file, so they are
+ * checked here to ensure that the file can be restored.  There is no safe
+ * default for client_encoding, as restoring data in the wrong encoding could
+ * corrupt it, but default_with_oids defaults to off, as on the server.
+ */
 func GetSessionGUCs(connection *utils.DBConn) SessionGUCs {
 	result := SessionGUCs{}
 	query := "SHOW client_encoding;"
 	err := connection.Get(&result, query)
+	utils.CheckError(err)
 	query = "SHOW default_with_oids;"
 	err = connection.Get(&result, query)
 	utils.CheckError(err)
+	if result.ClientEncoding == "" {
+		logger.Fatal(errors.New("Unable to determine the client encoding of the database: client_encoding is empty"), "")
+	}
+	if result.DefaultWithOids == "" {
+		logger.Verbose("default_with_oids is empty, so it will be set to off on restore")
+		result.DefaultWithOids = "off"
+	}
 	return result
 }
 
 type Database struct {
-	Oid        uint32
-	Name       string
-	Tablespace string
+	Oid               uint32
+	Name              string
+	Tablespace        string
+	DefaultTablespace string
+	Collate           string `db:"datcollate"`
+	CType             string `db:"datctype"`
 }
 
+/*
+ * DefaultTablespace is the current name of the cluster's default tablespace,
+ * pg_default, which always has OID 1663 but may have been renamed.
+ *
+ * Database-level collation settings were added in GPDB 6.
+ */
 func GetDatabaseName(connection *utils.DBConn) Database {
+	localeClause := ""
+	if connection.Version.AtLeast("6") {
+		localeClause = `
+	d.datcollate,
+	d.datctype,`
+	}
 	query := fmt.Sprintf(`
 SELECT
-	d.oid,
+	d.oid,%s
 	quote_ident(d.datname) AS name,
-	quote_ident(t.spcname) AS tablespace
+	quote_ident(t.spcname) AS tablespace,
+	(SELECT quote_ident(spcname) FROM pg_tablespace WHERE oid = 1663) AS defaulttablespace
 FROM pg_database d
 JOIN pg_tablespace t
 ON d.dattablespace = t.oid
-WHERE d.datname = '%s';`, connection.DBName)
+WHERE d.datname = '%s';`, localeClause, connection.DBName)
 
 	result := Database{}
 	err := connection.Get(&result, query)
@@ -49,18 +81,52 @@ WHERE d.datname = '%s';`, connection.DBName)
 	return result
 }
 
-func GetDatabaseGUCs(connection *utils.DBConn) []string {
-	//We do not want to quote list type config settings such as search_path and DateStyle
+/*
+ * A GUC holds the unquoted name and value of a configuration setting, which
+ * are quoted when the setting is printed (see GUC.SetClause).
+ */
+type GUC struct {
+	Name  string
+	Value string
+}
+
+/*
+ * This captures any default_tablespace set with ALTER DATABASE, which is separate
+ * from the tablespace in which the database was created (see GetDatabaseName).
+ * Database-level settings are stored in pg_database.datconfig before GPDB 6 and
+ * in pg_db_role_setting (with a setrole of 0) from GPDB 6 on.
+ */
+func GetDatabaseGUCs(connection *utils.DBConn) []GUC {
+	configSource := fmt.Sprintf("SELECT datconfig FROM pg_database WHERE datname = '%s'", connection.DBName)
+	if connection.Version.AtLeast("6") {
+		configSource = fmt.Sprintf("SELECT setconfig FROM pg_db_role_setting WHERE setrole = 0 AND setdatabase = (SELECT oid FROM pg_database WHERE datname = '%s')", connection.DBName)
+	}
 	query := fmt.Sprintf(`
-SELECT CASE
-	WHEN option_name='search_path' OR option_name = 'DateStyle'
-	THEN ('SET ' || option_name || ' TO ' || option_value)
-	ELSE ('SET ' || option_name || ' TO ' || quote_ident(option_value))
-END AS string
+SELECT
+	option_name AS name,
+	option_value AS value
 FROM pg_options_to_table(
-	(SELECT datconfig FROM pg_database WHERE datname = '%s')
-);`, connection.DBName)
-	return SelectStringSlice(connection, query)
+	(%s)
+);`, configSource)
+
+	results := make([]GUC, 0)
+	err := connection.Select(&results, query)
+	utils.CheckError(err)
+	return results
+}
+
+/*
+ * A custom database default search_path is easy to lose track of, and objects
+ * resolve differently after a restore without it, so it is picked out of the
+ * database GUCs to be logged and recorded in the report.
+ */
+func GetDatabaseSearchPath(gucs []GUC) (string, bool) {
+	for _, guc := range gucs {
+		if guc.Name == "search_path" {
+			return guc.Value, true
+		}
+	}
+	return "", false
 }
 
 type ResourceQueue struct {
@@ -162,6 +228,7 @@ type Role struct {
 	CreateRole      bool `db:"rolcreaterole"`
 	CreateDB        bool `db:"rolcreatedb"`
 	CanLogin        bool `db:"rolcanlogin"`
+	Replication     bool `db:"rolreplication"`
 	ConnectionLimit int  `db:"rolconnlimit"`
 	Password        string
 	ValidUntil      string
@@ -173,6 +240,7 @@ type Role struct {
 	Createrexthdfs  bool `db:"rolcreaterexthdfs"`
 	Createwexthdfs  bool `db:"rolcreatewexthdfs"`
 	TimeConstraints []TimeConstraint
+	Configs         []GUC
 }
 
 /*
@@ -185,6 +253,10 @@ func GetRoles(connection *utils.DBConn) []Role {
 	if connection.Version.AtLeast("5") {
 		resgroupQuery = "(SELECT quote_ident(rsgname) FROM pg_resgroup WHERE pg_resgroup.oid = rolresgroup) AS resgroup,"
 	}
+	replicationQuery := ""
+	if connection.Version.AtLeast("6") {
+		replicationQuery = "rolreplication,"
+	}
 	query := fmt.Sprintf(`
 SELECT
 	oid,
@@ -194,6 +266,7 @@ SELECT
 	rolcreaterole,
 	rolcreatedb,
 	rolcanlogin,
+	%s
 	rolconnlimit,
 	coalesce(rolpassword, '') AS password,
 	coalesce(timezone('UTC', rolvaliduntil) || '-00', '') AS validuntil,
@@ -205,16 +278,18 @@ SELECT
 	rolcreaterexthdfs,
 	rolcreatewexthdfs
 FROM
-	pg_authid`, resgroupQuery)
+	pg_authid`, replicationQuery, resgroupQuery)
 
 	roles := make([]Role, 0)
 	err := connection.Select(&roles, query)
 	utils.CheckError(err)
 
 	constraintsByRole := getTimeConstraintsByRole(connection)
+	gucsByRole := GetRoleGUCs(connection)
 
 	for idx, role := range roles {
 		roles[idx].TimeConstraints = constraintsByRole[role.Oid]
+		roles[idx].Configs = gucsByRole[role.Oid]
 	}
 
 	return roles
@@ -248,6 +323,54 @@ FROM
 	return constraintsByRole
 }
 
+type roleGUC struct {
+	Oid uint32
+	GUC
+}
+
+/*
+ * Role-level settings are stored in pg_authid.rolconfig before GPDB 6 and in
+ * pg_db_role_setting (with a setdatabase of 0) from GPDB 6 on.  Settings are
+ * sorted by name so that an unchanged role produces identical output in
+ * consecutive backups.
+ */
+func GetRoleGUCs(connection *utils.DBConn) map[uint32][]GUC {
+	configSource := `
+	SELECT
+		oid,
+		(pg_options_to_table(rolconfig)).*
+	FROM pg_authid
+	WHERE rolconfig IS NOT NULL`
+	if connection.Version.AtLeast("6") {
+		configSource = `
+	SELECT
+		setrole AS oid,
+		(pg_options_to_table(setconfig)).*
+	FROM pg_db_role_setting
+	WHERE setdatabase = 0`
+	}
+	query := fmt.Sprintf(`
+SELECT
+	oid,
+	option_name AS name,
+	option_value AS value
+FROM (%s
+) AS role_configs;`, configSource)
+
+	results := make([]roleGUC, 0)
+	err := connection.Select(&results, query)
+	utils.CheckError(err)
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	gucsByRole := make(map[uint32][]GUC, 0)
+	for _, guc := range results {
+		gucsByRole[guc.Oid] = append(gucsByRole[guc.Oid], guc.GUC)
+	}
+	return gucsByRole
+}
+
 type RoleMember struct {
 	Role    string
 	Member  string
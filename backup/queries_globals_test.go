@@ -0,0 +1,73 @@
+package backup_test
+
+import (
+	"database/sql/driver"
+
+	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/testutils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var _ = Describe("backup/queries_globals tests", func() {
+	Describe("GetSessionGUCs", func() {
+		expectSettings := func(clientEncoding string, defaultWithOids string) {
+			mock.ExpectQuery("SHOW client_encoding;").WillReturnRows(sqlmock.NewRows([]string{"client_encoding"}).AddRow(clientEncoding))
+			mock.ExpectQuery("SHOW default_with_oids;").WillReturnRows(sqlmock.NewRows([]string{"default_with_oids"}).AddRow(defaultWithOids))
+		}
+		It("returns the client encoding and default_with_oids setting", func() {
+			expectSettings("UTF8", "off")
+			gucs := backup.GetSessionGUCs(connection)
+			Expect(gucs).To(Equal(backup.SessionGUCs{ClientEncoding: "UTF8", DefaultWithOids: "off"}))
+		})
+		It("returns default_with_oids when it is on", func() {
+			expectSettings("LATIN1", "on")
+			gucs := backup.GetSessionGUCs(connection)
+			Expect(gucs).To(Equal(backup.SessionGUCs{ClientEncoding: "LATIN1", DefaultWithOids: "on"}))
+		})
+		It("defaults an empty default_with_oids to off", func() {
+			expectSettings("UTF8", "")
+			gucs := backup.GetSessionGUCs(connection)
+			Expect(gucs.DefaultWithOids).To(Equal("off"))
+		})
+		It("panics if the client encoding is empty", func() {
+			expectSettings("", "off")
+			defer testutils.ShouldPanicWithMessage("Unable to determine the client encoding of the database: client_encoding is empty")
+			backup.GetSessionGUCs(connection)
+		})
+	})
+	Describe("GetRoleGUCs", func() {
+		header := []string{"oid", "name", "value"}
+		workMem := []driver.Value{"1", "work_mem", "256MB"}
+		searchPath := []driver.Value{"1", "search_path", "public, pg_catalog"}
+		clientMinMessages := []driver.Value{"1", "client_min_messages", "error"}
+		otherRole := []driver.Value{"2", "statement_mem", "125MB"}
+
+		BeforeEach(func() {
+			testutils.SetDBVersion(connection, "5.0.0")
+		})
+		It("sorts each role's settings by name regardless of the order in which they are returned", func() {
+			expectedGUCs := map[uint32][]backup.GUC{
+				1: {{Name: "client_min_messages", Value: "error"}, {Name: "search_path", Value: "public, pg_catalog"}, {Name: "work_mem", Value: "256MB"}},
+				2: {{Name: "statement_mem", Value: "125MB"}},
+			}
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows(header).AddRow(workMem...).AddRow(otherRole...).AddRow(searchPath...).AddRow(clientMinMessages...))
+			firstRun := backup.GetRoleGUCs(connection)
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows(header).AddRow(clientMinMessages...).AddRow(searchPath...).AddRow(otherRole...).AddRow(workMem...))
+			secondRun := backup.GetRoleGUCs(connection)
+
+			Expect(firstRun).To(Equal(expectedGUCs))
+			Expect(secondRun).To(Equal(expectedGUCs))
+		})
+		It("reads role settings from pg_db_role_setting in GPDB 6", func() {
+			testutils.SetDBVersion(connection, "6.0.0")
+			mock.ExpectQuery(`(?s)FROM pg_db_role_setting\s+WHERE setdatabase = 0`).WillReturnRows(sqlmock.NewRows(header).AddRow(workMem...))
+
+			results := backup.GetRoleGUCs(connection)
+
+			Expect(results).To(Equal(map[uint32][]backup.GUC{1: {{Name: "work_mem", Value: "256MB"}}}))
+		})
+	})
+})
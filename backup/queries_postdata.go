@@ -7,6 +7,7 @@ package backup
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/greenplum-db/gpbackup/utils"
 )
@@ -154,3 +155,75 @@ ORDER BY tgname;`, SchemaFilterClause("n"))
 	utils.CheckError(err)
 	return results
 }
+
+/*
+ * This returns the tables in the given list that have constraint triggers.
+ * Constraint triggers are created along with their constraints in the predata
+ * file, so they are the only triggers that exist while data is restored; all
+ * other triggers are created in the postdata file, after the data restore.
+ */
+func GetTablesWithTriggers(connection *utils.DBConn, tables []Relation) []Relation {
+	tablesWithTriggers := make([]Relation, 0)
+	if len(tables) == 0 {
+		return tablesWithTriggers
+	}
+	tableOids := make([]string, len(tables))
+	for i, table := range tables {
+		tableOids[i] = fmt.Sprintf("%d", table.Oid)
+	}
+	query := fmt.Sprintf(`
+SELECT DISTINCT tgrelid AS oid
+FROM pg_trigger
+WHERE tgisconstraint = 't'
+AND tgrelid IN (%s);`, strings.Join(tableOids, ","))
+
+	results := make([]struct{ Oid uint32 }, 0)
+	err := connection.Select(&results, query)
+	utils.CheckError(err)
+	hasTriggers := make(map[uint32]bool, len(results))
+	for _, result := range results {
+		hasTriggers[result.Oid] = true
+	}
+	for _, table := range tables {
+		if hasTriggers[table.Oid] {
+			tablesWithTriggers = append(tablesWithTriggers, table)
+		}
+	}
+	return tablesWithTriggers
+}
+
+type EventTrigger struct {
+	Oid          uint32
+	Name         string
+	Event        string
+	FunctionName string
+	Enabled      string
+	EventTags    string
+}
+
+/*
+ * Event triggers are not in a schema, so we filter them on the schema of their
+ * trigger function instead, as the trigger cannot be restored without it.
+ */
+func GetEventTriggers(connection *utils.DBConn) []EventTrigger {
+	query := fmt.Sprintf(`
+SELECT
+	e.oid,
+	quote_ident(e.evtname) AS name,
+	e.evtevent AS event,
+	quote_ident(n.nspname) || '.' || quote_ident(p.proname) AS functionname,
+	e.evtenabled AS enabled,
+	coalesce(array_to_string(ARRAY(SELECT quote_literal(tag) FROM unnest(e.evttags) AS tag), ', '), '') AS eventtags
+FROM pg_event_trigger e
+JOIN pg_proc p
+	ON (e.evtfoid = p.oid)
+JOIN pg_namespace n
+	ON (p.pronamespace = n.oid)
+WHERE %s
+ORDER BY e.evtname;`, SchemaFilterClause("n"))
+
+	results := make([]EventTrigger, 0)
+	err := connection.Select(&results, query)
+	utils.CheckError(err)
+	return results
+}
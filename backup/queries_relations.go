@@ -273,6 +273,13 @@ func GetStorageOptions(connection *utils.DBConn) map[uint32]string {
 	return SelectAsOidToStringMap(connection, query)
 }
 
+/*
+ * A reltablespace of 0 means the relation is in the database's default
+ * tablespace, wherever that is at restore time, so such relations get no
+ * TABLESPACE clause.  A relation explicitly placed in pg_default in a database
+ * whose default tablespace is elsewhere has a nonzero reltablespace, so it is
+ * still restored to pg_default.
+ */
 func GetTablespaceNames(connection *utils.DBConn) map[uint32]string {
 	query := `SELECT c.oid, quote_ident(t.spcname) AS value FROM pg_class c JOIN pg_tablespace t ON t.oid = c.reltablespace`
 	return SelectAsOidToStringMap(connection, query)
@@ -328,8 +335,7 @@ JOIN pg_class c ON d.objid = c.oid AND c.relkind = 'r'`
 	}, 0)
 	dependencyMap := make(map[uint32][]string, 0)
 	inheritanceMap := make(map[uint32][]string, 0)
-	err := connection.Select(&results, query)
-	utils.CheckError(err)
+	selectDependencies(connection, &results, query)
 	for _, dependency := range results {
 		if dependency.IsTable {
 			inheritanceMap[dependency.Oid] = append(inheritanceMap[dependency.Oid], dependency.ReferencedObject)
@@ -466,8 +472,7 @@ ORDER BY v2.oid, referencedobject;`, SchemaFilterClause("n"))
 
 	results := make([]Dependency, 0)
 	dependencyMap := make(map[uint32][]string, 0)
-	err := connection.Select(&results, query)
-	utils.CheckError(err)
+	selectDependencies(connection, &results, query)
 	for _, dependency := range results {
 		dependencyMap[dependency.Oid] = append(dependencyMap[dependency.Oid], dependency.ReferencedObject)
 	}
@@ -142,6 +142,7 @@ var (
 	TYPE_CONSTRAINT      MetadataQueryParams
 	TYPE_CONVERSION      MetadataQueryParams
 	TYPE_DATABASE        MetadataQueryParams
+	TYPE_EVENTTRIGGER    MetadataQueryParams
 	TYPE_FUNCTION        MetadataQueryParams
 	TYPE_INDEX           MetadataQueryParams
 	TYPE_PROCLANGUAGE    MetadataQueryParams
@@ -170,6 +171,7 @@ func InitializeMetadataParams(connection *utils.DBConn) {
 	TYPE_CONSTRAINT = MetadataQueryParams{NameField: "conname", SchemaField: "connamespace", OidField: "oid", CatalogTable: "pg_constraint"}
 	TYPE_CONVERSION = MetadataQueryParams{NameField: "conname", OidField: "oid", SchemaField: "connamespace", OwnerField: "conowner", CatalogTable: "pg_conversion"}
 	TYPE_DATABASE = MetadataQueryParams{NameField: "datname", ACLField: "datacl", OwnerField: "datdba", CatalogTable: "pg_database", Shared: true}
+	TYPE_EVENTTRIGGER = MetadataQueryParams{NameField: "evtname", OidField: "oid", OwnerField: "evtowner", CatalogTable: "pg_event_trigger"}
 	TYPE_FUNCTION = MetadataQueryParams{NameField: "proname", SchemaField: "pronamespace", ACLField: "proacl", OwnerField: "proowner", CatalogTable: "pg_proc"}
 	TYPE_INDEX = MetadataQueryParams{NameField: "relname", OidField: "indexrelid", OidTable: "pg_class", CommentTable: "pg_class", CatalogTable: "pg_index"}
 	TYPE_PROCLANGUAGE = MetadataQueryParams{NameField: "lanname", ACLField: "lanacl", CatalogTable: "pg_language"}
@@ -195,6 +197,9 @@ func InitializeMetadataParams(connection *utils.DBConn) {
 	TYPE_TSTEMPLATE = MetadataQueryParams{NameField: "tmplname", OidField: "oid", SchemaField: "tmplnamespace", CatalogTable: "pg_ts_template"}
 	TYPE_TRIGGER = MetadataQueryParams{NameField: "tgname", OidField: "oid", CatalogTable: "pg_trigger"}
 	TYPE_TYPE = MetadataQueryParams{NameField: "typname", SchemaField: "typnamespace", OwnerField: "typowner", CatalogTable: "pg_type"}
+	if connection.Version.AtLeast("6") {
+		TYPE_TYPE.ACLField = "typacl" // Types, including domains, have privileges as of GPDB 6
+	}
 }
 
 // A list of schemas we don't want to back up, formatted for use in a WHERE clause
@@ -18,6 +18,7 @@ type AttributeStatistic struct {
 	Table        string
 	AttName      string
 	Type         string
+	TypeSchema   string
 	Relid        uint32         `db:"starelid"`
 	AttNumber    int            `db:"staattnum"`
 	NullFraction float64        `db:"stanullfrac"`
@@ -53,6 +54,7 @@ SELECT
 	quote_ident(c.relname) AS table,
 	quote_ident(a.attname) AS attname,
 	quote_ident(t.typname) AS type,
+	quote_ident(tn.nspname) AS typeschema,
 	s.starelid,
 	s.staattnum,
 	s.stanullfrac,
@@ -79,6 +81,7 @@ JOIN pg_namespace n ON c.relnamespace = n.oid
 JOIN pg_attribute a ON a.attrelid = c.oid
 JOIN pg_statistic s ON (c.oid = s.starelid AND a.attnum = s.staattnum)
 JOIN pg_type t ON a.atttypid = t.oid
+JOIN pg_namespace tn ON t.typnamespace = tn.oid
 WHERE %s
 AND quote_ident(n.nspname) || '.' || quote_ident(c.relname) IN (%s)
 ORDER BY n.nspname, c.relname, a.attnum;`, SchemaFilterClause("n"), utils.SliceToQuotedString(tablenames))
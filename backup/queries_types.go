@@ -10,6 +10,7 @@ import (
 
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/lib/pq"
+	"github.com/pkg/errors"
 )
 
 /*
@@ -18,6 +19,9 @@ import (
  * creating a table, so we construct queries to retrieve those types and use them
  * in an EXCEPT clause to exclude them in larger base and composite type retrieval
  * queries that are constructed in their respective functions.
+ *
+ * The --include-array-types flag leaves array types out of the EXCEPT clause so
+ * that the raw list of types can be inspected when diagnosing catalog problems.
  */
 func getTypeQuery(connection *utils.DBConn, selectClause string, groupBy string, typeType string) string {
 	arrayTypesClause := ""
@@ -72,6 +76,12 @@ UNION ALL
 JOIN pg_type it ON t.typelem = it.oid
 JOIN pg_class c ON it.typrelid = c.oid AND c.relkind IN ('r', 'S', 'v')
 GROUP BY %s`, selectClause, groupBy, selectClause, groupBy)
+	exceptClause := fmt.Sprintf(`%s
+UNION ALL
+%s`, arrayTypesClause, tableTypesClause)
+	if includeArrayTypes != nil && *includeArrayTypes {
+		exceptClause = tableTypesClause
+	}
 	return fmt.Sprintf(`
 %s
 WHERE %s
@@ -79,10 +89,8 @@ AND t.typtype = '%s'
 GROUP BY %s
 EXCEPT (
 %s
-UNION ALL
-%s
 )
-ORDER BY schema, name;`, selectClause, SchemaFilterClause("n"), typeType, groupBy, arrayTypesClause, tableTypesClause)
+ORDER BY schema, name;`, selectClause, SchemaFilterClause("n"), typeType, groupBy, exceptClause)
 }
 
 type Type struct {
@@ -106,20 +114,36 @@ type Type struct {
 	EnumLabels      string
 	BaseType        string
 	NotNull         bool `db:"typnotnull"`
+	ArrayType       string
 	Attributes      pq.StringArray
 	DependsUpon     []string
 }
 
+/*
+ * The I/O functions of a base type are schema-qualified by looking them up in
+ * pg_proc rather than by casting to regproc, whose text output depends on the
+ * search_path at backup time.  A function OID of 0 (displayed as "-") finds no
+ * function and so becomes an empty string.
+ *
+ * In GPDB 5 and later, ArrayType is set to the name of the type's array type
+ * only if it differs from the name CREATE TYPE generates, which is the type
+ * name prepended with an underscore and truncated to NAMEDATALEN - 1 bytes.
+ * This happens when the default name was taken when the type was created.
+ */
 func GetBaseTypes(connection *utils.DBConn) []Type {
+	qualifiedFunctionName := func(functionOid string) string {
+		return fmt.Sprintf(`coalesce((SELECT quote_ident(pn.nspname) || '.' || quote_ident(p.proname) FROM pg_proc p JOIN pg_namespace pn ON p.pronamespace = pn.oid WHERE p.oid = %s), '')`, functionOid)
+	}
 	typModClause := ""
 	if connection.Version.Before("5") {
-		typModClause = `t.typreceive AS receive,
-	t.typsend AS send,`
+		typModClause = fmt.Sprintf(`%s AS receive,
+	%s AS send,`, qualifiedFunctionName("t.typreceive"), qualifiedFunctionName("t.typsend"))
 	} else {
-		typModClause = `CASE WHEN t.typreceive = '-'::regproc THEN '' ELSE t.typreceive::regproc::text END AS receive,
-	CASE WHEN t.typsend = '-'::regproc THEN '' ELSE t.typsend::regproc::text END AS send,
-	CASE WHEN t.typmodin = '-'::regproc THEN '' ELSE t.typmodin::regproc::text END AS modin,
-	CASE WHEN t.typmodout = '-'::regproc THEN '' ELSE t.typmodout::regproc::text END AS modout,`
+		typModClause = fmt.Sprintf(`%s AS receive,
+	%s AS send,
+	%s AS modin,
+	%s AS modout,
+	coalesce((SELECT quote_ident(at.typname) FROM pg_type at WHERE at.oid = t.typarray AND at.typname != substring('_' || t.typname FROM 1 FOR 63)), '') AS arraytype,`, qualifiedFunctionName("t.typreceive"), qualifiedFunctionName("t.typsend"), qualifiedFunctionName("t.typmodin"), qualifiedFunctionName("t.typmodout"))
 	}
 	selectClause := fmt.Sprintf(`
 SELECT
@@ -127,8 +151,8 @@ SELECT
 	quote_ident(n.nspname) AS schema,
 	quote_ident(t.typname) AS name,
 	t.typtype,
-	t.typinput,
-	t.typoutput,
+	%s AS typinput,
+	%s AS typoutput,
 	%s
 	t.typlen,
 	t.typbyval,
@@ -138,36 +162,70 @@ SELECT
 	CASE WHEN t.typelem != 0::regproc THEN pg_catalog.format_type(t.typelem, NULL) ELSE '' END AS element,
 	t.typdelim
 FROM pg_type t
-JOIN pg_namespace n ON t.typnamespace = n.oid`, typModClause)
+JOIN pg_namespace n ON t.typnamespace = n.oid`, qualifiedFunctionName("t.typinput"), qualifiedFunctionName("t.typoutput"), typModClause)
 	groupBy := "t.oid, schema, name, t.typtype, t.typinput, t.typoutput, receive, send,%st.typlen, t.typbyval, alignment, t.typstorage, defaultval, element, t.typdelim"
 	if connection.Version.Before("5") {
 		groupBy = fmt.Sprintf(groupBy, " ")
 	} else {
-		groupBy = fmt.Sprintf(groupBy, " modin, modout, ")
+		groupBy = fmt.Sprintf(groupBy, " modin, modout, arraytype, ")
 	}
 	query := getTypeQuery(connection, selectClause, groupBy, "b")
 
 	results := make([]Type, 0)
 	err := connection.Select(&results, query)
 	utils.CheckError(err)
-	/*
-	 * GPDB 4.3 has no built-in regproc-to-text cast and uses "-" in place of
-	 * NULL for several fields, so to avoid dealing with hyphens later on we
-	 * replace those with empty strings here.
-	 */
-	if connection.Version.Before("5") {
-		for i := range results {
-			if results[i].Send == "-" {
-				results[i].Send = ""
-			}
-			if results[i].Receive == "-" {
-				results[i].Receive = ""
+	return results
+}
+
+/*
+ * A base type whose length, alignment, storage, and pass-by-value settings are
+ * inconsistent, e.g. because its catalog entry was edited by hand, would be
+ * written as a CREATE TYPE statement that fails on restore.  Such types are
+ * logged with a warning, recorded in the report, and skipped.  With
+ * --strict-type-checks they are instead handled as objects whose DDL cannot be
+ * generated, so they abort the backup unless it is run in best-effort mode.
+ */
+func CheckBaseTypeConsistency(bases []Type) []Type {
+	consistentBases := make([]Type, 0, len(bases))
+	for _, base := range bases {
+		if err := checkBaseTypeConsistency(base); err != nil {
+			if *strictTypeChecks {
+				HandleObjectError("TYPE", utils.MakeFQN(base.Schema, base.Name), err)
+			} else {
+				skipObject("TYPE", utils.MakeFQN(base.Schema, base.Name), err)
 			}
+			continue
 		}
+		consistentBases = append(consistentBases, base)
 	}
-	return results
+	return consistentBases
+}
+
+func checkBaseTypeConsistency(base Type) error {
+	isVariableLength := base.InternalLength == -1 || base.InternalLength == -2
+	if isVariableLength && base.Alignment == "" {
+		return errors.New("Variable-length type has no alignment")
+	}
+	if base.InternalLength == -1 && (base.Alignment == "c" || base.Alignment == "s") {
+		return errors.Errorf("Variable-length type has alignment %s, but must have int4 or double alignment", base.Alignment)
+	}
+	if base.InternalLength != -1 && base.Storage != "" && base.Storage != "p" {
+		return errors.Errorf("Type with internal length %d has storage %s, but only variable-length types may have storage other than plain", base.InternalLength, base.Storage)
+	}
+	if base.IsPassedByValue && base.InternalLength != 1 && base.InternalLength != 2 && base.InternalLength != 4 && base.InternalLength != 8 {
+		return errors.Errorf("Type passed by value has internal length %d, but must have a length of 1, 2, 4, or 8 bytes", base.InternalLength)
+	}
+	return nil
 }
 
+/*
+ * Dropped attributes remain in pg_attribute with attisdropped set, and system
+ * attributes have a non-positive attnum, so both are excluded here.  A
+ * composite type all of whose attributes have been dropped has no rows in
+ * pg_attribute that we join to, so the join is an outer join to keep the type,
+ * and it is returned with an attributes array of one empty string that we
+ * replace with an empty array.
+ */
 func GetCompositeTypes(connection *utils.DBConn) []Type {
 	selectClause := `
 SELECT
@@ -175,9 +233,9 @@ SELECT
 	quote_ident(n.nspname) AS schema,
 	quote_ident(t.typname) AS name,
 	t.typtype,
-	array_agg(E'\t' || quote_ident(a.attname) || ' ' || pg_catalog.format_type(a.atttypid, NULL) ORDER BY a.attnum) AS attributes
+	array_agg(coalesce(E'\t' || quote_ident(a.attname) || ' ' || pg_catalog.format_type(a.atttypid, NULL), '') ORDER BY a.attnum) AS attributes
 FROM pg_type t
-JOIN pg_attribute a ON t.typrelid = a.attrelid
+LEFT JOIN pg_attribute a ON t.typrelid = a.attrelid AND a.attisdropped = false AND a.attnum > 0
 JOIN pg_namespace n ON t.typnamespace = n.oid`
 	groupBy := "t.oid, schema, name, t.typtype"
 	query := getTypeQuery(connection, selectClause, groupBy, "c")
@@ -185,9 +243,20 @@ JOIN pg_namespace n ON t.typnamespace = n.oid`
 	results := make([]Type, 0)
 	err := connection.Select(&results, query)
 	utils.CheckError(err)
+	for i := range results {
+		if len(results[i].Attributes) == 1 && results[i].Attributes[0] == "" {
+			results[i].Attributes = pq.StringArray{}
+		}
+	}
 	return results
 }
 
+/*
+ * The text in typdefault was deparsed using the search_path in effect when the
+ * domain was created, so a default such as nextval('seq') may not be
+ * schema-qualified.  Deparsing typdefaultbin instead qualifies any object not
+ * in pg_catalog, as the backup's search_path contains only pg_catalog.
+ */
 func GetDomainTypes(connection *utils.DBConn) []Type {
 	query := fmt.Sprintf(`
 SELECT
@@ -195,7 +264,7 @@ SELECT
 	quote_ident(n.nspname) AS schema,
 	quote_ident(t.typname) AS name,
 	t.typtype,
-	coalesce(t.typdefault, '') AS defaultval,
+	coalesce(pg_catalog.pg_get_expr(t.typdefaultbin, 0), '') AS defaultval,
 	coalesce(quote_ident(b.typname), '') AS basetype,
 	t.typnotnull
 FROM pg_type t
@@ -203,7 +272,7 @@ JOIN pg_namespace n ON t.typnamespace = n.oid
 JOIN pg_type b ON t.typbasetype = b.oid
 WHERE %s
 AND t.typtype = 'd'
-ORDER BY n.nspname, t.typname;`, SchemaFilterClause("n"))
+ORDER BY schema, name;`, SchemaFilterClause("n"))
 
 	results := make([]Type, 0)
 	err := connection.Select(&results, query)
@@ -220,13 +289,13 @@ SELECT
 	t.typtype,
 	enumlabels
 FROM pg_type t
-LEFT JOIN pg_namespace n ON t.typnamespace = n.oid
+JOIN pg_namespace n ON t.typnamespace = n.oid
 LEFT JOIN (
 	  SELECT enumtypid,string_agg(quote_literal(enumlabel), E',\n\t') AS enumlabels FROM pg_enum GROUP BY enumtypid
 	) e ON t.oid = e.enumtypid
 WHERE %s
 AND t.typtype = 'e'
-ORDER BY n.nspname, t.typname;`, SchemaFilterClause("n"))
+ORDER BY schema, name;`, SchemaFilterClause("n"))
 
 	results := make([]Type, 0)
 	err := connection.Select(&results, query)
@@ -245,7 +314,7 @@ FROM pg_type t
 JOIN pg_namespace n ON t.typnamespace = n.oid
 WHERE %s
 AND t.typtype = 'p'
-ORDER BY n.nspname, t.typname;`, SchemaFilterClause("n"))
+ORDER BY schema, name;`, SchemaFilterClause("n"))
 
 	results := make([]Type, 0)
 	err := connection.Select(&results, query)
@@ -258,6 +327,11 @@ ORDER BY n.nspname, t.typname;`, SchemaFilterClause("n"))
  * TypeDefinition, but we need to query pg_proc to determine whether one of those
  * functions is a built-in function (and therefore should not be considered a
  * dependency for dependency sorting purposes).
+ *
+ * In all of the dependency queries below, the schema filter is applied to the
+ * current schema of the dependent type, as in the Get*Types queries, while the
+ * referenced object is qualified with its own current schema; a type that has
+ * been moved with ALTER TYPE ... SET SCHEMA is thus treated consistently.
  */
 func ConstructBaseTypeDependencies4(connection *utils.DBConn, types []Type, funcInfoMap map[uint32]FunctionInfo) []Type {
 	query := fmt.Sprintf(`
@@ -277,8 +351,7 @@ AND d.deptype = 'n';`, SchemaFilterClause("n"))
 		ReferencedOid uint32
 	}, 0)
 	dependencyMap := make(map[uint32][]string, 0)
-	err := connection.Select(&results, query)
-	utils.CheckError(err)
+	selectDependencies(connection, &results, query)
 	for _, dependency := range results {
 		referencedFunc := funcInfoMap[dependency.ReferencedOid]
 		dependencyStr := fmt.Sprintf("%s(%s)", referencedFunc.QualifiedName, referencedFunc.Arguments)
@@ -296,19 +369,19 @@ func ConstructBaseTypeDependencies5(connection *utils.DBConn, types []Type) []Ty
 	query := fmt.Sprintf(`
 SELECT DISTINCT
     t.oid,
-    quote_ident(n.nspname) || '.' || quote_ident(p.proname) || '(' || pg_get_function_arguments(p.oid) || ')' AS referencedobject
+    quote_ident(pn.nspname) || '.' || quote_ident(p.proname) || '(' || pg_get_function_arguments(p.oid) || ')' AS referencedobject
 FROM pg_depend d
 JOIN pg_proc p ON (d.refobjid = p.oid AND p.pronamespace != (SELECT oid FROM pg_namespace WHERE nspname = 'pg_catalog'))
 JOIN pg_type t ON (d.objid = t.oid AND t.typtype = 'b')
-JOIN pg_namespace n ON n.oid = p.pronamespace
+JOIN pg_namespace n ON n.oid = t.typnamespace
+JOIN pg_namespace pn ON pn.oid = p.pronamespace
 WHERE %s
 AND d.refclassid = 'pg_proc'::regclass
 AND d.deptype = 'n';`, SchemaFilterClause("n"))
 
 	results := make([]Dependency, 0)
 	dependencyMap := make(map[uint32][]string, 0)
-	err := connection.Select(&results, query)
-	utils.CheckError(err)
+	selectDependencies(connection, &results, query)
 	for _, dependency := range results {
 		dependencyMap[dependency.Oid] = append(dependencyMap[dependency.Oid], dependency.ReferencedObject)
 	}
@@ -329,10 +402,11 @@ func ConstructDomainDependencies(connection *utils.DBConn, types []Type) []Type
 	query := fmt.Sprintf(`
 SELECT
 	t.oid,
-	quote_ident(n.nspname) || '.' || quote_ident(bt.typname) AS referencedobject
+	quote_ident(bn.nspname) || '.' || quote_ident(bt.typname) AS referencedobject
 FROM pg_type t
 JOIN pg_type bt ON t.typbasetype = bt.oid
-JOIN pg_namespace n ON bt.typnamespace = n.oid
+JOIN pg_namespace n ON t.typnamespace = n.oid
+JOIN pg_namespace bn ON bt.typnamespace = bn.oid
 WHERE %s
 AND bt.typnamespace != (
 	SELECT
@@ -343,8 +417,7 @@ AND bt.typnamespace != (
 
 	results := make([]Dependency, 0)
 	dependencyMap := make(map[uint32][]string, 0)
-	err := connection.Select(&results, query)
-	utils.CheckError(err)
+	selectDependencies(connection, &results, query)
 	for _, dependency := range results {
 		dependencyMap[dependency.Oid] = append(dependencyMap[dependency.Oid], dependency.ReferencedObject)
 	}
@@ -360,13 +433,14 @@ func ConstructCompositeTypeDependencies(connection *utils.DBConn, types []Type)
 	query := fmt.Sprintf(`
 SELECT DISTINCT
 	tc.oid,
-	coalesce((SELECT quote_ident(n.nspname) || '.' || quote_ident(typname) FROM pg_type WHERE t.typelem = oid), quote_ident(n.nspname) || '.' || quote_ident(t.typname)) AS referencedobject
+	coalesce((SELECT quote_ident(en.nspname) || '.' || quote_ident(et.typname) FROM pg_type et JOIN pg_namespace en ON et.typnamespace = en.oid WHERE t.typelem = et.oid), quote_ident(tn.nspname) || '.' || quote_ident(t.typname)) AS referencedobject
 FROM pg_depend d
 JOIN pg_type t
 	ON (d.refobjid = t.oid AND t.typtype != 'p' AND t.typtype != 'e' AND t.typnamespace != (SELECT oid FROM pg_namespace WHERE nspname = 'pg_catalog'))
 JOIN pg_class c ON (d.objid = c.oid AND c.relkind = 'c')
 JOIN pg_type tc ON (tc.typrelid = c.oid AND tc.typtype = 'c')
-JOIN pg_namespace n ON n.oid = t.typnamespace
+JOIN pg_namespace n ON n.oid = tc.typnamespace
+JOIN pg_namespace tn ON tn.oid = t.typnamespace
 WHERE %s
 AND d.refclassid = 'pg_type'::regclass
 AND c.reltype != t.oid
@@ -374,8 +448,7 @@ AND d.deptype = 'n';`, SchemaFilterClause("n"))
 
 	results := make([]Dependency, 0)
 	dependencyMap := make(map[uint32][]string, 0)
-	err := connection.Select(&results, query)
-	utils.CheckError(err)
+	selectDependencies(connection, &results, query)
 	for _, dependency := range results {
 		dependencyMap[dependency.Oid] = append(dependencyMap[dependency.Oid], dependency.ReferencedObject)
 	}
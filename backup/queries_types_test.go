@@ -0,0 +1,154 @@
+package backup_test
+
+import (
+	"database/sql/driver"
+
+	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var _ = Describe("backup/queries_types tests", func() {
+	Describe("GetCompositeTypes", func() {
+		header := []string{"oid", "schema", "name", "typtype", "attributes"}
+		compositeType := []driver.Value{"1", "public", "composite_type", "c", "{\"\\tfoo integer\"}"}
+		arrayType := []driver.Value{"2", "public", "_composite_type", "c", "{\"\\tfoo integer\"}"}
+		/*
+		 * The array type clause is the only part of the EXCEPT clause that filters
+		 * on "typelem != 0", so a "!" between the start of the EXCEPT clause and the
+		 * table type clause means that array types are still being excluded.
+		 */
+		arrayTypesExcluded := `(?s)EXCEPT \(.*it\.typarray.*UNION ALL.*JOIN pg_class c ON t\.typrelid`
+		arrayTypesIncluded := `(?s)EXCEPT \(\s+SELECT[^!]*JOIN pg_class c ON t\.typrelid`
+
+		BeforeEach(func() {
+			testutils.SetDBVersion(connection, "5.0.0")
+		})
+		AfterEach(func() {
+			backup.SetIncludeArrayTypes(false)
+		})
+		It("excludes array types by default", func() {
+			fakeResult := sqlmock.NewRows(header).AddRow(compositeType...)
+			mock.ExpectQuery(arrayTypesExcluded).WillReturnRows(fakeResult)
+			results := backup.GetCompositeTypes(connection)
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Name).To(Equal("composite_type"))
+		})
+		It("does not exclude array types if the bypass is set", func() {
+			backup.SetIncludeArrayTypes(true)
+			fakeResult := sqlmock.NewRows(header).AddRow(compositeType...).AddRow(arrayType...)
+			mock.ExpectQuery(arrayTypesIncluded).WillReturnRows(fakeResult)
+			results := backup.GetCompositeTypes(connection)
+			Expect(results).To(HaveLen(2))
+			Expect(results[1].Name).To(Equal("_composite_type"))
+		})
+		It("returns a composite type whose only attribute was dropped with no attributes", func() {
+			emptyType := []driver.Value{"3", "public", "empty_type", "c", "{\"\"}"}
+			fakeResult := sqlmock.NewRows(header).AddRow(emptyType...)
+			mock.ExpectQuery(`LEFT JOIN pg_attribute a`).WillReturnRows(fakeResult)
+			results := backup.GetCompositeTypes(connection)
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Attributes).To(BeEmpty())
+		})
+	})
+	Describe("CheckBaseTypeConsistency", func() {
+		varlenaType := backup.Type{Oid: 1, Schema: "public", Name: "varlena_type", Type: "b", InternalLength: -1, Alignment: "i", Storage: "x"}
+		cstringType := backup.Type{Oid: 2, Schema: "public", Name: "cstring_type", Type: "b", InternalLength: -2, Alignment: "c", Storage: "p"}
+		fixedType := backup.Type{Oid: 3, Schema: "public", Name: "fixed_type", Type: "b", InternalLength: 8, IsPassedByValue: true, Alignment: "d", Storage: "p"}
+		var report *utils.Report
+		BeforeEach(func() {
+			report = &utils.Report{}
+			backup.SetReport(report)
+		})
+		AfterEach(func() {
+			backup.SetBestEffort(false)
+			backup.SetStrictTypeChecks(false)
+		})
+		It("keeps consistent variable-length and fixed-length types", func() {
+			bases := backup.CheckBaseTypeConsistency([]backup.Type{varlenaType, cstringType, fixedType})
+			Expect(bases).To(Equal([]backup.Type{varlenaType, cstringType, fixedType}))
+			Expect(report.SkippedObjects).To(BeEmpty())
+		})
+		It("aborts the backup in strict mode for a variable-length type without an alignment", func() {
+			backup.SetStrictTypeChecks(true)
+			noAlignment := varlenaType
+			noAlignment.Alignment = ""
+			defer testutils.ShouldPanicWithMessage("Unable to generate DDL for TYPE public.varlena_type: Variable-length type has no alignment")
+			backup.CheckBaseTypeConsistency([]backup.Type{noAlignment})
+		})
+		It("skips inconsistent types with a warning in strict mode with best-effort", func() {
+			backup.SetStrictTypeChecks(true)
+			backup.SetBestEffort(true)
+			noAlignment := varlenaType
+			noAlignment.Alignment = ""
+
+			bases := backup.CheckBaseTypeConsistency([]backup.Type{noAlignment})
+
+			Expect(bases).To(BeEmpty())
+			Expect(report.SkippedObjects).To(Equal([]string{"TYPE public.varlena_type"}))
+		})
+		It("skips inconsistent types with a warning by default", func() {
+			charAligned := varlenaType
+			charAligned.Name = "char_aligned"
+			charAligned.Alignment = "c"
+			fixedExtended := fixedType
+			fixedExtended.Name = "fixed_extended"
+			fixedExtended.Storage = "x"
+			longByValue := fixedType
+			longByValue.Name = "long_by_value"
+			longByValue.InternalLength = 16
+			oddByValue := fixedType
+			oddByValue.Name = "odd_by_value"
+			oddByValue.InternalLength = 3
+
+			bases := backup.CheckBaseTypeConsistency([]backup.Type{charAligned, fixedExtended, longByValue, oddByValue, varlenaType})
+
+			Expect(bases).To(Equal([]backup.Type{varlenaType}))
+			Expect(report.SkippedObjects).To(Equal([]string{"TYPE public.char_aligned", "TYPE public.fixed_extended", "TYPE public.long_by_value", "TYPE public.odd_by_value"}))
+			stdoutContents := string(stdout.Contents())
+			Expect(stdoutContents).To(ContainSubstring("[WARNING]:-Skipping TYPE public.char_aligned: Variable-length type has alignment c, but must have int4 or double alignment"))
+			Expect(stdoutContents).To(ContainSubstring("[WARNING]:-Skipping TYPE public.fixed_extended: Type with internal length 8 has storage x, but only variable-length types may have storage other than plain"))
+			Expect(stdoutContents).To(ContainSubstring("[WARNING]:-Skipping TYPE public.long_by_value: Type passed by value has internal length 16, but must have a length of 1, 2, 4, or 8 bytes"))
+			Expect(stdoutContents).To(ContainSubstring("[WARNING]:-Skipping TYPE public.odd_by_value: Type passed by value has internal length 3, but must have a length of 1, 2, 4, or 8 bytes"))
+		})
+	})
+	Describe("type ordering", func() {
+		/*
+		 * Every type query orders by the quoted schema and type names it selects,
+		 * so that all kinds of types are emitted in the same order.
+		 */
+		orderedByQuotedNames := `(?s)quote_ident\(n\.nspname\) AS schema,\s+quote_ident\(t\.typname\) AS name,.*ORDER BY schema, name;$`
+		typeQueries := map[string]func() []backup.Type{
+			"base":      func() []backup.Type { return backup.GetBaseTypes(connection) },
+			"composite": func() []backup.Type { return backup.GetCompositeTypes(connection) },
+			"domain":    func() []backup.Type { return backup.GetDomainTypes(connection) },
+			"enum":      func() []backup.Type { return backup.GetEnumTypes(connection) },
+			"shell":     func() []backup.Type { return backup.GetShellTypes(connection) },
+		}
+
+		BeforeEach(func() {
+			testutils.SetDBVersion(connection, "5.0.0")
+		})
+		for kind, getTypes := range typeQueries {
+			kind, getTypes := kind, getTypes
+			It("orders "+kind+" types by quoted schema and then name", func() {
+				typeRows := sqlmock.NewRows([]string{"oid", "schema", "name"}).
+					AddRow(3, `"testSchema"`, "type_b").
+					AddRow(1, "public", `"Type_A"`).
+					AddRow(2, "public", "type_a")
+				mock.ExpectQuery(orderedByQuotedNames).WillReturnRows(typeRows)
+				results := getTypes()
+				Expect(mock.ExpectationsWereMet()).To(Succeed())
+				orderedNames := make([]string, 0)
+				for _, result := range results {
+					orderedNames = append(orderedNames, result.Schema+"."+result.Name)
+				}
+				Expect(orderedNames).To(Equal([]string{`"testSchema".type_b`, `public."Type_A"`, "public.type_a"}))
+			})
+		}
+	})
+})
@@ -90,6 +90,15 @@ INSERT INTO pg_statistic VALUES (
 	NULL,
 	NULL`
 	} else {
+		/*
+		 * The type of a column may be a user-defined type outside the search_path
+		 * at restore time, so the type name is schema-qualified when the schema
+		 * is known.
+		 */
+		typeName := attStat.Type
+		if attStat.TypeSchema != "" {
+			typeName = utils.MakeFQN(attStat.TypeSchema, attStat.Type)
+		}
 		attributeQuery += fmt.Sprintf(`
 	%d::smallint,
 	%d::smallint,
@@ -118,10 +127,10 @@ INSERT INTO pg_statistic VALUES (
 			RealValues(attStat.Numbers2),
 			RealValues(attStat.Numbers3),
 			RealValues(attStat.Numbers4),
-			AnyValues(attStat.Values1, attStat.Type),
-			AnyValues(attStat.Values2, attStat.Type),
-			AnyValues(attStat.Values3, attStat.Type),
-			AnyValues(attStat.Values4, attStat.Type))
+			AnyValues(attStat.Values1, typeName),
+			AnyValues(attStat.Values2, typeName),
+			AnyValues(attStat.Values3, typeName),
+			AnyValues(attStat.Values4, typeName))
 	}
 	attributeQuery += `
 );`
@@ -179,6 +179,34 @@ INSERT INTO pg_statistic VALUES (
 	NULL
 );`))
 		})
+		It("generates attribute statistics query for a user-defined type with a schema-qualified type name", func() {
+			attStats.Type = "testtype"
+			attStats.TypeSchema = "testschema"
+			attStatsQuery := backup.GenerateAttributeStatisticsQuery(tableTestTable, attStats)
+			Expect(attStatsQuery).To(ContainSubstring(`array_in('{"4","5","6"}', 'testschema.testtype'::regtype::oid, -1),`))
+		})
+		It("does not restore statistics values for an array of a user-defined type", func() {
+			attStats.Type = "_testtype"
+			attStats.TypeSchema = "testschema"
+			attStatsQuery := backup.GenerateAttributeStatisticsQuery(tableTestTable, attStats)
+			Expect(attStatsQuery).ToNot(ContainSubstring("array_in"))
+		})
+	})
+	Describe("PrintStatisticsStatements", func() {
+		It("writes statistics entries to the statistics section of the TOC, not the metadata sections", func() {
+			toc, backupfile = testutils.InitializeTestTOC(buffer, "statistics")
+			tableTestTable := backup.BasicRelation("testschema", "testtable")
+			tableTestTable.Oid = 1
+			attStats := map[uint32][]backup.AttributeStatistic{1: {{Schema: "testschema", Table: "testtable", AttName: "testatt", Type: "testtype", TypeSchema: "testschema"}}}
+			tupleStats := map[uint32]backup.TupleStatistic{1: {Schema: "testschema", Table: "testtable"}}
+			backup.PrintStatisticsStatements(backupfile, toc, []backup.Relation{tableTestTable}, attStats, tupleStats)
+			Expect(toc.StatisticsEntries).To(HaveLen(2))
+			testutils.ExpectEntry(toc.StatisticsEntries, 0, "", "", "STATISTICS GUC")
+			testutils.ExpectEntry(toc.StatisticsEntries, 1, "testschema", "testtable", "STATISTICS")
+			Expect(toc.PredataEntries).To(BeEmpty())
+			Expect(toc.PostdataEntries).To(BeEmpty())
+			Expect(toc.GlobalEntries).To(BeEmpty())
+		})
 	})
 	Describe("AnyValues", func() {
 		It("returns properly casted string when length of anyvalues is greater than 0", func() {
@@ -3,6 +3,7 @@ package backup
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/pkg/errors"
@@ -67,11 +68,131 @@ WHERE quote_ident(n.nspname) || '.' || quote_ident(c.relname) IN (%s)`, quotedTa
 	}
 }
 
+/*
+ * This checks up front that the current role can read everything that the
+ * backup will read given the schema and table filters, so that a backup run by
+ * an underprivileged role fails before it starts instead of partway through,
+ * and so that every missing privilege is reported at once.
+ */
+func ValidatePrivileges(connection *utils.DBConn, backupData bool) {
+	inaccessibleObjects := GetInaccessibleObjects(connection, backupData)
+	if len(inaccessibleObjects) > 0 {
+		logger.Fatal(errors.Errorf("The current role lacks the privileges needed to back up the following objects:\n\t%s", strings.Join(inaccessibleObjects, "\n\t")), "")
+	}
+}
+
+func GetInaccessibleObjects(connection *utils.DBConn, backupData bool) []string {
+	catalogTables := []string{"pg_class", "pg_namespace", "pg_attribute", "pg_type", "pg_proc", "pg_constraint", "pg_depend", "pg_description"}
+	/* Roles are only backed up if no schemas are included, and pg_authid is only readable by superusers */
+	if len(includeSchemas) == 0 {
+		catalogTables = append(catalogTables, "pg_authid")
+	}
+	catalogQueries := make([]string, 0)
+	for _, catalogTable := range catalogTables {
+		catalogQueries = append(catalogQueries, fmt.Sprintf("SELECT 'catalog table pg_catalog.%[1]s (SELECT)' AS string WHERE NOT has_table_privilege('pg_catalog.%[1]s', 'SELECT')", catalogTable))
+	}
+	inaccessibleObjects := SelectStringSlice(connection, strings.Join(catalogQueries, "\nUNION ALL\n"))
+
+	schemaQuery := fmt.Sprintf(`
+SELECT 'schema ' || quote_ident(n.nspname) || ' (USAGE)' AS string
+FROM pg_namespace n
+WHERE %s
+AND NOT has_schema_privilege(n.oid, 'USAGE')
+ORDER BY n.nspname`, SchemaFilterClause("n"))
+	inaccessibleObjects = append(inaccessibleObjects, SelectStringSlice(connection, schemaQuery)...)
+
+	if backupData {
+		tableFilterStr := ""
+		if len(includeTables) > 0 {
+			tableFilterStr = fmt.Sprintf("\nAND quote_ident(n.nspname) || '.' || quote_ident(c.relname) IN (%s)", utils.SliceToQuotedString(includeTables))
+		}
+		if len(excludeTables) > 0 {
+			tableFilterStr += fmt.Sprintf("\nAND quote_ident(n.nspname) || '.' || quote_ident(c.relname) NOT IN (%s)", utils.SliceToQuotedString(excludeTables))
+		}
+		tableQuery := fmt.Sprintf(`
+SELECT 'table ' || quote_ident(n.nspname) || '.' || quote_ident(c.relname) || ' (SELECT)' AS string
+FROM pg_class c
+JOIN pg_namespace n ON c.relnamespace = n.oid
+WHERE %s%s
+AND c.relkind = 'r'
+AND c.relstorage != 'x'
+AND has_schema_privilege(n.oid, 'USAGE')
+AND NOT has_table_privilege(c.oid, 'SELECT')
+ORDER BY n.nspname, c.relname`, SchemaFilterClause("n"), tableFilterStr)
+		inaccessibleObjects = append(inaccessibleObjects, SelectStringSlice(connection, tableQuery)...)
+	}
+	return inaccessibleObjects
+}
+
+func ValidateTimestamp(timestamp string) {
+	if timestamp != "" && !utils.IsValidTimestamp(timestamp) {
+		logger.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.", timestamp), "")
+	}
+}
+
+/*
+ * A backup whose timestamp matches that of an existing backup would write its
+ * files to the same directories, so we refuse to start such a backup.  This can
+ * only happen when the timestamp is specified with --timestamp or when two
+ * backups are started within the same second.
+ */
+func ValidateTimestampIsUnused(cluster utils.Cluster) {
+	backupDir := cluster.GetDirForContent(-1)
+	if _, err := utils.System.Stat(backupDir); err == nil {
+		logger.Fatal(errors.Errorf("A backup with timestamp %s already exists in %s", cluster.Timestamp, backupDir), "")
+	}
+}
+
+/*
+ * Removing an empty string would insert nothing between every pair of
+ * characters, so an empty clause is almost certainly a quoting mistake.
+ */
+func ValidateStripDDLClauses(clauses utils.ArrayFlags) {
+	for _, clause := range clauses {
+		if clause == "" {
+			logger.Fatal(errors.New("The clause given to --strip-ddl-clause cannot be empty"), "")
+		}
+	}
+}
+
+/*
+ * The sendmail transport passes the message to echo in double quotes, so a
+ * subject containing any character that is special there could break the
+ * command or run arbitrary shell code.
+ */
+func ValidateEmailSubject(subjectTemplate string) {
+	if strings.ContainsAny(subjectTemplate, "\"$`\\") {
+		logger.Fatal(errors.Errorf("Email subject %s is invalid: it cannot contain double quotes, dollar signs, backticks, or backslashes", subjectTemplate), "")
+	}
+	_, err := utils.FormatEmailSubject(subjectTemplate, utils.EmailSubject{})
+	if err != nil {
+		logger.Fatal(errors.Errorf("Email subject %s is invalid: %s", subjectTemplate, err.Error()), "")
+	}
+}
+
+func ValidateEmailTransport(transport string, smtpServer string) {
+	switch transport {
+	case utils.EmailTransportSendmail:
+		if smtpServer != "" {
+			logger.Fatal(errors.Errorf("--email-smtp-server can only be used with --email-transport %s", utils.EmailTransportSMTP), "")
+		}
+	case utils.EmailTransportSMTP:
+		if smtpServer == "" {
+			logger.Fatal(errors.Errorf("--email-transport %s requires --email-smtp-server", utils.EmailTransportSMTP), "")
+		}
+	default:
+		logger.Fatal(errors.Errorf("Email transport %s is invalid.  Valid transports are %s and %s.", transport, utils.EmailTransportSendmail, utils.EmailTransportSMTP), "")
+	}
+}
+
 func ValidateFlagCombinations() {
 	utils.CheckMandatoryFlags("dbname")
 
 	utils.CheckExclusiveFlags("debug", "quiet", "verbose")
 	utils.CheckExclusiveFlags("data-only", "metadata-only")
+	utils.CheckExclusiveFlags("data-only", "disable-triggers-on-restore")
+	utils.CheckExclusiveFlags("data-only", "single-transaction-metadata")
+	utils.CheckExclusiveFlags("disable-triggers-on-restore", "exclude-table-file", "include-table-file")
 	utils.CheckExclusiveFlags("include-schema", "include-table-file")
 	utils.CheckExclusiveFlags("exclude-schema", "include-schema")
 	utils.CheckExclusiveFlags("exclude-schema", "exclude-table-file", "include-table-file")
@@ -1,11 +1,16 @@
 package backup_test
 
 import (
+	"errors"
+	"os"
+
 	"github.com/greenplum-db/gpbackup/backup"
 	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/greenplum-db/gpbackup/utils"
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("backup/validate tests", func() {
@@ -118,6 +123,54 @@ var _ = Describe("backup/validate tests", func() {
 			})
 		})
 	})
+	Describe("ValidatePrivileges", func() {
+		emptyRows := func() *sqlmock.Rows { return sqlmock.NewRows([]string{"string"}) }
+		AfterEach(func() {
+			backup.SetIncludeSchemas([]string{})
+			backup.SetIncludeTables([]string{})
+		})
+		It("passes if the role has all of the privileges needed", func() {
+			mock.ExpectQuery("pg_catalog.pg_authid").WillReturnRows(emptyRows())
+			mock.ExpectQuery("has_schema_privilege").WillReturnRows(emptyRows())
+			mock.ExpectQuery("has_table_privilege").WillReturnRows(emptyRows())
+			backup.ValidatePrivileges(connection, true)
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not check tables if data is not backed up", func() {
+			mock.ExpectQuery("pg_catalog.pg_authid").WillReturnRows(emptyRows())
+			mock.ExpectQuery("has_schema_privilege").WillReturnRows(emptyRows())
+			backup.ValidatePrivileges(connection, false)
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("restricts the checks to the schemas and tables being backed up", func() {
+			backup.SetIncludeSchemas([]string{"schema1"})
+			backup.SetIncludeTables([]string{"schema1.table1"})
+			mock.ExpectQuery(`SELECT 'catalog table pg_catalog.pg_class \(SELECT\)'`).WillReturnRows(emptyRows())
+			mock.ExpectQuery(`n.nspname IN \('schema1'\)`).WillReturnRows(emptyRows())
+			mock.ExpectQuery(`IN \('schema1.table1'\)`).WillReturnRows(emptyRows())
+			inaccessibleObjects := backup.GetInaccessibleObjects(connection, true)
+			Expect(inaccessibleObjects).To(BeEmpty())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not check pg_authid if roles are not backed up", func() {
+			backup.SetIncludeSchemas([]string{"schema1"})
+			mock.ExpectQuery(`has_table_privilege\('pg_catalog.pg_description', 'SELECT'\)$`).WillReturnRows(emptyRows())
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(emptyRows())
+			backup.GetInaccessibleObjects(connection, false)
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("panics with every inaccessible object if the role lacks access to a schema", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(emptyRows().AddRow("catalog table pg_catalog.pg_authid (SELECT)"))
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(emptyRows().AddRow("schema schema1 (USAGE)").AddRow(`schema "Schema2" (USAGE)`))
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(emptyRows().AddRow("table public.foo (SELECT)"))
+			defer testutils.ShouldPanicWithMessage(`The current role lacks the privileges needed to back up the following objects:
+	catalog table pg_catalog.pg_authid (SELECT)
+	schema schema1 (USAGE)
+	schema "Schema2" (USAGE)
+	table public.foo (SELECT)`)
+			backup.ValidatePrivileges(connection, true)
+		})
+	})
 	Describe("ValidateFQNs", func() {
 		It("validates an unquoted string", func() {
 			testStrings := []string{`schemaname.tablename`}
@@ -156,4 +209,70 @@ var _ = Describe("backup/validate tests", func() {
 			backup.ValidateFQNs(testStrings)
 		})
 	})
+	Describe("ValidateTimestamp", func() {
+		It("accepts an empty timestamp", func() {
+			backup.ValidateTimestamp("")
+		})
+		It("accepts a timestamp in the format YYYYMMDDHHMMSS", func() {
+			backup.ValidateTimestamp("20170101010101")
+		})
+		It("panics if given a timestamp in the wrong format", func() {
+			defer testutils.ShouldPanicWithMessage("Timestamp 2017-01-01 01:01:01 is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.")
+			backup.ValidateTimestamp("2017-01-01 01:01:01")
+		})
+	})
+	Describe("ValidateStripDDLClauses", func() {
+		It("accepts non-empty clauses", func() {
+			backup.ValidateStripDDLClauses([]string{" WITH OIDS"})
+		})
+		It("panics if given an empty clause", func() {
+			defer testutils.ShouldPanicWithMessage("The clause given to --strip-ddl-clause cannot be empty")
+			backup.ValidateStripDDLClauses([]string{" WITH OIDS", ""})
+		})
+	})
+	Describe("ValidateEmailSubject", func() {
+		It("accepts the default subject template", func() {
+			backup.ValidateEmailSubject(utils.DefaultEmailSubjectTemplate)
+		})
+		It("panics if the subject contains a double quote", func() {
+			defer testutils.ShouldPanicWithMessage(`Email subject backup" done is invalid: it cannot contain double quotes, dollar signs, backticks, or backslashes`)
+			backup.ValidateEmailSubject(`backup" done`)
+		})
+		It("panics if the subject contains a command substitution", func() {
+			defer testutils.ShouldPanicWithMessage("Email subject backup $(whoami) is invalid")
+			backup.ValidateEmailSubject("backup $(whoami)")
+		})
+		It("panics if the subject contains a backtick", func() {
+			defer testutils.ShouldPanicWithMessage("Email subject backup `whoami` is invalid")
+			backup.ValidateEmailSubject("backup `whoami`")
+		})
+		It("panics if the subject contains a backslash", func() {
+			defer testutils.ShouldPanicWithMessage(`Email subject backup\ done is invalid`)
+			backup.ValidateEmailSubject(`backup\ done`)
+		})
+		It("panics if the subject is not a valid template", func() {
+			defer testutils.ShouldPanicWithMessage("Email subject gpbackup {{.Timestamp is invalid")
+			backup.ValidateEmailSubject("gpbackup {{.Timestamp")
+		})
+	})
+	Describe("ValidateTimestampIsUnused", func() {
+		cluster := utils.NewCluster([]utils.SegConfig{{ContentID: -1, Hostname: "localhost", DataDir: "/data/gpseg-1"}}, "", "20170101010101", "gpseg")
+		var statPath string
+		AfterEach(func() {
+			utils.System = utils.InitializeSystemFunctions()
+		})
+		It("does nothing if no backup with the timestamp exists", func() {
+			utils.System.Stat = func(name string) (os.FileInfo, error) {
+				statPath = name
+				return nil, errors.New("no such file or directory")
+			}
+			backup.ValidateTimestampIsUnused(cluster)
+			Expect(statPath).To(Equal("/data/gpseg-1/backups/20170101/20170101010101"))
+		})
+		It("panics if a backup with the timestamp already exists", func() {
+			utils.System.Stat = func(name string) (os.FileInfo, error) { return nil, nil }
+			defer testutils.ShouldPanicWithMessage("A backup with timestamp 20170101010101 already exists in /data/gpseg-1/backups/20170101/20170101010101")
+			backup.ValidateTimestampIsUnused(cluster)
+		})
+	})
 })
@@ -1,7 +1,11 @@
 package backup
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
 )
 
 /*
@@ -16,6 +20,11 @@ import (
  */
 
 func SetLoggerVerbosity() {
+	logger.SetPrefixSeparator(*logPrefixSeparator)
+	logger.SetFormat(*logFormat)
+	if *noColor {
+		logger.SetColor(false)
+	}
 	if *quiet {
 		logger.SetVerbosity(utils.LOGERROR)
 	} else if *debug {
@@ -23,6 +32,27 @@ func SetLoggerVerbosity() {
 	} else if *verbose {
 		logger.SetVerbosity(utils.LOGVERBOSE)
 	}
+	for _, setting := range componentLogLevels {
+		logger.SetComponentVerbosityFromString(setting)
+	}
+	if *linkCurrentLog {
+		if err := logger.LinkCurrentLogFile(); err != nil {
+			logger.Warn("Unable to create a current log link to %s: %s", logger.GetLogFilePath(), err.Error())
+		}
+	}
+}
+
+/*
+ * Scopes the package logger to the given component, so that the verbosity set
+ * for it with --component-log-level applies, until the returned function is
+ * called to restore the previous logger.
+ */
+func logComponent(component string) func() {
+	previousLogger := logger
+	logger = logger.WithComponent(component)
+	return func() {
+		logger = previousLogger
+	}
 }
 
 func InitializeConnection() {
@@ -32,30 +62,111 @@ func InitializeConnection() {
 	utils.CheckError(err)
 	connection.SetDatabaseVersion()
 	InitializeMetadataParams(connection)
+	if *restorePoint != "" {
+		restorePointLSN = CreateRestorePoint(*restorePoint)
+	}
 	connection.Begin()
 	_, err = connection.Exec("SET search_path TO pg_catalog")
 	utils.CheckError(err)
 }
 
+/*
+ * A named restore point marks the start of the backup in the WAL, so that the
+ * backup can be lined up with a point-in-time recovery of the cluster.  This
+ * returns the LSN of the restore point.
+ */
+func CreateRestorePoint(name string) string {
+	if connection.Version.Before("6") {
+		logger.Fatal(errors.New("Restore points are only supported in GPDB 6 and later"), "")
+	}
+	query := fmt.Sprintf("SELECT pg_create_restore_point('%s')::text AS string;", strings.Replace(name, "'", "''", -1))
+	lsn := SelectString(connection, query)
+	logger.Info("Created restore point %s at %s", name, lsn)
+	return lsn
+}
+
+func InitializeDependencyCache() {
+	if *dependencyCacheFile == "" {
+		return
+	}
+	catalogMarker := GetCatalogMarker(connection)
+	dependencyCache = ReadDependencyCache(*dependencyCacheFile, catalogMarker)
+}
+
+/*
+ * The pointer is only updated after a successful backup, so that it always
+ * refers to a complete backup.
+ */
+func UpdateLatestBackupPointer(errMsg string) {
+	if !*updateLatest || errMsg != "" {
+		return
+	}
+	err := globalCluster.WriteLatestBackupPointer()
+	if err != nil {
+		logger.Warn("Unable to update latest backup pointer %s: %s", globalCluster.GetLatestBackupPointerPath(), err.Error())
+		return
+	}
+	logger.Verbose("Updated latest backup pointer %s to refer to backup %s", globalCluster.GetLatestBackupPointerPath(), globalCluster.Timestamp)
+}
+
+/*
+ * This function is called between backup phases to report the free space
+ * remaining in the master backup directory, which it returns so that later
+ * phases may adapt to it.  A warning is logged the first time free space falls
+ * below the --free-space-threshold value.
+ */
+func CheckFreeSpace(phase string) uint64 {
+	freeBytes, err := globalCluster.GetMasterFreeSpace()
+	if err != nil {
+		logger.Verbose("Unable to determine free space in %s: %s", globalCluster.GetDirForContent(-1), err.Error())
+		return 0
+	}
+	freeMegabytes := freeBytes / (1024 * 1024)
+	logger.Verbose("%d MB free in %s after %s", freeMegabytes, globalCluster.GetDirForContent(-1), phase)
+	if *freeSpaceThreshold > 0 && freeMegabytes < uint64(*freeSpaceThreshold) && !lowSpaceWarned {
+		logger.Warn("Free space in %s has fallen to %d MB after %s, below the threshold of %d MB", globalCluster.GetDirForContent(-1), freeMegabytes, phase, *freeSpaceThreshold)
+		lowSpaceWarned = true
+	}
+	return freeBytes
+}
+
 func InitializeBackupReport() {
 	config := utils.BackupConfig{
 		DatabaseName:    connection.DBName,
 		DatabaseVersion: connection.Version.VersionString,
 		BackupVersion:   version,
+		BuildCommit:     buildCommit,
+		BuildDate:       buildDate,
+		Connection:      connection.ConnectionInfo(),
 	}
 	dbSize := ""
+	dbSizeBytes := uint64(0)
 	if !*metadataOnly {
 		dbSize = connection.GetDBSize()
+		dbSizeBytes = connection.GetDBSizeInBytes()
 	}
 
 	backupReport = &utils.Report{
-		DatabaseSize: dbSize,
-		BackupConfig: config,
+		DatabaseSize:      dbSize,
+		DatabaseSizeBytes: dbSizeBytes,
+		BackupConfig:      config,
 	}
 	utils.InitializeCompressionParameters(!*noCompression)
+	utils.SetMetadataCompression(*compressMetadata)
+	utils.SetMetadataBufferSize(*metadataBufferSize)
+	backupReport.RestorePoint = *restorePoint
+	backupReport.RestorePointLSN = restorePointLSN
+	backupReport.MetadataCompressed = *compressMetadata
+	backupReport.CommentsExcluded = *noComments
+	backupReport.CountObjectsBySchema = *schemaObjectCounts
+	backupReport.SortObjectCountsByCount = *sortObjectCountsByCount
+	if *objectCountLabelsFile != "" {
+		backupReport.ObjectCountLabels = utils.ReadObjectCountLabels(*objectCountLabelsFile)
+	}
 	isSchemaFiltered := len(includeSchemas) > 0 || len(excludeSchemas) > 0
 	isTableFiltered := len(includeTables) > 0 || len(excludeTables) > 0
 	backupReport.SetBackupTypeFromFlags(*dataOnly, *metadataOnly, *noCompression, isSchemaFiltered, isTableFiltered, *withStats)
+	backupReport.SetFilters(includeSchemas, excludeSchemas, includeTables, excludeTables)
 }
 
 func InitializeFilterLists() {
@@ -67,6 +178,45 @@ func InitializeFilterLists() {
 	}
 }
 
+/*
+ * Each clause given with --strip-ddl-clause is removed from every metadata
+ * statement as it is written, using the statement transform in utils so that
+ * TOC byte ranges match the statements actually written.
+ */
+func InitializeStatementTransform() {
+	if len(stripDDLClauses) == 0 {
+		utils.SetStatementTransform(nil)
+		return
+	}
+	clauses := stripDDLClauses
+	utils.SetStatementTransform(func(objectType string, name string, statement string) string {
+		for _, clause := range clauses {
+			statement = strings.Replace(statement, clause, "", -1)
+		}
+		return statement
+	})
+}
+
+/*
+ * This logs the filters recorded in the report, which reflect the contents of
+ * any table files, to make it easier to tell why a backup included or excluded
+ * what it did.
+ */
+func LogFilterConfiguration() {
+	formatFilter := func(filter []string) string {
+		if len(filter) == 0 {
+			return "none"
+		}
+		return strings.Join(filter, ",")
+	}
+	logger.WithFields(map[string]string{
+		"include-schema": formatFilter(backupReport.IncludeSchemas),
+		"exclude-schema": formatFilter(backupReport.ExcludeSchemas),
+		"include-table":  formatFilter(backupReport.IncludeTables),
+		"exclude-table":  formatFilter(backupReport.ExcludeTables),
+	}).Info("Backup filters")
+}
+
 /*
  * Metadata retrieval wrapper functions
  */
@@ -93,6 +243,8 @@ func RetrieveAndProcessTables() ([]Relation, []Relation, map[uint32]TableDefinit
 	partTableMap := GetPartitionTableMap(connection)
 	metadataTables, dataTables := SplitTablesByPartitionType(tables, partTableMap, userPassedIncludeTables)
 	objectCounts["Tables"] = len(metadataTables)
+	backupReport.AddObjectCountsBySchema("Tables", len(metadataTables), func(i int) string { return metadataTables[i].Schema })
+	RecordFeaturesUsedByTables(tableDefs)
 
 	return metadataTables, dataTables, tableDefs
 }
@@ -101,6 +253,7 @@ func RetrieveFunctions(objectCounts map[string]int, procLangs []ProceduralLangua
 	logger.Verbose("Retrieving function information")
 	functions := GetFunctions(connection)
 	objectCounts["Functions"] = len(functions)
+	backupReport.AddObjectCountsBySchema("Functions", len(functions), func(i int) string { return functions[i].Schema })
 	functionMetadata := GetMetadataForObjectType(connection, TYPE_FUNCTION)
 	functions = ConstructFunctionDependencies(connection, functions)
 	langFuncs, otherFuncs := ExtractLanguageFunctions(functions, procLangs)
@@ -108,9 +261,10 @@ func RetrieveFunctions(objectCounts map[string]int, procLangs []ProceduralLangua
 }
 
 func RetrieveTypes(objectCounts map[string]int) ([]Type, MetadataMap, map[uint32]FunctionInfo) {
+	defer logComponent("types")()
 	logger.Verbose("Retrieving type information")
 	shells := GetShellTypes(connection)
-	bases := GetBaseTypes(connection)
+	bases := CheckBaseTypeConsistency(GetBaseTypes(connection))
 	funcInfoMap := GetFunctionOidToInfoMap(connection)
 	if connection.Version.Before("5") {
 		bases = ConstructBaseTypeDependencies4(connection, bases, funcInfoMap)
@@ -125,6 +279,7 @@ func RetrieveTypes(objectCounts map[string]int) ([]Type, MetadataMap, map[uint32
 	domains = ConstructDomainDependencies(connection, domains)
 	types = append(types, domains...)
 	objectCounts["Types"] = len(types)
+	backupReport.AddObjectCountsBySchema("Types", len(types), func(i int) string { return types[i].Schema })
 	typeMetadata := GetMetadataForObjectType(connection, TYPE_TYPE)
 	return types, typeMetadata, funcInfoMap
 }
@@ -150,16 +305,73 @@ func BackupSessionGUCs(postdataFile *utils.FileWithByteCount) {
 	PrintSessionGUCs(postdataFile, globalTOC, gucs)
 }
 
+/*
+ * This is for errors encountered while generating DDL for a single object.  By
+ * default they are fatal, but in best-effort mode the object is logged, recorded
+ * in the report, and skipped so that the rest of the backup can complete.
+ */
+func HandleObjectError(objectType string, name string, err error) {
+	if !*bestEffort {
+		logger.Fatal(err, "Unable to generate DDL for %s %s", objectType, name)
+	}
+	skipObject(objectType, name, err)
+}
+
+func skipObject(objectType string, name string, err error) {
+	logger.Warn("Skipping %s %s: %s", objectType, name, err.Error())
+	backupReport.SkippedObjects = append(backupReport.SkippedObjects, fmt.Sprintf("%s %s", objectType, name))
+}
+
+/*
+ * These record GPDB-specific features used by the objects being backed up, to
+ * be listed in the report.  Every GPDB 5 cluster has the admin_group and
+ * default_group resource groups, so only additional groups are counted, and
+ * all non-system tablespaces are created in a filespace.
+ */
+func RecordFeaturesUsedByResourceGroups(resGroups []ResourceGroup) {
+	for _, resGroup := range resGroups {
+		if resGroup.Name != "admin_group" && resGroup.Name != "default_group" {
+			backupReport.AddFeatureUsed("resource_groups")
+			return
+		}
+	}
+}
+
+func RecordFeaturesUsedByRoles(roles []Role) {
+	for _, role := range roles {
+		if role.Createrexthdfs || role.Createwexthdfs {
+			backupReport.AddFeatureUsed("gphdfs_protocol")
+			return
+		}
+	}
+}
+
+func RecordFeaturesUsedByTables(tableDefs map[uint32]TableDefinition) {
+	for _, tableDef := range tableDefs {
+		if tableDef.IsExternal && strings.HasPrefix(tableDef.ExtTableDef.Location, "gphdfs://") {
+			backupReport.AddFeatureUsed("gphdfs_protocol")
+			return
+		}
+	}
+}
+
 /*
  * Global metadata wrapper functions
  */
 
-func BackupTablespaces(globalFile *utils.FileWithByteCount, objectCounts map[string]int) {
+func BackupTablespaces(globalFile *utils.FileWithByteCount, objectCounts map[string]int, tablespaces []Tablespace) {
 	logger.Verbose("Writing CREATE TABLESPACE statements to global file")
-	tablespaces := GetTablespaces(connection)
 	objectCounts["Tablespaces"] = len(tablespaces)
+	if len(tablespaces) > 0 {
+		backupReport.AddFeatureUsed("filespaces")
+	}
+	PrintCreateTablespaceStatements(globalFile, globalTOC, tablespaces)
+}
+
+func BackupTablespaceMetadata(globalFile *utils.FileWithByteCount, tablespaces []Tablespace) {
+	logger.Verbose("Writing tablespace metadata to global file")
 	tablespaceMetadata := GetMetadataForObjectType(connection, TYPE_TABLESPACE)
-	PrintCreateTablespaceStatements(globalFile, globalTOC, tablespaces, tablespaceMetadata)
+	PrintTablespaceMetadata(globalFile, globalTOC, tablespaces, tablespaceMetadata)
 }
 
 func BackupCreateDatabase(globalFile *utils.FileWithByteCount, objectCounts map[string]int) {
@@ -173,6 +385,10 @@ func BackupDatabaseGUCs(globalFile *utils.FileWithByteCount, objectCounts map[st
 	logger.Verbose("Writing database GUCs to global file")
 	databaseGucs := GetDatabaseGUCs(connection)
 	objectCounts["Database GUCs"] = len(databaseGucs)
+	if searchPath, ok := GetDatabaseSearchPath(databaseGucs); ok {
+		logger.Verbose("Database default search_path is %s", searchPath)
+		backupReport.DatabaseSearchPath = searchPath
+	}
 	PrintDatabaseGUCs(globalFile, globalTOC, databaseGucs, connection.DBName)
 }
 
@@ -188,6 +404,7 @@ func BackupResourceGroups(globalFile *utils.FileWithByteCount, objectCounts map[
 	logger.Verbose("Writing CREATE RESOURCE GROUP statements to global file")
 	resGroups := GetResourceGroups(connection)
 	objectCounts["Resource Groups"] = len(resGroups)
+	RecordFeaturesUsedByResourceGroups(resGroups)
 	resGroupMetadata := GetCommentsForObjectType(connection, TYPE_RESOURCEGROUP)
 	PrintCreateResourceGroupStatements(globalFile, globalTOC, resGroups, resGroupMetadata)
 }
@@ -196,6 +413,7 @@ func BackupRoles(globalFile *utils.FileWithByteCount, objectCounts map[string]in
 	logger.Verbose("Writing CREATE ROLE statements to global file")
 	roles := GetRoles(connection)
 	objectCounts["Roles"] = len(roles)
+	RecordFeaturesUsedByRoles(roles)
 	roleMetadata := GetCommentsForObjectType(connection, TYPE_ROLE)
 	PrintCreateRoleStatements(globalFile, globalTOC, roles, roleMetadata)
 }
@@ -203,6 +421,7 @@ func BackupRoles(globalFile *utils.FileWithByteCount, objectCounts map[string]in
 func BackupRoleGrants(globalFile *utils.FileWithByteCount, objectCounts map[string]int) {
 	logger.Verbose("Writing GRANT ROLE statements to global file")
 	roleMembers := GetRoleMembers(connection)
+	roleMembers = SortRoleMembers(roleMembers)
 	PrintRoleMembershipStatements(globalFile, globalTOC, roleMembers)
 }
 
@@ -242,6 +461,7 @@ func BackupEnumTypes(predataFile *utils.FileWithByteCount, objectCounts map[stri
 func BackupCreateSequences(predataFile *utils.FileWithByteCount, objectCounts map[string]int, sequences []Sequence, relationMetadata MetadataMap) {
 	logger.Verbose("Writing CREATE SEQUENCE statements to predata file")
 	objectCounts["Sequences"] = len(sequences)
+	backupReport.AddObjectCountsBySchema("Sequences", len(sequences), func(i int) string { return sequences[i].Relation.Schema })
 	PrintCreateSequenceStatements(predataFile, globalTOC, sequences, relationMetadata)
 }
 
@@ -286,6 +506,7 @@ func BackupTSParsers(predataFile *utils.FileWithByteCount, objectCounts map[stri
 	logger.Verbose("Writing CREATE TEXT SEARCH PARSER statements to predata file")
 	parsers := GetTextSearchParsers(connection)
 	objectCounts["Text Search Parsers"] = len(parsers)
+	backupReport.AddObjectCountsBySchema("Text Search Parsers", len(parsers), func(i int) string { return parsers[i].Schema })
 	parserMetadata := GetCommentsForObjectType(connection, TYPE_TSPARSER)
 	PrintCreateTextSearchParserStatements(predataFile, globalTOC, parsers, parserMetadata)
 }
@@ -294,6 +515,7 @@ func BackupTSTemplates(predataFile *utils.FileWithByteCount, objectCounts map[st
 	logger.Verbose("Writing CREATE TEXT SEARCH TEMPLATE statements to predata file")
 	templates := GetTextSearchTemplates(connection)
 	objectCounts["Text Search Templates"] = len(templates)
+	backupReport.AddObjectCountsBySchema("Text Search Templates", len(templates), func(i int) string { return templates[i].Schema })
 	templateMetadata := GetCommentsForObjectType(connection, TYPE_TSTEMPLATE)
 	PrintCreateTextSearchTemplateStatements(predataFile, globalTOC, templates, templateMetadata)
 }
@@ -302,6 +524,7 @@ func BackupTSDictionaries(predataFile *utils.FileWithByteCount, objectCounts map
 	logger.Verbose("Writing CREATE TEXT SEARCH DICTIONARY statements to predata file")
 	dictionaries := GetTextSearchDictionaries(connection)
 	objectCounts["Text Search Dictionaries"] = len(dictionaries)
+	backupReport.AddObjectCountsBySchema("Text Search Dictionaries", len(dictionaries), func(i int) string { return dictionaries[i].Schema })
 	dictionaryMetadata := GetMetadataForObjectType(connection, TYPE_TSDICTIONARY)
 	PrintCreateTextSearchDictionaryStatements(predataFile, globalTOC, dictionaries, dictionaryMetadata)
 }
@@ -310,6 +533,7 @@ func BackupTSConfigurations(predataFile *utils.FileWithByteCount, objectCounts m
 	logger.Verbose("Writing CREATE TEXT SEARCH CONFIGURATION statements to predata file")
 	configurations := GetTextSearchConfigurations(connection)
 	objectCounts["Text Search Configurations"] = len(configurations)
+	backupReport.AddObjectCountsBySchema("Text Search Configurations", len(configurations), func(i int) string { return configurations[i].Schema })
 	configurationMetadata := GetMetadataForObjectType(connection, TYPE_TSCONFIGURATION)
 	PrintCreateTextSearchConfigurationStatements(predataFile, globalTOC, configurations, configurationMetadata)
 }
@@ -318,6 +542,7 @@ func BackupConversions(predataFile *utils.FileWithByteCount, objectCounts map[st
 	logger.Verbose("Writing CREATE CONVERSION statements to predata file")
 	conversions := GetConversions(connection)
 	objectCounts["Conversions"] = len(conversions)
+	backupReport.AddObjectCountsBySchema("Conversions", len(conversions), func(i int) string { return conversions[i].Schema })
 	convMetadata := GetMetadataForObjectType(connection, TYPE_CONVERSION)
 	PrintCreateConversionStatements(predataFile, globalTOC, conversions, convMetadata)
 }
@@ -326,6 +551,7 @@ func BackupOperators(predataFile *utils.FileWithByteCount, objectCounts map[stri
 	logger.Verbose("Writing CREATE OPERATOR statements to predata file")
 	operators := GetOperators(connection)
 	objectCounts["Operators"] = len(operators)
+	backupReport.AddObjectCountsBySchema("Operators", len(operators), func(i int) string { return operators[i].Schema })
 	operatorMetadata := GetMetadataForObjectType(connection, TYPE_OPERATOR)
 	PrintCreateOperatorStatements(predataFile, globalTOC, operators, operatorMetadata)
 }
@@ -334,6 +560,7 @@ func BackupOperatorFamilies(predataFile *utils.FileWithByteCount, objectCounts m
 	logger.Verbose("Writing CREATE OPERATOR FAMILY statements to predata file")
 	operatorFamilies := GetOperatorFamilies(connection)
 	objectCounts["Operator Families"] = len(operatorFamilies)
+	backupReport.AddObjectCountsBySchema("Operator Families", len(operatorFamilies), func(i int) string { return operatorFamilies[i].Schema })
 	operatorFamilyMetadata := GetMetadataForObjectType(connection, TYPE_OPERATORFAMILY)
 	PrintCreateOperatorFamilyStatements(predataFile, globalTOC, operatorFamilies, operatorFamilyMetadata)
 }
@@ -342,6 +569,7 @@ func BackupOperatorClasses(predataFile *utils.FileWithByteCount, objectCounts ma
 	logger.Verbose("Writing CREATE OPERATOR CLASS statements to predata file")
 	operatorClasses := GetOperatorClasses(connection)
 	objectCounts["Operator Classes"] = len(operatorClasses)
+	backupReport.AddObjectCountsBySchema("Operator Classes", len(operatorClasses), func(i int) string { return operatorClasses[i].Schema })
 	operatorClassMetadata := GetMetadataForObjectType(connection, TYPE_OPERATORCLASS)
 	PrintCreateOperatorClassStatements(predataFile, globalTOC, operatorClasses, operatorClassMetadata)
 }
@@ -350,6 +578,7 @@ func BackupAggregates(predataFile *utils.FileWithByteCount, objectCounts map[str
 	logger.Verbose("Writing CREATE AGGREGATE statements to predata file")
 	aggregates := GetAggregates(connection)
 	objectCounts["Aggregates"] = len(aggregates)
+	backupReport.AddObjectCountsBySchema("Aggregates", len(aggregates), func(i int) string { return aggregates[i].Schema })
 	aggMetadata := GetMetadataForObjectType(connection, TYPE_AGGREGATE)
 	PrintCreateAggregateStatements(predataFile, globalTOC, aggregates, funcInfoMap, aggMetadata)
 }
@@ -366,6 +595,7 @@ func BackupViews(predataFile *utils.FileWithByteCount, objectCounts map[string]i
 	logger.Verbose("Writing CREATE VIEW statements to predata file")
 	views := GetViews(connection)
 	objectCounts["Views"] = len(views)
+	backupReport.AddObjectCountsBySchema("Views", len(views), func(i int) string { return views[i].Schema })
 	views = ConstructViewDependencies(connection, views)
 	views = SortViews(views)
 	PrintCreateViewStatements(predataFile, globalTOC, views, relationMetadata)
@@ -385,6 +615,7 @@ func BackupIndexes(postdataFile *utils.FileWithByteCount, objectCounts map[strin
 	indexNameMap := ConstructImplicitIndexNames(connection)
 	indexes := GetIndexes(connection, indexNameMap)
 	objectCounts["Indexes"] = len(indexes)
+	backupReport.AddObjectCountsBySchema("Indexes", len(indexes), func(i int) string { return indexes[i].OwningSchema })
 	indexMetadata := GetCommentsForObjectType(connection, TYPE_INDEX)
 	PrintCreateIndexStatements(postdataFile, globalTOC, indexes, indexMetadata)
 }
@@ -393,6 +624,7 @@ func BackupRules(postdataFile *utils.FileWithByteCount, objectCounts map[string]
 	logger.Verbose("Writing CREATE RULE statements to postdata file")
 	rules := GetRules(connection)
 	objectCounts["Rules"] = len(rules)
+	backupReport.AddObjectCountsBySchema("Rules", len(rules), func(i int) string { return rules[i].OwningSchema })
 	ruleMetadata := GetCommentsForObjectType(connection, TYPE_RULE)
 	PrintCreateRuleStatements(postdataFile, globalTOC, rules, ruleMetadata)
 }
@@ -401,10 +633,31 @@ func BackupTriggers(postdataFile *utils.FileWithByteCount, objectCounts map[stri
 	logger.Verbose("Writing CREATE TRIGGER statements to postdata file")
 	triggers := GetTriggers(connection)
 	objectCounts["Triggers"] = len(triggers)
+	backupReport.AddObjectCountsBySchema("Triggers", len(triggers), func(i int) string { return triggers[i].OwningSchema })
 	triggerMetadata := GetCommentsForObjectType(connection, TYPE_TRIGGER)
 	PrintCreateTriggerStatements(postdataFile, globalTOC, triggers, triggerMetadata)
 }
 
+func BackupDisableTriggers(predataFile *utils.FileWithByteCount, tables []Relation) {
+	logger.Verbose("Writing ALTER TABLE ... DISABLE TRIGGER ALL statements to predata file")
+	tablesWithTriggers := GetTablesWithTriggers(connection, tables)
+	PrintDisableTriggerStatements(predataFile, globalTOC, tablesWithTriggers)
+}
+
+func BackupEnableTriggers(postdataFile *utils.FileWithByteCount, tables []Relation) {
+	logger.Verbose("Writing ALTER TABLE ... ENABLE TRIGGER ALL statements to postdata file")
+	tablesWithTriggers := GetTablesWithTriggers(connection, tables)
+	PrintEnableTriggerStatements(postdataFile, globalTOC, tablesWithTriggers)
+}
+
+func BackupEventTriggers(postdataFile *utils.FileWithByteCount, objectCounts map[string]int) {
+	logger.Verbose("Writing CREATE EVENT TRIGGER statements to postdata file")
+	eventTriggers := GetEventTriggers(connection)
+	objectCounts["Event Triggers"] = len(eventTriggers)
+	eventTriggerMetadata := GetMetadataForObjectType(connection, TYPE_EVENTTRIGGER)
+	PrintCreateEventTriggerStatements(postdataFile, globalTOC, eventTriggers, eventTriggerMetadata)
+}
+
 /*
  * Data wrapper functions
  */
@@ -0,0 +1,174 @@
+package backup_test
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var _ = Describe("backup/wrappers tests", func() {
+	Describe("UpdateLatestBackupPointer", func() {
+		var symlinkArgs []string
+		BeforeEach(func() {
+			symlinkArgs = nil
+			utils.System.Remove = func(name string) error { return nil }
+			utils.System.Symlink = func(oldname string, newname string) error {
+				symlinkArgs = []string{oldname, newname}
+				return nil
+			}
+			backup.SetCluster(utils.NewCluster([]utils.SegConfig{{ContentID: -1, Hostname: "localhost", DataDir: "/data/gpseg-1"}}, "", "20170101010101", "gpseg"))
+			backup.SetUpdateLatest(true)
+		})
+		AfterEach(func() {
+			utils.System = utils.InitializeSystemFunctions()
+			backup.SetUpdateLatest(false)
+		})
+		It("points the latest pointer at the backup after a successful backup", func() {
+			backup.UpdateLatestBackupPointer("")
+			Expect(symlinkArgs).To(Equal([]string{"20170101/20170101010101", "/data/gpseg-1/backups/latest"}))
+		})
+		It("does not update the latest pointer after a failed backup", func() {
+			backup.UpdateLatestBackupPointer("Failed to execute statement")
+			Expect(symlinkArgs).To(BeNil())
+		})
+		It("does not update the latest pointer if the flag is not set", func() {
+			backup.SetUpdateLatest(false)
+			backup.UpdateLatestBackupPointer("")
+			Expect(symlinkArgs).To(BeNil())
+		})
+	})
+	Describe("CheckFreeSpace", func() {
+		megabyte := uint64(1024 * 1024)
+		var freeSpaceValues []uint64
+		BeforeEach(func() {
+			freeSpaceValues = []uint64{300 * megabyte, 150 * megabyte, 50 * megabyte, 20 * megabyte}
+			utils.System.FreeSpace = func(path string) (uint64, error) {
+				Expect(path).To(Equal("/data/gpseg-1/backups/20170101/20170101010101"))
+				freeBytes := freeSpaceValues[0]
+				freeSpaceValues = freeSpaceValues[1:]
+				return freeBytes, nil
+			}
+			backup.SetCluster(utils.NewCluster([]utils.SegConfig{{ContentID: -1, Hostname: "localhost", DataDir: "/data/gpseg-1"}}, "", "20170101010101", "gpseg"))
+		})
+		AfterEach(func() {
+			utils.System = utils.InitializeSystemFunctions()
+			backup.SetFreeSpaceThreshold(0)
+		})
+		It("returns the current free space after each phase", func() {
+			backup.SetFreeSpaceThreshold(0)
+			Expect(backup.CheckFreeSpace("metadata backup")).To(Equal(300 * megabyte))
+			Expect(backup.CheckFreeSpace("data backup")).To(Equal(150 * megabyte))
+		})
+		It("warns once when free space falls below the threshold", func() {
+			backup.SetFreeSpaceThreshold(100)
+			warningsBefore := strings.Count(string(stdout.Contents()), "below the threshold")
+			backup.CheckFreeSpace("metadata backup")
+			backup.CheckFreeSpace("data backup")
+			Expect(strings.Count(string(stdout.Contents()), "below the threshold")).To(Equal(warningsBefore))
+			backup.CheckFreeSpace("statistics backup")
+			Expect(string(stdout.Contents())).To(ContainSubstring("has fallen to 50 MB after statistics backup, below the threshold of 100 MB"))
+			backup.CheckFreeSpace("statistics backup")
+			Expect(strings.Count(string(stdout.Contents()), "below the threshold")).To(Equal(warningsBefore + 1))
+		})
+		It("does not warn if no threshold is set", func() {
+			backup.SetFreeSpaceThreshold(0)
+			warningsBefore := strings.Count(string(stdout.Contents()), "below the threshold")
+			for range freeSpaceValues {
+				backup.CheckFreeSpace("data backup")
+			}
+			Expect(strings.Count(string(stdout.Contents()), "below the threshold")).To(Equal(warningsBefore))
+		})
+		It("returns 0 if free space cannot be determined", func() {
+			backup.SetFreeSpaceThreshold(100)
+			utils.System.FreeSpace = func(path string) (uint64, error) { return 0, errors.New("no such file or directory") }
+			Expect(backup.CheckFreeSpace("data backup")).To(Equal(uint64(0)))
+		})
+	})
+	Describe("LogFilterConfiguration", func() {
+		It("logs the filters recorded in the report", func() {
+			report := &utils.Report{}
+			report.SetFilters([]string{"public", "sales"}, []string{}, []string{}, []string{"public.foo", "public.bar"})
+			backup.SetReport(report)
+			backup.LogFilterConfiguration()
+			Expect(string(logfile.Contents())).To(ContainSubstring("exclude-schema=none exclude-table=public.foo,public.bar include-schema=public,sales include-table=none Backup filters"))
+		})
+	})
+	Describe("InitializeStatementTransform", func() {
+		AfterEach(func() {
+			backup.SetStripDDLClauses(nil)
+			utils.SetStatementTransform(nil)
+		})
+		It("removes each clause given with --strip-ddl-clause from metadata statements", func() {
+			backup.SetStripDDLClauses([]string{" WITH OIDS", " DEFERRABLE"})
+			backup.InitializeStatementTransform()
+			toc, backupfile = testutils.InitializeTestTOC(buffer, "predata")
+			backupfile.MustPrintf("CREATE TABLE public.foo (i int) WITH OIDS DEFERRABLE;\n")
+			toc.AddMetadataEntry("public", "foo", "TABLE", 0, backupfile)
+			Expect(string(buffer.Contents())).To(Equal("CREATE TABLE public.foo (i int);\n"))
+		})
+		It("writes statements unchanged if no clauses are given", func() {
+			backup.InitializeStatementTransform()
+			toc, backupfile = testutils.InitializeTestTOC(buffer, "predata")
+			backupfile.MustPrintf("CREATE TABLE public.foo (i int) WITH OIDS;\n")
+			Expect(string(buffer.Contents())).To(Equal("CREATE TABLE public.foo (i int) WITH OIDS;\n"))
+		})
+	})
+	Describe("CreateRestorePoint", func() {
+		It("creates a restore point and returns its LSN", func() {
+			testutils.SetDBVersion(connection, "6.0.0")
+			lsnRow := sqlmock.NewRows([]string{"string"}).AddRow("0/16B3748")
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_create_restore_point('backup''s point')::text AS string;")).WillReturnRows(lsnRow)
+			lsn := backup.CreateRestorePoint("backup's point")
+			Expect(lsn).To(Equal("0/16B3748"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("panics before GPDB 6", func() {
+			testutils.SetDBVersion(connection, "5.1.0")
+			defer testutils.ShouldPanicWithMessage("Restore points are only supported in GPDB 6 and later")
+			backup.CreateRestorePoint("backup_point")
+		})
+	})
+	Describe("RecordFeaturesUsedBy*", func() {
+		var report *utils.Report
+		BeforeEach(func() {
+			report = &utils.Report{}
+			backup.SetReport(report)
+		})
+		It("lists resource groups if a user-defined resource group exists", func() {
+			backup.RecordFeaturesUsedByResourceGroups([]backup.ResourceGroup{{Name: "admin_group"}, {Name: "default_group"}, {Name: "group1"}})
+			Expect(report.FeaturesUsed).To(Equal([]string{"resource_groups"}))
+		})
+		It("does not list resource groups if only the default resource groups exist", func() {
+			backup.RecordFeaturesUsedByResourceGroups([]backup.ResourceGroup{{Name: "admin_group"}, {Name: "default_group"}})
+			Expect(report.FeaturesUsed).To(BeEmpty())
+		})
+		It("lists the gphdfs protocol if a role can create gphdfs external tables", func() {
+			backup.RecordFeaturesUsedByRoles([]backup.Role{{Name: "role1"}, {Name: "role2", Createwexthdfs: true}})
+			Expect(report.FeaturesUsed).To(Equal([]string{"gphdfs_protocol"}))
+		})
+		It("lists the gphdfs protocol once if both a role and an external table use it", func() {
+			backup.RecordFeaturesUsedByRoles([]backup.Role{{Name: "role1", Createrexthdfs: true}})
+			tableDefs := map[uint32]backup.TableDefinition{
+				1: {IsExternal: true, ExtTableDef: backup.ExternalTableDefinition{Location: "gphdfs://example.com/data"}},
+			}
+			backup.RecordFeaturesUsedByTables(tableDefs)
+			Expect(report.FeaturesUsed).To(Equal([]string{"gphdfs_protocol"}))
+		})
+		It("does not list any features for tables that do not use them", func() {
+			tableDefs := map[uint32]backup.TableDefinition{
+				1: {IsExternal: false},
+				2: {IsExternal: true, ExtTableDef: backup.ExternalTableDefinition{Location: "gpfdist://example.com/data"}},
+			}
+			backup.RecordFeaturesUsedByTables(tableDefs)
+			Expect(report.FeaturesUsed).To(BeEmpty())
+		})
+	})
+})
@@ -59,6 +59,12 @@ var _ = BeforeEach(func() {
 	backup.SetIncludeSchemas([]string{})
 	backup.SetExcludeTables([]string{})
 	backup.SetIncludeTables([]string{})
+	backup.SetNoComments(false)
+	backup.SetDisableTriggersOnRestore(false)
+	backup.SetExcludeDefaultResourceGroups(false)
+	backup.SetRevokeRoleMemberships(false)
+	backup.SetSingleTransactionMetadata(false)
+	backup.SetStrictTypeChecks(false)
 })
 
 var _ = AfterSuite(func() {
@@ -14,6 +14,47 @@ var _ = Describe("backup integration create statement tests", func() {
 	BeforeEach(func() {
 		toc, backupfile = testutils.InitializeTestTOC(buffer, "predata")
 	})
+	Describe("PrintCreateDatabaseStatement", func() {
+		It("creates a database with a comment", func() {
+			db := backup.Database{Oid: 1, Name: "create_test_db", Tablespace: "pg_default"}
+			dbMetadataMap := testutils.DefaultMetadataMap("DATABASE", false, false, true)
+			dbMetadata := dbMetadataMap[1]
+
+			backup.PrintCreateDatabaseStatement(backupfile, toc, db, dbMetadataMap)
+
+			// CREATE DATABASE statements can not be part of a multi-command statement, so
+			// feed the CREATE DATABASE and COMMENT ON statements separately.
+			hunks := regexp.MustCompile(";\n\n").Split(buffer.String(), 2)
+			testutils.AssertQueryRuns(connection, hunks[0])
+			defer testutils.AssertQueryRuns(connection, "DROP DATABASE create_test_db")
+			testutils.AssertQueryRuns(connection, hunks[1])
+
+			resultMetadataMap := backup.GetMetadataForObjectType(connection, backup.TYPE_DATABASE)
+			oid := testutils.OidFromObjectName(connection, "", "create_test_db", backup.TYPE_DATABASE)
+			resultMetadata := resultMetadataMap[oid]
+			Expect(resultMetadata.Comment).To(Equal(dbMetadata.Comment))
+		})
+	})
+	Describe("PrintDatabaseGUCs", func() {
+		It("restores a multi-schema database default search_path", func() {
+			testutils.AssertQueryRuns(connection, `CREATE SCHEMA "My Schema"`)
+			defer testutils.AssertQueryRuns(connection, `DROP SCHEMA "My Schema"`)
+			testutils.AssertQueryRuns(connection, `ALTER DATABASE testdb SET search_path TO "My Schema", public, pg_catalog`)
+			defer testutils.AssertQueryRuns(connection, "ALTER DATABASE testdb RESET search_path")
+			gucs := backup.GetDatabaseGUCs(connection)
+			searchPath, ok := backup.GetDatabaseSearchPath(gucs)
+			Expect(ok).To(BeTrue())
+			testutils.AssertQueryRuns(connection, "ALTER DATABASE testdb RESET search_path")
+
+			backup.PrintDatabaseGUCs(backupfile, toc, gucs, "testdb")
+			Expect(buffer.String()).To(ContainSubstring(`ALTER DATABASE testdb SET search_path TO 'My Schema', 'public', 'pg_catalog';`))
+			testutils.AssertQueryRuns(connection, buffer.String())
+
+			resultSearchPath, ok := backup.GetDatabaseSearchPath(backup.GetDatabaseGUCs(connection))
+			Expect(ok).To(BeTrue())
+			Expect(resultSearchPath).To(Equal(searchPath))
+		})
+	})
 	Describe("PrintCreateResourceQueueStatements", func() {
 		It("creates a basic resource queue with a comment", func() {
 			basicQueue := backup.ResourceQueue{Oid: 1, Name: `"basicQueue"`, ActiveStatements: -1, MaxCost: "32.80", CostOvercommit: false, MinCost: "0.00", Priority: "medium", MemoryLimit: "-1"}
@@ -203,6 +244,38 @@ var _ = Describe("backup integration create statement tests", func() {
 			}
 			Fail("Role 'role1' was not found")
 		})
+		It("creates a role with a time constraint spanning Saturday night into Sunday", func() {
+			role1 := backup.Role{
+				Oid:             0,
+				Name:            "role1",
+				Inherit:         true,
+				ConnectionLimit: -1,
+				ResQueue:        "pg_default",
+				TimeConstraints: []backup.TimeConstraint{{StartDay: 6, StartTime: "22:00:00", EndDay: 0, EndTime: "02:00:00"}},
+			}
+			emptyMetadataMap := backup.MetadataMap{}
+
+			backup.PrintCreateRoleStatements(backupfile, toc, []backup.Role{role1}, emptyMetadataMap)
+
+			testutils.AssertQueryRuns(connection, buffer.String())
+			defer testutils.AssertQueryRuns(connection, `DROP ROLE "role1"`)
+
+			resultRoles := backup.GetRoles(connection)
+			for _, role := range resultRoles {
+				if role.Name == "role1" {
+					Expect(role.TimeConstraints).To(HaveLen(2))
+					for i := range role.TimeConstraints {
+						role.TimeConstraints[i].Oid = 0
+					}
+					Expect(role.TimeConstraints).To(ConsistOf(
+						backup.TimeConstraint{StartDay: 6, StartTime: "22:00:00", EndDay: 6, EndTime: "24:00:00"},
+						backup.TimeConstraint{StartDay: 0, StartTime: "00:00:00", EndDay: 0, EndTime: "02:00:00"},
+					))
+					return
+				}
+			}
+			Fail("Role 'role1' was not found")
+		})
 	})
 	Describe("PrintRoleMembershipStatements", func() {
 		BeforeEach(func() {
@@ -252,8 +325,7 @@ var _ = Describe("backup integration create statement tests", func() {
 		expectedTablespace := backup.Tablespace{Oid: 1, Tablespace: "test_tablespace", Filespace: "test_filespace"}
 		It("creates a basic tablespace", func() {
 			numTablespaces := len(backup.GetTablespaces(connection))
-			emptyMetadataMap := backup.MetadataMap{}
-			backup.PrintCreateTablespaceStatements(backupfile, toc, []backup.Tablespace{expectedTablespace}, emptyMetadataMap)
+			backup.PrintCreateTablespaceStatements(backupfile, toc, []backup.Tablespace{expectedTablespace})
 
 			testutils.AssertQueryRuns(connection, buffer.String())
 			defer testutils.AssertQueryRuns(connection, "DROP TABLESPACE test_tablespace")
@@ -272,7 +344,8 @@ var _ = Describe("backup integration create statement tests", func() {
 			numTablespaces := len(backup.GetTablespaces(connection))
 			tablespaceMetadataMap := testutils.DefaultMetadataMap("TABLESPACE", true, true, true)
 			tablespaceMetadata := tablespaceMetadataMap[1]
-			backup.PrintCreateTablespaceStatements(backupfile, toc, []backup.Tablespace{expectedTablespace}, tablespaceMetadataMap)
+			backup.PrintCreateTablespaceStatements(backupfile, toc, []backup.Tablespace{expectedTablespace})
+			backup.PrintTablespaceMetadata(backupfile, toc, []backup.Tablespace{expectedTablespace}, tablespaceMetadataMap)
 
 			testutils.AssertQueryRuns(connection, buffer.String())
 			defer testutils.AssertQueryRuns(connection, "DROP TABLESPACE test_tablespace")
@@ -3,6 +3,7 @@ package integration
 import (
 	"github.com/greenplum-db/gpbackup/backup"
 	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/greenplum-db/gpbackup/utils"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -28,10 +29,31 @@ var _ = Describe("backup integration tests", func() {
 			defer testutils.AssertQueryRuns(connection, "ALTER DATABASE testdb SET search_path TO pg_catalog,public")
 			testutils.AssertQueryRuns(connection, "ALTER DATABASE testdb SET lc_time TO 'C'")
 			results := backup.GetDatabaseGUCs(connection)
-			Expect(len(results)).To(Equal(3))
-			Expect(results[0]).To(Equal(`SET default_with_oids TO "true"`))
-			Expect(results[1]).To(Equal("SET search_path TO public, pg_catalog"))
-			Expect(results[2]).To(Equal(`SET lc_time TO "C"`))
+			Expect(results).To(Equal([]backup.GUC{
+				{Name: "default_with_oids", Value: "true"},
+				{Name: "search_path", Value: "public, pg_catalog"},
+				{Name: "lc_time", Value: "C"},
+			}))
+		})
+		It("returns a database level default_tablespace GUC", func() {
+			testutils.AssertQueryRuns(connection, "CREATE TABLESPACE test_tablespace FILESPACE test_filespace")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLESPACE test_tablespace")
+			testutils.AssertQueryRuns(connection, "ALTER DATABASE testdb SET default_tablespace TO test_tablespace")
+			defer testutils.AssertQueryRuns(connection, "ALTER DATABASE testdb RESET default_tablespace")
+			results := backup.GetDatabaseGUCs(connection)
+			Expect(results).To(ContainElement(backup.GUC{Name: "default_tablespace", Value: "test_tablespace"}))
+		})
+		It("returns a database level search_path GUC with quoted identifiers", func() {
+			testutils.AssertQueryRuns(connection, `ALTER DATABASE testdb SET search_path TO "My Schema", public`)
+			defer testutils.AssertQueryRuns(connection, "ALTER DATABASE testdb SET search_path TO pg_catalog,public")
+			results := backup.GetDatabaseGUCs(connection)
+			Expect(results).To(ContainElement(backup.GUC{Name: "search_path", Value: `"My Schema", public`}))
+		})
+		It("returns a database level GUC set to an empty string", func() {
+			testutils.AssertQueryRuns(connection, "ALTER DATABASE testdb SET default_tablespace TO ''")
+			defer testutils.AssertQueryRuns(connection, "ALTER DATABASE testdb RESET default_tablespace")
+			results := backup.GetDatabaseGUCs(connection)
+			Expect(results).To(ContainElement(backup.GUC{Name: "default_tablespace", Value: ""}))
 		})
 	})
 	Describe("GetDatabaseNames", func() {
@@ -41,8 +63,56 @@ var _ = Describe("backup integration tests", func() {
 
 			result := backup.GetDatabaseName(connection)
 
-			testdbExpected := backup.Database{Oid: 0, Name: "testdb", Tablespace: "pg_default"}
-			testutils.ExpectStructsToMatchExcluding(&testdbExpected, &result, "Oid")
+			testdbExpected := backup.Database{Oid: 0, Name: "testdb", Tablespace: "pg_default", DefaultTablespace: "pg_default"}
+			testutils.ExpectStructsToMatchExcluding(&testdbExpected, &result, "Oid", "Collate", "CType")
+		})
+		It("returns a database name struct for a database created in a non-default tablespace", func() {
+			testutils.AssertQueryRuns(connection, "CREATE TABLESPACE test_tablespace FILESPACE test_filespace")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLESPACE test_tablespace")
+			testutils.AssertQueryRuns(connection, "CREATE DATABASE tablespace_db TABLESPACE test_tablespace")
+			defer testutils.AssertQueryRuns(connection, "DROP DATABASE tablespace_db")
+			tablespaceConn := *connection
+			tablespaceConn.DBName = "tablespace_db"
+
+			result := backup.GetDatabaseName(&tablespaceConn)
+
+			tablespaceExpected := backup.Database{Oid: 0, Name: "tablespace_db", Tablespace: "test_tablespace", DefaultTablespace: "pg_default"}
+			testutils.ExpectStructsToMatchExcluding(&tablespaceExpected, &result, "Oid", "Collate", "CType")
+		})
+		It("returns the current tablespace for a database moved to a non-default tablespace", func() {
+			testutils.AssertQueryRuns(connection, "CREATE TABLESPACE test_tablespace FILESPACE test_filespace")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLESPACE test_tablespace")
+			testutils.AssertQueryRuns(connection, "CREATE DATABASE moved_db")
+			defer testutils.AssertQueryRuns(connection, "DROP DATABASE moved_db")
+			testutils.AssertQueryRuns(connection, "ALTER DATABASE moved_db SET TABLESPACE test_tablespace")
+			movedConn := utils.NewDBConn("moved_db")
+			movedConn.Connect()
+			defer movedConn.Close()
+			movedConn.SetDatabaseVersion()
+			testutils.AssertQueryRuns(movedConn, "CREATE TABLE public.default_table(i int)")
+			testutils.AssertQueryRuns(movedConn, "CREATE TABLE public.pg_default_table(i int) TABLESPACE pg_default")
+
+			result := backup.GetDatabaseName(movedConn)
+			tablespaceNames := backup.GetTablespaceNames(movedConn)
+
+			movedExpected := backup.Database{Oid: 0, Name: "moved_db", Tablespace: "test_tablespace", DefaultTablespace: "pg_default"}
+			testutils.ExpectStructsToMatchExcluding(&movedExpected, &result, "Oid", "Collate", "CType")
+			defaultOid := testutils.OidFromObjectName(movedConn, "public", "default_table", backup.TYPE_RELATION)
+			pgDefaultOid := testutils.OidFromObjectName(movedConn, "public", "pg_default_table", backup.TYPE_RELATION)
+			Expect(tablespaceNames).ToNot(HaveKey(defaultOid))
+			Expect(tablespaceNames[pgDefaultOid]).To(Equal("pg_default"))
+		})
+		It("returns a database name struct with collation settings", func() {
+			testutils.SkipIfBefore6(connection)
+			testutils.AssertQueryRuns(connection, "CREATE DATABASE collation_db TEMPLATE template0 LC_COLLATE 'C' LC_CTYPE 'C'")
+			defer testutils.AssertQueryRuns(connection, "DROP DATABASE collation_db")
+			collationConn := *connection
+			collationConn.DBName = "collation_db"
+
+			result := backup.GetDatabaseName(&collationConn)
+
+			collationExpected := backup.Database{Oid: 0, Name: "collation_db", Tablespace: "pg_default", DefaultTablespace: "pg_default", Collate: "C", CType: "C"}
+			testutils.ExpectStructsToMatchExcluding(&collationExpected, &result, "Oid")
 		})
 	})
 	Describe("GetResourceQueues", func() {
@@ -157,6 +227,21 @@ var _ = Describe("backup integration tests", func() {
 			}
 			Fail("Role 'role1' was not found")
 		})
+		It("returns a role with the REPLICATION attribute", func() {
+			testutils.SkipIfBefore6(connection)
+			testutils.AssertQueryRuns(connection, "CREATE ROLE role1 REPLICATION")
+			defer testutils.AssertQueryRuns(connection, "DROP ROLE role1")
+
+			results := backup.GetRoles(connection)
+
+			for _, role := range results {
+				if role.Name == "role1" {
+					Expect(role.Replication).To(BeTrue())
+					return
+				}
+			}
+			Fail("Role 'role1' was not found")
+		})
 		It("returns a role with all properties specified", func() {
 			testutils.AssertQueryRuns(connection, "CREATE ROLE role1")
 			defer testutils.AssertQueryRuns(connection, "DROP ROLE role1")
@@ -223,6 +308,24 @@ CREATEEXTTABLE (protocol='gphdfs', type='writable')`)
 			Fail("Role 'role1' was not found")
 		})
 	})
+	Describe("GetRoleGUCs", func() {
+		It("returns a role's settings sorted by name in every backup", func() {
+			testutils.AssertQueryRuns(connection, "CREATE ROLE role1")
+			defer testutils.AssertQueryRuns(connection, "DROP ROLE role1")
+			testutils.AssertQueryRuns(connection, "ALTER ROLE role1 SET work_mem TO '256MB'")
+			testutils.AssertQueryRuns(connection, "ALTER ROLE role1 SET search_path TO public, pg_catalog")
+			testutils.AssertQueryRuns(connection, "ALTER ROLE role1 SET client_min_messages TO 'error'")
+			roleOid := testutils.OidFromObjectName(connection, "", "role1", backup.TYPE_ROLE)
+			expectedGUCs := []backup.GUC{{Name: "client_min_messages", Value: "error"}, {Name: "search_path", Value: "public, pg_catalog"}, {Name: "work_mem", Value: "256MB"}}
+
+			firstRun := backup.GetRoleGUCs(connection)
+			testutils.AssertQueryRuns(connection, "ALTER ROLE role1 SET search_path TO public, pg_catalog")
+			secondRun := backup.GetRoleGUCs(connection)
+
+			Expect(firstRun[roleOid]).To(Equal(expectedGUCs))
+			Expect(secondRun[roleOid]).To(Equal(expectedGUCs))
+		})
+	})
 	Describe("GetRoleMembers", func() {
 		BeforeEach(func() {
 			testutils.AssertQueryRuns(connection, `CREATE ROLE usergroup`)
@@ -152,4 +152,24 @@ var _ = Describe("backup integration create statement tests", func() {
 			testutils.ExpectStructsToMatch(&resultMetadata, &triggerMetadata)
 		})
 	})
+	Describe("PrintDisableTriggerStatements and PrintEnableTriggerStatements", func() {
+		It("suppresses a table's triggers while its data is loaded", func() {
+			testutils.AssertQueryRuns(connection, "CREATE FUNCTION public.reject_insert() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN RAISE EXCEPTION 'insert rejected'; END; $$")
+			defer testutils.AssertQueryRuns(connection, "DROP FUNCTION public.reject_insert()")
+			testutils.AssertQueryRuns(connection, "CREATE TABLE testtable(i int)")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLE testtable")
+			testutils.AssertQueryRuns(connection, "CREATE TRIGGER reject_testtable BEFORE INSERT ON testtable FOR EACH STATEMENT EXECUTE PROCEDURE public.reject_insert()")
+			tables := []backup.Relation{backup.BasicRelation("public", "testtable")}
+
+			backup.PrintDisableTriggerStatements(backupfile, toc, tables)
+			testutils.AssertQueryRuns(connection, buffer.String())
+			testutils.AssertQueryRuns(connection, "INSERT INTO testtable VALUES (1)")
+
+			buffer.Reset()
+			backup.PrintEnableTriggerStatements(backupfile, toc, tables)
+			testutils.AssertQueryRuns(connection, buffer.String())
+			_, err := connection.Exec("INSERT INTO testtable VALUES (2)")
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
@@ -243,4 +243,55 @@ PARTITION BY RANGE (date)
 			testutils.ExpectStructsToMatchExcluding(&trigger1, &results[0], "Oid")
 		})
 	})
+	Describe("GetTablesWithTriggers", func() {
+		It("returns only the given tables that have constraint triggers", func() {
+			testutils.AssertQueryRuns(connection, "CREATE TABLE trigger_table1(i int PRIMARY KEY)")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLE trigger_table1")
+			testutils.AssertQueryRuns(connection, "CREATE TABLE trigger_table2(j int)")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLE trigger_table2")
+			testutils.AssertQueryRuns(connection, "ALTER TABLE trigger_table2 ADD CONSTRAINT fkc FOREIGN KEY (j) REFERENCES trigger_table1 (i) ON UPDATE RESTRICT ON DELETE RESTRICT")
+			testutils.AssertQueryRuns(connection, "CREATE TABLE user_trigger_table(k int)")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLE user_trigger_table")
+			testutils.AssertQueryRuns(connection, "CREATE TRIGGER sync_user_trigger_table AFTER INSERT OR DELETE OR UPDATE ON user_trigger_table FOR EACH STATEMENT EXECUTE PROCEDURE flatfile_update_trigger()")
+			defer testutils.AssertQueryRuns(connection, "DROP TRIGGER sync_user_trigger_table ON user_trigger_table")
+			tables := make([]backup.Relation, 0)
+			for _, table := range backup.GetAllUserTables(connection) {
+				if table.Name != "trigger_table1" {
+					tables = append(tables, table)
+				}
+			}
+
+			results := backup.GetTablesWithTriggers(connection, tables)
+
+			Expect(len(results)).To(Equal(1))
+			Expect(results[0].ToString()).To(Equal("public.trigger_table2"))
+		})
+	})
+	Describe("GetEventTriggers", func() {
+		BeforeEach(func() {
+			testutils.SkipIfBefore6(connection)
+			testutils.AssertQueryRuns(connection, "CREATE FUNCTION public.abort_any_command() RETURNS event_trigger LANGUAGE plpgsql AS $$ BEGIN RAISE EXCEPTION 'command % is disabled', tg_tag; END; $$")
+		})
+		AfterEach(func() {
+			testutils.AssertQueryRuns(connection, "DROP FUNCTION public.abort_any_command()")
+		})
+		It("returns a slice of enabled and disabled event triggers", func() {
+			testutils.AssertQueryRuns(connection, "CREATE EVENT TRIGGER enabled_trigger ON ddl_command_start EXECUTE PROCEDURE public.abort_any_command()")
+			defer testutils.AssertQueryRuns(connection, "DROP EVENT TRIGGER enabled_trigger")
+			testutils.AssertQueryRuns(connection, "ALTER EVENT TRIGGER enabled_trigger DISABLE")
+			testutils.AssertQueryRuns(connection, "ALTER EVENT TRIGGER enabled_trigger ENABLE")
+			testutils.AssertQueryRuns(connection, "CREATE EVENT TRIGGER disabled_trigger ON ddl_command_start WHEN TAG IN ('CREATE TABLE', 'DROP TABLE') EXECUTE PROCEDURE public.abort_any_command()")
+			defer testutils.AssertQueryRuns(connection, "DROP EVENT TRIGGER disabled_trigger")
+			testutils.AssertQueryRuns(connection, "ALTER EVENT TRIGGER disabled_trigger DISABLE")
+
+			disabledTrigger := backup.EventTrigger{Oid: 0, Name: "disabled_trigger", Event: "ddl_command_start", FunctionName: "public.abort_any_command", Enabled: "D", EventTags: "'CREATE TABLE', 'DROP TABLE'"}
+			enabledTrigger := backup.EventTrigger{Oid: 0, Name: "enabled_trigger", Event: "ddl_command_start", FunctionName: "public.abort_any_command", Enabled: "O", EventTags: ""}
+
+			results := backup.GetEventTriggers(connection)
+
+			Expect(len(results)).To(Equal(2))
+			testutils.ExpectStructsToMatchExcluding(&disabledTrigger, &results[0], "Oid")
+			testutils.ExpectStructsToMatchExcluding(&enabledTrigger, &results[1], "Oid")
+		})
+	})
 })
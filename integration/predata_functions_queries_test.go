@@ -319,6 +319,22 @@ LANGUAGE SQL`)
 
 			castDef := backup.Cast{Oid: 0, SourceTypeFQN: "testschema1.casttesttype1", TargetTypeFQN: "testschema2.casttesttype2", FunctionSchema: "", FunctionName: "", FunctionArgs: "", CastContext: "i"}
 
+			Expect(len(results)).To(Equal(1))
+			testutils.ExpectStructsToMatchExcluding(&castDef, &results[0], "Oid")
+		})
+		It("returns a slice for an assignment cast with a function between two user-defined types", func() {
+			testutils.SkipIf4(connection)
+			testutils.AssertQueryRuns(connection, "CREATE TYPE public.casttesttype1 AS (t text)")
+			defer testutils.AssertQueryRuns(connection, "DROP TYPE public.casttesttype1 CASCADE")
+			testutils.AssertQueryRuns(connection, "CREATE TYPE public.casttesttype2 AS (t text)")
+			defer testutils.AssertQueryRuns(connection, "DROP TYPE public.casttesttype2 CASCADE")
+			testutils.AssertQueryRuns(connection, "CREATE FUNCTION public.casttesttype1_to_2(public.casttesttype1) RETURNS public.casttesttype2 STRICT IMMUTABLE LANGUAGE SQL AS 'SELECT ROW($1.t)::public.casttesttype2;'")
+			testutils.AssertQueryRuns(connection, "CREATE CAST (public.casttesttype1 AS public.casttesttype2) WITH FUNCTION public.casttesttype1_to_2(public.casttesttype1) AS ASSIGNMENT")
+
+			results := backup.GetCasts(connection)
+
+			castDef := backup.Cast{Oid: 0, SourceTypeFQN: "public.casttesttype1", TargetTypeFQN: "public.casttesttype2", FunctionSchema: "public", FunctionName: "casttesttype1_to_2", FunctionArgs: "casttesttype1", CastContext: "a"}
+
 			Expect(len(results)).To(Equal(1))
 			testutils.ExpectStructsToMatchExcluding(&castDef, &results[0], "Oid")
 		})
@@ -3,6 +3,7 @@ package integration
 import (
 	"github.com/greenplum-db/gpbackup/backup"
 	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/greenplum-db/gpbackup/utils"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -55,6 +56,28 @@ var _ = Describe("backup integration tests", func() {
 
 		})
 	})
+	Describe("GetCatalogMarker", func() {
+		It("changes when an older transaction renames an object after newer catalog rows are committed", func() {
+			testutils.AssertQueryRuns(connection, "CREATE TABLE public.xid_table(i int)")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLE public.xid_table")
+			testutils.AssertQueryRuns(connection, "CREATE TABLE public.marker_table(i int)")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLE public.renamed_marker_table")
+			olderConn := utils.NewDBConn("testdb")
+			olderConn.Connect()
+			defer olderConn.Close()
+			olderConn.Begin()
+			// Writing to a user table gives the transaction an xid without changing the catalog
+			testutils.AssertQueryRuns(olderConn, "INSERT INTO public.xid_table VALUES (1)")
+			testutils.AssertQueryRuns(connection, "CREATE TABLE public.newer_table(i int)")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLE public.newer_table")
+			markerBefore := backup.GetCatalogMarker(connection)
+
+			testutils.AssertQueryRuns(olderConn, "ALTER TABLE public.marker_table RENAME TO renamed_marker_table")
+			olderConn.Commit()
+
+			Expect(backup.GetCatalogMarker(connection)).ToNot(Equal(markerBefore))
+		})
+	})
 	Describe("GetConstraints", func() {
 		var (
 			uniqueConstraint         = backup.Constraint{Oid: 0, Name: "uniq2", ConType: "u", ConDef: "UNIQUE (a, b)", OwningObject: "public.constraints_table", IsDomainConstraint: false, IsPartitionParent: false}
@@ -408,6 +431,42 @@ LANGUAGE SQL`)
 				resultMetadata := resultMetadataMap[oid]
 				testutils.ExpectStructsToMatchExcluding(&expectedMetadata, &resultMetadata, "Oid")
 			})
+			It("returns a slice of metadata for an enum type with an owner and privileges", func() {
+				testutils.SkipIfBefore6(connection)
+				testutils.AssertQueryRuns(connection, `CREATE TYPE enum_type AS ENUM ('bar', 'baz')`)
+				defer testutils.AssertQueryRuns(connection, "DROP TYPE enum_type")
+				testutils.AssertQueryRuns(connection, "ALTER TYPE enum_type OWNER TO anothertestrole")
+				testutils.AssertQueryRuns(connection, "REVOKE ALL ON TYPE enum_type FROM PUBLIC")
+				testutils.AssertQueryRuns(connection, "GRANT ALL ON TYPE enum_type TO testrole")
+
+				resultMetadataMap := backup.GetMetadataForObjectType(connection, backup.TYPE_TYPE)
+
+				oid := testutils.OidFromObjectName(connection, "", "enum_type", backup.TYPE_TYPE)
+				expectedMetadata := backup.ObjectMetadata{Owner: "anothertestrole", Privileges: []backup.ACL{
+					testutils.DefaultACLForType("anothertestrole", "TYPE"),
+					testutils.DefaultACLForType("testrole", "TYPE"),
+				}}
+				resultMetadata := resultMetadataMap[oid]
+				testutils.ExpectStructsToMatchExcluding(&expectedMetadata, &resultMetadata, "Oid")
+			})
+			It("returns a slice of metadata for a domain with an owner and privileges", func() {
+				testutils.SkipIfBefore6(connection)
+				testutils.AssertQueryRuns(connection, `CREATE DOMAIN domain_type AS numeric`)
+				defer testutils.AssertQueryRuns(connection, "DROP TYPE domain_type")
+				testutils.AssertQueryRuns(connection, "ALTER DOMAIN domain_type OWNER TO anothertestrole")
+				testutils.AssertQueryRuns(connection, "REVOKE ALL ON DOMAIN domain_type FROM PUBLIC")
+				testutils.AssertQueryRuns(connection, "GRANT ALL ON DOMAIN domain_type TO testrole")
+
+				resultMetadataMap := backup.GetMetadataForObjectType(connection, backup.TYPE_TYPE)
+
+				oid := testutils.OidFromObjectName(connection, "", "domain_type", backup.TYPE_TYPE)
+				expectedMetadata := backup.ObjectMetadata{Owner: "anothertestrole", Privileges: []backup.ACL{
+					testutils.DefaultACLForType("anothertestrole", "DOMAIN"),
+					testutils.DefaultACLForType("testrole", "DOMAIN"),
+				}}
+				resultMetadata := resultMetadataMap[oid]
+				testutils.ExpectStructsToMatchExcluding(&expectedMetadata, &resultMetadata, "Oid")
+			})
 			It("returns a slice of default metadata for an external protocol", func() {
 				testutils.AssertQueryRuns(connection, `CREATE OR REPLACE FUNCTION read_from_s3() RETURNS integer AS '$libdir/gps3ext.so', 's3_import' LANGUAGE C STABLE;`)
 				defer testutils.AssertQueryRuns(connection, "DROP FUNCTION read_from_s3()")
@@ -23,12 +23,12 @@ var _ = Describe("backup integration tests", func() {
 		BeforeEach(func() {
 			shellType = backup.Type{Type: "p", Schema: "public", Name: "shell_type"}
 			baseTypeDefault = backup.Type{
-				Oid: 1, Type: "b", Schema: "public", Name: "base_type", Input: "base_fn_in", Output: "base_fn_out", Receive: "",
+				Oid: 1, Type: "b", Schema: "public", Name: "base_type", Input: "public.base_fn_in", Output: "public.base_fn_out", Receive: "",
 				Send: "", ModIn: "", ModOut: "", InternalLength: -1, IsPassedByValue: false, Alignment: "i", Storage: "p",
 				DefaultVal: "", Element: "", Delimiter: ",",
 			}
 			baseTypeCustom = backup.Type{
-				Oid: 1, Type: "b", Schema: "public", Name: "base_type", Input: "base_fn_in", Output: "base_fn_out", Receive: "",
+				Oid: 1, Type: "b", Schema: "public", Name: "base_type", Input: "public.base_fn_in", Output: "public.base_fn_out", Receive: "",
 				Send: "", ModIn: "", ModOut: "", InternalLength: 8, IsPassedByValue: true, Alignment: "c", Storage: "p",
 				DefaultVal: "0", Element: "integer", Delimiter: ";",
 			}
@@ -58,6 +58,29 @@ var _ = Describe("backup integration tests", func() {
 			Expect(len(results)).To(Equal(1))
 			testutils.ExpectStructsToMatchIncluding(&compositeType, &results[0], "Type", "Schema", "Name")
 		})
+		It("does not return dropped attributes of a composite type", func() {
+			testutils.SkipIfBefore6(connection)
+			testutils.AssertQueryRuns(connection, "CREATE TYPE composite_type AS (name int4, dropped text, name2 int, name1 text);")
+			defer testutils.AssertQueryRuns(connection, "DROP TYPE composite_type")
+			testutils.AssertQueryRuns(connection, "ALTER TYPE composite_type DROP ATTRIBUTE dropped")
+
+			results := backup.GetCompositeTypes(connection)
+
+			Expect(len(results)).To(Equal(1))
+			testutils.ExpectStructsToMatchIncluding(&compositeType, &results[0], "Type", "Schema", "Name", "Attributes")
+		})
+		It("returns a composite type whose only attribute was dropped with no attributes", func() {
+			testutils.SkipIfBefore6(connection)
+			testutils.AssertQueryRuns(connection, "CREATE TYPE composite_type AS (dropped text);")
+			defer testutils.AssertQueryRuns(connection, "DROP TYPE composite_type")
+			testutils.AssertQueryRuns(connection, "ALTER TYPE composite_type DROP ATTRIBUTE dropped")
+
+			results := backup.GetCompositeTypes(connection)
+
+			Expect(len(results)).To(Equal(1))
+			Expect(results[0].Name).To(Equal("composite_type"))
+			Expect(results[0].Attributes).To(BeEmpty())
+		})
 		It("returns a slice for a base type with default values", func() {
 			testutils.AssertQueryRuns(connection, "CREATE TYPE base_type")
 			defer testutils.AssertQueryRuns(connection, "DROP TYPE base_type CASCADE")
@@ -90,6 +113,62 @@ var _ = Describe("backup integration tests", func() {
 				testutils.ExpectStructsToMatchExcluding(&results[0], &baseTypeCustom, "Oid")
 			}
 		})
+		It("returns schema-qualified I/O functions for a base type whose functions are in another schema", func() {
+			testutils.AssertQueryRuns(connection, "CREATE SCHEMA testschema")
+			defer testutils.AssertQueryRuns(connection, "DROP SCHEMA testschema CASCADE")
+			testutils.AssertQueryRuns(connection, "CREATE TYPE base_type")
+			defer testutils.AssertQueryRuns(connection, "DROP TYPE base_type CASCADE")
+			testutils.AssertQueryRuns(connection, "CREATE FUNCTION testschema.base_fn_in(cstring) RETURNS base_type AS 'boolin' LANGUAGE internal")
+			testutils.AssertQueryRuns(connection, "CREATE FUNCTION testschema.base_fn_out(base_type) RETURNS cstring AS 'boolout' LANGUAGE internal")
+			testutils.AssertQueryRuns(connection, "CREATE FUNCTION testschema.base_fn_recv(internal) RETURNS base_type AS 'boolrecv' LANGUAGE internal")
+			testutils.AssertQueryRuns(connection, "CREATE FUNCTION testschema.base_fn_send(base_type) RETURNS bytea AS 'boolsend' LANGUAGE internal")
+			testutils.AssertQueryRuns(connection, "CREATE TYPE base_type(INPUT=testschema.base_fn_in, OUTPUT=testschema.base_fn_out, RECEIVE=testschema.base_fn_recv, SEND=testschema.base_fn_send)")
+
+			results := backup.GetBaseTypes(connection)
+
+			Expect(len(results)).To(Equal(1))
+			Expect(results[0].Input).To(Equal("testschema.base_fn_in"))
+			Expect(results[0].Output).To(Equal("testschema.base_fn_out"))
+			Expect(results[0].Receive).To(Equal("testschema.base_fn_recv"))
+			Expect(results[0].Send).To(Equal("testschema.base_fn_send"))
+		})
+		It("returns base and composite types under the schema they were moved to", func() {
+			testutils.AssertQueryRuns(connection, "CREATE SCHEMA testschema")
+			defer testutils.AssertQueryRuns(connection, "DROP SCHEMA testschema CASCADE")
+			testutils.AssertQueryRuns(connection, "CREATE TYPE base_type")
+			testutils.AssertQueryRuns(connection, "CREATE FUNCTION base_fn_in(cstring) RETURNS base_type AS 'boolin' LANGUAGE internal")
+			testutils.AssertQueryRuns(connection, "CREATE FUNCTION base_fn_out(base_type) RETURNS cstring AS 'boolout' LANGUAGE internal")
+			testutils.AssertQueryRuns(connection, "CREATE TYPE base_type(INPUT=base_fn_in, OUTPUT=base_fn_out)")
+			testutils.AssertQueryRuns(connection, "CREATE TYPE composite_type AS (base base_type, builtin integer)")
+			testutils.AssertQueryRuns(connection, "ALTER TYPE base_type SET SCHEMA testschema")
+			testutils.AssertQueryRuns(connection, "ALTER TYPE composite_type SET SCHEMA testschema")
+			backup.SetIncludeSchemas([]string{"testschema"})
+
+			baseTypes := backup.GetBaseTypes(connection)
+			composites := backup.GetCompositeTypes(connection)
+
+			Expect(len(baseTypes)).To(Equal(1))
+			Expect(baseTypes[0].Schema).To(Equal("testschema"))
+			Expect(baseTypes[0].Name).To(Equal("base_type"))
+			Expect(len(composites)).To(Equal(1))
+			Expect(composites[0].Schema).To(Equal("testschema"))
+			Expect(composites[0].Name).To(Equal("composite_type"))
+		})
+		It("returns the array type name for a base type whose default array type name was taken", func() {
+			testutils.SkipIfBefore5(connection)
+			testutils.AssertQueryRuns(connection, "CREATE TYPE _base_type AS (i int)")
+			defer testutils.AssertQueryRuns(connection, "DROP TYPE _base_type")
+			testutils.AssertQueryRuns(connection, "CREATE TYPE base_type")
+			defer testutils.AssertQueryRuns(connection, "DROP TYPE base_type CASCADE")
+			testutils.AssertQueryRuns(connection, "CREATE FUNCTION base_fn_in(cstring) RETURNS base_type AS 'boolin' LANGUAGE internal")
+			testutils.AssertQueryRuns(connection, "CREATE FUNCTION base_fn_out(base_type) RETURNS cstring AS 'boolout' LANGUAGE internal")
+			testutils.AssertQueryRuns(connection, "CREATE TYPE base_type(INPUT=base_fn_in, OUTPUT=base_fn_out)")
+
+			results := backup.GetBaseTypes(connection)
+
+			Expect(len(results)).To(Equal(1))
+			Expect(results[0].ArrayType).To(Equal("__base_type"))
+		})
 		It("returns a slice for an enum type", func() {
 			testutils.SkipIf4(connection)
 			testutils.AssertQueryRuns(connection, "CREATE TYPE enum_type AS ENUM ('label1','label2','label3')")
@@ -100,6 +179,18 @@ var _ = Describe("backup integration tests", func() {
 			Expect(len(results)).To(Equal(1))
 			testutils.ExpectStructsToMatchExcluding(&results[0], &enumType, "Oid")
 		})
+		It("returns a schema-qualified slice for an enum type in a non-public schema", func() {
+			testutils.SkipIf4(connection)
+			testutils.AssertQueryRuns(connection, `CREATE SCHEMA "testSchema"`)
+			defer testutils.AssertQueryRuns(connection, `DROP SCHEMA "testSchema" CASCADE`)
+			testutils.AssertQueryRuns(connection, `CREATE TYPE "testSchema".enum_type AS ENUM ('label1','label2','label3')`)
+
+			results := backup.GetEnumTypes(connection)
+
+			Expect(len(results)).To(Equal(1))
+			Expect(results[0].Schema).To(Equal(`"testSchema"`))
+			Expect(results[0].Name).To(Equal("enum_type"))
+		})
 		It("does not return types for sequences or views", func() {
 			testutils.AssertQueryRuns(connection, "CREATE SEQUENCE my_sequence START 10")
 			defer testutils.AssertQueryRuns(connection, "DROP SEQUENCE my_sequence")
@@ -110,6 +201,18 @@ var _ = Describe("backup integration tests", func() {
 
 			Expect(len(results)).To(Equal(0))
 		})
+		It("does not return the implicit types of tables with storage options", func() {
+			testutils.AssertQueryRuns(connection, "CREATE TABLE heap_with_options(i int) WITH (fillfactor=50)")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLE heap_with_options")
+			testutils.AssertQueryRuns(connection, "CREATE TABLE ao_with_options(i int) WITH (appendonly=true, compresslevel=5)")
+			defer testutils.AssertQueryRuns(connection, "DROP TABLE ao_with_options")
+
+			bases := backup.GetBaseTypes(connection)
+			composites := backup.GetCompositeTypes(connection)
+
+			Expect(len(bases)).To(Equal(0))
+			Expect(len(composites)).To(Equal(0))
+		})
 		It("does not return implicit base or composite types for tables with length > NAMEDATALEN", func() {
 			testutils.AssertQueryRuns(connection, "CREATE TABLE looooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooong(i int)")
 			// The table's name will be truncated to 63 characters upon creation, as will the names of its implicit types
@@ -133,6 +236,23 @@ var _ = Describe("backup integration tests", func() {
 			Expect(len(results)).To(Equal(1))
 			testutils.ExpectStructsToMatchIncluding(&results[0], &domainType, "Schema", "Name", "Type", "DefaultVal", "BaseType", "NotNull")
 		})
+		It("returns a slice for a domain type whose default calls nextval on a sequence in another schema", func() {
+			testutils.AssertQueryRuns(connection, "CREATE SCHEMA testschema")
+			defer testutils.AssertQueryRuns(connection, "DROP SCHEMA testschema")
+			testutils.AssertQueryRuns(connection, "CREATE SEQUENCE testschema.domain_seq")
+			defer testutils.AssertQueryRuns(connection, "DROP SEQUENCE testschema.domain_seq")
+			testutils.AssertQueryRuns(connection, "SET search_path TO testschema, public")
+			testutils.AssertQueryRuns(connection, "CREATE DOMAIN public.domain1 AS integer DEFAULT nextval('domain_seq')")
+			defer testutils.AssertQueryRuns(connection, "DROP DOMAIN public.domain1")
+			testutils.AssertQueryRuns(connection, "SET search_path TO pg_catalog")
+			defer testutils.AssertQueryRuns(connection, "RESET search_path")
+
+			results := backup.GetDomainTypes(connection)
+
+			domainType := backup.Type{Type: "d", Schema: "public", Name: "domain1", DefaultVal: "nextval('testschema.domain_seq'::regclass)", BaseType: "int4"}
+			Expect(len(results)).To(Equal(1))
+			testutils.ExpectStructsToMatchIncluding(&domainType, &results[0], "Schema", "Name", "Type", "DefaultVal", "BaseType", "NotNull")
+		})
 		It("returns a slice for a type in a specific schema", func() {
 			testutils.AssertQueryRuns(connection, "CREATE TYPE shell_type")
 			defer testutils.AssertQueryRuns(connection, "DROP TYPE shell_type")
@@ -196,6 +316,24 @@ var _ = Describe("backup integration tests", func() {
 			Expect(len(compTypes[0].DependsUpon)).To(Equal(1))
 			Expect(compTypes[0].DependsUpon[0]).To(Equal("public.base_type"))
 		})
+		It("constructs dependencies correctly for a composite type moved to a different schema than its dependency", func() {
+			testutils.AssertQueryRuns(connection, "CREATE SCHEMA testschema")
+			defer testutils.AssertQueryRuns(connection, "DROP SCHEMA testschema")
+			testutils.AssertQueryRuns(connection, "CREATE TYPE comp_type AS (base base_type, builtin integer)")
+			testutils.AssertQueryRuns(connection, "ALTER TYPE comp_type SET SCHEMA testschema")
+			defer testutils.AssertQueryRuns(connection, "DROP TYPE testschema.comp_type")
+			backup.SetIncludeSchemas([]string{"testschema"})
+
+			composites := backup.GetCompositeTypes(connection)
+			Expect(len(composites)).To(Equal(1))
+			Expect(composites[0].Schema).To(Equal("testschema"))
+			compTypes := backup.ConstructCompositeTypeDependencies(connection, composites)
+
+			Expect(len(compTypes)).To(Equal(1))
+			Expect(compTypes[0].Schema).To(Equal("testschema"))
+			Expect(len(compTypes[0].DependsUpon)).To(Equal(1))
+			Expect(compTypes[0].DependsUpon[0]).To(Equal("public.base_type"))
+		})
 	})
 	Describe("ConstructBaseTypeDependencies4", func() {
 		funcInfoMap := map[uint32]backup.FunctionInfo{}
@@ -284,6 +422,26 @@ var _ = Describe("backup integration tests", func() {
 			Expect(len(domains[0].DependsUpon)).To(Equal(1))
 			Expect(domains[0].DependsUpon[0]).To(Equal("public.parent_domain"))
 		})
+		It("constructs dependencies correctly for a domain moved to a different schema than its base type", func() {
+			testutils.AssertQueryRuns(connection, "CREATE SCHEMA testschema")
+			defer testutils.AssertQueryRuns(connection, "DROP SCHEMA testschema")
+			testutils.AssertQueryRuns(connection, "CREATE DOMAIN parent_domain AS integer")
+			defer testutils.AssertQueryRuns(connection, "DROP DOMAIN parent_domain")
+			testutils.AssertQueryRuns(connection, "CREATE DOMAIN domain_type AS parent_domain")
+			testutils.AssertQueryRuns(connection, "ALTER DOMAIN domain_type SET SCHEMA testschema")
+			defer testutils.AssertQueryRuns(connection, "DROP DOMAIN testschema.domain_type")
+			backup.SetIncludeSchemas([]string{"testschema"})
+
+			domains := backup.GetDomainTypes(connection)
+			Expect(len(domains)).To(Equal(1))
+			Expect(domains[0].Schema).To(Equal("testschema"))
+			domains = backup.ConstructDomainDependencies(connection, domains)
+
+			Expect(len(domains)).To(Equal(1))
+			Expect(domains[0].Schema).To(Equal("testschema"))
+			Expect(len(domains[0].DependsUpon)).To(Equal(1))
+			Expect(domains[0].DependsUpon[0]).To(Equal("public.parent_domain"))
+		})
 		It("doesn't construct dependencies on built-in types", func() {
 			testutils.AssertQueryRuns(connection, "CREATE DOMAIN parent_domain AS integer")
 			defer testutils.AssertQueryRuns(connection, "DROP DOMAIN parent_domain")
@@ -36,22 +36,22 @@ var _ = Describe("backup integration tests", func() {
 			 * certain table should always be the same in a particular version given
 			 * the same schema and data.
 			 */
-			expectedStats4I := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "i",
+			expectedStats4I := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "i", TypeSchema: "pg_catalog",
 				Type: "int4", Relid: tableOid, AttNumber: 1, Width: 4, Distinct: -1, Kind1: 1, Kind2: 0, Operator1: 96,
 				Operator2: 0, Numbers1: []string{"0.5", "0.5"}, Values1: []string{"1", "2"}}
-			expectedStats4J := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "j",
+			expectedStats4J := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "j", TypeSchema: "pg_catalog",
 				Type: "text", Relid: tableOid, AttNumber: 2, Width: 2, Distinct: -1, Kind1: 1, Kind2: 0, Operator1: 98,
 				Operator2: 0, Numbers1: []string{"0.5", "0.5"}, Values1: []string{"a", "b"}}
-			expectedStats4K := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "k",
+			expectedStats4K := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "k", TypeSchema: "pg_catalog",
 				Type: "bool", Relid: tableOid, AttNumber: 3, Width: 1, Distinct: 2, Kind1: 1, Kind2: 0, Operator1: 91,
 				Operator2: 0, Numbers1: []string{"0.5", "0.5"}, Values1: []string{"f", "t"}}
-			expectedStats5I := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "i",
+			expectedStats5I := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "i", TypeSchema: "pg_catalog",
 				Type: "int4", Relid: tableOid, AttNumber: 1, Width: 4, Distinct: -1, Kind1: 2, Kind2: 3, Operator1: 97,
 				Operator2: 97, Numbers2: []string{"1"}, Values1: []string{"1", "2"}}
-			expectedStats5J := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "j",
+			expectedStats5J := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "j", TypeSchema: "pg_catalog",
 				Type: "text", Relid: tableOid, AttNumber: 2, Width: 2, Distinct: -1, Kind1: 2, Kind2: 3, Operator1: 664,
 				Operator2: 664, Numbers2: []string{"1"}, Values1: []string{"a", "b"}}
-			expectedStats5K := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "k",
+			expectedStats5K := backup.AttributeStatistic{Oid: tableOid, Schema: "public", Table: "foo", AttName: "k", TypeSchema: "pg_catalog",
 				Type: "bool", Relid: tableOid, AttNumber: 3, Width: 1, Distinct: -1, Kind1: 2, Kind2: 3, Operator1: 58,
 				Operator2: 58, Numbers2: []string{"-1"}, Values1: []string{"f", "t"}}
 
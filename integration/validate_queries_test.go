@@ -0,0 +1,50 @@
+package integration
+
+import (
+	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/testutils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backup integration tests", func() {
+	Describe("GetInaccessibleObjects", func() {
+		BeforeEach(func() {
+			testutils.AssertQueryRuns(connection, "CREATE ROLE limitedrole")
+			testutils.AssertQueryRuns(connection, "CREATE SCHEMA privateschema")
+			testutils.AssertQueryRuns(connection, "CREATE TABLE privateschema.foo(i int)")
+			testutils.AssertQueryRuns(connection, "CREATE TABLE public.privatetable(i int)")
+			testutils.AssertQueryRuns(connection, "REVOKE ALL ON TABLE public.privatetable FROM PUBLIC")
+		})
+		AfterEach(func() {
+			testutils.AssertQueryRuns(connection, "SET ROLE testrole")
+			testutils.AssertQueryRuns(connection, "DROP TABLE public.privatetable")
+			testutils.AssertQueryRuns(connection, "DROP SCHEMA privateschema CASCADE")
+			testutils.AssertQueryRuns(connection, "DROP ROLE limitedrole")
+		})
+		It("returns nothing for a superuser", func() {
+			results := backup.GetInaccessibleObjects(connection, true)
+
+			Expect(results).To(BeEmpty())
+		})
+		It("returns the catalog tables, schemas, and tables that a role lacking schema access cannot read", func() {
+			testutils.AssertQueryRuns(connection, "SET ROLE limitedrole")
+
+			results := backup.GetInaccessibleObjects(connection, true)
+
+			Expect(results).To(ContainElement("catalog table pg_catalog.pg_authid (SELECT)"))
+			Expect(results).To(ContainElement("schema privateschema (USAGE)"))
+			Expect(results).To(ContainElement("table public.privatetable (SELECT)"))
+			Expect(results).ToNot(ContainElement("table privateschema.foo (SELECT)"))
+		})
+		It("only checks the schemas being backed up", func() {
+			backup.SetIncludeSchemas([]string{"public"})
+			testutils.AssertQueryRuns(connection, "SET ROLE limitedrole")
+
+			results := backup.GetInaccessibleObjects(connection, false)
+
+			Expect(results).To(BeEmpty())
+		})
+	})
+})
@@ -17,6 +17,7 @@ var (
 	globalCluster utils.Cluster
 	globalTOC     *utils.TOC
 	logger        *utils.Logger
+	parallelJobs  = 1
 	version       string
 )
 
@@ -25,27 +26,54 @@ var (
  */
 
 var (
-	backupDir      *string
-	createdb       *bool
-	debug          *bool
-	numJobs        *int
-	printVersion   *bool
-	quiet          *bool
-	redirect       *string
-	restoreGlobals *bool
-	timestamp      *string
-	verbose        *bool
-	withStats      *bool
+	backupDir          *string
+	componentLogLevels utils.ArrayFlags
+	createdb           *bool
+	debug              *bool
+	forceCrossVersion  *bool
+	linkCurrentLog     *bool
+	logFormat          *string
+	logPrefixSeparator *string
+	maxConnections     *int
+	noColor            *bool
+	numJobs            *int
+	printVersion       *bool
+	quiet              *bool
+	redirect           *string
+	restoreGlobals     *bool
+	syslogOnly         *bool
+	syslogServer       *string
+	timestamp          *string
+	useSyslog          *bool
+	validateDDL        *bool
+	verbose            *bool
+	withStats          *bool
 )
 
 /*
  * Setter functions
  */
 
+func SetBackupConfig(config *utils.BackupConfig) {
+	backupConfig = config
+}
+
+func SetConnection(conn *utils.DBConn) {
+	connection = conn
+}
+
+func SetMaxConnections(max int) {
+	maxConnections = &max
+}
+
 func SetLogger(log *utils.Logger) {
 	logger = log
 }
 
+func SetRedirect(name string) {
+	redirect = &name
+}
+
 func SetTOC(toc *utils.TOC) {
 	globalTOC = toc
 }
@@ -19,39 +19,54 @@ import (
  */
 func initializeFlags() {
 	backupDir = flag.String("backupdir", "", "The absolute path of the directory in which the backup files to be restored are located")
+	flag.Var(&componentLogLevels, "component-log-level", "Set the log level of one component of the restore, as component=level, e.g. data=debug; components are globals, predata, data, postdata, and statistics. --component-log-level can be specified multiple times.")
 	createdb = flag.Bool("createdb", false, "Create the database before metadata restore")
 	debug = flag.Bool("debug", false, "Print verbose and debug log messages")
+	forceCrossVersion = flag.Bool("force-cross-version", false, "Attempt to restore a backup taken from a newer major version of GPDB, which may partially fail due to catalog incompatibilities")
 	numJobs = flag.Int("jobs", 1, "Number of parallel connections to use when restoring table data and post-data metadata")
+	linkCurrentLog = flag.Bool("link-current-log", false, "Point a symlink named gprestore_current.log in the log directory at the log file for this run")
+	logFormat = flag.String("log-format", "text", "The format of log lines, either text or json for one JSON object per line")
+	logPrefixSeparator = flag.String("log-prefix-separator", ":", "The separator to use between fields of the prefix of each log line")
+	maxConnections = flag.Int("max-connections", 0, "The maximum number of connections to use for a parallel restore, overriding --jobs if lower; by default, the number of connections the database can accept less a safety margin")
+	noColor = flag.Bool("no-color", false, "Do not color the level of warning and error messages printed to a terminal")
 	printVersion = flag.Bool("version", false, "Print version number and exit")
 	quiet = flag.Bool("quiet", false, "Suppress non-warning, non-error log messages")
 	redirect = flag.String("redirect", "", "Restore to the specified database instead of the database that was backed up")
 	restoreGlobals = flag.Bool("globals", false, "Restore global metadata")
+	useSyslog = flag.Bool("syslog", false, "Also write log messages to syslog")
+	syslogOnly = flag.Bool("syslog-only", false, "Write log messages to syslog instead of to a log file; implies --syslog")
+	syslogServer = flag.String("syslog-server", "", "The host:port of a remote syslog server to which to send log messages over UDP, instead of the local syslog server; implies --syslog")
 	timestamp = flag.String("timestamp", "", "The timestamp to be restored, in the format YYYYMMDDHHMMSS")
+	validateDDL = flag.Bool("validate-ddl", false, "Check that metadata statements in the backup execute without error, rolling them back instead of restoring anything")
 	verbose = flag.Bool("verbose", false, "Print verbose log messages")
 	withStats = flag.Bool("with-stats", false, "Restore query plan statistics")
 }
 
-// This function handles setup that can be done before parsing flags.
+/*
+ * This function parses flags, which must be done before the logger is created
+ * so that the syslog flags can be applied to it.
+ */
 func DoInit() {
-	SetLogger(utils.InitializeLogging("gprestore", ""))
 	initializeFlags()
+	if len(os.Args) == 1 {
+		flag.PrintDefaults()
+		os.Exit(0)
+	}
+	flag.Parse()
+	SetLogger(utils.InitializeLoggingFromFlags("gprestore", *useSyslog, *syslogServer, *syslogOnly))
 }
 
 /*
-* This function handles argument parsing and validation, e.g. checking that a passed filename exists.
+* This function handles argument validation, e.g. checking that a passed filename exists.
 * It should only validate; initialization with any sort of side effects should go in DoInit or DoSetup.
  */
 func DoValidation() {
-	if len(os.Args) == 1 {
-		flag.PrintDefaults()
-		os.Exit(0)
-	}
-	flag.Parse()
 	if *printVersion {
 		fmt.Printf("gprestore %s\n", version)
 		os.Exit(0)
 	}
 	ValidateFlagCombinations()
+	utils.ValidateLogPrefixSeparator(*logPrefixSeparator)
 	utils.ValidateBackupDir(*backupDir)
 	if !utils.IsValidTimestamp(*timestamp) {
 		logger.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.", *timestamp), "")
@@ -84,6 +99,10 @@ func DoRestore() {
 	globalTOC = utils.NewTOC(tocFilename)
 	globalTOC.InitializeEntryMapFromCluster(globalCluster)
 	setSerialRestore()
+	if *validateDDL {
+		validateMetadata()
+		return
+	}
 	if *restoreGlobals {
 		restoreGlobal()
 	} else if *createdb {
@@ -122,26 +141,22 @@ func createDatabase() {
 	objectTypes := []string{"SESSION GUCS", "GPDB4 SESSION GUCS", "DATABASE GUC", "DATABASE", "DATABASE METADATA"}
 	globalFilename := globalCluster.GetGlobalFilePath()
 	logger.Info("Creating database")
-	statements := GetRestoreMetadataStatements(globalFilename, objectTypes...)
-	if *redirect != "" {
-		statements = utils.SubstituteRedirectDatabaseInStatements(statements, backupConfig.DatabaseName, *redirect)
-	}
+	statements := RedirectGlobalStatements(GetRestoreMetadataStatements(globalFilename, objectTypes...))
 	ExecuteRestoreMetadataStatements(statements, 1, false)
 	logger.Info("Database creation complete")
 }
 
 func restoreGlobal() {
+	defer logComponent("globals")()
 	globalFilename := globalCluster.GetGlobalFilePath()
 	logger.Info("Restoring global database metadata from %s", globalCluster.GetGlobalFilePath())
-	statements := GetRestoreMetadataStatements(globalFilename)
-	if *redirect != "" {
-		statements = utils.SubstituteRedirectDatabaseInStatements(statements, backupConfig.DatabaseName, *redirect)
-	}
+	statements := RedirectGlobalStatements(GetRestoreMetadataStatements(globalFilename))
 	ExecuteRestoreMetadataStatements(statements, 1, false)
 	logger.Info("Global database metadata restore complete")
 }
 
 func restorePredata() {
+	defer logComponent("predata")()
 	predataFilename := globalCluster.GetPredataFilePath()
 	logger.Info("Restoring pre-data metadata from %s", predataFilename)
 	statements := GetRestoreMetadataStatements(predataFilename)
@@ -149,7 +164,32 @@ func restorePredata() {
 	logger.Info("Pre-data metadata restore complete")
 }
 
+func validateMetadata() {
+	logger.Info("Validating metadata statements; no metadata or data will be restored")
+	statements := make([]utils.StatementWithType, 0)
+	if *restoreGlobals {
+		statements = append(statements, RedirectGlobalStatements(GetRestoreMetadataStatements(globalCluster.GetGlobalFilePath()))...)
+	}
+	if !backupConfig.DataOnly {
+		statements = append(statements, GetRestoreMetadataStatements(globalCluster.GetPredataFilePath())...)
+		if !backupConfig.TableFiltered {
+			statements = append(statements, GetRestoreMetadataStatements(globalCluster.GetPostdataFilePath())...)
+		}
+	}
+	invalidStatements := ValidateMetadataStatements(statements)
+	for _, invalid := range invalidStatements {
+		objectName := utils.FQN(invalid.Statement.Schema, invalid.Statement.Name)
+		logger.Error("Statement for %s %s failed validation: %v", invalid.Statement.ObjectType, objectName, invalid.Error)
+		logger.Verbose("Failed statement: %s", invalid.Statement.Statement)
+	}
+	if len(invalidStatements) > 0 {
+		logger.Fatal(errors.Errorf("%d of %d metadata statements failed validation", len(invalidStatements), len(statements)), "")
+	}
+	logger.Info("All metadata statements validated successfully")
+}
+
 func restoreData() {
+	defer logComponent("data")()
 	setParallelRestore()
 	defer setSerialRestore()
 	logger.Info("Restoring data")
@@ -157,7 +197,7 @@ func restoreData() {
 	dataProgressBar := utils.NewProgressBar(totalTables, "Tables restored: ", logger.GetVerbosity() == utils.LOGINFO)
 	dataProgressBar.Start()
 
-	if *numJobs == 1 {
+	if parallelJobs == 1 {
 		disableDistPolicyChecking()
 		for i, entry := range globalTOC.DataEntries {
 			restoreSingleTableData(entry, uint32(i)+1, totalTables)
@@ -167,7 +207,7 @@ func restoreData() {
 		var tableNum uint32 = 1
 		tasks := make(chan utils.DataEntry, totalTables)
 		var workerPool sync.WaitGroup
-		for i := 0; i < *numJobs; i++ {
+		for i := 0; i < parallelJobs; i++ {
 			workerPool.Add(1)
 			go func() {
 				disableDistPolicyChecking()
@@ -196,7 +236,7 @@ func disableDistPolicyChecking() {
 }
 
 func restoreSingleTableData(entry utils.DataEntry, tableNum uint32, totalTables int) {
-	name := utils.MakeFQN(entry.Schema, entry.Name)
+	name := utils.FQN(entry.Schema, entry.Name)
 	if logger.GetVerbosity() > utils.LOGINFO {
 		// No progress bar at this log level, so we note table count here
 		logger.Verbose("Reading data for table %s from file (table %d of %d)", name, tableNum, totalTables)
@@ -208,16 +248,18 @@ func restoreSingleTableData(entry utils.DataEntry, tableNum uint32, totalTables
 }
 
 func restorePostdata() {
+	defer logComponent("postdata")()
 	setParallelRestore()
 	defer setSerialRestore()
 	postdataFilename := globalCluster.GetPostdataFilePath()
 	logger.Info("Restoring post-data metadata from %s", postdataFilename)
 	statements := GetRestoreMetadataStatements(postdataFilename)
-	ExecuteRestoreMetadataStatements(statements, *numJobs, false)
+	ExecuteRestoreMetadataStatements(statements, parallelJobs, false)
 	logger.Info("Post-data metadata restore complete")
 }
 
 func restoreStatistics() {
+	defer logComponent("statistics")()
 	statisticsFilename := globalCluster.GetStatisticsFilePath()
 	logger.Info("Restoring query planner statistics from %s", statisticsFilename)
 	statements := GetRestoreMetadataStatements(statisticsFilename)
@@ -226,19 +268,16 @@ func restoreStatistics() {
 }
 
 func DoTeardown() {
-	errStr := ""
-	if err := recover(); err != nil {
-		errStr = fmt.Sprintf("%v", err)
-		if connection != nil {
-			if strings.Contains(errStr, fmt.Sprintf(`Database "%s" does not exist`, connection.DBName)) {
-				errStr = fmt.Sprintf(`%s.  Use the --createdb flag to create "%s" as part of the restore process.`, errStr, connection.DBName)
-			} else if strings.Contains(errStr, fmt.Sprintf(`Database "%s" already exists`, connection.DBName)) {
-				errStr = fmt.Sprintf(`%s.  Run gprestore again without the --createdb flag.`, errStr)
-			}
+	recovered := recover()
+	if recovered != nil && connection != nil {
+		errStr := fmt.Sprintf("%v", recovered)
+		if strings.Contains(errStr, fmt.Sprintf(`Database "%s" does not exist`, connection.DBName)) {
+			recovered = fmt.Sprintf(`%s.  Use the --createdb flag to create "%s" as part of the restore process.`, errStr, connection.DBName)
+		} else if strings.Contains(errStr, fmt.Sprintf(`Database "%s" already exists`, connection.DBName)) {
+			recovered = fmt.Sprintf(`%s.  Run gprestore again without the --createdb flag.`, errStr)
 		}
-		fmt.Println(errStr)
 	}
-	_, exitCode := utils.ParseErrorMessage(errStr)
+	exitCode := utils.HandleFatalPanic(recovered)
 	if connection != nil {
 		connection.Close()
 	}
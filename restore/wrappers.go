@@ -16,6 +16,11 @@ import (
  */
 
 func SetLoggerVerbosity() {
+	logger.SetPrefixSeparator(*logPrefixSeparator)
+	logger.SetFormat(*logFormat)
+	if *noColor {
+		logger.SetColor(false)
+	}
 	if *quiet {
 		logger.SetVerbosity(utils.LOGERROR)
 	} else if *debug {
@@ -23,6 +28,27 @@ func SetLoggerVerbosity() {
 	} else if *verbose {
 		logger.SetVerbosity(utils.LOGVERBOSE)
 	}
+	for _, setting := range componentLogLevels {
+		logger.SetComponentVerbosityFromString(setting)
+	}
+	if *linkCurrentLog {
+		if err := logger.LinkCurrentLogFile(); err != nil {
+			logger.Warn("Unable to create a current log link to %s: %s", logger.GetLogFilePath(), err.Error())
+		}
+	}
+}
+
+/*
+ * Scopes the package logger to the given component, so that the verbosity set
+ * for it with --component-log-level applies, until the returned function is
+ * called to restore the previous logger.
+ */
+func logComponent(component string) func() {
+	previousLogger := logger
+	logger = logger.WithComponent(component)
+	return func() {
+		logger = previousLogger
+	}
 }
 
 func InitializeConnection(dbname string) {
@@ -40,12 +66,17 @@ func InitializeConnection(dbname string) {
 func InitializeBackupConfig() {
 	backupConfig = utils.ReadConfigFile(globalCluster.GetConfigFilePath())
 	utils.InitializeCompressionParameters(backupConfig.Compressed)
+	utils.SetMetadataCompression(backupConfig.MetadataCompressed)
 	utils.EnsureBackupVersionCompatibility(backupConfig.BackupVersion, version)
-	utils.EnsureDatabaseVersionCompatibility(backupConfig.DatabaseVersion, connection.Version)
+	utils.EnsureDatabaseVersionCompatibility(backupConfig.DatabaseVersion, connection.Version, *forceCrossVersion)
+	if backupConfig.SegmentCount > 0 && backupConfig.SegmentCount != globalCluster.GetSegmentCount() {
+		logger.Warn("Backup was taken on a cluster with %d segments, but the current cluster has %d segments", backupConfig.SegmentCount, globalCluster.GetSegmentCount())
+	}
 }
 
 func GetRestoreMetadataStatements(filename string, objectTypes ...string) []utils.StatementWithType {
-	metadataFile := utils.MustOpenFileForReading(filename)
+	metadataFile := utils.MustOpenMetadataFileForReading(filename)
+	defer metadataFile.Close()
 	var statements []utils.StatementWithType
 	if len(objectTypes) > 0 {
 		statements = globalTOC.GetSQLStatementForObjectTypes(filename, metadataFile, objectTypes...)
@@ -55,6 +86,34 @@ func GetRestoreMetadataStatements(filename string, objectTypes ...string) []util
 	return statements
 }
 
+/*
+ * Statements in the global file that name the database being restored are
+ * changed to name the --redirect database instead.
+ */
+func RedirectGlobalStatements(statements []utils.StatementWithType) []utils.StatementWithType {
+	if *redirect != "" {
+		statements = utils.SubstituteRedirectDatabaseInStatements(statements, backupConfig.DatabaseName, *redirect)
+	}
+	return statements
+}
+
+/*
+ * Statements are validated on the current connection to the postgres database,
+ * so the database being restored need not exist, and statements that refer to
+ * it are skipped.  Statements that cannot run inside a transaction block, such
+ * as CREATE DATABASE, CREATE TABLESPACE, and CREATE RESOURCE GROUP, are also
+ * skipped, and so are the owners, comments, and privileges of the tablespaces
+ * that are therefore never created.  Statements are already validated in a transaction, so the BEGIN
+ * and COMMIT written by --single-transaction-metadata are skipped as well.
+ */
+func ValidateMetadataStatements(statements []utils.StatementWithType) []utils.InvalidStatement {
+	skipObjectTypes := []string{"DATABASE", "DATABASE GUC", "DATABASE METADATA", "TABLESPACE", "TABLESPACE METADATA", "RESOURCE GROUP", "BEGIN TRANSACTION", "COMMIT TRANSACTION"}
+	if connection.Version.AtLeast("5") {
+		skipObjectTypes = append(skipObjectTypes, "GPDB4 SESSION GUCS")
+	}
+	return connection.ValidateStatements(statements, skipObjectTypes...)
+}
+
 func ExecuteRestoreMetadataStatements(statements []utils.StatementWithType, jobs int, showProgressBar bool) {
 	if connection.Version.AtLeast("5") {
 		connection.ExecuteAllStatementsExcept(statements, jobs, showProgressBar, "GPDB4 SESSION GUCS")
@@ -63,12 +122,46 @@ func ExecuteRestoreMetadataStatements(statements []utils.StatementWithType, jobs
 	}
 }
 
+/*
+ * The connection pool is capped at the number of jobs, so once that is limited
+ * to the connections the server can spare, workers wait for a free connection
+ * instead of failing with "too many clients".
+ */
 func setParallelRestore() {
-	connection.Conn.SetMaxOpenConns(*numJobs)
-	connection.Conn.SetMaxIdleConns(*numJobs)
+	parallelJobs = GetConnectionBudget(*numJobs)
+	connection.Conn.SetMaxOpenConns(parallelJobs)
+	connection.Conn.SetMaxIdleConns(parallelJobs)
+}
+
+// The number of connections left free for other clients during a parallel restore
+const connectionSafetyMargin = 5
+
+/*
+ * This returns the number of connections to use for a parallel restore: the
+ * requested number of jobs, limited by --max-connections if it is set or else
+ * by the number of connections available on the server less a safety margin.
+ * The connection already open for the restore counts toward the budget.
+ */
+func GetConnectionBudget(requestedJobs int) int {
+	if requestedJobs <= 1 {
+		return 1
+	}
+	budget := *maxConnections
+	if budget == 0 {
+		budget = connection.GetAvailableConnections() + 1 - connectionSafetyMargin
+	}
+	if budget < 1 {
+		budget = 1
+	}
+	if requestedJobs > budget {
+		logger.Warn("Using %d parallel connections instead of %d to avoid exceeding the connections available on the server", budget, requestedJobs)
+		return budget
+	}
+	return requestedJobs
 }
 
 func setSerialRestore() {
+	parallelJobs = 1
 	connection.Conn.SetMaxOpenConns(1)
 	connection.Conn.SetMaxIdleConns(1)
 }
@@ -0,0 +1,97 @@
+package restore_test
+
+import (
+	"github.com/greenplum-db/gpbackup/restore"
+	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var _ = Describe("restore/wrappers tests", func() {
+	Describe("GetConnectionBudget", func() {
+		BeforeEach(func() {
+			restore.SetLogger(logger)
+			restore.SetConnection(connection)
+			restore.SetMaxConnections(0)
+		})
+		expectAvailableConnections := func(count int) {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+		}
+		It("uses one connection for a serial restore without querying the server", func() {
+			Expect(restore.GetConnectionBudget(1)).To(Equal(1))
+		})
+		It("uses the requested number of jobs if the server has enough connections available", func() {
+			expectAvailableConnections(100)
+			Expect(restore.GetConnectionBudget(8)).To(Equal(8))
+		})
+		It("limits the number of jobs to the available connections less a safety margin", func() {
+			expectAvailableConnections(6)
+			Expect(restore.GetConnectionBudget(8)).To(Equal(2))
+			Expect(stdout).To(gbytes.Say("Using 2 parallel connections instead of 8 to avoid exceeding the connections available on the server"))
+		})
+		It("uses at least one connection if the server has none to spare", func() {
+			expectAvailableConnections(0)
+			Expect(restore.GetConnectionBudget(8)).To(Equal(1))
+		})
+		It("limits the number of jobs to --max-connections without querying the server", func() {
+			restore.SetMaxConnections(3)
+			Expect(restore.GetConnectionBudget(8)).To(Equal(3))
+		})
+		It("uses the requested number of jobs if it is below --max-connections", func() {
+			restore.SetMaxConnections(10)
+			Expect(restore.GetConnectionBudget(8)).To(Equal(8))
+		})
+	})
+	Describe("RedirectGlobalStatements", func() {
+		databaseGUC := utils.StatementWithType{ObjectType: "DATABASE GUC", Statement: "ALTER DATABASE testdb SET search_path TO public;"}
+		BeforeEach(func() {
+			restore.SetBackupConfig(&utils.BackupConfig{DatabaseName: "testdb"})
+		})
+		AfterEach(func() {
+			restore.SetRedirect("")
+		})
+		It("changes statements that name the backed-up database to name the redirect database", func() {
+			restore.SetRedirect("newdb")
+			statements := restore.RedirectGlobalStatements([]utils.StatementWithType{databaseGUC})
+			Expect(statements[0].Statement).To(Equal("ALTER DATABASE newdb SET search_path TO public;"))
+		})
+		It("leaves statements unchanged without a redirect database", func() {
+			restore.SetRedirect("")
+			statements := restore.RedirectGlobalStatements([]utils.StatementWithType{databaseGUC})
+			Expect(statements[0].Statement).To(Equal("ALTER DATABASE testdb SET search_path TO public;"))
+		})
+	})
+	Describe("ValidateMetadataStatements", func() {
+		role := utils.StatementWithType{Name: "testrole", ObjectType: "ROLE", Statement: "CREATE ROLE testrole;"}
+		BeforeEach(func() {
+			connection, mock = testutils.CreateAndConnectMockDB()
+			testutils.SetDBVersion(connection, "5.1.0")
+			restore.SetConnection(connection)
+			testutils.ExpectBegin(mock)
+		})
+		DescribeTable("validates only the statements that can run in a transaction without the restored database", func(skipped utils.StatementWithType) {
+			mock.ExpectExec("SAVEPOINT gpbackup_validate").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("CREATE ROLE testrole;").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("RELEASE SAVEPOINT gpbackup_validate").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+			invalidStatements := restore.ValidateMetadataStatements([]utils.StatementWithType{skipped, role})
+			Expect(invalidStatements).To(BeEmpty())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		},
+			Entry("skips CREATE DATABASE", utils.StatementWithType{ObjectType: "DATABASE", Statement: "CREATE DATABASE testdb;"}),
+			Entry("skips database GUCs", utils.StatementWithType{ObjectType: "DATABASE GUC", Statement: "ALTER DATABASE testdb SET search_path TO public;"}),
+			Entry("skips database owners, comments, and privileges", utils.StatementWithType{ObjectType: "DATABASE METADATA", Statement: "ALTER DATABASE testdb OWNER TO testrole;"}),
+			Entry("skips CREATE TABLESPACE", utils.StatementWithType{ObjectType: "TABLESPACE", Statement: "CREATE TABLESPACE test_tablespace FILESPACE test_dir;"}),
+			Entry("skips tablespace owners, comments, and privileges", utils.StatementWithType{ObjectType: "TABLESPACE METADATA", Statement: "ALTER TABLESPACE test_tablespace OWNER TO testrole;"}),
+			Entry("skips resource groups", utils.StatementWithType{ObjectType: "RESOURCE GROUP", Statement: "CREATE RESOURCE GROUP some_group WITH (CPU_RATE_LIMIT=10, MEMORY_LIMIT=20);"}),
+			Entry("skips BEGIN", utils.StatementWithType{ObjectType: "BEGIN TRANSACTION", Statement: "BEGIN;"}),
+			Entry("skips COMMIT", utils.StatementWithType{ObjectType: "COMMIT TRANSACTION", Statement: "COMMIT;"}),
+			Entry("skips GPDB 4 session GUCs", utils.StatementWithType{ObjectType: "GPDB4 SESSION GUCS", Statement: "SET gp_strict_xml_parse = off;"}),
+		)
+	})
+})
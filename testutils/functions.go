@@ -114,7 +114,7 @@ func DefaultACLForType(grantee string, objType string) backup.ACL {
 		Truncate:   objType == "TABLE" || objType == "VIEW",
 		References: objType == "TABLE" || objType == "VIEW",
 		Trigger:    objType == "TABLE" || objType == "VIEW",
-		Usage:      objType == "LANGUAGE" || objType == "SCHEMA" || objType == "SEQUENCE",
+		Usage:      objType == "DOMAIN" || objType == "LANGUAGE" || objType == "SCHEMA" || objType == "SEQUENCE" || objType == "TYPE",
 		Execute:    objType == "FUNCTION",
 		Create:     objType == "DATABASE" || objType == "SCHEMA" || objType == "TABLESPACE",
 		Temporary:  objType == "DATABASE",
@@ -132,7 +132,7 @@ func DefaultACLForTypeWithGrant(grantee string, objType string) backup.ACL {
 		TruncateWithGrant:   objType == "TABLE" || objType == "VIEW",
 		ReferencesWithGrant: objType == "TABLE" || objType == "VIEW",
 		TriggerWithGrant:    objType == "TABLE" || objType == "VIEW",
-		UsageWithGrant:      objType == "LANGUAGE" || objType == "SCHEMA" || objType == "SEQUENCE",
+		UsageWithGrant:      objType == "DOMAIN" || objType == "LANGUAGE" || objType == "SCHEMA" || objType == "SEQUENCE" || objType == "TYPE",
 		ExecuteWithGrant:    objType == "FUNCTION",
 		CreateWithGrant:     objType == "DATABASE" || objType == "SCHEMA" || objType == "TABLESPACE",
 		TemporaryWithGrant:  objType == "DATABASE",
@@ -368,6 +368,18 @@ func SkipIfNot4(dbconn *utils.DBConn) {
 	}
 }
 
+func SkipIfBefore5(dbconn *utils.DBConn) {
+	if dbconn.Version.Before("5") {
+		Skip("Test only applicable to GPDB5 and above")
+	}
+}
+
+func SkipIfBefore6(dbconn *utils.DBConn) {
+	if dbconn.Version.Before("6") {
+		Skip("Test only applicable to GPDB6 and above")
+	}
+}
+
 func InitializeTestTOC(buffer io.Writer, which string) (*utils.TOC, *utils.FileWithByteCount) {
 	toc := &utils.TOC{}
 	toc.InitializeEntryMap("global", "predata", "postdata", "statistics")
@@ -44,6 +44,7 @@ type TestExecutor struct {
 	LocalError      error
 	LocalCommands   []string
 	ClusterError    map[int]error
+	ClusterOutput   map[int]string
 	ClusterCommands []map[int][]string
 	ErrorOnExecNum  int // Throw the specified error after this many executions of Execute[...]Command(); 0 means always return error
 	NumExecutions   int
@@ -67,6 +68,10 @@ func (executor *TestExecutor) ExecuteClusterCommand(commandMap map[int][]string)
 	return nil
 }
 
+func (executor *TestExecutor) ExecuteClusterCommandWithOutput(commandMap map[int][]string) (map[int]string, map[int]error) {
+	return executor.ClusterOutput, executor.ExecuteClusterCommand(commandMap)
+}
+
 /*
  * If fields are to be filtered in or out, set shouldFilter to true; filterInclude is true to
  * include fields or false to exclude fields, and filterFields contains the field names to filter on.
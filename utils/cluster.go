@@ -16,8 +16,9 @@ import (
 )
 
 var (
-	usingCompression   = true
-	compressionProgram Compression
+	usingCompression         = true
+	usingMetadataCompression = false
+	compressionProgram       Compression
 )
 
 type Compression struct {
@@ -41,9 +42,23 @@ func SetCompressionParameters(compress bool, compression Compression) {
 	compressionProgram = compression
 }
 
+/*
+ * Metadata files are compressed in-process rather than by piping through an
+ * external program, so only gzip is supported, but the file extension is taken
+ * from the data compression program so that all backup files are named alike.
+ */
+func GetMetadataCompression() bool {
+	return usingMetadataCompression
+}
+
+func SetMetadataCompression(compress bool) {
+	usingMetadataCompression = compress
+}
+
 type Executor interface {
 	ExecuteLocalCommand(commandStr string) error
 	ExecuteClusterCommand(commandMap map[int][]string) map[int]error
+	ExecuteClusterCommandWithOutput(commandMap map[int][]string) (map[int]string, map[int]error)
 }
 
 // This type only exists to allow us to mock Execute[...]Command functions for testing
@@ -73,6 +88,17 @@ func (cluster *Cluster) IsUserSpecifiedBackupDir() bool {
 	return cluster.UserSpecifiedBackupDir != ""
 }
 
+// The master is not counted as a segment
+func (cluster *Cluster) GetSegmentCount() int {
+	count := 0
+	for _, contentID := range cluster.ContentIDs {
+		if contentID != -1 {
+			count++
+		}
+	}
+	return count
+}
+
 func NewCluster(segConfigs []SegConfig, userSpecifiedBackupDir string, timestamp string, userSegPrefix string) Cluster {
 	cluster := Cluster{}
 	cluster.SegHostMap = make(map[int]string, 0)
@@ -127,27 +153,39 @@ func (executor *GPDBExecutor) ExecuteLocalCommand(commandStr string) error {
 }
 
 func (executor *GPDBExecutor) ExecuteClusterCommand(commandMap map[int][]string) map[int]error {
+	_, errMap := executor.ExecuteClusterCommandWithOutput(commandMap)
+	return errMap
+}
+
+/*
+ * This returns the combined output of the command for each content ID, whether
+ * or not it succeeded, along with the errors of the commands that failed.
+ */
+func (executor *GPDBExecutor) ExecuteClusterCommandWithOutput(commandMap map[int][]string) (map[int]string, map[int]error) {
+	outputMap := make(map[int]string)
 	errMap := make(map[int]error)
 	finished := make(chan int)
 	contentIDs := make([]int, 0)
 	for key := range commandMap {
 		contentIDs = append(contentIDs, key)
 	}
+	outputList := make([][]byte, len(contentIDs))
 	errorList := make([]error, len(contentIDs))
 	for i, contentID := range contentIDs {
 		go func(index int, segCommand []string) {
-			_, errorList[index] = exec.Command(segCommand[0], segCommand[1:]...).CombinedOutput()
+			outputList[index], errorList[index] = exec.Command(segCommand[0], segCommand[1:]...).CombinedOutput()
 			finished <- index
 		}(i, commandMap[contentID])
 	}
 	for i := 0; i < len(contentIDs); i++ {
 		index := <-finished
+		outputMap[contentIDs[index]] = string(outputList[index])
 		hostErr := errorList[index]
 		if hostErr != nil {
 			errMap[contentIDs[index]] = hostErr
 		}
 	}
-	return errMap
+	return outputMap, errMap
 }
 
 func (cluster *Cluster) VerifyBackupFileCountOnSegments(fileCount int) {
@@ -198,6 +236,28 @@ func (cluster *Cluster) CreateBackupDirectoriesOnAllHosts() {
 	cluster.LogFatalError("Unable to create directories", numErrors)
 }
 
+/*
+ * This creates and removes a file in the backup directory on every host, to
+ * catch permission or mount problems that would otherwise only surface once
+ * data is being written, after the metadata has been backed up.
+ */
+func (cluster *Cluster) VerifyBackupDirectoriesWritableOnAllHosts() {
+	logger.Verbose("Verifying that backup directories are writable")
+	commandMap := cluster.GenerateSSHCommandMapForCluster(func(contentID int) string {
+		testFile := fmt.Sprintf("%s/gpbackup_%s_write_test", cluster.GetDirForContent(contentID), cluster.Timestamp)
+		return fmt.Sprintf("touch %s && rm %s", testFile, testFile)
+	})
+	errMap := cluster.ExecuteClusterCommand(commandMap)
+	numErrors := len(errMap)
+	if numErrors == 0 {
+		return
+	}
+	for contentID := range errMap {
+		logger.Verbose("Unable to write to directory %s for segment %d on host %s", cluster.GetDirForContent(contentID), contentID, cluster.GetHostForContent(contentID))
+	}
+	cluster.LogFatalError("Backup directories not writable", numErrors)
+}
+
 func (cluster *Cluster) LogFatalError(errMessage string, numErrors int) {
 	s := ""
 	if numErrors != 1 {
@@ -222,6 +282,89 @@ func (cluster *Cluster) GetDirForContent(contentID int) string {
 	return path.Join(cluster.SegDirMap[contentID], "backups", cluster.Timestamp[0:8], cluster.Timestamp)
 }
 
+/*
+ * This returns the total size on disk, in bytes, of the backup directories of
+ * every segment and the master, i.e. the size of the backup as written.
+ */
+func (cluster *Cluster) GetBackupSizeOnAllHosts() (uint64, error) {
+	commandMap := cluster.GenerateSSHCommandMapForCluster(func(contentID int) string {
+		return fmt.Sprintf("du -sk %s 2>/dev/null", cluster.GetDirForContent(contentID))
+	})
+	outputMap, errMap := cluster.ExecuteClusterCommandWithOutput(commandMap)
+	totalBytes := uint64(0)
+	numErrors := 0
+	for _, contentID := range cluster.ContentIDs {
+		var kilobytes uint64
+		err := errMap[contentID]
+		if err == nil {
+			fields := strings.Fields(outputMap[contentID])
+			if len(fields) == 0 {
+				err = errors.New("no output from du")
+			} else {
+				kilobytes, err = strconv.ParseUint(fields[0], 10, 64)
+			}
+		}
+		if err != nil {
+			logger.Verbose("Unable to determine the size of directory %s for segment %d on host %s: %v", cluster.GetDirForContent(contentID), contentID, cluster.GetHostForContent(contentID), err)
+			numErrors++
+			continue
+		}
+		totalBytes += kilobytes * 1024
+	}
+	if numErrors > 0 {
+		s := ""
+		if numErrors != 1 {
+			s = "s"
+		}
+		return 0, errors.Errorf("Unable to determine the size of the backup on %d segment%s", numErrors, s)
+	}
+	return totalBytes, nil
+}
+
+/*
+ * This returns the free space available for backup files on the master, as
+ * segment backup directories are on remote hosts and so cannot be checked
+ * without running a command on each host.
+ */
+func (cluster *Cluster) GetMasterFreeSpace() (uint64, error) {
+	return Storage.FreeSpace(cluster.GetDirForContent(-1))
+}
+
+/*
+ * The "latest" pointer lives in the master's backups directory, alongside the
+ * per-date directories, and refers to the timestamp directory of the most recent
+ * successful backup relative to that directory.
+ */
+func (cluster *Cluster) GetLatestBackupPointerPath() string {
+	return path.Join(path.Dir(path.Dir(cluster.GetDirForContent(-1))), "latest")
+}
+
+/*
+ * On the local filesystem the pointer is a symlink, so that it can be used as a
+ * directory path; other storage backends do not necessarily support symlinks, so
+ * a file containing the relative path of the backup directory is written instead.
+ * Any existing pointer is replaced, and a failure to remove it is reported when
+ * the new pointer cannot be created.
+ */
+func (cluster *Cluster) WriteLatestBackupPointer() error {
+	pointerPath := cluster.GetLatestBackupPointerPath()
+	target := path.Join(cluster.Timestamp[0:8], cluster.Timestamp)
+	Storage.Remove(pointerPath)
+	if _, isLocal := Storage.(LocalStorage); isLocal {
+		return System.Symlink(target, pointerPath)
+	}
+	pointerFile, err := Storage.Create(pointerPath)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(pointerFile, "%s\n", target)
+	if err != nil {
+		pointerFile.Close()
+		return err
+	}
+	return pointerFile.Close()
+}
+
 func (cluster *Cluster) GetTableBackupFilePath(contentID int, tableOid uint32) string {
 	templateFilePath := cluster.GetTableBackupFilePathForCopyCommand(tableOid)
 	filePath := strings.Replace(templateFilePath, "<SEG_DATA_DIR>", cluster.SegDirMap[contentID], -1)
@@ -252,10 +395,22 @@ var metadataFilenameMap = map[string]string{
 	"statistics":        "statistics.sql",
 	"table of contents": "toc.yaml",
 	"report":            "report",
+	"json report":       "report.json",
+}
+
+var compressibleFiletypes = map[string]bool{
+	"global":     true,
+	"predata":    true,
+	"postdata":   true,
+	"statistics": true,
 }
 
 func (cluster *Cluster) GetBackupFilePath(filetype string) string {
-	return path.Join(cluster.GetDirForContent(-1), fmt.Sprintf("gpbackup_%s_%s", cluster.Timestamp, metadataFilenameMap[filetype]))
+	filename := fmt.Sprintf("gpbackup_%s_%s", cluster.Timestamp, metadataFilenameMap[filetype])
+	if usingMetadataCompression && compressibleFiletypes[filetype] {
+		filename += compressionProgram.Extension
+	}
+	return path.Join(cluster.GetDirForContent(-1), filename)
 }
 
 func (cluster *Cluster) GetGlobalFilePath() string {
@@ -282,6 +437,10 @@ func (cluster *Cluster) GetReportFilePath() string {
 	return cluster.GetBackupFilePath("report")
 }
 
+func (cluster *Cluster) GetJSONReportFilePath() string {
+	return cluster.GetBackupFilePath("json report")
+}
+
 func (cluster *Cluster) GetConfigFilePath() string {
 	return cluster.GetBackupFilePath("config")
 }
@@ -77,6 +77,16 @@ var _ = Describe("utils/cluster tests", func() {
 			Expect(results[2].Hostname).To(Equal("remotehost"))
 		})
 	})
+	Describe("GetSegmentCount", func() {
+		It("returns the number of segments, excluding the master, for a multi-host, multi-segment cluster", func() {
+			cluster := utils.NewCluster([]utils.SegConfig{masterSeg, localSegOne, remoteSegOne, remoteSegTwo}, "", "20170101010101", "gpseg")
+			Expect(cluster.GetSegmentCount()).To(Equal(3))
+		})
+		It("returns 0 for a cluster with only a master", func() {
+			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
+			Expect(cluster.GetSegmentCount()).To(Equal(0))
+		})
+	})
 	Describe("GenerateSSHCommandMap", func() {
 		It("Returns a map of ssh commands for the master, including master", func() {
 			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
@@ -285,6 +295,12 @@ var _ = Describe("utils/cluster tests", func() {
 			Expect(cluster.GetTableBackupFilePathForCopyCommand(1234)).To(Equal("/foo/bar/gpseg<SEGID>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_1234"))
 		})
 	})
+	Describe("GetJSONReportFilePath", func() {
+		It("returns the JSON report file path next to the report file", func() {
+			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
+			Expect(cluster.GetJSONReportFilePath()).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_report.json"))
+		})
+	})
 	Describe("GetReportFilePath", func() {
 		It("returns report file path", func() {
 			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
@@ -295,6 +311,82 @@ var _ = Describe("utils/cluster tests", func() {
 			Expect(cluster.GetReportFilePath()).To(Equal("/foo/bar/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_report"))
 		})
 	})
+	Describe("GetPredataFilePath", func() {
+		AfterEach(func() {
+			utils.SetMetadataCompression(false)
+			utils.SetCompressionParameters(false, utils.Compression{})
+		})
+		It("returns predata file path", func() {
+			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
+			Expect(cluster.GetPredataFilePath()).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_predata.sql"))
+		})
+		It("returns compressed predata file path if metadata compression is in use", func() {
+			utils.SetCompressionParameters(true, utils.Compression{Name: "gzip", CompressCommand: "gzip -c", DecompressCommand: "gzip -d", Extension: ".gz"})
+			utils.SetMetadataCompression(true)
+			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
+			Expect(cluster.GetPredataFilePath()).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_predata.sql.gz"))
+			Expect(cluster.GetTOCFilePath()).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_toc.yaml"))
+		})
+	})
+	Describe("GetMasterFreeSpace", func() {
+		AfterEach(func() {
+			utils.System = utils.InitializeSystemFunctions()
+		})
+		It("returns the free space in the master backup directory", func() {
+			utils.System.FreeSpace = func(path string) (uint64, error) {
+				Expect(path).To(Equal("/data/gpseg-1/backups/20170101/20170101010101"))
+				return 2048, nil
+			}
+			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
+			freeBytes, err := cluster.GetMasterFreeSpace()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(freeBytes).To(Equal(uint64(2048)))
+		})
+	})
+	Describe("GetLatestBackupPointerPath", func() {
+		It("returns the latest pointer path in the master backups directory", func() {
+			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
+			Expect(cluster.GetLatestBackupPointerPath()).To(Equal("/data/gpseg-1/backups/latest"))
+		})
+		It("returns the latest pointer path based on user specified path", func() {
+			cluster := utils.NewCluster(nil, "/foo/bar", "20170101010101", "gpseg")
+			Expect(cluster.GetLatestBackupPointerPath()).To(Equal("/foo/bar/gpseg-1/backups/latest"))
+		})
+	})
+	Describe("WriteLatestBackupPointer", func() {
+		AfterEach(func() {
+			utils.System = utils.InitializeSystemFunctions()
+		})
+		It("replaces the latest symlink with one referring to the backup directory", func() {
+			removed := ""
+			var symlinkArgs []string
+			utils.System.Remove = func(name string) error {
+				removed = name
+				return nil
+			}
+			utils.System.Symlink = func(oldname string, newname string) error {
+				symlinkArgs = []string{oldname, newname}
+				return nil
+			}
+			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
+			err := cluster.WriteLatestBackupPointer()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(removed).To(Equal("/data/gpseg-1/backups/latest"))
+			Expect(symlinkArgs).To(Equal([]string{"20170101/20170101010101", "/data/gpseg-1/backups/latest"}))
+		})
+		It("creates the latest symlink if none exists yet", func() {
+			utils.System.Remove = func(name string) error { return os.ErrNotExist }
+			utils.System.Symlink = func(oldname string, newname string) error { return nil }
+			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
+			Expect(cluster.WriteLatestBackupPointer()).To(Succeed())
+		})
+		It("returns an error if the symlink cannot be created", func() {
+			utils.System.Remove = func(name string) error { return nil }
+			utils.System.Symlink = func(oldname string, newname string) error { return errors.New("permission denied") }
+			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
+			Expect(cluster.WriteLatestBackupPointer()).To(MatchError("permission denied"))
+		})
+	})
 	Describe("GetTableBackupFilePath", func() {
 		It("returns table file path", func() {
 			cluster := utils.NewCluster([]utils.SegConfig{masterSeg}, "", "20170101010101", "gpseg")
@@ -374,6 +466,73 @@ var _ = Describe("utils/cluster tests", func() {
 			testCluster.CreateBackupDirectoriesOnAllHosts()
 		})
 	})
+	Describe("VerifyBackupDirectoriesWritableOnAllHosts", func() {
+		It("writes and removes a test file in the backup directory on every host", func() {
+			testCluster.VerifyBackupDirectoriesWritableOnAllHosts()
+			Expect((*testExecutor).NumExecutions).To(Equal(1))
+			Expect(testExecutor.ClusterCommands[0]).To(Equal(map[int][]string{
+				-1: {"bash", "-c", "touch /data/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_write_test && rm /data/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_write_test"},
+				0:  {"ssh", "-o", "StrictHostKeyChecking=no", "testUser@localhost", "touch /data/gpseg0/backups/20170101/20170101010101/gpbackup_20170101010101_write_test && rm /data/gpseg0/backups/20170101/20170101010101/gpbackup_20170101010101_write_test"},
+				1:  {"ssh", "-o", "StrictHostKeyChecking=no", "testUser@remotehost1", "touch /data/gpseg1/backups/20170101/20170101010101/gpbackup_20170101010101_write_test && rm /data/gpseg1/backups/20170101/20170101010101/gpbackup_20170101010101_write_test"},
+			}))
+		})
+		It("panics with one error for all segments whose write test fails", func() {
+			testExecutor.ClusterError = map[int]error{
+				0: errors.Errorf("exit status 1"),
+				1: errors.Errorf("exit status 1"),
+			}
+			testCluster.Executor = testExecutor
+			defer func() {
+				logContents := string(logfile.Contents())
+				Expect(logContents).To(ContainSubstring("Unable to write to directory /data/gpseg0/backups/20170101/20170101010101 for segment 0 on host localhost"))
+				Expect(logContents).To(ContainSubstring("Unable to write to directory /data/gpseg1/backups/20170101/20170101010101 for segment 1 on host remotehost1"))
+			}()
+			defer testutils.ShouldPanicWithMessage("Backup directories not writable on 2 segments")
+			testCluster.VerifyBackupDirectoriesWritableOnAllHosts()
+		})
+		It("panics if the write test fails on one segment", func() {
+			testExecutor.ClusterError = map[int]error{
+				1: errors.Errorf("exit status 1"),
+			}
+			testCluster.Executor = testExecutor
+			defer testutils.ShouldPanicWithMessage("Backup directories not writable on 1 segment")
+			testCluster.VerifyBackupDirectoriesWritableOnAllHosts()
+		})
+	})
+	Describe("GetBackupSizeOnAllHosts", func() {
+		It("sums the size of the backup directory on every host", func() {
+			testExecutor.ClusterOutput = map[int]string{
+				-1: "12\t/data/gpseg-1/backups/20170101/20170101010101\n",
+				0:  "1024\t/data/gpseg0/backups/20170101/20170101010101\n",
+				1:  "2048\t/data/gpseg1/backups/20170101/20170101010101\n",
+			}
+			size, err := testCluster.GetBackupSizeOnAllHosts()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(Equal(uint64((12 + 1024 + 2048) * 1024)))
+			Expect(testExecutor.ClusterCommands[0]).To(Equal(map[int][]string{
+				-1: {"bash", "-c", "du -sk /data/gpseg-1/backups/20170101/20170101010101 2>/dev/null"},
+				0:  {"ssh", "-o", "StrictHostKeyChecking=no", "testUser@localhost", "du -sk /data/gpseg0/backups/20170101/20170101010101 2>/dev/null"},
+				1:  {"ssh", "-o", "StrictHostKeyChecking=no", "testUser@remotehost1", "du -sk /data/gpseg1/backups/20170101/20170101010101 2>/dev/null"},
+			}))
+		})
+		It("returns an error counting the segments whose size could not be determined", func() {
+			testExecutor.ClusterOutput = map[int]string{
+				-1: "12\t/data/gpseg-1/backups/20170101/20170101010101\n",
+				0:  "",
+				1:  "not a size",
+			}
+			_, err := testCluster.GetBackupSizeOnAllHosts()
+			Expect(err).To(MatchError("Unable to determine the size of the backup on 2 segments"))
+			Expect(string(logfile.Contents())).To(ContainSubstring("Unable to determine the size of directory /data/gpseg1/backups/20170101/20170101010101 for segment 1 on host remotehost1"))
+		})
+		It("returns an error if the command fails on a segment", func() {
+			testExecutor.ClusterOutput = map[int]string{-1: "12\t/dir\n", 0: "", 1: "34\t/dir\n"}
+			testExecutor.ClusterError = map[int]error{0: errors.Errorf("exit status 1")}
+			testCluster.Executor = testExecutor
+			_, err := testCluster.GetBackupSizeOnAllHosts()
+			Expect(err).To(MatchError("Unable to determine the size of the backup on 1 segment"))
+		})
+	})
 	Describe("ParseSegPrefix", func() {
 		AfterEach(func() {
 			utils.System.Glob = filepath.Glob
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq" // Need driver for postgres
@@ -29,6 +30,35 @@ type DBConn struct {
 	Port    int
 	Tx      *sqlx.Tx
 	Version GPDBVersion
+
+	keepAlive *keepAlive
+}
+
+/*
+ * While a keep-alive is running, queries on the connection hold this lock so
+ * that the keep-alive query never runs concurrently with another query in the
+ * same transaction, and record when they finish so that the keep-alive can
+ * tell how long the connection has been idle.
+ */
+type keepAlive struct {
+	sync.Mutex
+	lastActivity time.Time
+}
+
+/*
+ * A ConnectionInfo records where a DBConn connects, for the report.  It has no
+ * field for a password, so that credentials can never be written to the report
+ * or config file; gpbackup takes passwords only from PGPASSWORD or .pgpass.
+ */
+type ConnectionInfo struct {
+	Host   string
+	Port   int
+	DBName string
+	User   string
+}
+
+func (info ConnectionInfo) String() string {
+	return fmt.Sprintf("%s@%s:%d/%s", info.User, info.Host, info.Port, info.DBName)
 }
 
 func NewDBConn(dbname string) *DBConn {
@@ -56,6 +86,10 @@ func NewDBConn(dbname string) *DBConn {
 	}
 }
 
+func (dbconn *DBConn) ConnectionInfo() *ConnectionInfo {
+	return &ConnectionInfo{Host: dbconn.Host, Port: dbconn.Port, DBName: dbconn.DBName, User: dbconn.User}
+}
+
 /*
  * Wrapper functions for built-in sqlx and database/sql functionality; they will
  * automatically execute the query as part of an existing transaction if one is
@@ -95,6 +129,16 @@ func (dbconn *DBConn) Commit() {
 	dbconn.Tx = nil
 }
 
+func (dbconn *DBConn) Rollback() {
+	if dbconn.Tx == nil {
+		logger.Fatal(errors.New("Cannot roll back transaction; there is no transaction in progress"), "")
+	}
+	var err error
+	err = dbconn.Tx.Rollback()
+	CheckError(err)
+	dbconn.Tx = nil
+}
+
 func (dbconn *DBConn) Connect() {
 	dbname := escapeConnectionParam(dbconn.DBName)
 	user := escapeConnectionParam(dbconn.User)
@@ -118,6 +162,11 @@ func (dbconn *DBConn) Connect() {
 }
 
 func (dbconn *DBConn) Exec(query string) (sql.Result, error) {
+	defer dbconn.lockForQuery()()
+	return dbconn.exec(query)
+}
+
+func (dbconn *DBConn) exec(query string) (sql.Result, error) {
 	if dbconn.Tx != nil {
 		return dbconn.Tx.Exec(query)
 	}
@@ -125,6 +174,7 @@ func (dbconn *DBConn) Exec(query string) (sql.Result, error) {
 }
 
 func (dbconn *DBConn) Get(destination interface{}, query string) error {
+	defer dbconn.lockForQuery()()
 	if dbconn.Tx != nil {
 		return dbconn.Tx.Get(destination, query)
 	}
@@ -132,12 +182,67 @@ func (dbconn *DBConn) Get(destination interface{}, query string) error {
 }
 
 func (dbconn *DBConn) Select(destination interface{}, query string) error {
+	defer dbconn.lockForQuery()()
 	if dbconn.Tx != nil {
 		return dbconn.Tx.Select(destination, query)
 	}
 	return dbconn.Conn.Select(destination, query)
 }
 
+/*
+ * This returns a function to be deferred until the query finishes, which
+ * records the time of the query and releases the lock taken for it.  If no
+ * keep-alive has been started, no lock is taken.
+ */
+func (dbconn *DBConn) lockForQuery() func() {
+	if dbconn.keepAlive == nil {
+		return func() {}
+	}
+	dbconn.keepAlive.Lock()
+	return func() {
+		dbconn.keepAlive.lastActivity = System.Now()
+		dbconn.keepAlive.Unlock()
+	}
+}
+
+/*
+ * This periodically issues a trivial query on the connection once it has been
+ * idle for at least idleInterval, so that a firewall or server idle timeout
+ * does not drop the connection during a long phase in which it is not used,
+ * e.g. while table data is written to a slow backup directory.  The returned
+ * function stops the keep-alive and must be called before the transaction on
+ * the connection is committed or rolled back.
+ */
+func (dbconn *DBConn) StartKeepAlive(idleInterval time.Duration) func() {
+	dbconn.keepAlive = &keepAlive{lastActivity: System.Now()}
+	ticks := System.Tick(idleInterval)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticks:
+				dbconn.keepAlive.Lock()
+				if System.Now().Sub(dbconn.keepAlive.lastActivity) >= idleInterval {
+					logger.Debug("Sending keep-alive query to database %s", dbconn.DBName)
+					if _, err := dbconn.exec("SELECT 1"); err != nil {
+						logger.Warn("Keep-alive query to database %s failed: %s", dbconn.DBName, err.Error())
+					}
+					dbconn.keepAlive.lastActivity = System.Now()
+				}
+				dbconn.keepAlive.Unlock()
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
 /*
  * Other useful/helper functions involving DBConn
  */
@@ -156,6 +261,31 @@ func (dbconn *DBConn) GetDBSize() string {
 	return size.DBSize
 }
 
+func (dbconn *DBConn) GetDBSizeInBytes() uint64 {
+	size := struct{ DBSize uint64 }{}
+	sizeQuery := fmt.Sprintf("SELECT sodddatsize as dbsize FROM gp_toolkit.gp_size_of_database WHERE sodddatname=E'%s'", escapeConnectionParam(dbconn.DBName))
+	err := dbconn.Get(&size, sizeQuery)
+	CheckError(err)
+	return size.DBSize
+}
+
+/*
+ * This returns the number of additional connections the server can accept from
+ * non-superusers, not counting the connections already open, so that parallel
+ * operations can stay within max_connections instead of failing with "too many
+ * clients".
+ */
+func (dbconn *DBConn) GetAvailableConnections() int {
+	available := struct{ Count int }{}
+	query := `
+SELECT current_setting('max_connections')::int
+	- current_setting('superuser_reserved_connections')::int
+	- (SELECT count(*) FROM pg_stat_activity)::int AS count`
+	err := dbconn.Get(&available, query)
+	CheckError(err)
+	return available.Count
+}
+
 func (dbconn *DBConn) SetDatabaseVersion() {
 	dbconn.Version.Initialize(dbconn)
 	dbconn.validateGPDBVersionCompatibility()
@@ -245,3 +375,43 @@ func CheckErrorForQuery(err error, statement string) {
 		logger.Fatal(err, "Failed to execute statement; see log file %s for details.  Error was", logger.GetLogFilePath())
 	}
 }
+
+type InvalidStatement struct {
+	Statement StatementWithType
+	Error     error
+}
+
+/*
+ * This function executes each statement in order inside a single transaction
+ * that is rolled back afterward, so that statements are checked against the
+ * objects created by the statements preceding them but nothing is changed in
+ * the database.  Each statement runs in its own savepoint so that an error does
+ * not prevent the remaining statements from being checked.  Statements of the
+ * specified object types (e.g. those that cannot run in a transaction block)
+ * are skipped.
+ */
+func (dbconn *DBConn) ValidateStatements(statements []StatementWithType, skipObjectTypes ...string) []InvalidStatement {
+	shouldSkip := make(map[string]bool, len(skipObjectTypes))
+	for _, obj := range skipObjectTypes {
+		shouldSkip[obj] = true
+	}
+	invalidStatements := make([]InvalidStatement, 0)
+	dbconn.Begin()
+	defer dbconn.Rollback()
+	for _, statement := range statements {
+		if shouldSkip[statement.ObjectType] {
+			continue
+		}
+		_, err := dbconn.Exec("SAVEPOINT gpbackup_validate")
+		CheckError(err)
+		_, err = dbconn.Exec(statement.Statement)
+		if err != nil {
+			invalidStatements = append(invalidStatements, InvalidStatement{Statement: statement, Error: err})
+			_, err = dbconn.Exec("ROLLBACK TO SAVEPOINT gpbackup_validate")
+		} else {
+			_, err = dbconn.Exec("RELEASE SAVEPOINT gpbackup_validate")
+		}
+		CheckError(err)
+	}
+	return invalidStatements
+}
@@ -3,6 +3,7 @@ package utils_test
 import (
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/greenplum-db/gpbackup/testutils"
@@ -12,6 +13,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	yaml "gopkg.in/yaml.v2"
 )
 
 var _ = Describe("utils/db tests", func() {
@@ -40,6 +42,21 @@ var _ = Describe("utils/db tests", func() {
 			connection = utils.NewDBConn("")
 		})
 	})
+	Describe("DBConn.ConnectionInfo", func() {
+		It("returns the host, port, database, and user of the connection and no password", func() {
+			os.Setenv("PGPASSWORD", "secret")
+			defer os.Unsetenv("PGPASSWORD")
+			connection = &utils.DBConn{Host: "mdw", Port: 5432, DBName: "testdb", User: "gpadmin"}
+
+			info := connection.ConnectionInfo()
+
+			Expect(info).To(Equal(&utils.ConnectionInfo{Host: "mdw", Port: 5432, DBName: "testdb", User: "gpadmin"}))
+			infoYAML, err := yaml.Marshal(info)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(infoYAML)).ToNot(ContainSubstring("secret"))
+			Expect(string(infoYAML)).ToNot(ContainSubstring("password"))
+		})
+	})
 	Describe("DBConn.Connect", func() {
 		It("connects successfully if the database exists", func() {
 			var mockdb *sqlx.DB
@@ -228,6 +245,85 @@ var _ = Describe("utils/db tests", func() {
 			connection.Commit()
 		})
 	})
+	Describe("DBConn.Rollback", func() {
+		It("successfully executes a ROLLBACK in a transaction", func() {
+			connection, mock = testutils.CreateAndConnectMockDB()
+			testutils.ExpectBegin(mock)
+			mock.ExpectRollback()
+			connection.Begin()
+			connection.Rollback()
+			Expect(connection.Tx).To(BeNil())
+		})
+		It("panics if it executes a ROLLBACK outside a transaction", func() {
+			connection, mock = testutils.CreateAndConnectMockDB()
+			defer testutils.ShouldPanicWithMessage("Cannot roll back transaction; there is no transaction in progress")
+			connection.Rollback()
+		})
+	})
+	Describe("DBConn.StartKeepAlive", func() {
+		var (
+			start     time.Time
+			now       time.Time
+			clockLock sync.Mutex
+			ticks     chan time.Time
+		)
+		setNow := func(elapsed time.Duration) {
+			clockLock.Lock()
+			now = start.Add(elapsed)
+			clockLock.Unlock()
+		}
+		BeforeEach(func() {
+			connection, mock = testutils.CreateAndConnectMockDB()
+			start = time.Date(2017, time.January, 1, 1, 1, 1, 1, time.Local)
+			now = start
+			ticks = make(chan time.Time)
+			utils.System.Now = func() time.Time {
+				clockLock.Lock()
+				defer clockLock.Unlock()
+				return now
+			}
+			utils.System.Tick = func(d time.Duration) <-chan time.Time {
+				Expect(d).To(Equal(time.Minute))
+				return ticks
+			}
+		})
+		/*
+		 * The keep-alive handles one tick at a time, so sending a tick only returns
+		 * once the previous tick has been handled.
+		 */
+		tickAt := func(elapsed time.Duration) {
+			setNow(elapsed)
+			ticks <- start.Add(elapsed)
+			ticks <- start.Add(elapsed)
+		}
+		It("sends a keep-alive query once the connection has been idle for the configured interval", func() {
+			stopKeepAlive := connection.StartKeepAlive(time.Minute)
+
+			tickAt(30 * time.Second)
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+			tickAt(61 * time.Second)
+			stopKeepAlive()
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			Expect(string(stdout.Contents())).ToNot(ContainSubstring("Keep-alive query to database testdb failed"))
+		})
+		It("does not send a keep-alive query while the connection is in use", func() {
+			stopKeepAlive := connection.StartKeepAlive(time.Minute)
+
+			setNow(50 * time.Second)
+			mock.ExpectExec("INSERT (.*)").WillReturnResult(sqlmock.NewResult(0, 1))
+			connection.Exec("INSERT INTO pg_tables VALUES ('schema', 'table')")
+			tickAt(70 * time.Second)
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+			tickAt(111 * time.Second)
+			stopKeepAlive()
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			Expect(string(stdout.Contents())).ToNot(ContainSubstring("Keep-alive query to database testdb failed"))
+		})
+	})
 	Describe("Dbconn.SetDatabaseVersion", func() {
 		It("parses GPDB version string", func() {
 			connection, mock = testutils.CreateAndConnectMockDB()
@@ -313,5 +409,80 @@ var _ = Describe("utils/db tests", func() {
 				})
 			})
 		})
+		Context("Connection budget", func() {
+			BeforeEach(func() {
+				mock.MatchExpectationsInOrder(false)
+			})
+			AfterEach(func() {
+				mock.MatchExpectationsInOrder(true)
+			})
+			It("never opens more connections than the pool allows when there are more jobs than connections", func() {
+				connection.Conn.SetMaxOpenConns(2)
+				manyStatements := make([]utils.StatementWithType, 0)
+				for i := 0; i < 16; i++ {
+					manyStatements = append(manyStatements, utils.StatementWithType{ObjectType: "TABLE", Statement: "SELECT 1"})
+					mock.ExpectExec("SELECT 1").WillDelayFor(5 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 0))
+				}
+				done := make(chan bool)
+				maxOpen := make(chan int)
+				go func() {
+					max := 0
+					for {
+						select {
+						case <-done:
+							maxOpen <- max
+							return
+						default:
+							if open := connection.Conn.Stats().OpenConnections; open > max {
+								max = open
+							}
+						}
+					}
+				}()
+				connection.ExecuteAllStatements(manyStatements, 8, false)
+				done <- true
+				Expect(<-maxOpen).To(BeNumerically("<=", 2))
+				Expect(mock.ExpectationsWereMet()).To(Succeed())
+			})
+			It("gets the number of connections available on the server", func() {
+				countRow := sqlmock.NewRows([]string{"count"}).AddRow(42)
+				mock.ExpectQuery("SELECT (.*)").WillReturnRows(countRow)
+				Expect(connection.GetAvailableConnections()).To(Equal(42))
+			})
+		})
+		Context("Dbconn.ValidateStatements", func() {
+			BeforeEach(func() {
+				connection, mock = testutils.CreateAndConnectMockDB()
+				testutils.ExpectBegin(mock)
+			})
+			It("executes each statement in a savepoint and rolls back the transaction", func() {
+				mock.ExpectExec("SAVEPOINT gpbackup_validate").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(commentStr).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("RELEASE SAVEPOINT gpbackup_validate").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("SAVEPOINT gpbackup_validate").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(gucStr).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("RELEASE SAVEPOINT gpbackup_validate").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+				invalidStatements := connection.ValidateStatements(statements, "DATABASE")
+				Expect(invalidStatements).To(BeEmpty())
+				Expect(connection.Tx).To(BeNil())
+				Expect(mock.ExpectationsWereMet()).To(Succeed())
+			})
+			It("returns statements that fail and continues validating the remaining statements", func() {
+				badTable := utils.StatementWithType{Schema: "public", Name: "badtable", ObjectType: "TABLE", Statement: "CREATE TABLE public.badtable (i nonexistent_type);"}
+				goodTable := utils.StatementWithType{Schema: "public", Name: "goodtable", ObjectType: "TABLE", Statement: "CREATE TABLE public.goodtable (i int);"}
+				syntaxErr := fmt.Errorf(`pq: type "nonexistent_type" does not exist`)
+				mock.ExpectExec("SAVEPOINT gpbackup_validate").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`CREATE TABLE public.badtable \(i nonexistent_type\);`).WillReturnError(syntaxErr)
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT gpbackup_validate").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("SAVEPOINT gpbackup_validate").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`CREATE TABLE public.goodtable \(i int\);`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("RELEASE SAVEPOINT gpbackup_validate").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+				invalidStatements := connection.ValidateStatements([]utils.StatementWithType{badTable, goodTable})
+				Expect(invalidStatements).To(Equal([]utils.InvalidStatement{{Statement: badTable, Error: syntaxErr}}))
+				Expect(mock.ExpectationsWereMet()).To(Succeed())
+			})
+		})
 	})
 })
@@ -7,8 +7,11 @@ package utils
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"regexp"
@@ -52,32 +55,82 @@ func SliceToQuotedString(slice []string) string {
  */
 
 func MustOpenFileForWriting(filename string) io.WriteCloser {
-	fileHandle, err := System.OpenFileWrite(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	fileHandle, err := Storage.Create(filename)
 	if err != nil {
 		logger.Fatal(err, "Unable to create or open file for writing")
 	}
 	return fileHandle
 }
 
+/*
+ * The TOC, report, and config files may be read over NFS, where a reader can
+ * observe a partially-written file, so they are written under a temporary name
+ * and renamed into place only once they are complete.
+ */
+func MustWriteFileAtomically(filename string, write func(file io.Writer)) {
+	tempFilename := filename + ".tmp"
+	_ = Storage.Remove(tempFilename) // Do not append to a temporary file left behind by an earlier run
+	fileHandle := MustOpenFileForWriting(tempFilename)
+	write(fileHandle)
+	err := fileHandle.Close()
+	if err != nil {
+		logger.Fatal(err, "Unable to close file %s", tempFilename)
+	}
+	err = Storage.Rename(tempFilename, filename)
+	if err != nil {
+		logger.Fatal(err, "Unable to rename %s to %s", tempFilename, filename)
+	}
+}
+
 func MustOpenFileForReading(filename string) ReadCloserAt {
-	fileHandle, err := System.OpenFileRead(filename, os.O_RDONLY, 0644)
+	fileHandle, err := Storage.Open(filename)
 	if err != nil {
 		logger.Fatal(err, "Unable to open file for reading")
 	}
 	return fileHandle
 }
 
+type inMemoryFile struct {
+	*bytes.Reader
+}
+
+func (file inMemoryFile) Close() error {
+	return nil
+}
+
+/*
+ * If metadata compression is in use, the whole file is decompressed into memory
+ * so that statements can be read using the uncompressed byte offsets in the TOC.
+ */
+func MustOpenMetadataFileForReading(filename string) ReadCloserAt {
+	fileHandle := MustOpenFileForReading(filename)
+	if !usingMetadataCompression {
+		return fileHandle
+	}
+	defer fileHandle.Close()
+	gzipReader, err := gzip.NewReader(fileHandle)
+	if err != nil {
+		logger.Fatal(err, "Unable to decompress file %s", filename)
+	}
+	contents, err := ioutil.ReadAll(gzipReader)
+	if err != nil {
+		logger.Fatal(err, "Unable to decompress file %s", filename)
+	}
+	return inMemoryFile{bytes.NewReader(contents)}
+}
+
 func FileExistsAndIsReadable(filename string) bool {
-	_, err := System.Stat(filename)
-	if err == nil {
-		var fileHandle io.ReadCloser
-		fileHandle, err = System.OpenFileRead(filename, os.O_RDONLY, 0644)
-		fileHandle.Close()
-		if err == nil {
-			return true
-		}
+	return Storage.Exists(filename)
+}
+
+func MustReadFile(filename string) []byte {
+	fileHandle := MustOpenFileForReading(filename)
+	defer fileHandle.Close()
+	contents, err := ioutil.ReadAll(fileHandle)
+	if err != nil {
+		logger.Fatal(err, "Unable to read file %s", filename)
 	}
-	return false
+	return contents
 }
 
 func CreateBackupLockFile(timestamp string) {
@@ -157,32 +210,144 @@ func CreateDirectoryOnMaster(dirname string) {
 }
 
 func ReadLinesFromFile(filename string) []string {
-	file := MustOpenFileForReading(filename)
+	contents, err := ReadLines(filename)
+	if err != nil {
+		logger.Fatal(err, "Unable to read file %s", filename)
+	}
+	return contents
+}
+
+func ReadLines(filename string) ([]string, error) {
+	file, err := Storage.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
 	contents := make([]string, 0)
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		contents = append(contents, scanner.Text())
 	}
-	return contents
+	return contents, scanner.Err()
 }
 
+/*
+ * A StatementTransform is called on each metadata statement after it has been
+ * generated but before it is written out, and returns the statement to write
+ * in its place.  This allows generated DDL to be adjusted for environments
+ * with special requirements without changing the Print* functions themselves.
+ */
+type StatementTransform func(objectType string, name string, statement string) string
+
+var statementTransform StatementTransform
+
+func SetStatementTransform(transform StatementTransform) {
+	statementTransform = transform
+}
+
+var metadataBufferSize int
+
+/*
+ * By default each write goes straight to the underlying file; a nonzero buffer
+ * size instead collects writes in memory and writes them out in chunks of that
+ * size, which is much faster on networked filesystems.
+ */
+func SetMetadataBufferSize(size int) {
+	metadataBufferSize = size
+}
+
+/*
+ * ByteCount always tracks the number of uncompressed bytes written, so TOC
+ * offsets remain valid whether or not the underlying file is compressed or
+ * buffered.
+ *
+ * When a statement transform is set, output is held in pending until the TOC
+ * entry for the statement is added, so that the statement can be transformed
+ * as a whole.
+ */
 type FileWithByteCount struct {
-	Filename  string
-	writer    io.Writer
-	closer    io.WriteCloser
-	ByteCount uint64
+	Filename   string
+	writer     io.Writer
+	closer     io.WriteCloser
+	compressor io.WriteCloser
+	buffer     *bufio.Writer
+	ByteCount  uint64
+	pending    bytes.Buffer
 }
 
 func NewFileWithByteCount(writer io.Writer) *FileWithByteCount {
-	return &FileWithByteCount{"", writer, nil, 0}
+	return &FileWithByteCount{writer: writer}
 }
 
 func NewFileWithByteCountFromFile(filename string) *FileWithByteCount {
 	file := MustOpenFileForWriting(filename)
-	return &FileWithByteCount{filename, file, file, 0}
+	fileWithByteCount := &FileWithByteCount{Filename: filename, writer: file, closer: file}
+	if usingMetadataCompression {
+		gzipWriter := gzip.NewWriter(file)
+		fileWithByteCount.writer = gzipWriter
+		fileWithByteCount.compressor = gzipWriter
+	}
+	if metadataBufferSize > 0 {
+		fileWithByteCount.buffer = bufio.NewWriterSize(fileWithByteCount.writer, metadataBufferSize)
+		fileWithByteCount.writer = fileWithByteCount.buffer
+	}
+	return fileWithByteCount
+}
+
+func (file *FileWithByteCount) output() io.Writer {
+	if statementTransform != nil {
+		return &file.pending
+	}
+	return file.writer
+}
+
+/*
+ * This writes out any pending output, passing the portion written since start
+ * through the statement transform and adjusting ByteCount to match the length
+ * of the transformed statement.
+ */
+func (file *FileWithByteCount) TransformAndFlush(objectType string, name string, start uint64) {
+	if file.pending.Len() == 0 {
+		return
+	}
+	contents := file.pending.String()
+	file.pending.Reset()
+	flushedCount := file.ByteCount - uint64(len(contents))
+	if statementTransform != nil && start >= flushedCount {
+		offset := start - flushedCount
+		contents = contents[:offset] + statementTransform(objectType, name, contents[offset:])
+	}
+	_, err := io.WriteString(file.writer, contents)
+	if err != nil {
+		logger.Fatal(err, "Unable to write to file")
+	}
+	file.ByteCount = flushedCount + uint64(len(contents))
+}
+
+// This writes out any held or buffered output; it does not affect ByteCount.
+func (file *FileWithByteCount) flush() {
+	if file.pending.Len() > 0 {
+		_, err := file.pending.WriteTo(file.writer)
+		if err != nil {
+			logger.Fatal(err, "Unable to write to file")
+		}
+	}
+	if file.buffer != nil {
+		err := file.buffer.Flush()
+		if err != nil {
+			logger.Fatal(err, "Unable to write to file")
+		}
+	}
 }
 
 func (file *FileWithByteCount) Close() {
+	file.flush()
+	if file.compressor != nil {
+		err := file.compressor.Close()
+		if err != nil {
+			logger.Fatal(err, "Unable to finish compressing file %s", file.Filename)
+		}
+	}
 	if file.closer != nil {
 		file.closer.Close()
 		if file.Filename != "" {
@@ -192,7 +357,7 @@ func (file *FileWithByteCount) Close() {
 }
 
 func (file *FileWithByteCount) MustPrintln(v ...interface{}) {
-	bytesWritten, err := fmt.Fprintln(file.writer, v...)
+	bytesWritten, err := fmt.Fprintln(file.output(), v...)
 	if err != nil {
 		logger.Fatal(err, "Unable to write to file")
 	}
@@ -200,7 +365,7 @@ func (file *FileWithByteCount) MustPrintln(v ...interface{}) {
 }
 
 func (file *FileWithByteCount) MustPrintf(s string, v ...interface{}) {
-	bytesWritten, err := fmt.Fprintf(file.writer, s, v...)
+	bytesWritten, err := fmt.Fprintf(file.output(), s, v...)
 	if err != nil {
 		logger.Fatal(err, "Unable to write to file")
 	}
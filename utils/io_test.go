@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 
 	"github.com/greenplum-db/gpbackup/testutils"
@@ -187,6 +188,25 @@ public."bar%baz"`)
 			contents := utils.ReadLinesFromFile("/tmp/table_file")
 			Expect(contents).To(Equal(expectedContents))
 		})
+		It("panics if the file cannot be opened", func() {
+			utils.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (utils.ReadCloserAt, error) {
+				return nil, errors.New("Permission denied")
+			}
+			defer func() { utils.System.OpenFileRead = utils.OpenFileRead }()
+			defer testutils.ShouldPanicWithMessage("Unable to read file /tmp/table_file: Permission denied")
+			utils.ReadLinesFromFile("/tmp/table_file")
+		})
+	})
+	Describe("ReadLines", func() {
+		It("returns an error instead of panicking if the file cannot be opened", func() {
+			utils.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (utils.ReadCloserAt, error) {
+				return nil, errors.New("Permission denied")
+			}
+			defer func() { utils.System.OpenFileRead = utils.OpenFileRead }()
+			contents, err := utils.ReadLines("/tmp/table_file")
+			Expect(contents).To(BeNil())
+			Expect(err).To(MatchError("Permission denied"))
+		})
 	})
 	Describe("MustPrintf", func() {
 		It("writes to a writable file", func() {
@@ -244,6 +264,106 @@ public."bar%baz"`)
 			file.MustPrintf("message")
 		})
 	})
+	Describe("metadata compression", func() {
+		var filename string
+		BeforeEach(func() {
+			tempFile, _ := ioutil.TempFile("", "gpbackup_predata")
+			tempFile.Close()
+			os.Remove(tempFile.Name())
+			filename = tempFile.Name()
+		})
+		AfterEach(func() {
+			utils.SetMetadataCompression(false)
+			os.Remove(filename)
+		})
+		It("round-trips statements through a compressed metadata file using uncompressed offsets", func() {
+			utils.SetMetadataCompression(true)
+			toc := &utils.TOC{}
+			toc.InitializeEntryMap("global", filename, "postdata", "statistics")
+			file := utils.NewFileWithByteCountFromFile(filename)
+			start := file.ByteCount
+			file.MustPrintf("CREATE SCHEMA schema1;\n")
+			toc.AddMetadataEntry("schema1", "schema1", "SCHEMA", start, file)
+			start = file.ByteCount
+			file.MustPrintf("CREATE TABLE schema1.table1 (i int);\n")
+			toc.AddMetadataEntry("schema1", "table1", "TABLE", start, file)
+			file.Close()
+
+			rawContents, _ := ioutil.ReadFile(filename)
+			Expect(rawContents[:2]).To(Equal([]byte{0x1f, 0x8b}))
+
+			metadataFile := utils.MustOpenMetadataFileForReading(filename)
+			statements := toc.GetSQLStatementForObjectTypes(filename, metadataFile, "TABLE")
+			Expect(statements).To(Equal([]utils.StatementWithType{{Schema: "schema1", Name: "table1", ObjectType: "TABLE", Statement: "CREATE TABLE schema1.table1 (i int);\n"}}))
+		})
+		It("reads an uncompressed metadata file if metadata compression is not in use", func() {
+			toc := &utils.TOC{}
+			toc.InitializeEntryMap("global", filename, "postdata", "statistics")
+			file := utils.NewFileWithByteCountFromFile(filename)
+			file.MustPrintf("CREATE SCHEMA schema1;\n")
+			toc.AddMetadataEntry("schema1", "schema1", "SCHEMA", 0, file)
+			file.Close()
+
+			rawContents, _ := ioutil.ReadFile(filename)
+			Expect(string(rawContents)).To(Equal("CREATE SCHEMA schema1;\n"))
+
+			metadataFile := utils.MustOpenMetadataFileForReading(filename)
+			statements := toc.GetAllSQLStatements(filename, metadataFile)
+			Expect(statements).To(Equal([]utils.StatementWithType{{Schema: "schema1", Name: "schema1", ObjectType: "SCHEMA", Statement: "CREATE SCHEMA schema1;\n"}}))
+		})
+	})
+	Describe("metadata buffering", func() {
+		var filename string
+		writeStatements := func(toc *utils.TOC, filename string) *utils.FileWithByteCount {
+			file := utils.NewFileWithByteCountFromFile(filename)
+			start := file.ByteCount
+			file.MustPrintf("\n\nCREATE SCHEMA schema1;\n")
+			toc.AddMetadataEntry("schema1", "schema1", "SCHEMA", start, file)
+			start = file.ByteCount
+			file.MustPrintln("CREATE TABLE schema1.table1 (i int);")
+			toc.AddMetadataEntry("schema1", "table1", "TABLE", start, file)
+			return file
+		}
+		BeforeEach(func() {
+			tempFile, _ := ioutil.TempFile("", "gpbackup_predata")
+			tempFile.Close()
+			os.Remove(tempFile.Name())
+			filename = tempFile.Name()
+		})
+		AfterEach(func() {
+			utils.SetMetadataBufferSize(0)
+			os.Remove(filename)
+		})
+		It("counts the same bytes and records the same TOC entries whether or not output is buffered", func() {
+			unbufferedTOC := &utils.TOC{}
+			unbufferedTOC.InitializeEntryMap("global", filename, "postdata", "statistics")
+			unbufferedFile := writeStatements(unbufferedTOC, filename)
+			unbufferedFile.Close()
+			unbufferedContents, _ := ioutil.ReadFile(filename)
+			os.Remove(filename)
+
+			utils.SetMetadataBufferSize(16)
+			bufferedTOC := &utils.TOC{}
+			bufferedTOC.InitializeEntryMap("global", filename, "postdata", "statistics")
+			bufferedFile := writeStatements(bufferedTOC, filename)
+			Expect(bufferedFile.ByteCount).To(Equal(unbufferedFile.ByteCount))
+			bufferedFile.Close()
+			bufferedContents, _ := ioutil.ReadFile(filename)
+
+			Expect(bufferedTOC.PredataEntries).To(Equal(unbufferedTOC.PredataEntries))
+			Expect(string(bufferedContents)).To(Equal(string(unbufferedContents)))
+		})
+		It("holds output in the buffer until the file is closed", func() {
+			utils.SetMetadataBufferSize(1024)
+			file := utils.NewFileWithByteCountFromFile(filename)
+			file.MustPrintf("CREATE SCHEMA schema1;\n")
+			rawContents, _ := ioutil.ReadFile(filename)
+			Expect(rawContents).To(BeEmpty())
+			file.Close()
+			rawContents, _ = ioutil.ReadFile(filename)
+			Expect(string(rawContents)).To(Equal("CREATE SCHEMA schema1;\n"))
+		})
+	})
 	Describe("CreateBackupLockFile", func() {
 		It("Does not panic if lock file does not exist for current timestamp", func() {
 			utils.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
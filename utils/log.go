@@ -5,10 +5,18 @@ package utils
  */
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"log/syslog"
 	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -18,8 +26,10 @@ import (
 var (
 	logger *Logger
 
-	defaultLogDir   = "gpAdminLogs"
-	headerFormatStr = "%s:%s:%s:%06d-[%s]:-" // PROGRAMNAME:USERNAME:HOSTNAME:PID-[LOGLEVEL]:-, to match gpcrondump
+	defaultLogDir          = "gpAdminLogs"
+	defaultPrefixSeparator = ":"
+	headerFormatStr        = "%s:%s:%s:%06d-[%s]:-" // PROGRAMNAME:USERNAME:HOSTNAME:PID-[LOGLEVEL]:-, to match gpcrondump
+	jsonTimestampFormat    = "2006-01-02T15:04:05.000Z07:00"
 )
 
 /*
@@ -32,13 +42,16 @@ const (
 	LOGINFO
 	LOGVERBOSE
 	LOGDEBUG
+	LOGTRACE
 )
 
 /*
  * Leveled logging output functions using the above log levels are implemented
  * below.  Info(), Verbose(), and Debug() print messages when the log level is
- * set at or above the log level matching their names.  Warn(), Error(), and
- * Fatal() always print their messages regardless of the current log level.
+ * set at or above the log level matching their names, and Trace() writes its
+ * messages to the log file only, and only when the log level is set to Trace.
+ * Warn(), Error(), and Fatal() always print their messages regardless of the
+ * current log level.
  *
  * The intended usage of these functions is as follows:
  * - Info: Messages that should always be written unless the user explicitly
@@ -47,6 +60,8 @@ const (
  *            printing information about a function's substeps for progress tracking.
  * - Debug: More detailed messages that are mostly useful to developers, e.g.
  *          noting that a function has been called with certain arguments.
+ * - Trace: Messages too voluminous even for Debug output, e.g. the full text
+ *          of generated queries.
  * - Warn: Messages indicating unusual but not incorrect behavior that a user
  *         may want to know, e.g. that certain steps are skipped when using
  *         certain flags.  These messages are shown even if output is suppressed.
@@ -57,13 +72,68 @@ const (
  *          the error message.
  */
 
+/*
+ * A Logger may also be scoped to a named component (e.g. "types" or "data") with
+ * WithComponent, in which case output functions check the verbosity set for
+ * that component with SetComponentVerbosity, if any, instead of the default
+ * verbosity.  Component-scoped loggers share their verbosity settings and output
+ * destinations with the Logger from which they were created.
+ *
+ * Similarly, WithField and WithFields return a Logger that appends key=value
+ * pairs, e.g. the schema and table being backed up, after the prefix of each
+ * line it writes.  Such a Logger shares verbosity and output destinations with
+ * its parent, so context is cleared simply by going back to using the parent.
+ */
 type Logger struct {
-	logStdout   *log.Logger
-	logStderr   *log.Logger
-	logFile     *log.Logger
-	logFileName string
-	verbosity   int
-	header      string
+	logStdout          *log.Logger
+	logStderr          *log.Logger
+	logFile            *log.Logger
+	logFileName        string
+	mutex              *sync.Mutex
+	logSyslog          SyslogWriter
+	verbosity          *int
+	quiet              *bool
+	color              *bool
+	componentVerbosity map[string]int
+	component          string
+	fields             []logField
+	header             string
+	separator          string
+	timestampFormat    string
+	format             string
+}
+
+type logField struct {
+	key   string
+	value string
+}
+
+/*
+ * A SyslogConfig selects a syslog server to which every log line is written,
+ * in addition to the log file or, if SyslogOnly is set, instead of it.  An
+ * empty Network and Address select the local syslog server; otherwise Network
+ * is e.g. "udp" or "tcp" and Address is the host:port of a remote server.
+ */
+type SyslogConfig struct {
+	Network    string
+	Address    string
+	SyslogOnly bool
+}
+
+/*
+ * In the json log format, each log line is a single JSON object with these
+ * fields instead of a line with a text prefix.
+ */
+type jsonLogLine struct {
+	Timestamp string            `json:"timestamp"`
+	Level     string            `json:"level"`
+	Program   string            `json:"program"`
+	User      string            `json:"user"`
+	Host      string            `json:"host"`
+	Pid       int               `json:"pid"`
+	Component string            `json:"component,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Message   string            `json:"message"`
 }
 
 /*
@@ -72,16 +142,24 @@ type Logger struct {
 
 // stdout and stderr are passed in to this function to enable output redirection in tests.
 func NewLogger(stdout io.Writer, stderr io.Writer, logFile io.Writer, logFileName string, verbosity int, header string) *Logger {
-	if verbosity < LOGERROR || verbosity > LOGDEBUG {
+	if verbosity < LOGERROR || verbosity > LOGTRACE {
 		Abort("Cannot create logger with an invalid logging level")
 	}
+	quiet := false
+	color := false
 	return &Logger{
-		logStdout:   log.New(stdout, "", 0),
-		logStderr:   log.New(stderr, "", 0),
-		logFile:     log.New(logFile, "", 0),
-		logFileName: logFileName,
-		verbosity:   verbosity,
-		header:      header,
+		logStdout:          log.New(stdout, "", 0),
+		logStderr:          log.New(stderr, "", 0),
+		logFile:            log.New(logFile, "", 0),
+		logFileName:        logFileName,
+		mutex:              &sync.Mutex{},
+		verbosity:          &verbosity,
+		quiet:              &quiet,
+		color:              &color,
+		componentVerbosity: make(map[string]int, 0),
+		header:             header,
+		separator:          defaultPrefixSeparator,
+		format:             "text",
 	}
 }
 
@@ -96,90 +174,535 @@ func SetLogger(log *Logger) {
 /*
  * This function creates a logger, sets it to global so it's usable in utils/,
  * and then returns it so the same logger can be used in backup/ and restore/.
+ *
+ * Output that would normally go to stdout and stderr can be redirected by
+ * passing in up to two writers, e.g. when gpbackup is used as a library; the
+ * first replaces os.Stdout and the second replaces os.Stderr, and a nil writer
+ * keeps the default.  The log file is unaffected.
+ */
+func InitializeLogging(program string, logdir string, outputWriters ...io.Writer) *Logger {
+	return initializeLogging(program, logdir, nil, outputWriters...)
+}
+
+/*
+ * This function creates a logger as InitializeLogging does, but which also
+ * writes to the syslog server selected by syslogConfig.  If the syslog server
+ * cannot be reached, the logger writes to the log file alone and a warning is
+ * logged, so that an unreachable syslog server does not prevent a backup or
+ * restore.
+ */
+func InitializeLoggingWithSyslog(program string, logdir string, syslogConfig SyslogConfig, outputWriters ...io.Writer) *Logger {
+	return initializeLogging(program, logdir, &syslogConfig, outputWriters...)
+}
+
+/*
+ * This function creates a logger according to the --syslog, --syslog-server,
+ * and --syslog-only flags shared by gpbackup and gprestore, using syslog if
+ * any of them is set.  A remote syslog server is reached over UDP.
  */
-func InitializeLogging(program string, logdir string) *Logger {
+func InitializeLoggingFromFlags(program string, useSyslog bool, syslogServer string, syslogOnly bool) *Logger {
+	if !useSyslog && syslogServer == "" && !syslogOnly {
+		return InitializeLogging(program, "")
+	}
+	syslogConfig := SyslogConfig{Address: syslogServer, SyslogOnly: syslogOnly}
+	if syslogServer != "" {
+		syslogConfig.Network = "udp"
+	}
+	return InitializeLoggingWithSyslog(program, "", syslogConfig)
+}
+
+func initializeLogging(program string, logdir string, syslogConfig *SyslogConfig, outputWriters ...io.Writer) *Logger {
 	user, homedir, host := GetUserAndHostInfo()
 	pid := System.Getpid()
 	header := fmt.Sprintf(headerFormatStr, program, user, host, pid, "%s")
 
+	var stdout io.Writer = os.Stdout
+	var stderr io.Writer = os.Stderr
+	if len(outputWriters) > 0 && outputWriters[0] != nil {
+		stdout = outputWriters[0]
+	}
+	if len(outputWriters) > 1 && outputWriters[1] != nil {
+		stderr = outputWriters[1]
+	}
+
 	// Create a temporary logger to start in case there are fatal errors during initialization
 	nullFile, _ := os.Open("/dev/null")
-	tempLogger := NewLogger(os.Stdout, os.Stderr, nullFile, "/dev/null", LOGINFO, header)
+	tempLogger := NewLogger(stdout, stderr, nullFile, "/dev/null", LOGINFO, header)
 	SetLogger(tempLogger)
 
-	if logdir == "" {
-		logdir = fmt.Sprintf("%s/%s", homedir, defaultLogDir)
+	var syslogWriter SyslogWriter
+	var syslogErr error
+	if syslogConfig != nil {
+		syslogWriter, syslogErr = System.DialSyslog(syslogConfig.Network, syslogConfig.Address, syslog.LOG_INFO|syslog.LOG_USER, program)
 	}
 
-	CreateDirectoryOnMaster(logdir)
+	var logFileHandle io.Writer = ioutil.Discard
+	logfile := ""
+	if syslogWriter == nil || !syslogConfig.SyslogOnly {
+		if logdir == "" {
+			logdir = fmt.Sprintf("%s/%s", homedir, defaultLogDir)
+		}
 
-	logfile := fmt.Sprintf("%s/%s_%s.log", logdir, program, CurrentTimestamp()[0:8])
-	logFileHandle := MustOpenFileForWriting(logfile)
+		CreateDirectoryOnMaster(logdir)
 
-	logger := NewLogger(os.Stdout, os.Stderr, logFileHandle, logfile, LOGINFO, header)
+		logfile = fmt.Sprintf("%s/%s_%s.log", logdir, program, CurrentTimestamp()[0:8])
+		logFileHandle = MustOpenFileForWriting(logfile)
+	}
+
+	logger := NewLogger(stdout, stderr, logFileHandle, logfile, LOGINFO, header)
+	logger.logSyslog = syslogWriter
 	SetLogger(logger)
+	logger.SetColor(isTerminal(stdout) && isTerminal(stderr))
+	logger.setVerbosityFromEnvironment()
+	if logfile != "" {
+		logger.pruneLogFilesFromEnvironment(logdir, program)
+	}
+	if syslogErr != nil {
+		syslogName := syslogConfig.Address
+		if syslogName == "" {
+			syslogName = "the local syslog server"
+		}
+		logger.Warn("Unable to connect to %s, logging to %s only: %v", syslogName, logfile, syslogErr)
+	}
 	return logger
 }
 
+var logLevelNames = map[string]int{
+	"error":   LOGERROR,
+	"info":    LOGINFO,
+	"verbose": LOGVERBOSE,
+	"debug":   LOGDEBUG,
+	"trace":   LOGTRACE,
+}
+
+/*
+ * The initial verbosity can be set with the GPBACKUP_LOG_LEVEL environment
+ * variable, e.g. to get debug output during a support incident without
+ * changing how gpbackup is invoked.  Flags such as --verbose set the verbosity
+ * after initialization and so take precedence over the environment variable.
+ */
+func (logger *Logger) setVerbosityFromEnvironment() {
+	levelStr := System.Getenv("GPBACKUP_LOG_LEVEL")
+	if levelStr == "" {
+		return
+	}
+	if level, ok := logLevelNames[strings.ToLower(levelStr)]; ok {
+		logger.SetVerbosity(level)
+	} else {
+		logger.Warn("Invalid GPBACKUP_LOG_LEVEL value %s; the log level must be error, info, verbose, debug, or trace", levelStr)
+	}
+}
+
+/*
+ * If the GPBACKUP_LOG_RETENTION_DAYS environment variable is set, log files of
+ * this program in the log directory that are dated more than that many days
+ * before today are removed, so that old logs do not accumulate.  Files are
+ * dated by name rather than by modification time, and any file whose name does
+ * not end in a date, such as the current log link, is left alone.  A file that
+ * cannot be removed is only warned about, so that pruning never prevents a
+ * backup or restore.
+ */
+func (logger *Logger) pruneLogFilesFromEnvironment(logdir string, program string) {
+	daysStr := System.Getenv("GPBACKUP_LOG_RETENTION_DAYS")
+	if daysStr == "" {
+		return
+	}
+	days, err := strconv.Atoi(daysStr)
+	if err != nil || days < 1 {
+		logger.Warn("Invalid GPBACKUP_LOG_RETENTION_DAYS value %s; the retention period must be a positive number of days", daysStr)
+		return
+	}
+	logger.pruneLogFiles(logdir, program, days)
+}
+
+func (logger *Logger) pruneLogFiles(logdir string, program string, days int) {
+	cutoff := System.Now().AddDate(0, 0, -days).Format("20060102")
+	prefix := fmt.Sprintf("%s/%s_", logdir, program)
+	logFiles, err := System.Glob(prefix + "*.log")
+	if err != nil {
+		logger.Warn("Unable to list log files in %s: %v", logdir, err)
+		return
+	}
+	for _, logFile := range logFiles {
+		date := strings.TrimSuffix(strings.TrimPrefix(logFile, prefix), ".log")
+		if _, err := time.Parse("20060102", date); err != nil || date >= cutoff {
+			continue
+		}
+		if err := System.Remove(logFile); err != nil {
+			logger.Warn("Unable to remove expired log file %s: %v", logFile, err)
+			continue
+		}
+		logger.Verbose("Removed expired log file %s", logFile)
+	}
+}
+
+/*
+ * The default timestamp is formatted piece by piece rather than by building a
+ * layout containing the separator, as time.Format would interpret any digits
+ * or month names in the separator as part of the layout.
+ */
 func (logger *Logger) GetLogPrefix(level string) string {
-	logTimestamp := System.Now().Format("20060102:15:04:05")
+	now := System.Now()
+	logTimestamp := ""
+	if logger.timestampFormat != "" {
+		logTimestamp = now.Format(logger.timestampFormat)
+	} else {
+		timestampFields := []string{now.Format("20060102"), now.Format("15"), now.Format("04"), now.Format("05")}
+		logTimestamp = strings.Join(timestampFields, logger.separator)
+	}
 	return fmt.Sprintf("%s %s", logTimestamp, fmt.Sprintf(logger.header, level))
 }
 
+/*
+ * This replaces the separator between the fields of the log prefix, e.g. so that
+ * log lines can be split unambiguously when the hostname contains colons.
+ */
+func (logger *Logger) SetPrefixSeparator(separator string) {
+	ValidateLogPrefixSeparator(separator)
+	if fields := splitHeader(logger.header, logger.separator); fields != nil {
+		logger.header = strings.Join(fields, separator) + "-[%s]" + separator + "-"
+	}
+	logger.separator = separator
+}
+
+// This returns the part of the log prefix that marks the level, e.g. "[CRITICAL]:-"
+func (logger *Logger) LevelMarker(level string) string {
+	return fmt.Sprintf("[%s]%s-", level, logger.separator)
+}
+
+func ValidateLogPrefixSeparator(separator string) {
+	if separator == "" {
+		logger.Fatal(errors.New("The log prefix separator cannot be empty"), "")
+	}
+}
+
+/*
+ * This replaces the time.Time layout of the timestamp at the start of the log
+ * prefix, e.g. time.RFC3339 for timestamps with a timezone offset when logs
+ * from several timezones are compared.  By default the timestamp is formatted
+ * as 20060102:15:04:05, with the date, hour, minute, and second split by the
+ * prefix separator.
+ */
+func (logger *Logger) SetTimestampFormat(layout string) {
+	if layout == "" {
+		logger.Fatal(errors.New("The log timestamp format cannot be empty"), "")
+	}
+	logger.timestampFormat = layout
+}
+
+/*
+ * This splits the header into the program name, user name, hostname, and PID.
+ * The program name and user name cannot contain the separator and the PID is
+ * followed by the log level, so the hostname is whatever lies between them.
+ */
+func splitHeader(header string, separator string) []string {
+	fields := strings.SplitN(header, separator, 3)
+	if len(fields) != 3 {
+		return nil
+	}
+	hostAndPid := fields[2]
+	if levelIndex := strings.Index(hostAndPid, "-[%s]"); levelIndex != -1 {
+		hostAndPid = hostAndPid[:levelIndex]
+	}
+	pidIndex := strings.LastIndex(hostAndPid, separator)
+	if pidIndex == -1 {
+		return nil
+	}
+	return []string{fields[0], fields[1], hostAndPid[:pidIndex], hostAndPid[pidIndex+len(separator):]}
+}
+
+/*
+ * This sets the format of log lines, either "text" for lines with the usual
+ * prefix or "json" for one JSON object per line, e.g. for log aggregation
+ * tools that cannot parse the prefix.  Verbosity and the destinations of each
+ * log level are the same in both formats.
+ */
+func (logger *Logger) SetFormat(format string) {
+	if format != "text" && format != "json" {
+		logger.Fatal(errors.Errorf("Invalid log format %s; the log format must be text or json", format), "")
+	}
+	logger.format = format
+}
+
+func (logger *Logger) formatMessage(level string, message string) string {
+	if logger.format != "json" {
+		return logger.GetLogPrefix(level) + logger.formatFields() + message
+	}
+	line := jsonLogLine{
+		Timestamp: System.Now().Format(jsonTimestampFormat),
+		Level:     level,
+		Component: logger.component,
+		Message:   message,
+	}
+	if len(logger.fields) > 0 {
+		line.Fields = make(map[string]string, len(logger.fields))
+		for _, field := range logger.fields {
+			line.Fields[field.key] = field.value
+		}
+	}
+	if fields := splitHeader(logger.header, logger.separator); fields != nil {
+		line.Program, line.User, line.Host = fields[0], fields[1], fields[2]
+		line.Pid, _ = strconv.Atoi(fields[3])
+	}
+	lineBytes, _ := json.Marshal(line)
+	return string(lineBytes)
+}
+
+/*
+ * Each line is written to syslog exactly as it is written to the log file, with
+ * a priority matching its log level.  Errors writing to syslog are ignored, as
+ * errors writing to the log file are.
+ */
+func (logger *Logger) writeSyslog(level string, message string) {
+	if logger.logSyslog == nil {
+		return
+	}
+	switch level {
+	case "CRITICAL":
+		logger.logSyslog.Crit(message)
+	case "ERROR":
+		logger.logSyslog.Err(message)
+	case "WARNING":
+		logger.logSyslog.Warning(message)
+	case "INFO":
+		logger.logSyslog.Info(message)
+	default:
+		logger.logSyslog.Debug(message)
+	}
+}
+
 func (logger *Logger) GetLogFilePath() string {
 	return logger.logFileName
 }
 
+/*
+ * This points a symlink named <program>_current.log in the log directory at the
+ * log file for this run, so that the active log can be followed at a stable
+ * path.  The target is relative, like the latest backup pointer, and the link is
+ * left in place on exit so that it refers to the log of the most recent run.
+ */
+func (logger *Logger) LinkCurrentLogFile() error {
+	if logger.logFileName == "" {
+		return errors.New("Logging to syslog only, so there is no log file to link to")
+	}
+	logDir, logFile := path.Split(logger.logFileName)
+	linkPath := path.Join(logDir, logFile[:strings.LastIndex(logFile, "_")]+"_current.log")
+	System.Remove(linkPath)
+	return System.Symlink(logFile, linkPath)
+}
+
 func (logger *Logger) GetVerbosity() int {
-	return logger.verbosity
+	return *logger.verbosity
 }
 
 func (logger *Logger) SetVerbosity(verbosity int) {
-	logger.verbosity = verbosity
+	*logger.verbosity = verbosity
+}
+
+/*
+ * In quiet mode nothing is written to stdout, regardless of verbosity, e.g. for
+ * wrapper scripts that only check the exit code and the report file.  Warnings
+ * are then written to the log file only; errors are still written to stderr,
+ * and every level is still written to the log file.
+ */
+func (logger *Logger) SetQuiet(quiet bool) {
+	*logger.quiet = quiet
+}
+
+func (logger *Logger) IsQuiet() bool {
+	return *logger.quiet
+}
+
+/*
+ * With color enabled, the level of each WARNING and ERROR line is highlighted
+ * in the output to stdout and stderr, to stand out in interactive runs.  Color is enabled by InitializeLogging when both are terminals.  The
+ * log file and syslog never receive color codes.
+ */
+func (logger *Logger) SetColor(enabled bool) {
+	*logger.color = enabled
+}
+
+func (logger *Logger) IsColor() bool {
+	return *logger.color
+}
+
+func isTerminal(writer io.Writer) bool {
+	file, ok := writer.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (logger *Logger) WithComponent(component string) *Logger {
+	componentLogger := *logger
+	componentLogger.component = component
+	return &componentLogger
+}
+
+/*
+ * Fields are written in the order in which they were added.  Adding a field
+ * with a key the logger already has replaces that field's value in place.
+ */
+func (logger *Logger) WithField(key string, value string) *Logger {
+	fieldLogger := *logger
+	fieldLogger.fields = make([]logField, 0, len(logger.fields)+1)
+	replaced := false
+	for _, field := range logger.fields {
+		if field.key == key {
+			field.value = value
+			replaced = true
+		}
+		fieldLogger.fields = append(fieldLogger.fields, field)
+	}
+	if !replaced {
+		fieldLogger.fields = append(fieldLogger.fields, logField{key: key, value: value})
+	}
+	return &fieldLogger
+}
+
+// Fields given in a map are added in order of their keys, for consistent output
+func (logger *Logger) WithFields(fields map[string]string) *Logger {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fieldLogger := *logger
+	for _, key := range keys {
+		fieldLogger = *fieldLogger.WithField(key, fields[key])
+	}
+	return &fieldLogger
+}
+
+func (logger *Logger) formatFields() string {
+	formatted := ""
+	for _, field := range logger.fields {
+		formatted += fmt.Sprintf("%s=%s ", field.key, field.value)
+	}
+	return formatted
+}
+
+func (logger *Logger) GetComponent() string {
+	return logger.component
+}
+
+func (logger *Logger) SetComponentVerbosity(component string, verbosity int) {
+	if verbosity < LOGERROR || verbosity > LOGTRACE {
+		Abort("Cannot set an invalid logging level for component %s", component)
+	}
+	logger.componentVerbosity[component] = verbosity
+}
+
+/*
+ * Sets the verbosity of a component from a setting of the form component=level,
+ * e.g. "types=debug", as passed to the --component-log-level flag.
+ */
+func (logger *Logger) SetComponentVerbosityFromString(setting string) {
+	parts := strings.SplitN(setting, "=", 2)
+	if len(parts) == 2 && parts[0] != "" {
+		if level, ok := logLevelNames[strings.ToLower(parts[1])]; ok {
+			logger.SetComponentVerbosity(parts[0], level)
+			return
+		}
+	}
+	logger.Fatal(errors.Errorf("Invalid component log level %s; the setting must be of the form component=level, where level is error, info, verbose, debug, or trace", setting), "")
+}
+
+func (logger *Logger) ClearComponentVerbosity(component string) {
+	delete(logger.componentVerbosity, component)
+}
+
+/*
+ * Returns the verbosity set for the logger's component, if there is one, and
+ * the default verbosity otherwise.
+ */
+func (logger *Logger) GetEffectiveVerbosity() int {
+	if verbosity, ok := logger.componentVerbosity[logger.component]; ok && logger.component != "" {
+		return verbosity
+	}
+	return *logger.verbosity
 }
 
 /*
  * Log output functions, as described above
  */
 
-func (logger *Logger) Info(s string, v ...interface{}) {
-	message := logger.GetLogPrefix("INFO") + fmt.Sprintf(s, v...)
+/*
+ * Each line is written to every destination while holding the logger's lock,
+ * which is shared with the loggers created by WithComponent, so that lines
+ * logged concurrently, e.g. by goroutines backing up data in parallel, are not
+ * interleaved when several destinations share an underlying writer.  A nil
+ * console logger, or stdout in quiet mode, writes the line to the log file and
+ * syslog only.
+ */
+func (logger *Logger) write(level string, message string, console *log.Logger) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
 	logger.logFile.Output(1, message)
-	if logger.verbosity >= LOGINFO {
-		logger.logStdout.Output(1, message)
+	logger.writeSyslog(level, message)
+	if console != nil && !(console == logger.logStdout && *logger.quiet) {
+		console.Output(1, logger.colorize(level, message))
 	}
 }
 
+var levelColors = map[string]string{
+	"WARNING": "\x1b[33m",
+	"ERROR":   "\x1b[31m",
+}
+
+// Only the bracketed level in the prefix is colored, and only in the text format
+func (logger *Logger) colorize(level string, message string) string {
+	color, ok := levelColors[level]
+	if !*logger.color || !ok || logger.format != "text" {
+		return message
+	}
+	levelStr := fmt.Sprintf("[%s]", level)
+	return strings.Replace(message, levelStr, color+levelStr+"\x1b[0m", 1)
+}
+
+func (logger *Logger) Info(s string, v ...interface{}) {
+	var console *log.Logger
+	if logger.GetEffectiveVerbosity() >= LOGINFO {
+		console = logger.logStdout
+	}
+	logger.write("INFO", logger.formatMessage("INFO", fmt.Sprintf(s, v...)), console)
+}
+
 func (logger *Logger) Warn(s string, v ...interface{}) {
-	message := logger.GetLogPrefix("WARNING") + fmt.Sprintf(s, v...)
-	logger.logFile.Output(1, message)
-	logger.logStdout.Output(1, message)
+	logger.write("WARNING", logger.formatMessage("WARNING", fmt.Sprintf(s, v...)), logger.logStdout)
 }
 
 func (logger *Logger) Verbose(s string, v ...interface{}) {
-	message := logger.GetLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
-	logger.logFile.Output(1, message)
-	if logger.verbosity >= LOGVERBOSE {
-		logger.logStdout.Output(1, message)
+	var console *log.Logger
+	if logger.GetEffectiveVerbosity() >= LOGVERBOSE {
+		console = logger.logStdout
 	}
+	logger.write("DEBUG", logger.formatMessage("DEBUG", fmt.Sprintf(s, v...)), console)
 }
 
 func (logger *Logger) Debug(s string, v ...interface{}) {
-	message := logger.GetLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
-	logger.logFile.Output(1, message)
-	if logger.verbosity >= LOGDEBUG {
-		logger.logStdout.Output(1, message)
+	var console *log.Logger
+	if logger.GetEffectiveVerbosity() >= LOGDEBUG {
+		console = logger.logStdout
 	}
+	logger.write("DEBUG", logger.formatMessage("DEBUG", fmt.Sprintf(s, v...)), console)
+}
+
+func (logger *Logger) Trace(s string, v ...interface{}) {
+	if logger.GetEffectiveVerbosity() < LOGTRACE {
+		return
+	}
+	logger.write("TRACE", logger.formatMessage("TRACE", fmt.Sprintf(s, v...)), nil)
 }
 
 func (logger *Logger) Error(s string, v ...interface{}) {
-	message := logger.GetLogPrefix("ERROR") + fmt.Sprintf(s, v...)
-	logger.logFile.Output(1, message)
-	logger.logStderr.Output(1, message)
+	logger.write("ERROR", logger.formatMessage("ERROR", fmt.Sprintf(s, v...)), logger.logStderr)
 }
 
 func (logger *Logger) Fatal(err error, s string, v ...interface{}) {
-	message := logger.GetLogPrefix("CRITICAL") + fmt.Sprintf(s, v...)
+	message := fmt.Sprintf(s, v...)
 	stackTraceStr := ""
 	if err != nil {
 		if s != "" {
@@ -188,8 +711,14 @@ func (logger *Logger) Fatal(err error, s string, v ...interface{}) {
 		message += fmt.Sprintf("%v", err)
 		stackTraceStr = formatStackTrace(errors.WithStack(err))
 	}
-	logger.logFile.Output(1, message+stackTraceStr)
-	if logger.verbosity >= LOGVERBOSE {
+	// The lock is released before panicking, so that deferred recovery can log
+	logger.write("CRITICAL", logger.formatMessage("CRITICAL", message+stackTraceStr), nil)
+	/*
+	 * The panic message keeps the text prefix in any format, as ParseErrorMessage
+	 * expects, but not the fields, which are only for the log line
+	 */
+	message = logger.GetLogPrefix("CRITICAL") + message
+	if logger.GetEffectiveVerbosity() >= LOGVERBOSE {
 		Abort(message + stackTraceStr)
 	} else {
 		Abort(message)
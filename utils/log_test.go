@@ -3,19 +3,61 @@ package utils_test
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
+	"log/syslog"
 	"os"
 	"os/user"
 	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/greenplum-db/gpbackup/testutils"
 	"github.com/greenplum-db/gpbackup/utils"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/pkg/errors"
 )
 
+/*
+ * This writer appends one byte at a time and yields between bytes, so that
+ * concurrent writes to it interleave unless they are serialized by the caller.
+ */
+type byteAtATimeWriter struct {
+	mutex sync.Mutex
+	bytes []byte
+}
+
+func (writer *byteAtATimeWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		writer.mutex.Lock()
+		writer.bytes = append(writer.bytes, b)
+		writer.mutex.Unlock()
+		runtime.Gosched()
+	}
+	return len(p), nil
+}
+
+type fakeSyslogWriter struct {
+	lines []string
+}
+
+func (writer *fakeSyslogWriter) write(priority string, m string) error {
+	writer.lines = append(writer.lines, fmt.Sprintf("%s %s", priority, m))
+	return nil
+}
+
+func (writer *fakeSyslogWriter) Crit(m string) error    { return writer.write("CRIT", m) }
+func (writer *fakeSyslogWriter) Err(m string) error     { return writer.write("ERR", m) }
+func (writer *fakeSyslogWriter) Warning(m string) error { return writer.write("WARNING", m) }
+func (writer *fakeSyslogWriter) Info(m string) error    { return writer.write("INFO", m) }
+func (writer *fakeSyslogWriter) Debug(m string) error   { return writer.write("DEBUG", m) }
+
 var _ = Describe("utils/log tests", func() {
 	var (
 		testLogger   *utils.Logger
@@ -108,6 +150,248 @@ var _ = Describe("utils/log tests", func() {
 				utils.InitializeLogging("testProgram", "/tmp/log_dir")
 			})
 		})
+		Context("Logger initialized with custom output writers", func() {
+			It("routes stdout and stderr output to the passed-in writers", func() {
+				customStdout := gbytes.NewBuffer()
+				customStderr := gbytes.NewBuffer()
+				newLogger := utils.InitializeLogging("testProgram", "/tmp/log_dir", customStdout, customStderr)
+				newLogger.Info("info message")
+				newLogger.Error("error message")
+				testutils.ExpectRegexp(customStdout, "[INFO]:-info message")
+				testutils.NotExpectRegexp(customStdout, "[ERROR]:-error message")
+				testutils.ExpectRegexp(customStderr, "[ERROR]:-error message")
+				testutils.ExpectRegexp(buffer, "[INFO]:-info message")
+			})
+			It("keeps the default for a nil writer", func() {
+				stdoutRead, stdoutWrite, _ := os.Pipe()
+				realStdout := os.Stdout
+				os.Stdout = stdoutWrite
+				defer func() { os.Stdout = realStdout }()
+				customStderr := gbytes.NewBuffer()
+				newLogger := utils.InitializeLogging("testProgram", "/tmp/log_dir", nil, customStderr)
+				newLogger.Info("info message")
+				newLogger.Error("error message")
+				stdoutWrite.Close()
+				stdoutContents, _ := ioutil.ReadAll(stdoutRead)
+				Expect(string(stdoutContents)).To(ContainSubstring("[INFO]:-info message"))
+				testutils.NotExpectRegexp(customStderr, "info message")
+				testutils.ExpectRegexp(customStderr, "[ERROR]:-error message")
+			})
+		})
+		Context("Logger initialized with a log level from the environment", func() {
+			It("sets the verbosity from GPBACKUP_LOG_LEVEL", func() {
+				utils.System.Getenv = func(key string) string {
+					if key == "GPBACKUP_LOG_LEVEL" {
+						return "Verbose"
+					}
+					return ""
+				}
+				newLogger := utils.InitializeLogging("testProgram", "/tmp/log_dir")
+				Expect(newLogger.GetVerbosity()).To(Equal(utils.LOGVERBOSE))
+			})
+			It("lets a later SetVerbosity call override GPBACKUP_LOG_LEVEL", func() {
+				utils.System.Getenv = func(key string) string { return "debug" }
+				newLogger := utils.InitializeLogging("testProgram", "/tmp/log_dir")
+				newLogger.SetVerbosity(utils.LOGERROR)
+				Expect(newLogger.GetVerbosity()).To(Equal(utils.LOGERROR))
+			})
+			It("warns and keeps the default verbosity for an invalid GPBACKUP_LOG_LEVEL", func() {
+				utils.System.Getenv = func(key string) string { return "loud" }
+				customStdout := gbytes.NewBuffer()
+				newLogger := utils.InitializeLogging("testProgram", "/tmp/log_dir", customStdout)
+				Expect(newLogger.GetVerbosity()).To(Equal(utils.LOGINFO))
+				testutils.ExpectRegexp(customStdout, "[WARNING]:-Invalid GPBACKUP_LOG_LEVEL value loud; the log level must be error, info, verbose, debug, or trace")
+			})
+		})
+		Context("Logger initialized with a log retention period from the environment", func() {
+			var removed []string
+			BeforeEach(func() {
+				removed = []string{}
+				utils.System.Getenv = func(key string) string {
+					if key == "GPBACKUP_LOG_RETENTION_DAYS" {
+						return "10"
+					}
+					return ""
+				}
+				utils.System.Glob = func(pattern string) ([]string, error) {
+					Expect(pattern).To(Equal("/tmp/log_dir/testProgram_*.log"))
+					return []string{
+						"/tmp/log_dir/testProgram_20161101.log",
+						"/tmp/log_dir/testProgram_20161215.log",
+						"/tmp/log_dir/testProgram_20161222.log",
+						"/tmp/log_dir/testProgram_20161225.log",
+						"/tmp/log_dir/testProgram_20170101.log",
+						"/tmp/log_dir/testProgram_current.log",
+					}, nil
+				}
+				utils.System.Remove = func(name string) error {
+					removed = append(removed, name)
+					return nil
+				}
+			})
+			It("removes only the log files dated before the retention period", func() {
+				utils.InitializeLogging("testProgram", "/tmp/log_dir")
+				Expect(removed).To(Equal([]string{"/tmp/log_dir/testProgram_20161101.log", "/tmp/log_dir/testProgram_20161215.log"}))
+			})
+			It("warns and continues if an expired log file cannot be removed", func() {
+				utils.System.Remove = func(name string) error {
+					removed = append(removed, name)
+					if name == "/tmp/log_dir/testProgram_20161101.log" {
+						return errors.New("permission denied")
+					}
+					return nil
+				}
+				customStdout := gbytes.NewBuffer()
+				utils.InitializeLogging("testProgram", "/tmp/log_dir", customStdout)
+				Expect(removed).To(Equal([]string{"/tmp/log_dir/testProgram_20161101.log", "/tmp/log_dir/testProgram_20161215.log"}))
+				testutils.ExpectRegexp(customStdout, "[WARNING]:-Unable to remove expired log file /tmp/log_dir/testProgram_20161101.log: permission denied")
+			})
+			It("removes nothing if GPBACKUP_LOG_RETENTION_DAYS is not set", func() {
+				utils.System.Getenv = func(key string) string { return "" }
+				utils.InitializeLogging("testProgram", "/tmp/log_dir")
+				Expect(removed).To(BeEmpty())
+			})
+			It("warns and removes nothing for an invalid GPBACKUP_LOG_RETENTION_DAYS", func() {
+				utils.System.Getenv = func(key string) string {
+					if key == "GPBACKUP_LOG_RETENTION_DAYS" {
+						return "forever"
+					}
+					return ""
+				}
+				customStdout := gbytes.NewBuffer()
+				utils.InitializeLogging("testProgram", "/tmp/log_dir", customStdout)
+				Expect(removed).To(BeEmpty())
+				testutils.ExpectRegexp(customStdout, "[WARNING]:-Invalid GPBACKUP_LOG_RETENTION_DAYS value forever; the retention period must be a positive number of days")
+			})
+		})
+		Context("Logger initialized with syslog", func() {
+			var syslogWriter *fakeSyslogWriter
+			BeforeEach(func() {
+				syslogWriter = &fakeSyslogWriter{}
+				utils.System.DialSyslog = func(network string, raddr string, priority syslog.Priority, tag string) (utils.SyslogWriter, error) {
+					return syslogWriter, nil
+				}
+			})
+			It("dials the given syslog server with the program name as the tag", func() {
+				var dialedNetwork, dialedAddress, dialedTag string
+				utils.System.DialSyslog = func(network string, raddr string, priority syslog.Priority, tag string) (utils.SyslogWriter, error) {
+					dialedNetwork, dialedAddress, dialedTag = network, raddr, tag
+					return syslogWriter, nil
+				}
+				utils.InitializeLoggingWithSyslog("testProgram", "/tmp/log_dir", utils.SyslogConfig{Network: "udp", Address: "loghost:514"})
+				Expect(dialedNetwork).To(Equal("udp"))
+				Expect(dialedAddress).To(Equal("loghost:514"))
+				Expect(dialedTag).To(Equal("testProgram"))
+			})
+			It("writes each log line to both syslog and the log file with a priority matching its level", func() {
+				newLogger := utils.InitializeLoggingWithSyslog("testProgram", "/tmp/log_dir", utils.SyslogConfig{}, gbytes.NewBuffer(), gbytes.NewBuffer())
+				newLogger.SetVerbosity(utils.LOGDEBUG)
+				newLogger.Info("info message")
+				newLogger.Warn("warn message")
+				newLogger.Verbose("verbose message")
+				newLogger.Debug("debug message")
+				newLogger.Error("error message")
+				defer testutils.ShouldPanicWithMessage("fatal message")
+				defer func() {
+					Expect(syslogWriter.lines).To(HaveLen(6))
+					Expect(syslogWriter.lines[0]).To(HaveSuffix("[INFO]:-info message"))
+					Expect(syslogWriter.lines[0]).To(HavePrefix("INFO "))
+					Expect(syslogWriter.lines[1]).To(HavePrefix("WARNING "))
+					Expect(syslogWriter.lines[2]).To(HavePrefix("DEBUG "))
+					Expect(syslogWriter.lines[3]).To(HavePrefix("DEBUG "))
+					Expect(syslogWriter.lines[4]).To(HavePrefix("ERR "))
+					Expect(syslogWriter.lines[5]).To(HavePrefix("CRIT "))
+					testutils.ExpectRegexp(buffer, "[INFO]:-info message")
+				}()
+				newLogger.Fatal(errors.New("fatal message"), "")
+			})
+			It("does not create a log file if logging to syslog only", func() {
+				openedFile := false
+				utils.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+					openedFile = true
+					return buffer, nil
+				}
+				newLogger := utils.InitializeLoggingWithSyslog("testProgram", "/tmp/log_dir", utils.SyslogConfig{SyslogOnly: true}, gbytes.NewBuffer())
+				newLogger.Info("info message")
+				Expect(openedFile).To(BeFalse())
+				Expect(newLogger.GetLogFilePath()).To(Equal(""))
+				Expect(syslogWriter.lines).To(Equal([]string{"INFO " + newLogger.GetLogPrefix("INFO") + "info message"}))
+			})
+			It("falls back to the log file and warns if syslog cannot be reached", func() {
+				utils.System.DialSyslog = func(network string, raddr string, priority syslog.Priority, tag string) (utils.SyslogWriter, error) {
+					return nil, errors.New("connection refused")
+				}
+				customStdout := gbytes.NewBuffer()
+				newLogger := utils.InitializeLoggingWithSyslog("testProgram", "/tmp/log_dir", utils.SyslogConfig{Network: "tcp", Address: "loghost:514", SyslogOnly: true}, customStdout)
+				newLogger.Info("info message")
+				Expect(newLogger.GetLogFilePath()).To(Equal("/tmp/log_dir/testProgram_20170101.log"))
+				testutils.ExpectRegexp(customStdout, "[WARNING]:-Unable to connect to loghost:514, logging to /tmp/log_dir/testProgram_20170101.log only: connection refused")
+				testutils.ExpectRegexp(buffer, "[INFO]:-info message")
+			})
+		})
+		Context("Logger initialized from the syslog flags", func() {
+			var dialed bool
+			var dialedNetwork, dialedAddress string
+			BeforeEach(func() {
+				dialed, dialedNetwork, dialedAddress = false, "", ""
+				utils.System.DialSyslog = func(network string, raddr string, priority syslog.Priority, tag string) (utils.SyslogWriter, error) {
+					dialed, dialedNetwork, dialedAddress = true, network, raddr
+					return &fakeSyslogWriter{}, nil
+				}
+			})
+			It("does not use syslog if no syslog flag is set", func() {
+				newLogger := utils.InitializeLoggingFromFlags("testProgram", false, "", false)
+				Expect(dialed).To(BeFalse())
+				Expect(newLogger.GetLogFilePath()).To(Equal("testDir/gpAdminLogs/testProgram_20170101.log"))
+			})
+			It("uses the local syslog server with --syslog", func() {
+				utils.InitializeLoggingFromFlags("testProgram", true, "", false)
+				Expect(dialed).To(BeTrue())
+				Expect(dialedNetwork).To(Equal(""))
+				Expect(dialedAddress).To(Equal(""))
+			})
+			It("uses a remote syslog server over UDP with --syslog-server", func() {
+				utils.InitializeLoggingFromFlags("testProgram", false, "loghost:514", false)
+				Expect(dialedNetwork).To(Equal("udp"))
+				Expect(dialedAddress).To(Equal("loghost:514"))
+			})
+			It("does not create a log file with --syslog-only", func() {
+				newLogger := utils.InitializeLoggingFromFlags("testProgram", false, "", true)
+				Expect(dialed).To(BeTrue())
+				Expect(newLogger.GetLogFilePath()).To(Equal(""))
+			})
+		})
+	})
+	Describe("LinkCurrentLogFile", func() {
+		It("points a current log symlink at the log file for this run", func() {
+			removed := ""
+			symlinkTarget, symlinkPath := "", ""
+			utils.System.Remove = func(name string) error {
+				removed = name
+				return nil
+			}
+			utils.System.Symlink = func(oldname string, newname string) error {
+				symlinkTarget, symlinkPath = oldname, newname
+				return nil
+			}
+			currentLogger := utils.NewLogger(os.Stdout, os.Stderr, buffer, "/tmp/log_dir/testProgram_20170101.log", utils.LOGINFO, "testProgram:testUser:testHost:000000-[%s]:-")
+
+			Expect(currentLogger.LinkCurrentLogFile()).To(Succeed())
+			Expect(removed).To(Equal("/tmp/log_dir/testProgram_current.log"))
+			Expect(symlinkPath).To(Equal("/tmp/log_dir/testProgram_current.log"))
+			Expect(symlinkTarget).To(Equal("testProgram_20170101.log"))
+		})
+		It("replaces a link left by a previous run", func() {
+			currentLogger := utils.NewLogger(os.Stdout, os.Stderr, buffer, "/tmp/log_dir/testProgram_20170102.log", utils.LOGINFO, "testProgram:testUser:testHost:000000-[%s]:-")
+			utils.System.Remove("/tmp/log_dir/testProgram_current.log")
+			Expect(utils.System.Symlink("testProgram_20170101.log", "/tmp/log_dir/testProgram_current.log")).To(Succeed())
+			defer utils.System.Remove("/tmp/log_dir/testProgram_current.log")
+
+			Expect(currentLogger.LinkCurrentLogFile()).To(Succeed())
+			target, err := os.Readlink("/tmp/log_dir/testProgram_current.log")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(target).To(Equal("testProgram_20170102.log"))
+		})
 	})
 	Describe("GetLogPrefix", func() {
 		It("returns a prefix for the current time", func() {
@@ -115,6 +399,77 @@ var _ = Describe("utils/log tests", func() {
 			prefix := logger.GetLogPrefix("INFO")
 			Expect(expectedMessage).To(Equal(prefix))
 		})
+		Context("with a custom prefix separator", func() {
+			var ipv6Logger *utils.Logger
+			BeforeEach(func() {
+				ipv6Logger = utils.NewLogger(os.Stdout, os.Stderr, buffer, "/tmp/log_dir/testProgram_20170101.log",
+					utils.LOGINFO, "testProgram:testUser:fe80::1:000000-[%s]:-")
+			})
+			It("uses the separator between all fields of the prefix", func() {
+				ipv6Logger.SetPrefixSeparator("|")
+				prefix := ipv6Logger.GetLogPrefix("INFO")
+				Expect(prefix).To(Equal("20170101|01|01|01 testProgram|testUser|fe80::1|000000-[INFO]|-"))
+				fields := strings.Split(strings.SplitN(prefix, " ", 2)[1], "|")
+				Expect(fields).To(Equal([]string{"testProgram", "testUser", "fe80::1", "000000-[INFO]", "-"}))
+			})
+			It("can change the separator more than once", func() {
+				ipv6Logger.SetPrefixSeparator("|")
+				ipv6Logger.SetPrefixSeparator(";")
+				Expect(ipv6Logger.GetLogPrefix("WARNING")).To(Equal("20170101;01;01;01 testProgram;testUser;fe80::1;000000-[WARNING];-"))
+			})
+			It("uses the separator between the fields of the time", func() {
+				ipv6Logger.SetPrefixSeparator("|")
+				timestamp := strings.SplitN(ipv6Logger.GetLogPrefix("INFO"), " ", 2)[0]
+				Expect(strings.Split(timestamp, "|")).To(Equal([]string{"20170101", "01", "01", "01"}))
+			})
+			It("does not interpret a separator containing digits as part of the timestamp layout", func() {
+				ipv6Logger.SetPrefixSeparator("_1_")
+				Expect(ipv6Logger.GetLogPrefix("INFO")).To(Equal("20170101_1_01_1_01_1_01 testProgram_1_testUser_1_fe80::1_1_000000-[INFO]_1_-"))
+			})
+			It("panics if given an empty separator", func() {
+				defer testutils.ShouldPanicWithMessage("The log prefix separator cannot be empty")
+				ipv6Logger.SetPrefixSeparator("")
+			})
+			It("leaves the prefix unchanged with the default separator", func() {
+				ipv6Logger.SetPrefixSeparator(":")
+				Expect(ipv6Logger.GetLogPrefix("INFO")).To(Equal("20170101:01:01:01 testProgram:testUser:fe80::1:000000-[INFO]:-"))
+			})
+		})
+	})
+	Describe("ValidateLogPrefixSeparator", func() {
+		It("accepts a non-empty separator", func() {
+			utils.ValidateLogPrefixSeparator("|")
+		})
+		It("panics if given an empty separator", func() {
+			defer testutils.ShouldPanicWithMessage("The log prefix separator cannot be empty")
+			utils.ValidateLogPrefixSeparator("")
+		})
+	})
+	Describe("SetTimestampFormat", func() {
+		var tzLogger *utils.Logger
+		BeforeEach(func() {
+			tzLogger = utils.NewLogger(os.Stdout, os.Stderr, buffer, "/tmp/log_dir/testProgram_20170101.log",
+				utils.LOGINFO, "testProgram:testUser:testHost:000000-[%s]:-")
+			utils.System.Now = func() time.Time { return time.Date(2017, time.January, 1, 1, 1, 1, 1, time.FixedZone("PST", -8*60*60)) }
+		})
+		It("formats the timestamp in the prefix with the given layout", func() {
+			tzLogger.SetTimestampFormat(time.RFC3339)
+			Expect(tzLogger.GetLogPrefix("INFO")).To(Equal("2017-01-01T01:01:01-08:00 testProgram:testUser:testHost:000000-[INFO]:-"))
+		})
+		It("keeps the custom layout when the prefix separator is changed", func() {
+			tzLogger.SetTimestampFormat(time.RFC3339)
+			tzLogger.SetPrefixSeparator("|")
+			Expect(tzLogger.GetLogPrefix("WARNING")).To(Equal("2017-01-01T01:01:01-08:00 testProgram|testUser|testHost|000000-[WARNING]|-"))
+		})
+		It("uses the custom layout in logged messages", func() {
+			tzLogger.SetTimestampFormat(time.RFC3339)
+			tzLogger.Info("info message")
+			Expect(buffer).To(gbytes.Say(`2017-01-01T01:01:01-08:00 testProgram:testUser:testHost:000000-\[INFO\]:-info message`))
+		})
+		It("panics if given an empty layout", func() {
+			defer testutils.ShouldPanicWithMessage("The log timestamp format cannot be empty")
+			tzLogger.SetTimestampFormat("")
+		})
 	})
 	Describe("Output function tests", func() {
 		patternExpected := "20170101:01:01:01 testProgram:testUser:testHost:000000-[%s]:-"
@@ -122,6 +477,7 @@ var _ = Describe("utils/log tests", func() {
 		warnExpected := fmt.Sprintf(patternExpected, "WARNING")
 		verboseExpected := fmt.Sprintf(patternExpected, "DEBUG")
 		debugExpected := fmt.Sprintf(patternExpected, "DEBUG")
+		traceExpected := fmt.Sprintf(patternExpected, "TRACE")
 		errorExpected := fmt.Sprintf(patternExpected, "ERROR")
 		fatalExpected := fmt.Sprintf(patternExpected, "CRITICAL")
 
@@ -355,6 +711,15 @@ var _ = Describe("utils/log tests", func() {
 					testutils.ExpectRegexp(logfile, debugExpected+expectedMessage)
 				})
 			})
+			Context("Trace", func() {
+				It("does not print", func() {
+					expectedMessage := "debug trace"
+					logger.Trace(expectedMessage)
+					testutils.NotExpectRegexp(stdout, traceExpected+expectedMessage)
+					testutils.NotExpectRegexp(stderr, traceExpected+expectedMessage)
+					testutils.NotExpectRegexp(logfile, traceExpected+expectedMessage)
+				})
+			})
 			Context("Error", func() {
 				It("prints to stderr and the log file", func() {
 					expectedMessage := "debug error"
@@ -377,6 +742,314 @@ var _ = Describe("utils/log tests", func() {
 				})
 			})
 		})
+		Describe("Verbosity set to Trace", func() {
+			BeforeEach(func() {
+				logger.SetVerbosity(utils.LOGTRACE)
+			})
+
+			Context("Debug", func() {
+				It("prints to stdout and the log file", func() {
+					expectedMessage := "trace debug"
+					logger.Debug(expectedMessage)
+					testutils.ExpectRegexp(stdout, debugExpected+expectedMessage)
+					testutils.NotExpectRegexp(stderr, debugExpected+expectedMessage)
+					testutils.ExpectRegexp(logfile, debugExpected+expectedMessage)
+				})
+			})
+			Context("Trace", func() {
+				It("prints to the log file", func() {
+					expectedMessage := "trace trace"
+					logger.Trace(expectedMessage)
+					testutils.NotExpectRegexp(stdout, traceExpected+expectedMessage)
+					testutils.NotExpectRegexp(stderr, traceExpected+expectedMessage)
+					testutils.ExpectRegexp(logfile, traceExpected+expectedMessage)
+				})
+			})
+		})
+	})
+	Describe("Quiet mode", func() {
+		patternExpected := "20170101:01:01:01 testProgram:testUser:testHost:000000-[%s]:-"
+		BeforeEach(func() {
+			logger.SetVerbosity(utils.LOGTRACE)
+			logger.SetQuiet(true)
+		})
+		It("prints Info only to the log file", func() {
+			logger.Info("quiet info")
+			testutils.NotExpectRegexp(stdout, "quiet info")
+			testutils.NotExpectRegexp(stderr, "quiet info")
+			testutils.ExpectRegexp(logfile, fmt.Sprintf(patternExpected, "INFO")+"quiet info")
+		})
+		It("prints Warn only to the log file", func() {
+			logger.Warn("quiet warn")
+			testutils.NotExpectRegexp(stdout, "quiet warn")
+			testutils.NotExpectRegexp(stderr, "quiet warn")
+			testutils.ExpectRegexp(logfile, fmt.Sprintf(patternExpected, "WARNING")+"quiet warn")
+		})
+		It("prints Verbose only to the log file", func() {
+			logger.Verbose("quiet verbose")
+			testutils.NotExpectRegexp(stdout, "quiet verbose")
+			testutils.NotExpectRegexp(stderr, "quiet verbose")
+			testutils.ExpectRegexp(logfile, fmt.Sprintf(patternExpected, "DEBUG")+"quiet verbose")
+		})
+		It("prints Debug only to the log file", func() {
+			logger.Debug("quiet debug")
+			testutils.NotExpectRegexp(stdout, "quiet debug")
+			testutils.NotExpectRegexp(stderr, "quiet debug")
+			testutils.ExpectRegexp(logfile, fmt.Sprintf(patternExpected, "DEBUG")+"quiet debug")
+		})
+		It("prints Trace only to the log file", func() {
+			logger.Trace("quiet trace")
+			testutils.NotExpectRegexp(stdout, "quiet trace")
+			testutils.NotExpectRegexp(stderr, "quiet trace")
+			testutils.ExpectRegexp(logfile, fmt.Sprintf(patternExpected, "TRACE")+"quiet trace")
+		})
+		It("still prints Error to stderr and the log file", func() {
+			logger.Error("quiet error")
+			testutils.NotExpectRegexp(stdout, "quiet error")
+			testutils.ExpectRegexp(stderr, fmt.Sprintf(patternExpected, "ERROR")+"quiet error")
+			testutils.ExpectRegexp(logfile, fmt.Sprintf(patternExpected, "ERROR")+"quiet error")
+		})
+		It("prints Fatal to the log file, then panics", func() {
+			defer func() {
+				testutils.NotExpectRegexp(stdout, "quiet fatal")
+				testutils.ExpectRegexp(logfile, fmt.Sprintf(patternExpected, "CRITICAL")+"quiet fatal")
+			}()
+			defer testutils.ShouldPanicWithMessage("quiet fatal")
+			logger.Fatal(errors.New("quiet fatal"), "")
+		})
+		It("applies to component-scoped loggers and can be turned off again", func() {
+			componentLogger := logger.WithComponent("data")
+			Expect(componentLogger.IsQuiet()).To(BeTrue())
+			logger.SetQuiet(false)
+			componentLogger.Info("loud info")
+			testutils.ExpectRegexp(stdout, fmt.Sprintf(patternExpected, "INFO")+"loud info")
+		})
+	})
+	Describe("Colored output", func() {
+		prefix := "20170101:01:01:01 testProgram:testUser:testHost:000000-"
+		BeforeEach(func() {
+			logger.SetVerbosity(utils.LOGINFO)
+			logger.SetColor(true)
+		})
+		It("colors the level of warnings on stdout but not in the log file", func() {
+			logger.Warn("color warn")
+			Expect(string(stdout.Contents())).To(Equal(prefix + "\x1b[33m[WARNING]\x1b[0m:-color warn\n"))
+			Expect(string(logfile.Contents())).To(Equal(prefix + "[WARNING]:-color warn\n"))
+		})
+		It("colors the level of errors on stderr but not in the log file", func() {
+			logger.Error("color error [ERROR]")
+			Expect(string(stderr.Contents())).To(Equal(prefix + "\x1b[31m[ERROR]\x1b[0m:-color error [ERROR]\n"))
+			Expect(string(logfile.Contents())).To(Equal(prefix + "[ERROR]:-color error [ERROR]\n"))
+		})
+		It("does not color info messages", func() {
+			logger.Info("color info")
+			Expect(string(stdout.Contents())).To(Equal(prefix + "[INFO]:-color info\n"))
+		})
+		It("does not color the CRITICAL line written before panicking on Fatal", func() {
+			defer func() {
+				Expect(string(logfile.Contents())).To(HavePrefix(prefix + "[CRITICAL]:-color fatal"))
+			}()
+			defer testutils.ShouldPanicWithMessage(prefix + "[CRITICAL]:-color fatal")
+			logger.Fatal(errors.New("color fatal"), "")
+		})
+		It("does not color output when color is disabled", func() {
+			logger.SetColor(false)
+			logger.Warn("plain warn")
+			Expect(string(stdout.Contents())).To(Equal(prefix + "[WARNING]:-plain warn\n"))
+		})
+		It("does not color output in the json format", func() {
+			logger.SetFormat("json")
+			logger.Error("json error")
+			Expect(string(stderr.Contents())).ToNot(ContainSubstring("\x1b["))
+		})
+		It("is disabled by default", func() {
+			Expect(utils.NewLogger(stdout, stderr, logfile, "gbytes.Buffer", utils.LOGINFO, "").IsColor()).To(BeFalse())
+		})
+	})
+	Describe("Component verbosity", func() {
+		patternExpected := "20170101:01:01:01 testProgram:testUser:testHost:000000-[%s]:-"
+		debugExpected := fmt.Sprintf(patternExpected, "DEBUG")
+		infoExpected := fmt.Sprintf(patternExpected, "INFO")
+		BeforeEach(func() {
+			logger.SetVerbosity(utils.LOGINFO)
+		})
+		AfterEach(func() {
+			logger.ClearComponentVerbosity("types")
+			logger.ClearComponentVerbosity("data")
+		})
+		It("prints debug messages for a component whose verbosity has been raised", func() {
+			logger.SetComponentVerbosity("types", utils.LOGDEBUG)
+			expectedMessage := "types debug"
+			logger.WithComponent("types").Debug(expectedMessage)
+			testutils.ExpectRegexp(stdout, debugExpected+expectedMessage)
+			testutils.ExpectRegexp(logfile, debugExpected+expectedMessage)
+		})
+		It("uses the default verbosity for components without an override", func() {
+			logger.SetComponentVerbosity("types", utils.LOGDEBUG)
+			expectedMessage := "data debug"
+			logger.WithComponent("data").Debug(expectedMessage)
+			logger.Debug(expectedMessage)
+			testutils.NotExpectRegexp(stdout, debugExpected+expectedMessage)
+			testutils.ExpectRegexp(logfile, debugExpected+expectedMessage)
+		})
+		It("suppresses messages for a component whose verbosity has been lowered", func() {
+			logger.SetComponentVerbosity("data", utils.LOGERROR)
+			expectedMessage := "data info"
+			logger.WithComponent("data").Info(expectedMessage)
+			testutils.NotExpectRegexp(stdout, infoExpected+expectedMessage)
+			testutils.ExpectRegexp(logfile, infoExpected+expectedMessage)
+		})
+		It("applies overrides set after the component logger was created", func() {
+			typesLogger := logger.WithComponent("types")
+			logger.SetComponentVerbosity("types", utils.LOGDEBUG)
+			Expect(typesLogger.GetEffectiveVerbosity()).To(Equal(utils.LOGDEBUG))
+			Expect(typesLogger.GetComponent()).To(Equal("types"))
+		})
+		It("follows changes to the default verbosity", func() {
+			dataLogger := logger.WithComponent("data")
+			logger.SetVerbosity(utils.LOGVERBOSE)
+			Expect(dataLogger.GetEffectiveVerbosity()).To(Equal(utils.LOGVERBOSE))
+			Expect(logger.GetComponent()).To(Equal(""))
+		})
+		It("panics when given an invalid verbosity", func() {
+			defer testutils.ShouldPanicWithMessage("Cannot set an invalid logging level for component types")
+			logger.SetComponentVerbosity("types", 42)
+		})
+		It("sets a component verbosity from a component=level setting", func() {
+			logger.SetComponentVerbosityFromString("types=Debug")
+			Expect(logger.WithComponent("types").GetEffectiveVerbosity()).To(Equal(utils.LOGDEBUG))
+		})
+		DescribeTable("panics when given an invalid component=level setting", func(setting string) {
+			defer testutils.ShouldPanicWithMessage(fmt.Sprintf("Invalid component log level %s; the setting must be of the form component=level", setting))
+			logger.SetComponentVerbosityFromString(setting)
+		},
+			Entry("with no level", "types"),
+			Entry("with no component", "=debug"),
+			Entry("with an unknown level", "types=loud"),
+		)
+	})
+	Describe("Concurrent output", func() {
+		It("does not interleave lines logged concurrently to a shared writer", func() {
+			writer := &byteAtATimeWriter{}
+			concurrentLogger := utils.NewLogger(writer, writer, writer, "/tmp/log_dir/testProgram_20170101.log",
+				utils.LOGINFO, "testProgram:testUser:testHost:000000-[%s]:-")
+			numGoroutines := 200
+			var wg sync.WaitGroup
+			for i := 0; i < numGoroutines; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					if i%2 == 0 {
+						concurrentLogger.Info("info message %d", i)
+					} else {
+						concurrentLogger.WithComponent("data").Error("error message %d", i)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			lines := strings.Split(strings.TrimSuffix(string(writer.bytes), "\n"), "\n")
+			Expect(lines).To(HaveLen(2 * numGoroutines))
+			linePattern := regexp.MustCompile(`^20170101:01:01:01 testProgram:testUser:testHost:000000-\[(INFO\]:-info|ERROR\]:-error) message \d+$`)
+			for _, line := range lines {
+				Expect(linePattern.MatchString(line)).To(BeTrue(), "Interleaved log line: %s", line)
+			}
+		})
+	})
+	Describe("Contextual fields", func() {
+		infoExpected := "20170101:01:01:01 testProgram:testUser:testHost:000000-[INFO]:-"
+		BeforeEach(func() {
+			logger.SetVerbosity(utils.LOGINFO)
+		})
+		It("appends fields after the prefix of each line", func() {
+			tableLogger := logger.WithField("schema", "foo").WithField("table", "bar")
+			tableLogger.Info("backing up table")
+			testutils.ExpectRegexp(stdout, infoExpected+"schema=foo table=bar backing up table")
+			testutils.ExpectRegexp(logfile, infoExpected+"schema=foo table=bar backing up table")
+		})
+		It("adds fields from a map in order of their keys", func() {
+			logger.WithFields(map[string]string{"table": "bar", "schema": "foo"}).Info("backing up table")
+			testutils.ExpectRegexp(logfile, infoExpected+"schema=foo table=bar backing up table")
+		})
+		It("replaces the value of a field that is added again", func() {
+			logger.WithField("table", "bar").WithField("schema", "foo").WithField("table", "baz").Info("backing up table")
+			testutils.ExpectRegexp(logfile, infoExpected+"table=baz schema=foo backing up table")
+		})
+		It("does not add fields to the parent logger", func() {
+			schemaLogger := logger.WithField("schema", "foo")
+			schemaLogger.WithField("table", "bar")
+			logger.Info("parent message")
+			schemaLogger.Info("child message")
+			testutils.ExpectRegexp(logfile, infoExpected+"parent message")
+			testutils.ExpectRegexp(logfile, infoExpected+"schema=foo child message")
+		})
+		It("shares verbosity with the parent logger", func() {
+			tableLogger := logger.WithField("table", "bar")
+			logger.SetVerbosity(utils.LOGERROR)
+			tableLogger.Info("suppressed message")
+			testutils.NotExpectRegexp(stdout, "suppressed message")
+			testutils.ExpectRegexp(logfile, infoExpected+"table=bar suppressed message")
+		})
+		It("logs fields but leaves them out of the panic message on Fatal", func() {
+			defer func() {
+				testutils.ExpectRegexp(logfile, "20170101:01:01:01 testProgram:testUser:testHost:000000-[CRITICAL]:-table=bar fatal message")
+			}()
+			defer testutils.ShouldPanicWithMessage("20170101:01:01:01 testProgram:testUser:testHost:000000-[CRITICAL]:-fatal message")
+			logger.WithField("table", "bar").Fatal(errors.New("fatal message"), "")
+		})
+	})
+	Describe("JSON log format", func() {
+		var timestamp string
+		jsonLine := func(level string, message string) string {
+			return fmt.Sprintf(`{"timestamp":"%s","level":"%s","program":"testProgram","user":"testUser","host":"testHost","pid":0,"message":"%s"}`, timestamp, level, message)
+		}
+		BeforeEach(func() {
+			timestamp = utils.System.Now().Format("2006-01-02T15:04:05.000Z07:00")
+			logger.SetVerbosity(utils.LOGINFO)
+			logger.SetFormat("json")
+		})
+		It("writes each log line as a JSON object", func() {
+			logger.Info("json \"info\"")
+			testutils.ExpectRegexp(stdout, jsonLine("INFO", `json \"info\"`)+"\n")
+			testutils.ExpectRegexp(logfile, jsonLine("INFO", `json \"info\"`)+"\n")
+		})
+		It("routes warnings to stdout and the log file", func() {
+			logger.Warn("json warn")
+			testutils.ExpectRegexp(stdout, jsonLine("WARNING", "json warn"))
+			testutils.NotExpectRegexp(stderr, "json warn")
+			testutils.ExpectRegexp(logfile, jsonLine("WARNING", "json warn"))
+		})
+		It("routes errors to stderr and the log file", func() {
+			logger.Error("json error")
+			testutils.NotExpectRegexp(stdout, "json error")
+			testutils.ExpectRegexp(stderr, jsonLine("ERROR", "json error"))
+			testutils.ExpectRegexp(logfile, jsonLine("ERROR", "json error"))
+		})
+		It("filters messages by verbosity as in the text format", func() {
+			logger.Verbose("json verbose")
+			testutils.NotExpectRegexp(stdout, "json verbose")
+			testutils.ExpectRegexp(logfile, jsonLine("DEBUG", "json verbose"))
+		})
+		It("includes the component of a component-scoped logger", func() {
+			logger.WithComponent("types").Info("json component")
+			testutils.ExpectRegexp(logfile, fmt.Sprintf(`{"timestamp":"%s","level":"INFO","program":"testProgram","user":"testUser","host":"testHost","pid":0,"component":"types","message":"json component"}`, timestamp))
+		})
+		It("includes the fields of a logger with contextual fields", func() {
+			logger.WithField("table", "bar").WithField("schema", "foo").Info("json fields")
+			testutils.ExpectRegexp(logfile, fmt.Sprintf(`{"timestamp":"%s","level":"INFO","program":"testProgram","user":"testUser","host":"testHost","pid":0,"fields":{"schema":"foo","table":"bar"},"message":"json fields"}`, timestamp))
+		})
+		It("writes a JSON line to the log file before panicking on Fatal", func() {
+			defer func() {
+				// The stack trace follows the message, escaped within the JSON string
+				testutils.ExpectRegexp(logfile, strings.TrimSuffix(jsonLine("CRITICAL", "json fatal"), `"}`)+`\n`)
+			}()
+			defer testutils.ShouldPanicWithMessage("20170101:01:01:01 testProgram:testUser:testHost:000000-[CRITICAL]:-json fatal")
+			logger.Fatal(errors.New("json fatal"), "")
+		})
+		It("panics when given an invalid format", func() {
+			defer testutils.ShouldPanicWithMessage("Invalid log format xml; the log format must be text or json")
+			logger.SetFormat("xml")
+		})
 	})
 	Describe("NewProgressBar", func() {
 		It("will print when passed a value that the progress bar should show", func() {
@@ -1,12 +1,20 @@
 package utils
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"net"
+	"net/smtp"
 	"os"
-	"regexp"
+	"path"
 	"sort"
 	"strings"
+	"text/template"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
 
@@ -14,16 +22,57 @@ import (
 	"github.com/pkg/errors"
 )
 
+/*
+ * The config file is the machine-readable counterpart of the report file.  Its
+ * format is versioned so that consumers can detect changes they do not
+ * understand; increment ReportFormatVersion whenever a field is removed,
+ * renamed, or changes meaning, but not when a field is added.
+ *
+ * Version 1 contains the fields of BackupConfig below.  Config files written
+ * before the format was versioned have no reportformatversion field and are
+ * read as version 0, which lacks the fields added since, such as Phases.
+ */
+const ReportFormatVersion = 1
+
 type BackupConfig struct {
-	BackupVersion   string
-	DatabaseName    string
-	DatabaseVersion string
-	Compressed      bool
-	DataOnly        bool
-	SchemaFiltered  bool
-	TableFiltered   bool
-	MetadataOnly    bool
-	WithStatistics  bool
+	ReportFormatVersion int
+	BackupVersion       string
+	BuildCommit         string `yaml:",omitempty"`
+	BuildDate           string `yaml:",omitempty"`
+	DatabaseName        string
+	DatabaseVersion     string
+	Compressed          bool
+	MetadataCompressed  bool
+	SegmentCount        int
+	DataOnly            bool
+	SchemaFiltered      bool
+	TableFiltered       bool
+	MetadataOnly        bool
+	WithStatistics      bool
+	DatabaseSearchPath  string          `yaml:",omitempty"`
+	Connection          *ConnectionInfo `yaml:",omitempty"`
+	RestorePoint        string          `yaml:",omitempty"`
+	RestorePointLSN     string          `yaml:",omitempty"`
+	MetadataEntryCount  int             `yaml:",omitempty"`
+	MetadataBytes       uint64          `yaml:",omitempty"`
+	Phases              []Phase         `yaml:",omitempty"`
+	IncludeSchemas      []string        `yaml:",omitempty"`
+	ExcludeSchemas      []string        `yaml:",omitempty"`
+	IncludeTables       []string        `yaml:",omitempty"`
+	ExcludeTables       []string        `yaml:",omitempty"`
+
+	// Counts of schema-qualified objects by schema and then by type, if requested
+	ObjectCountsBySchema map[string]map[string]int `yaml:",omitempty"`
+}
+
+/*
+ * A Phase records the wall-clock start and end of a major step of the backup.
+ * End is the zero time while the phase is still in progress.
+ */
+type Phase struct {
+	Name  string
+	Start time.Time
+	End   time.Time
 }
 
 /*
@@ -35,19 +84,95 @@ type Report struct {
 	BackupType   string
 	DatabaseSize string
 	BackupConfig
+
+	// The size of the database and of the backup files on disk, if known, for the compression ratio
+	DatabaseSizeBytes uint64
+	BackupSizeBytes   uint64
+
+	// When the backup started and ended, if known; the zero time otherwise
+	StartTime time.Time
+	EndTime   time.Time
+
+	// If set, object counts are listed from most to least numerous instead of alphabetically
+	SortObjectCountsByCount bool
+
+	// If set, object counts are also broken down by schema in ObjectCountsBySchema
+	CountObjectsBySchema bool
+
+	// Display labels for object count categories in the report file, by category; the config file keeps the categories
+	ObjectCountLabels map[string]string
+
+	CommentsExcluded bool
+
+	// GPDB-specific features the backup relies on, for planning migrations to other versions
+	FeaturesUsed []string
+
+	// Objects left out of the backup because their DDL could not be generated
+	SkippedObjects []string
 }
 
 func ParseErrorMessage(errStr string) (string, int) {
 	if errStr == "" {
 		return "", 0
 	}
-	errLevelStr := "[CRITICAL]:-"
+	errLevelStr := logger.LevelMarker("CRITICAL")
+	exitCode := 1 // TODO: Define different error codes for different kinds of errors
 	headerIndex := strings.Index(errStr, errLevelStr)
+	if headerIndex == -1 {
+		return errStr, exitCode
+	}
 	errMsg := errStr[headerIndex+len(errLevelStr):]
-	exitCode := 1 // TODO: Define different error codes for different kinds of errors
 	return errMsg, exitCode
 }
 
+func (report *Report) AddFeatureUsed(feature string) {
+	for _, existing := range report.FeaturesUsed {
+		if existing == feature {
+			return
+		}
+	}
+	report.FeaturesUsed = append(report.FeaturesUsed, feature)
+}
+
+/*
+ * This adds numObjects objects of the given type to the per-schema object
+ * counts, using schemaOf to look up the schema of each object by its index in
+ * the caller's slice of objects.  It does nothing unless CountObjectsBySchema
+ * is set.
+ */
+func (report *Report) AddObjectCountsBySchema(objectType string, numObjects int, schemaOf func(i int) string) {
+	if !report.CountObjectsBySchema {
+		return
+	}
+	if report.ObjectCountsBySchema == nil {
+		report.ObjectCountsBySchema = make(map[string]map[string]int, 0)
+	}
+	for i := 0; i < numObjects; i++ {
+		schema := schemaOf(i)
+		if report.ObjectCountsBySchema[schema] == nil {
+			report.ObjectCountsBySchema[schema] = make(map[string]int, 0)
+		}
+		report.ObjectCountsBySchema[schema][objectType]++
+	}
+}
+
+func (report *Report) StartPhase(name string) {
+	report.StartPhaseAt(name, System.Now())
+}
+
+func (report *Report) StartPhaseAt(name string, start time.Time) {
+	report.Phases = append(report.Phases, Phase{Name: name, Start: start})
+}
+
+func (report *Report) EndPhase(name string) {
+	for i := len(report.Phases) - 1; i >= 0; i-- {
+		if report.Phases[i].Name == name && report.Phases[i].End.IsZero() {
+			report.Phases[i].End = System.Now()
+			return
+		}
+	}
+}
+
 func (report *Report) SetBackupTypeFromFlags(dataOnly bool, ddlOnly bool, noCompression bool, isSchemaFiltered bool, isTableFiltered bool, withStats bool) {
 	filterStr := "Unfiltered"
 	if isSchemaFiltered {
@@ -80,32 +205,189 @@ func (report *Report) SetBackupTypeFromFlags(dataOnly bool, ddlOnly bool, noComp
 	report.BackupType = fmt.Sprintf("%s %s Full%s Backup%s", filterStr, compressStr, sectionStr, statsStr)
 }
 
+// These are the schemas and tables given to the filter flags, to be listed in the report
+func (report *Report) SetFilters(includeSchemas []string, excludeSchemas []string, includeTables []string, excludeTables []string) {
+	report.IncludeSchemas = includeSchemas
+	report.ExcludeSchemas = excludeSchemas
+	report.IncludeTables = includeTables
+	report.ExcludeTables = excludeTables
+}
+
 func ReadConfigFile(filename string) *BackupConfig {
 	config := &BackupConfig{}
-	contents, err := ioutil.ReadFile(filename)
-	CheckError(err)
-	err = yaml.Unmarshal(contents, config)
+	contents := MustReadFile(filename)
+	err := yaml.Unmarshal(contents, config)
 	CheckError(err)
+	if config.ReportFormatVersion > ReportFormatVersion {
+		logger.Fatal(errors.Errorf("Config file %s has report format version %d, but only versions up to %d are supported; please use a newer version of gprestore.",
+			filename, config.ReportFormatVersion, ReportFormatVersion), "")
+	}
 	return config
 }
 
 func (report *Report) WriteConfigFile(configFilename string) {
-	configFile := MustOpenFileForWriting(configFilename)
 	defer System.Chmod(configFilename, 0444)
+	report.ReportFormatVersion = ReportFormatVersion
 	config := report.BackupConfig
 	configContents, _ := yaml.Marshal(config)
-	MustPrintBytes(configFile, configContents)
+	MustWriteFileAtomically(configFilename, func(configFile io.Writer) {
+		MustPrintBytes(configFile, configContents)
+	})
 }
 
 func (report *Report) WriteReportFile(reportFilename string, timestamp string, objectCounts map[string]int, errMsg string) {
-	reportFile := MustOpenFileForWriting(reportFilename)
 	defer System.Chmod(reportFilename, 0444)
+	MustWriteFileAtomically(reportFilename, func(reportFile io.Writer) {
+		report.writeReport(reportFile, timestamp, objectCounts, errMsg)
+	})
+}
+
+/*
+ * The JSON report carries the contents of the report file in a form that
+ * tools can parse without scraping the text.  Its field names are fixed here
+ * rather than derived from the Go structs, so that they remain stable as the
+ * structs change; as for the config file, fields may be added but must not be
+ * removed or renamed without incrementing ReportFormatVersion.
+ */
+type jsonReport struct {
+	ReportFormatVersion  int                       `json:"report_format_version"`
+	Timestamp            string                    `json:"timestamp"`
+	Status               string                    `json:"status"`
+	Error                string                    `json:"error,omitempty"`
+	BackupType           string                    `json:"backup_type"`
+	DatabaseSize         string                    `json:"database_size,omitempty"`
+	DatabaseSizeBytes    uint64                    `json:"database_size_bytes,omitempty"`
+	BackupSizeBytes      uint64                    `json:"backup_size_bytes,omitempty"`
+	StartTime            *time.Time                `json:"start_time,omitempty"`
+	EndTime              *time.Time                `json:"end_time,omitempty"`
+	Config               jsonBackupConfig          `json:"config"`
+	ObjectCounts         map[string]int            `json:"object_counts"`
+	ObjectCountsBySchema map[string]map[string]int `json:"object_counts_by_schema,omitempty"`
+}
+
+type jsonBackupConfig struct {
+	BackupVersion      string              `json:"backup_version"`
+	BuildCommit        string              `json:"build_commit,omitempty"`
+	BuildDate          string              `json:"build_date,omitempty"`
+	DatabaseName       string              `json:"database_name"`
+	DatabaseVersion    string              `json:"database_version"`
+	Compressed         bool                `json:"compressed"`
+	MetadataCompressed bool                `json:"metadata_compressed"`
+	SegmentCount       int                 `json:"segment_count"`
+	DataOnly           bool                `json:"data_only"`
+	MetadataOnly       bool                `json:"metadata_only"`
+	SchemaFiltered     bool                `json:"schema_filtered"`
+	TableFiltered      bool                `json:"table_filtered"`
+	WithStatistics     bool                `json:"with_statistics"`
+	DatabaseSearchPath string              `json:"database_search_path,omitempty"`
+	Connection         *jsonConnectionInfo `json:"connection,omitempty"`
+	RestorePoint       string              `json:"restore_point,omitempty"`
+	RestorePointLSN    string              `json:"restore_point_lsn,omitempty"`
+	MetadataEntryCount int                 `json:"metadata_entry_count"`
+	MetadataBytes      uint64              `json:"metadata_bytes"`
+	Phases             []jsonPhase         `json:"phases,omitempty"`
+	IncludeSchemas     []string            `json:"include_schemas,omitempty"`
+	ExcludeSchemas     []string            `json:"exclude_schemas,omitempty"`
+	IncludeTables      []string            `json:"include_tables,omitempty"`
+	ExcludeTables      []string            `json:"exclude_tables,omitempty"`
+}
+
+type jsonConnectionInfo struct {
+	Host   string `json:"host"`
+	Port   int    `json:"port"`
+	DBName string `json:"dbname"`
+	User   string `json:"user"`
+}
+
+type jsonPhase struct {
+	Name  string     `json:"name"`
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+func (report *Report) WriteJSONReport(reportFilename string, timestamp string, objectCounts map[string]int, errMsg string) {
+	defer System.Chmod(reportFilename, 0444)
+	reportContents, err := json.MarshalIndent(report.toJSONReport(timestamp, objectCounts, errMsg), "", "  ")
+	CheckError(err)
+	MustWriteFileAtomically(reportFilename, func(reportFile io.Writer) {
+		MustPrintBytes(reportFile, append(reportContents, '\n'))
+	})
+}
+
+func (report *Report) toJSONReport(timestamp string, objectCounts map[string]int, errMsg string) jsonReport {
+	status := "success"
+	if errMsg != "" {
+		status = "failure"
+	}
+	if objectCounts == nil {
+		objectCounts = map[string]int{}
+	}
+	config := jsonBackupConfig{
+		BackupVersion:      report.BackupVersion,
+		BuildCommit:        report.BuildCommit,
+		BuildDate:          report.BuildDate,
+		DatabaseName:       report.DatabaseName,
+		DatabaseVersion:    report.DatabaseVersion,
+		Compressed:         report.Compressed,
+		MetadataCompressed: report.MetadataCompressed,
+		SegmentCount:       report.SegmentCount,
+		DataOnly:           report.DataOnly,
+		MetadataOnly:       report.MetadataOnly,
+		SchemaFiltered:     report.SchemaFiltered,
+		TableFiltered:      report.TableFiltered,
+		WithStatistics:     report.WithStatistics,
+		DatabaseSearchPath: report.DatabaseSearchPath,
+		RestorePoint:       report.RestorePoint,
+		RestorePointLSN:    report.RestorePointLSN,
+		MetadataEntryCount: report.MetadataEntryCount,
+		MetadataBytes:      report.MetadataBytes,
+		IncludeSchemas:     report.IncludeSchemas,
+		ExcludeSchemas:     report.ExcludeSchemas,
+		IncludeTables:      report.IncludeTables,
+		ExcludeTables:      report.ExcludeTables,
+	}
+	if report.Connection != nil {
+		config.Connection = &jsonConnectionInfo{Host: report.Connection.Host, Port: report.Connection.Port, DBName: report.Connection.DBName, User: report.Connection.User}
+	}
+	for _, phase := range report.Phases {
+		jsonPhase := jsonPhase{Name: phase.Name, Start: phase.Start}
+		if !phase.End.IsZero() {
+			end := phase.End
+			jsonPhase.End = &end
+		}
+		config.Phases = append(config.Phases, jsonPhase)
+	}
+	var startTime, endTime *time.Time
+	if !report.StartTime.IsZero() {
+		startTime = &report.StartTime
+	}
+	if !report.EndTime.IsZero() {
+		endTime = &report.EndTime
+	}
+	return jsonReport{
+		ReportFormatVersion:  ReportFormatVersion,
+		Timestamp:            timestamp,
+		Status:               status,
+		Error:                errMsg,
+		BackupType:           report.BackupType,
+		DatabaseSize:         report.DatabaseSize,
+		DatabaseSizeBytes:    report.DatabaseSizeBytes,
+		BackupSizeBytes:      report.BackupSizeBytes,
+		StartTime:            startTime,
+		EndTime:              endTime,
+		Config:               config,
+		ObjectCounts:         objectCounts,
+		ObjectCountsBySchema: report.ObjectCountsBySchema,
+	}
+}
+
+func (report *Report) writeReport(reportFile io.Writer, timestamp string, objectCounts map[string]int, errMsg string) {
 	reportFileTemplate := `Greenplum Database Backup Report
 
 Timestamp Key: %s
 GPDB Version: %s
 gpbackup Version: %s
-
+%s
 Database Name: %s
 Command Line: %s
 Backup Type: %s
@@ -118,24 +400,210 @@ Backup Status: %s
 		backupStatus = "Failure"
 		errMsg = fmt.Sprintf("Backup Error: %s\n", errMsg)
 	}
-	dbSizeStr := ""
+	buildStr := ""
+	if report.BuildCommit != "" {
+		buildStr = fmt.Sprintf("gpbackup Build: %s", report.BuildCommit)
+		if report.BuildDate != "" {
+			buildStr += fmt.Sprintf(" built %s", report.BuildDate)
+		}
+		buildStr += "\n"
+	}
+	detailsStr := ""
+	if !report.StartTime.IsZero() {
+		detailsStr += fmt.Sprintf("\nStart Time: %s", report.StartTime.Format(reportTimeFormat))
+	}
+	if !report.EndTime.IsZero() {
+		detailsStr += fmt.Sprintf("\nEnd Time: %s", report.EndTime.Format(reportTimeFormat))
+	}
+	if !report.StartTime.IsZero() && !report.EndTime.IsZero() {
+		detailsStr += fmt.Sprintf("\nDuration: %s", report.EndTime.Sub(report.StartTime).Round(time.Second))
+	}
+	if report.Connection != nil {
+		detailsStr += fmt.Sprintf("\nConnection: %s", report.Connection)
+	}
 	if report.DatabaseSize != "" {
-		dbSizeStr = fmt.Sprintf("\nDatabase Size: %s", report.DatabaseSize)
+		detailsStr += fmt.Sprintf("\nDatabase Size: %s", report.DatabaseSize)
+	}
+	if report.BackupSizeBytes > 0 {
+		detailsStr += fmt.Sprintf("\nBackup Size on Disk: %s", formatBytes(report.BackupSizeBytes))
+		if !report.Compressed {
+			detailsStr += "\nCompression Ratio: N/A"
+		} else if report.DatabaseSizeBytes > 0 {
+			detailsStr += fmt.Sprintf("\nCompression Ratio: %.1fx", float64(report.DatabaseSizeBytes)/float64(report.BackupSizeBytes))
+		}
+	}
+	if report.SegmentCount > 0 {
+		detailsStr += fmt.Sprintf("\nSegment Count: %d", report.SegmentCount)
+	}
+	if report.DatabaseSearchPath != "" {
+		detailsStr += fmt.Sprintf("\nDatabase search_path: %s", report.DatabaseSearchPath)
+	}
+	if report.RestorePoint != "" {
+		detailsStr += fmt.Sprintf("\nRestore Point: %s at %s", report.RestorePoint, report.RestorePointLSN)
+	}
+	if report.MetadataEntryCount > 0 {
+		detailsStr += fmt.Sprintf("\nMetadata Entries: %d spanning %d bytes", report.MetadataEntryCount, report.MetadataBytes)
+	}
+	if report.MetadataCompressed {
+		detailsStr += "\nMetadata Compression: gzip"
+	}
+	if report.CommentsExcluded {
+		detailsStr += "\nObject Comments: Excluded"
+	}
+	if report.WithStatistics {
+		detailsStr += "\nQuery Planner Statistics: Included; statistics for columns of array types are not backed up"
+	}
+	if len(report.FeaturesUsed) > 0 {
+		features := make([]string, len(report.FeaturesUsed))
+		copy(features, report.FeaturesUsed)
+		sort.Strings(features)
+		detailsStr += fmt.Sprintf("\nFeatures Used: %s", strings.Join(features, ", "))
+	}
+	if len(report.SkippedObjects) > 0 {
+		detailsStr += fmt.Sprintf("\nSkipped Objects: %s", strings.Join(report.SkippedObjects, ", "))
+	}
+	MustPrintf(reportFile, reportFileTemplate, timestamp, report.DatabaseVersion, report.BackupVersion, buildStr,
+		report.DatabaseName, gpbackupCommandLine, report.BackupType, backupStatus, errMsg, detailsStr)
+
+	filters := []struct {
+		label  string
+		values []string
+	}{
+		{"Include Schema", report.IncludeSchemas},
+		{"Exclude Schema", report.ExcludeSchemas},
+		{"Include Table", report.IncludeTables},
+		{"Exclude Table", report.ExcludeTables},
+	}
+	filterStr := ""
+	for _, filter := range filters {
+		for _, value := range filter.values {
+			filterStr += fmt.Sprintf("%-29s%s\n", filter.label, value)
+		}
+	}
+	if filterStr != "" {
+		MustPrintf(reportFile, "\nFilters:\n%s", filterStr)
 	}
-	MustPrintf(reportFile, reportFileTemplate, timestamp, report.DatabaseVersion, report.BackupVersion, report.DatabaseName,
-		gpbackupCommandLine, report.BackupType, backupStatus, errMsg, dbSizeStr)
 
 	objectStr := "\nCount of Database Objects in Backup:\n"
 	objectSlice := make([]string, 0)
 	for k := range objectCounts {
 		objectSlice = append(objectSlice, k)
 	}
-	sort.Strings(objectSlice)
+	report.sortObjectCountCategories(objectSlice)
+	if report.SortObjectCountsByCount {
+		sort.SliceStable(objectSlice, func(i, j int) bool {
+			return objectCounts[objectSlice[i]] > objectCounts[objectSlice[j]]
+		})
+	}
 	for _, object := range objectSlice {
-		objectStr += fmt.Sprintf("%-29s%d\n", object, objectCounts[object])
+		objectStr += fmt.Sprintf("%-29s%d\n", report.objectCountLabel(object), objectCounts[object])
 
 	}
 	MustPrintf(reportFile, objectStr)
+
+	if len(report.ObjectCountsBySchema) > 0 {
+		schemaStr := "\nCount of Database Objects in Backup by Schema:\n"
+		schemas := make([]string, 0)
+		for schema := range report.ObjectCountsBySchema {
+			schemas = append(schemas, schema)
+		}
+		sort.Strings(schemas)
+		for _, schema := range schemas {
+			schemaStr += fmt.Sprintf("%s\n", schema)
+			schemaCounts := report.ObjectCountsBySchema[schema]
+			objectTypes := make([]string, 0)
+			for objectType := range schemaCounts {
+				objectTypes = append(objectTypes, objectType)
+			}
+			report.sortObjectCountCategories(objectTypes)
+			for _, objectType := range objectTypes {
+				schemaStr += fmt.Sprintf("    %-25s%d\n", report.objectCountLabel(objectType), schemaCounts[objectType])
+			}
+		}
+		MustPrintf(reportFile, schemaStr)
+	}
+
+	if len(report.Phases) > 0 {
+		phaseStr := "\nPhase Timeline:\n"
+		for _, phase := range report.Phases {
+			end := "in progress"
+			if !phase.End.IsZero() {
+				end = phase.End.Format(phaseTimeFormat)
+			}
+			phaseStr += fmt.Sprintf("%-29s%s - %s\n", phase.Name, phase.Start.Format(phaseTimeFormat), end)
+		}
+		MustPrintf(reportFile, phaseStr)
+	}
+}
+
+// This formats a number of bytes in the largest unit in which it is at least 1, e.g. "12.3 MB"
+func formatBytes(numBytes uint64) string {
+	units := []string{"bytes", "kB", "MB", "GB", "TB"}
+	size := float64(numBytes)
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d bytes", numBytes)
+	}
+	return fmt.Sprintf("%.1f %s", size, units[unit])
+}
+
+const (
+	reportTimeFormat = "2006-01-02 15:04:05"
+	phaseTimeFormat  = "2006-01-02 15:04:05.000"
+)
+
+/*
+ * Object count labels are read from a file with one category=label pair per
+ * line, e.g. "tables=Tabellen".  Blank lines are ignored.
+ */
+func ReadObjectCountLabels(filename string) map[string]string {
+	labels := make(map[string]string)
+	for _, line := range ReadLinesFromFile(filename) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			logger.Fatal(errors.Errorf("Invalid object count label %s in %s; each line must be of the form category=label", line, filename), "")
+		}
+		labels[parts[0]] = parts[1]
+	}
+	return labels
+}
+
+// Categories without a label in ObjectCountLabels are shown as they are.
+func (report *Report) objectCountLabel(category string) string {
+	if label, ok := report.ObjectCountLabels[category]; ok {
+		return label
+	}
+	return category
+}
+
+// Categories are listed alphabetically by their labels.
+func (report *Report) sortObjectCountCategories(categories []string) {
+	sort.Slice(categories, func(i, j int) bool {
+		return report.objectCountLabel(categories[i]) < report.objectCountLabel(categories[j])
+	})
+}
+
+/*
+ * This returns the value of the "Timestamp Key" line of a report file, which
+ * records when the backup was taken independently of the file's mtime.
+ */
+func ReadReportTimestamp(reportFilename string) (string, error) {
+	if !FileExistsAndIsReadable(reportFilename) {
+		return "", errors.Errorf("Report file %s does not exist or cannot be read", reportFilename)
+	}
+	for _, line := range ReadLinesFromFile(reportFilename) {
+		if strings.HasPrefix(line, "Timestamp Key: ") {
+			return strings.TrimPrefix(line, "Timestamp Key: "), nil
+		}
+	}
+	return "", errors.Errorf("Report file %s does not contain a timestamp", reportFilename)
 }
 
 /*
@@ -149,65 +617,370 @@ Backup Status: %s
  * users will never use a +dev version in production.
  */
 func EnsureBackupVersionCompatibility(backupVersion string, restoreVersion string) {
+	result := CheckBackupVersionCompatibility(backupVersion, restoreVersion)
+	if !result.Compatible {
+		logger.Fatal(errors.New(result.Reason), "")
+	}
+	if result.Warning != "" {
+		logger.Warn(result.Warning)
+	}
+}
+
+type VersionCompatibility struct {
+	Compatible     bool
+	Reason         string
+	Warning        string
+	BackupVersion  string
+	RestoreVersion string
+}
+
+/*
+ * A backup taken with a newer gpbackup than gprestore is only incompatible if
+ * the major versions differ; a backup from a version that is newer only in its
+ * minor or patch version is restored with a warning.  While the major version
+ * is 0, a difference in the minor version is treated as a difference in the
+ * major version, as semantic versioning allows any 0.y release to make
+ * incompatible changes.
+ */
+const (
+	majorVersion = iota
+	minorVersion
+	patchVersion
+)
+
+func newerVersionComponent(newer semver.Version, older semver.Version) int {
+	if newer.Major != older.Major {
+		return majorVersion
+	}
+	if newer.Minor != older.Minor {
+		if newer.Major == 0 {
+			return majorVersion
+		}
+		return minorVersion
+	}
+	return patchVersion
+}
+
+/*
+ * This function performs the same comparison as EnsureBackupVersionCompatibility,
+ * but returns the result for display instead of exiting on incompatibility.
+ * Reason is empty if the versions are compatible, and Warning is set if they
+ * are compatible but gpbackup is newer than gprestore.
+ */
+func CheckBackupVersionCompatibility(backupVersion string, restoreVersion string) VersionCompatibility {
 	backupSemVer, err := semver.Make(backupVersion)
 	CheckError(err)
 	restoreSemVer, err := semver.Make(restoreVersion)
 	CheckError(err)
+	result := VersionCompatibility{Compatible: true, BackupVersion: backupVersion, RestoreVersion: restoreVersion}
 	if backupSemVer.GT(restoreSemVer) {
-		logger.Fatal(errors.Errorf("gprestore %s cannot restore a backup taken with gpbackup %s; please use gprestore %s or later.",
-			restoreVersion, backupVersion, backupVersion), "")
+		if newerVersionComponent(backupSemVer, restoreSemVer) == majorVersion {
+			result.Compatible = false
+			result.Reason = fmt.Sprintf("gprestore %s cannot restore a backup taken with gpbackup %s; please use gprestore %s or later.",
+				restoreVersion, backupVersion, backupVersion)
+		} else {
+			result.Warning = fmt.Sprintf("gprestore %s is older than gpbackup %s, which took this backup; restoring anyway, but please use gprestore %s or later if the restore fails.",
+				restoreVersion, backupVersion, backupVersion)
+		}
 	}
+	return result
 }
 
-func EnsureDatabaseVersionCompatibility(backupGPDBVersion string, restoreGPDBVersion GPDBVersion) {
-	pattern := regexp.MustCompile(`\d+\.\d+\.\d+`)
-	threeDigitVersion := pattern.FindStringSubmatch(backupGPDBVersion)[0]
-	backupGPDBSemVer, err := semver.Make(threeDigitVersion)
-	CheckError(err)
+/*
+ * A backup from a newer major version of GPDB than the one being restored to
+ * cannot be restored in general, due to catalog changes between major versions.
+ * If force is set, a warning is logged instead, so that a restore can be
+ * attempted in the knowledge that part of it may fail.
+ */
+func EnsureDatabaseVersionCompatibility(backupGPDBVersion string, restoreGPDBVersion GPDBVersion, force bool) {
+	backupGPDBSemVer := NewVersion(backupGPDBVersion).SemVer
 	if backupGPDBSemVer.Major > restoreGPDBVersion.SemVer.Major {
-		logger.Fatal(errors.Errorf("Cannot restore from GPDB version %s to %s due to catalog incompatibilities.", backupGPDBVersion, restoreGPDBVersion.VersionString), "")
+		if !force {
+			logger.Fatal(errors.Errorf("Cannot restore from GPDB version %s to %s due to catalog incompatibilities.", backupGPDBVersion, restoreGPDBVersion.VersionString), "")
+		}
+		logger.Warn("Restoring from GPDB version %s to %s despite catalog incompatibilities; some objects may fail to restore.", backupGPDBVersion, restoreGPDBVersion.VersionString)
+	}
+}
+
+/*
+ * The subject of the email report is a text/template with the fields of
+ * EmailSubject, so that e.g. emails from different environments can be told
+ * apart.  The default template gives the subject used before it could be set.
+ */
+const DefaultEmailSubjectTemplate = "gpbackup {{.Timestamp}} on {{.Hostname}} completed"
+
+type EmailSubject struct {
+	Timestamp string
+	Hostname  string
+	Status    string
+}
+
+func FormatEmailSubject(subjectTemplate string, fields EmailSubject) (string, error) {
+	tmpl, err := template.New("subject").Parse(subjectTemplate)
+	if err != nil {
+		return "", err
+	}
+	subject := &bytes.Buffer{}
+	err = tmpl.Execute(subject, fields)
+	if err != nil {
+		return "", err
+	}
+	return subject.String(), nil
+}
+
+/*
+ * The status is read from the report file rather than passed in, so that the
+ * subject always agrees with the report in the body of the email.
+ */
+func getReportStatus(reportLines []string) string {
+	for _, line := range reportLines {
+		if strings.HasPrefix(line, "Backup Status: ") {
+			return strings.TrimPrefix(line, "Backup Status: ")
+		}
 	}
+	return "Unknown"
 }
 
-func ConstructEmailMessage(cluster Cluster, contactList string) string {
+func ConstructEmailMessage(cluster Cluster, contactList string, subjectTemplate string) (string, error) {
 	hostname, _ := System.Hostname()
+	reportLines := ReadLinesFromFile(cluster.GetReportFilePath())
+	subject, err := FormatEmailSubject(subjectTemplate, EmailSubject{Timestamp: cluster.Timestamp, Hostname: hostname, Status: getReportStatus(reportLines)})
+	if err != nil {
+		return "", err
+	}
 	emailHeader := fmt.Sprintf(`To: %s
-Subject: gpbackup %s on %s completed
+Subject: %s
 Content-Type: text/html
 Content-Disposition: inline
 <html>
 <body>
-<pre style=\"font: monospace\">
-`, contactList, cluster.Timestamp, hostname)
+<pre style="font: monospace">
+`, contactList, subject)
 	emailFooter := `
 </pre>
 </body>
 </html>`
-	fileContents := strings.Join(ReadLinesFromFile(cluster.GetReportFilePath()), "\n")
-	return emailHeader + fileContents + emailFooter
+	fileContents := strings.Join(reportLines, "\n")
+	return emailHeader + fileContents + emailFooter, nil
+}
+
+/*
+ * This constructs a multipart/mixed message with a short body giving the
+ * backup status and the whole report file as an attachment, for mail clients
+ * that truncate a long report inlined in the body.  The attachment is base64
+ * encoded, so that a long error message in the report reaches the recipient
+ * intact whether the message is sent through sendmail or SMTP.
+ */
+func ConstructEmailMessageWithAttachment(cluster Cluster, contactList string, subjectTemplate string) (string, error) {
+	hostname, _ := System.Hostname()
+	reportFilename := cluster.GetReportFilePath()
+	reportLines := ReadLinesFromFile(reportFilename)
+	status := getReportStatus(reportLines)
+	subject, err := FormatEmailSubject(subjectTemplate, EmailSubject{Timestamp: cluster.Timestamp, Hostname: hostname, Status: status})
+	if err != nil {
+		return "", err
+	}
+	boundary := fmt.Sprintf("gpbackup-report-%s", cluster.Timestamp)
+	encoded := base64.StdEncoding.EncodeToString([]byte(strings.Join(reportLines, "\n") + "\n"))
+	encodedLines := make([]string, 0)
+	for len(encoded) > 76 {
+		encodedLines = append(encodedLines, encoded[:76])
+		encoded = encoded[76:]
+	}
+	encodedLines = append(encodedLines, encoded)
+	return fmt.Sprintf(`To: %s
+Subject: %s
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary=%s
+
+--%s
+Content-Type: text/plain; charset=utf-8
+
+Backup %s on %s finished with status %s.
+The full report is attached.
+
+--%s
+Content-Type: text/plain; charset=utf-8
+Content-Disposition: attachment; filename=%s.txt
+Content-Transfer-Encoding: base64
+
+%s
+--%s--`, contactList, subject, boundary, boundary, cluster.Timestamp, hostname, status, boundary, path.Base(reportFilename), strings.Join(encodedLines, "\n"), boundary), nil
+}
+
+/*
+ * An EmailConfig selects how the email report is sent.  With the sendmail
+ * transport the message is piped to sendmail on the master; with the smtp
+ * transport it is sent directly to the SMTP server at SMTPAddress, a host:port
+ * pair, for hosts with no mail transfer agent installed.  The connection is
+ * upgraded with STARTTLS if StartTLS is set, and authenticates with PLAIN auth
+ * if Username is set.  If OnlyOnFailure is set, no email is sent for a backup
+ * whose report gives its status as Success, so that an email for a failed
+ * backup stands out; a report with no status is still sent.  If ContactsFile is
+ * set, recipients are read from that file instead of from a mail_contacts file
+ * in $HOME or $GPHOME/bin.  If AttachReport is set, the report is attached to
+ * the email instead of being inlined in its body.
+ */
+const (
+	EmailTransportSendmail = "sendmail"
+	EmailTransportSMTP     = "smtp"
+)
+
+type EmailConfig struct {
+	SubjectTemplate string
+	Transport       string
+	SMTPAddress     string
+	StartTLS        bool
+	Username        string
+	Password        string
+	OnlyOnFailure   bool
+	ContactsFile    string
+	AttachReport    bool
 }
 
-func EmailReport(cluster Cluster) {
+func EmailReport(cluster Cluster, config EmailConfig) {
+	if config.OnlyOnFailure && getReportStatus(ReadLinesFromFile(cluster.GetReportFilePath())) == "Success" {
+		logger.Info("Backup succeeded, so no email report will be sent")
+		return
+	}
 	contactsFilename := "mail_contacts"
-	gphomeFile := fmt.Sprintf("%s/bin/%s", System.Getenv("GPHOME"), contactsFilename)
-	homeFile := fmt.Sprintf("%s/%s", System.Getenv("HOME"), contactsFilename)
-	homeErr := cluster.ExecuteLocalCommand(fmt.Sprintf("test -f %s", homeFile))
-	if homeErr != nil {
-		gphomeErr := cluster.ExecuteLocalCommand(fmt.Sprintf("test -f %s", gphomeFile))
-		if gphomeErr != nil {
-			logger.Warn("Found neither %s nor %s", gphomeFile, homeFile)
+	if config.ContactsFile != "" {
+		contactsFilename = config.ContactsFile
+		if err := cluster.ExecuteLocalCommand(fmt.Sprintf("test -f %s", contactsFilename)); err != nil {
+			logger.Warn("Found no contacts file at %s", contactsFilename)
 			logger.Warn("Unable to send backup email notification")
 			return
 		}
-		contactsFilename = gphomeFile
 	} else {
-		contactsFilename = homeFile
+		gphomeFile := fmt.Sprintf("%s/bin/%s", System.Getenv("GPHOME"), contactsFilename)
+		homeFile := fmt.Sprintf("%s/%s", System.Getenv("HOME"), contactsFilename)
+		homeErr := cluster.ExecuteLocalCommand(fmt.Sprintf("test -f %s", homeFile))
+		if homeErr != nil {
+			gphomeErr := cluster.ExecuteLocalCommand(fmt.Sprintf("test -f %s", gphomeFile))
+			if gphomeErr != nil {
+				logger.Warn("Found neither %s nor %s", gphomeFile, homeFile)
+				logger.Warn("Unable to send backup email notification")
+				return
+			}
+			contactsFilename = gphomeFile
+		} else {
+			contactsFilename = homeFile
+		}
+	}
+	contacts, readErr := readContactsFile(contactsFilename)
+	if readErr != nil {
+		logger.Warn("Unable to read %s: %s", contactsFilename, readErr.Error())
+		logger.Warn("Unable to send backup email notification")
+		return
+	}
+	contactList := strings.Join(contacts, " ")
+	constructEmailMessage := ConstructEmailMessage
+	if config.AttachReport {
+		constructEmailMessage = ConstructEmailMessageWithAttachment
+	}
+	message, constructErr := constructEmailMessage(cluster, contactList, config.SubjectTemplate)
+	if constructErr != nil {
+		logger.Warn("Unable to construct email report: %s", constructErr.Error())
+		logger.Warn("Unable to send backup email notification")
+		return
 	}
-	contactList := strings.Join(ReadLinesFromFile(contactsFilename), " ")
-	message := ConstructEmailMessage(cluster, contactList)
 	logger.Verbose("Sending email report to the following addresses: %s", contactList)
-	sendErr := cluster.ExecuteLocalCommand(fmt.Sprintf(`echo "%s" | sendmail -t`, message))
+	var sendErr error
+	if config.Transport == EmailTransportSMTP {
+		sendErr = sendEmailViaSMTP(config, contacts, message)
+	} else {
+		sendErr = cluster.ExecuteLocalCommand(fmt.Sprintf(`echo "%s" | sendmail -t`, escapeForEcho(message)))
+	}
 	if sendErr != nil {
 		logger.Warn("Unable to send email report: %s", sendErr.Error())
 	}
 }
+
+// The message is passed to echo in double quotes, so the characters special there are escaped
+func escapeForEcho(message string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(message)
+}
+
+// The sender is the current user at the master hostname, as with sendmail
+func sendEmailViaSMTP(config EmailConfig, recipients []string, message string) error {
+	client, err := System.DialSMTP(config.SMTPAddress)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	host, _, err := net.SplitHostPort(config.SMTPAddress)
+	if err != nil {
+		return err
+	}
+	if config.StartTLS {
+		if err = client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if config.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", config.Username, config.Password, host)); err != nil {
+			return err
+		}
+	}
+	currentUser, err := System.CurrentUser()
+	if err != nil {
+		return err
+	}
+	hostname, _ := System.Hostname()
+	if err = client.Mail(fmt.Sprintf("%s@%s", currentUser.Username, hostname)); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if err = client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(writer, message); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+/*
+ * The contacts file is often on a shared filesystem such as NFS, where a read
+ * can fail transiently, so the read is attempted more than once before the
+ * email is given up on.
+ */
+var (
+	contactsFileReadAttempts = 3
+	contactsFileRetryDelay   = time.Second
+)
+
+func SetContactsFileReadAttempts(attempts int) {
+	contactsFileReadAttempts = attempts
+}
+
+// Blank lines are skipped, as an empty recipient would make the whole send fail
+func readContactsFile(filename string) ([]string, error) {
+	var err error
+	for attempt := 1; attempt <= contactsFileReadAttempts; attempt++ {
+		logger.Verbose("Reading email contacts from %s, attempt %d of %d", filename, attempt, contactsFileReadAttempts)
+		var lines []string
+		if lines, err = ReadLines(filename); err == nil {
+			contacts := make([]string, 0)
+			for _, line := range lines {
+				if contact := strings.TrimSpace(line); contact != "" {
+					contacts = append(contacts, contact)
+				}
+			}
+			return contacts, nil
+		}
+		logger.Verbose("Attempt %d of %d to read %s failed: %s", attempt, contactsFileReadAttempts, filename, err.Error())
+		if attempt < contactsFileReadAttempts {
+			System.Sleep(contactsFileRetryDelay)
+		}
+	}
+	return nil, err
+}
@@ -1,8 +1,17 @@
 package utils_test
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"io"
+	"net/smtp"
 	"os"
+	"os/user"
+	"strings"
+	"time"
 
 	"github.com/blang/semver"
 	"github.com/greenplum-db/gpbackup/testutils"
@@ -14,6 +23,45 @@ import (
 	"github.com/pkg/errors"
 )
 
+type fakeSMTPClient struct {
+	calls   []string
+	message *bytes.Buffer
+	rcptErr error
+}
+
+type fakeSMTPData struct {
+	*bytes.Buffer
+}
+
+func (data fakeSMTPData) Close() error { return nil }
+
+func (client *fakeSMTPClient) StartTLS(config *tls.Config) error {
+	client.calls = append(client.calls, fmt.Sprintf("STARTTLS %s", config.ServerName))
+	return nil
+}
+func (client *fakeSMTPClient) Auth(auth smtp.Auth) error {
+	client.calls = append(client.calls, "AUTH")
+	return nil
+}
+func (client *fakeSMTPClient) Mail(from string) error {
+	client.calls = append(client.calls, fmt.Sprintf("MAIL %s", from))
+	return nil
+}
+func (client *fakeSMTPClient) Rcpt(to string) error {
+	client.calls = append(client.calls, fmt.Sprintf("RCPT %s", to))
+	return client.rcptErr
+}
+func (client *fakeSMTPClient) Data() (io.WriteCloser, error) {
+	client.calls = append(client.calls, "DATA")
+	client.message = &bytes.Buffer{}
+	return fakeSMTPData{client.message}, nil
+}
+func (client *fakeSMTPClient) Quit() error {
+	client.calls = append(client.calls, "QUIT")
+	return nil
+}
+func (client *fakeSMTPClient) Close() error { return nil }
+
 var _ = Describe("utils/report tests", func() {
 	Describe("ParseErrorMessage", func() {
 		It("Parses a CRITICAL error message and returns error code 1", func() {
@@ -22,6 +70,11 @@ var _ = Describe("utils/report tests", func() {
 			Expect(errMsg).To(Equal("Error Message"))
 			Expect(exitCode).To(Equal(1))
 		})
+		It("Returns the whole message and error code 1 for a message without a CRITICAL header", func() {
+			errMsg, exitCode := utils.ParseErrorMessage("runtime error: index out of range")
+			Expect(errMsg).To(Equal("runtime error: index out of range"))
+			Expect(exitCode).To(Equal(1))
+		})
 		It("Returns error code 0 for an empty error message", func() {
 			errMsg, exitCode := utils.ParseErrorMessage("")
 			Expect(errMsg).To(Equal(""))
@@ -46,6 +99,8 @@ var _ = Describe("utils/report tests", func() {
 			utils.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
 				return buffer, nil
 			}
+			utils.System.Remove = func(name string) error { return nil }
+			utils.System.Rename = func(oldname string, newname string) error { return nil }
 		})
 
 		It("writes a report for a successful backup", func() {
@@ -66,6 +121,15 @@ Count of Database Objects in Backup:
 sequences                    1
 tables                       42
 types                        1000`))
+		})
+		It("writes the build commit and date if they are set", func() {
+			backupReport.BuildCommit = "2a47ec9"
+			backupReport.BuildDate = "2017-01-01T01:01:01Z"
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`gpbackup Version: 0\.1\.0
+gpbackup Build: 2a47ec9 built 2017-01-01T01:01:01Z
+
+Database Name: testdb`))
 		})
 		It("writes a report for a failed backup", func() {
 			backupReport.WriteReportFile("filename", timestamp, objectCounts, "Cannot access /tmp/backups: Permission denied")
@@ -87,6 +151,241 @@ sequences                    1
 tables                       42
 types                        1000`))
 		})
+		It("writes object counts from most to least numerous if requested", func() {
+			backupReport.SortObjectCountsByCount = true
+			backupReport.WriteReportFile("filename", timestamp, map[string]int{"tables": 42, "sequences": 1, "types": 1000, "views": 42}, "")
+			Expect(buffer).To(gbytes.Say(`Count of Database Objects in Backup:
+types                        1000
+tables                       42
+views                        42
+sequences                    1`))
+		})
+		It("writes object counts alphabetically by default", func() {
+			backupReport.WriteReportFile("filename", timestamp, map[string]int{"tables": 42, "sequences": 1, "types": 1000, "views": 42}, "")
+			Expect(buffer).To(gbytes.Say(`Count of Database Objects in Backup:
+sequences                    1
+tables                       42
+types                        1000
+views                        42`))
+		})
+		It("writes object counts with the display labels supplied for their categories", func() {
+			backupReport.ObjectCountLabels = map[string]string{"tables": "Tabellen", "types": "Datentypen"}
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Count of Database Objects in Backup:
+Datentypen                   1000
+Tabellen                     42
+sequences                    1`))
+		})
+		It("writes object counts with the display labels read from a labels file", func() {
+			r, w, _ := os.Pipe()
+			utils.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (utils.ReadCloserAt, error) { return r, nil }
+			defer func() { utils.System.OpenFileRead = utils.OpenFileRead }()
+			w.Write([]byte("tables=Tabellen\n\ntypes=Datentypen\n"))
+			w.Close()
+			backupReport.ObjectCountLabels = utils.ReadObjectCountLabels("/tmp/labels_file")
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Count of Database Objects in Backup:
+Datentypen                   1000
+Tabellen                     42
+sequences                    1`))
+		})
+		It("panics if a line of the labels file is not of the form category=label", func() {
+			r, w, _ := os.Pipe()
+			utils.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (utils.ReadCloserAt, error) { return r, nil }
+			defer func() { utils.System.OpenFileRead = utils.OpenFileRead }()
+			w.Write([]byte("tables=Tabellen\nviews\n"))
+			w.Close()
+			defer testutils.ShouldPanicWithMessage("Invalid object count label views in /tmp/labels_file; each line must be of the form category=label")
+			utils.ReadObjectCountLabels("/tmp/labels_file")
+		})
+		It("writes the segment count from a multi-segment cluster", func() {
+			segConfigs := []utils.SegConfig{{ContentID: -1}, {ContentID: 0}, {ContentID: 1}, {ContentID: 2}}
+			cluster := utils.NewCluster(segConfigs, "", timestamp, "gpseg")
+			backupReport.SegmentCount = cluster.GetSegmentCount()
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Database Size: 42 MB
+Segment Count: 3
+Count of Database Objects in Backup:`))
+		})
+		It("records metadata compression in the report if it was used", func() {
+			backupReport.MetadataCompressed = true
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Backup Status: Success
+
+Database Size: 42 MB
+Metadata Compression: gzip
+Count of Database Objects in Backup:`))
+		})
+		It("notes in the report that comments were excluded", func() {
+			backupReport.CommentsExcluded = true
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Database Size: 42 MB
+Object Comments: Excluded
+Count of Database Objects in Backup:`))
+		})
+		It("notes in the report that query planner statistics were backed up", func() {
+			backupReport.SetBackupTypeFromFlags(false, false, false, false, false, true)
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Backup Type: Unfiltered Compressed Full Backup With Statistics
+Backup Status: Success
+
+Database Size: 42 MB
+Query Planner Statistics: Included; statistics for columns of array types are not backed up
+Count of Database Objects in Backup:`))
+		})
+		It("lists the GPDB-specific features used in the backup", func() {
+			backupReport.AddFeatureUsed("resource_groups")
+			backupReport.AddFeatureUsed("filespaces")
+			backupReport.AddFeatureUsed("resource_groups")
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Database Size: 42 MB
+Features Used: filespaces, resource_groups
+Count of Database Objects in Backup:`))
+		})
+		It("records the database default search_path", func() {
+			backupReport.DatabaseSearchPath = `"My Schema", public`
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Database Size: 42 MB
+Database search_path: "My Schema", public
+Count of Database Objects in Backup:`))
+		})
+		It("records when the backup started and ended and how long it took", func() {
+			backupReport.StartTime = time.Date(2017, 1, 1, 1, 1, 1, 0, time.UTC)
+			backupReport.EndTime = time.Date(2017, 1, 1, 2, 3, 4, 600000000, time.UTC)
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Backup Status: Success
+
+Start Time: 2017-01-01 01:01:01
+End Time: 2017-01-01 02:03:04
+Duration: 1h2m4s
+Database Size: 42 MB`))
+		})
+		It("omits the duration if the backup has no end time", func() {
+			backupReport.StartTime = time.Date(2017, 1, 1, 1, 1, 1, 0, time.UTC)
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Start Time: 2017-01-01 01:01:01
+Database Size: 42 MB`))
+			Expect(string(buffer.Contents())).ToNot(ContainSubstring("Duration:"))
+		})
+		It("lists the schema and table filters one per line before the object counts", func() {
+			backupReport.SetFilters([]string{"public", "sales"}, nil, nil, []string{"public.big_table"})
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Database Size: 42 MB
+Filters:
+Include Schema               public
+Include Schema               sales
+Exclude Table                public.big_table
+
+Count of Database Objects in Backup:
+sequences                    1
+tables                       42
+types                        1000`))
+		})
+		It("omits the filters section when no filters were given", func() {
+			backupReport.SetFilters(nil, nil, nil, nil)
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(string(buffer.Contents())).ToNot(ContainSubstring("Filters:"))
+		})
+		It("records the size of the backup on disk and the compression ratio", func() {
+			backupReport.Compressed = true
+			backupReport.DatabaseSizeBytes = 44040192
+			backupReport.BackupSizeBytes = 12952998
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Database Size: 42 MB
+Backup Size on Disk: 12\.4 MB
+Compression Ratio: 3\.4x
+Count of Database Objects in Backup:`))
+		})
+		It("does not compute a compression ratio for an uncompressed backup", func() {
+			backupReport.Compressed = false
+			backupReport.DatabaseSizeBytes = 44040192
+			backupReport.BackupSizeBytes = 45088768
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Database Size: 42 MB
+Backup Size on Disk: 43\.0 MB
+Compression Ratio: N/A
+Count of Database Objects in Backup:`))
+		})
+		It("omits the backup size and compression ratio if the backup size is unknown", func() {
+			backupReport.Compressed = true
+			backupReport.DatabaseSizeBytes = 44040192
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(string(buffer.Contents())).ToNot(ContainSubstring("Backup Size on Disk"))
+			Expect(string(buffer.Contents())).ToNot(ContainSubstring("Compression Ratio"))
+		})
+		It("records the connection the backup used", func() {
+			backupReport.Connection = &utils.ConnectionInfo{Host: "mdw", Port: 5432, DBName: "testdb", User: "gpadmin"}
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Backup Status: Success
+
+Connection: gpadmin@mdw:5432/testdb
+Database Size: 42 MB`))
+		})
+		It("records the restore point created at the start of the backup", func() {
+			backupReport.RestorePoint = "backup_point"
+			backupReport.RestorePointLSN = "0/16B3748"
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Database Size: 42 MB
+Restore Point: backup_point at 0/16B3748
+Count of Database Objects in Backup:`))
+		})
+		It("records the number of metadata entries and the bytes they span", func() {
+			backupReport.MetadataEntryCount = 1234
+			backupReport.MetadataBytes = 47185920
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Database Size: 42 MB
+Metadata Entries: 1234 spanning 47185920 bytes
+Count of Database Objects in Backup:`))
+		})
+		It("lists the objects skipped in best-effort mode", func() {
+			backupReport.SkippedObjects = []string{"RESOURCE QUEUE bad_queue"}
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`Database Size: 42 MB
+Skipped Objects: RESOURCE QUEUE bad_queue
+Count of Database Objects in Backup:`))
+		})
+		It("writes a timeline of the backup phases", func() {
+			start := time.Date(2017, 1, 1, 1, 1, 1, 0, time.UTC)
+			backupReport.Phases = []utils.Phase{
+				{Name: "predata", Start: start, End: start.Add(1500 * time.Millisecond)},
+				{Name: "report", Start: start.Add(2 * time.Second)},
+			}
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`types                        1000
+
+Phase Timeline:
+predata                      2017-01-01 01:01:01\.000 - 2017-01-01 01:01:02\.500
+report                       2017-01-01 01:01:03\.000 - in progress`))
+		})
+		It("writes per-schema object counts if requested", func() {
+			backupReport.CountObjectsBySchema = true
+			tables := []string{"tenant_b", "tenant_a", "tenant_b", "tenant_b"}
+			functions := []string{"tenant_a", "tenant_a"}
+			backupReport.AddObjectCountsBySchema("Tables", len(tables), func(i int) string { return tables[i] })
+			backupReport.AddObjectCountsBySchema("Functions", len(functions), func(i int) string { return functions[i] })
+
+			Expect(backupReport.ObjectCountsBySchema).To(Equal(map[string]map[string]int{
+				"tenant_a": {"Functions": 2, "Tables": 1},
+				"tenant_b": {"Tables": 3},
+			}))
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(buffer).To(gbytes.Say(`types                        1000
+
+Count of Database Objects in Backup by Schema:
+tenant_a
+    Functions                2
+    Tables                   1
+tenant_b
+    Tables                   3
+`))
+		})
+		It("does not write per-schema object counts by default", func() {
+			backupReport.AddObjectCountsBySchema("Tables", 1, func(i int) string { return "tenant_a" })
+
+			Expect(backupReport.ObjectCountsBySchema).To(BeNil())
+			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
+			Expect(string(buffer.Contents())).ToNot(ContainSubstring("by Schema"))
+		})
 		It("writes a report without database size information", func() {
 			backupReport.DatabaseSize = ""
 			backupReport.WriteReportFile("filename", timestamp, objectCounts, "")
@@ -107,6 +406,201 @@ tables                       42
 types                        1000`))
 		})
 	})
+	Describe("WriteJSONReport", func() {
+		timestamp := "20170101010101"
+		objectCounts := map[string]int{"tables": 42, "sequences": 1}
+		backupReport := &utils.Report{}
+		var parsed map[string]interface{}
+		writeAndParse := func(errMsg string) {
+			backupReport.WriteJSONReport("filename", timestamp, objectCounts, errMsg)
+			parsed = map[string]interface{}{}
+			Expect(json.Unmarshal(buffer.Contents(), &parsed)).To(Succeed())
+		}
+		BeforeEach(func() {
+			backupReport = &utils.Report{
+				BackupType:   "Unfiltered Full Backup",
+				DatabaseSize: "42 MB",
+				BackupConfig: utils.BackupConfig{
+					BackupVersion:   "0.1.0",
+					DatabaseName:    "testdb",
+					DatabaseVersion: "5.0.0 build test",
+					SegmentCount:    3,
+					Connection:      &utils.ConnectionInfo{Host: "mdw", Port: 5432, DBName: "testdb", User: "gpadmin"},
+				},
+			}
+			utils.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				return buffer, nil
+			}
+			utils.System.Remove = func(name string) error { return nil }
+			utils.System.Rename = func(oldname string, newname string) error { return nil }
+		})
+		It("writes a report for a successful backup with snake_case field names", func() {
+			writeAndParse("")
+			Expect(parsed["report_format_version"]).To(Equal(float64(utils.ReportFormatVersion)))
+			Expect(parsed["timestamp"]).To(Equal(timestamp))
+			Expect(parsed["status"]).To(Equal("success"))
+			Expect(parsed).ToNot(HaveKey("error"))
+			Expect(parsed["backup_type"]).To(Equal("Unfiltered Full Backup"))
+			Expect(parsed["database_size"]).To(Equal("42 MB"))
+			Expect(parsed["object_counts"]).To(Equal(map[string]interface{}{"tables": float64(42), "sequences": float64(1)}))
+			config := parsed["config"].(map[string]interface{})
+			Expect(config["backup_version"]).To(Equal("0.1.0"))
+			Expect(config["database_name"]).To(Equal("testdb"))
+			Expect(config["segment_count"]).To(Equal(float64(3)))
+			Expect(config["data_only"]).To(Equal(false))
+			Expect(config["connection"]).To(Equal(map[string]interface{}{"host": "mdw", "port": float64(5432), "dbname": "testdb", "user": "gpadmin"}))
+		})
+		It("writes the build commit and date only if they are set", func() {
+			writeAndParse("")
+			config := parsed["config"].(map[string]interface{})
+			Expect(config).ToNot(HaveKey("build_commit"))
+			Expect(config).ToNot(HaveKey("build_date"))
+
+			buffer = gbytes.NewBuffer()
+			backupReport.BuildCommit = "2a47ec9"
+			backupReport.BuildDate = "2017-01-01T01:01:01Z"
+			writeAndParse("")
+			config = parsed["config"].(map[string]interface{})
+			Expect(config["build_commit"]).To(Equal("2a47ec9"))
+			Expect(config["build_date"]).To(Equal("2017-01-01T01:01:01Z"))
+		})
+		It("writes the start and end times only if they are set", func() {
+			writeAndParse("")
+			Expect(parsed).ToNot(HaveKey("start_time"))
+			Expect(parsed).ToNot(HaveKey("end_time"))
+
+			buffer = gbytes.NewBuffer()
+			backupReport.StartTime = time.Date(2017, 1, 1, 1, 1, 1, 0, time.UTC)
+			backupReport.EndTime = time.Date(2017, 1, 1, 2, 3, 4, 0, time.UTC)
+			writeAndParse("")
+			Expect(parsed["start_time"]).To(Equal("2017-01-01T01:01:01Z"))
+			Expect(parsed["end_time"]).To(Equal("2017-01-01T02:03:04Z"))
+		})
+		It("writes the schema and table filters", func() {
+			backupReport.SetFilters([]string{"public"}, nil, []string{"public.foo"}, nil)
+			writeAndParse("")
+			config := parsed["config"].(map[string]interface{})
+			Expect(config["include_schemas"]).To(Equal([]interface{}{"public"}))
+			Expect(config["include_tables"]).To(Equal([]interface{}{"public.foo"}))
+			Expect(config).ToNot(HaveKey("exclude_schemas"))
+			Expect(config).ToNot(HaveKey("exclude_tables"))
+		})
+		It("writes the error and a failure status for a failed backup", func() {
+			writeAndParse("Cannot access /tmp/backups: Permission denied")
+			Expect(parsed["status"]).To(Equal("failure"))
+			Expect(parsed["error"]).To(Equal("Cannot access /tmp/backups: Permission denied"))
+		})
+		It("writes phases, leaving out the end of a phase still in progress", func() {
+			start := time.Date(2017, 1, 1, 1, 1, 1, 0, time.UTC)
+			backupReport.Phases = []utils.Phase{
+				{Name: "globals", Start: start, End: start.Add(time.Second)},
+				{Name: "report", Start: start.Add(time.Second)},
+			}
+			writeAndParse("")
+			config := parsed["config"].(map[string]interface{})
+			Expect(config["phases"]).To(Equal([]interface{}{
+				map[string]interface{}{"name": "globals", "start": "2017-01-01T01:01:01Z", "end": "2017-01-01T01:01:02Z"},
+				map[string]interface{}{"name": "report", "start": "2017-01-01T01:01:02Z"},
+			}))
+		})
+		It("writes an empty object for object counts when there are none", func() {
+			backupReport.WriteJSONReport("filename", timestamp, nil, "")
+			Expect(string(buffer.Contents())).To(ContainSubstring(`"object_counts": {}`))
+		})
+	})
+	Describe("StartPhase and EndPhase", func() {
+		It("records phases in order with monotonically increasing timestamps", func() {
+			now := time.Date(2017, 1, 1, 1, 1, 1, 0, time.UTC)
+			utils.System.Now = func() time.Time {
+				now = now.Add(time.Second)
+				return now
+			}
+			report := utils.Report{}
+			for _, name := range []string{"connect", "globals", "predata", "data", "postdata", "report"} {
+				report.StartPhase(name)
+				report.EndPhase(name)
+			}
+			Expect(report.Phases).To(HaveLen(6))
+			Expect(report.Phases[0].Name).To(Equal("connect"))
+			Expect(report.Phases[5].Name).To(Equal("report"))
+			previous := time.Time{}
+			for _, phase := range report.Phases {
+				Expect(phase.Start.After(previous)).To(BeTrue())
+				Expect(phase.End.After(phase.Start)).To(BeTrue())
+				previous = phase.End
+			}
+		})
+		It("ends only the most recent unfinished phase with the given name", func() {
+			start := time.Date(2017, 1, 1, 1, 1, 1, 0, time.UTC)
+			report := utils.Report{}
+			report.StartPhaseAt("connect", start)
+			report.StartPhase("globals")
+			report.EndPhase("connect")
+			Expect(report.Phases[0].End.IsZero()).To(BeFalse())
+			Expect(report.Phases[1].End.IsZero()).To(BeTrue())
+		})
+		It("includes per-schema object counts in the config file", func() {
+			utils.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				return buffer, nil
+			}
+			utils.System.Chmod = func(name string, mode os.FileMode) error { return nil }
+			utils.System.Remove = func(name string) error { return nil }
+			utils.System.Rename = func(oldname string, newname string) error { return nil }
+			report := utils.Report{CountObjectsBySchema: true}
+			report.AddObjectCountsBySchema("Views", 2, func(i int) string { return "tenant_a" })
+			report.WriteConfigFile("filename")
+			Expect(buffer).To(gbytes.Say(`objectcountsbyschema:
+  tenant_a:
+    Views: 2`))
+		})
+		It("includes the connection in the config file", func() {
+			utils.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				return buffer, nil
+			}
+			utils.System.Chmod = func(name string, mode os.FileMode) error { return nil }
+			utils.System.Remove = func(name string) error { return nil }
+			utils.System.Rename = func(oldname string, newname string) error { return nil }
+			report := utils.Report{}
+			report.Connection = &utils.ConnectionInfo{Host: "mdw", Port: 5432, DBName: "testdb", User: "gpadmin"}
+			report.WriteConfigFile("filename")
+			Expect(buffer).To(gbytes.Say(`connection:
+  host: mdw
+  port: 5432
+  dbname: testdb
+  user: gpadmin`))
+		})
+		It("keeps the object count categories in the config file when display labels are supplied", func() {
+			utils.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				return buffer, nil
+			}
+			utils.System.Chmod = func(name string, mode os.FileMode) error { return nil }
+			utils.System.Remove = func(name string) error { return nil }
+			utils.System.Rename = func(oldname string, newname string) error { return nil }
+			report := utils.Report{CountObjectsBySchema: true, ObjectCountLabels: map[string]string{"Views": "Sichten"}}
+			report.AddObjectCountsBySchema("Views", 2, func(i int) string { return "tenant_a" })
+			report.WriteConfigFile("filename")
+			Expect(buffer).To(gbytes.Say(`objectcountsbyschema:
+  tenant_a:
+    Views: 2`))
+			Expect(string(buffer.Contents())).ToNot(ContainSubstring("Sichten"))
+		})
+		It("includes the phases in the config file", func() {
+			start := time.Date(2017, 1, 1, 1, 1, 1, 0, time.UTC)
+			utils.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				return buffer, nil
+			}
+			utils.System.Chmod = func(name string, mode os.FileMode) error { return nil }
+			utils.System.Remove = func(name string) error { return nil }
+			utils.System.Rename = func(oldname string, newname string) error { return nil }
+			report := utils.Report{}
+			report.Phases = []utils.Phase{{Name: "connect", Start: start, End: start.Add(time.Second)}}
+			report.WriteConfigFile("filename")
+			Expect(buffer).To(gbytes.Say(`phases:
+- name: connect
+  start: 2017-01-01T01:01:01Z
+  end: 2017-01-01T01:01:02Z`))
+		})
+	})
 	Describe("SetBackupTypeFromFlags", func() {
 		var backupReport *utils.Report
 		BeforeEach(func() {
@@ -165,6 +659,39 @@ types                        1000`))
 			testutils.ExpectStructsToMatch(expectedBackupConfig, backupReport.BackupConfig)
 		})
 	})
+	Describe("ReadConfigFile", func() {
+		var storage memoryStorage
+		BeforeEach(func() {
+			storage = memoryStorage{files: make(map[string]*bytes.Buffer, 0)}
+			utils.SetStorage(storage)
+		})
+		AfterEach(func() {
+			utils.SetStorage(utils.LocalStorage{})
+		})
+		It("stamps the current report format version when writing a config file", func() {
+			utils.System.Chmod = func(name string, mode os.FileMode) error { return nil }
+			report := utils.Report{BackupConfig: utils.BackupConfig{DatabaseName: "testdb"}}
+			report.WriteConfigFile("/backups/gpbackup_config.yaml")
+			Expect(storage.files["/backups/gpbackup_config.yaml"].String()).To(ContainSubstring("reportformatversion: 1\n"))
+		})
+		It("reads a config file with a known report format version", func() {
+			storage.files["/backups/gpbackup_config.yaml"] = bytes.NewBufferString("reportformatversion: 1\ndatabasename: testdb\n")
+			config := utils.ReadConfigFile("/backups/gpbackup_config.yaml")
+			Expect(config.ReportFormatVersion).To(Equal(1))
+			Expect(config.DatabaseName).To(Equal("testdb"))
+		})
+		It("reads a config file written before the format was versioned", func() {
+			storage.files["/backups/gpbackup_config.yaml"] = bytes.NewBufferString("databasename: testdb\n")
+			config := utils.ReadConfigFile("/backups/gpbackup_config.yaml")
+			Expect(config.ReportFormatVersion).To(Equal(0))
+			Expect(config.DatabaseName).To(Equal("testdb"))
+		})
+		It("panics if the config file has an unknown report format version", func() {
+			storage.files["/backups/gpbackup_config.yaml"] = bytes.NewBufferString("reportformatversion: 99\ndatabasename: testdb\n")
+			defer testutils.ShouldPanicWithMessage("Config file /backups/gpbackup_config.yaml has report format version 99, but only versions up to 1 are supported")
+			utils.ReadConfigFile("/backups/gpbackup_config.yaml")
+		})
+	})
 	Describe("EnsureBackupVersionCompatibility", func() {
 		It("Panics if gpbackup version is greater than gprestore version", func() {
 			defer testutils.ShouldPanicWithMessage("gprestore 0.1.0 cannot restore a backup taken with gpbackup 0.2.0; please use gprestore 0.2.0 or later.")
@@ -176,6 +703,47 @@ types                        1000`))
 		It("Does not panic if gpbackup version equals gprestore version", func() {
 			utils.EnsureBackupVersionCompatibility("0.1.0", "0.1.0")
 		})
+		It("Warns and does not panic if gpbackup is a newer patch version than gprestore", func() {
+			utils.EnsureBackupVersionCompatibility("0.2.1", "0.2.0")
+			Expect(string(stdout.Contents())).To(ContainSubstring("[WARNING]:-gprestore 0.2.0 is older than gpbackup 0.2.1, which took this backup; restoring anyway, but please use gprestore 0.2.1 or later if the restore fails."))
+		})
+	})
+	Describe("CheckBackupVersionCompatibility", func() {
+		It("returns an incompatible result if gpbackup version is greater than gprestore version", func() {
+			result := utils.CheckBackupVersionCompatibility("0.2.0", "0.1.0")
+			Expect(result).To(Equal(utils.VersionCompatibility{
+				Compatible:     false,
+				Reason:         "gprestore 0.1.0 cannot restore a backup taken with gpbackup 0.2.0; please use gprestore 0.2.0 or later.",
+				BackupVersion:  "0.2.0",
+				RestoreVersion: "0.1.0",
+			}))
+		})
+		It("returns a compatible result if gpbackup version is less than gprestore version", func() {
+			result := utils.CheckBackupVersionCompatibility("0.1.0", "0.1.3")
+			Expect(result).To(Equal(utils.VersionCompatibility{Compatible: true, Reason: "", BackupVersion: "0.1.0", RestoreVersion: "0.1.3"}))
+		})
+		It("returns a compatible result if gpbackup version equals gprestore version", func() {
+			result := utils.CheckBackupVersionCompatibility("0.1.0", "0.1.0")
+			Expect(result).To(Equal(utils.VersionCompatibility{Compatible: true, Reason: "", BackupVersion: "0.1.0", RestoreVersion: "0.1.0"}))
+		})
+		DescribeTable("compares a newer gpbackup version with gprestore by component",
+			func(backupVersion string, restoreVersion string, compatible bool) {
+				result := utils.CheckBackupVersionCompatibility(backupVersion, restoreVersion)
+				Expect(result.Compatible).To(Equal(compatible))
+				if compatible {
+					Expect(result.Reason).To(BeEmpty())
+					Expect(result.Warning).ToNot(BeEmpty())
+				} else {
+					Expect(result.Reason).ToNot(BeEmpty())
+					Expect(result.Warning).To(BeEmpty())
+				}
+			},
+			Entry("patch delta", "1.2.4", "1.2.3", true),
+			Entry("minor delta", "1.3.0", "1.2.3", true),
+			Entry("major delta", "2.0.0", "1.2.3", false),
+			Entry("patch delta before 1.0", "0.2.1", "0.2.0", true),
+			Entry("minor delta before 1.0", "0.3.0", "0.2.0", false),
+		)
 	})
 	Describe("EnsureDatabaseVersionCompatibility", func() {
 		var restoreVersion utils.GPDBVersion
@@ -188,13 +756,17 @@ types                        1000`))
 		})
 		It("Panics if backup database major version is greater than restore major version", func() {
 			defer testutils.ShouldPanicWithMessage("Cannot restore from GPDB version 6.0.0-beta.9+dev.129.g4bd4e41 build dev to 5.0.0-beta.9+dev.129.g4bd4e41 build dev due to catalog incompatibilities.")
-			utils.EnsureDatabaseVersionCompatibility("6.0.0-beta.9+dev.129.g4bd4e41 build dev", restoreVersion)
+			utils.EnsureDatabaseVersionCompatibility("6.0.0-beta.9+dev.129.g4bd4e41 build dev", restoreVersion, false)
+		})
+		It("Warns and does not panic if backup database major version is greater than restore major version and force is set", func() {
+			utils.EnsureDatabaseVersionCompatibility("6.0.0-beta.9+dev.129.g4bd4e41 build dev", restoreVersion, true)
+			Expect(string(stdout.Contents())).To(ContainSubstring("[WARNING]:-Restoring from GPDB version 6.0.0-beta.9+dev.129.g4bd4e41 build dev to 5.0.0-beta.9+dev.129.g4bd4e41 build dev despite catalog incompatibilities; some objects may fail to restore."))
 		})
 		It("Does not panic if backup database major version is greater than restore major version", func() {
-			utils.EnsureDatabaseVersionCompatibility("4.3.16-beta.9+dev.129.g4bd4e41 build dev", restoreVersion)
+			utils.EnsureDatabaseVersionCompatibility("4.3.16-beta.9+dev.129.g4bd4e41 build dev", restoreVersion, false)
 		})
 		It("Does not panic if backup database major version is equal to restore major version", func() {
-			utils.EnsureDatabaseVersionCompatibility("5.0.6-beta.9+dev.129.g4bd4e41 build dev", restoreVersion)
+			utils.EnsureDatabaseVersionCompatibility("5.0.6-beta.9+dev.129.g4bd4e41 build dev", restoreVersion, false)
 		})
 	})
 	Describe("Email-related functions", func() {
@@ -228,21 +800,22 @@ contact2@example.org`)
 			testCluster.Executor = testExecutor
 		})
 		AfterEach(func() {
-			utils.InitializeSystemFunctions()
+			utils.System = utils.InitializeSystemFunctions()
 		})
 		Context("ConstructEmailMessage", func() {
 			It("adds HTML formatting to the contents of the report file", func() {
 				w.Write(reportFileContents)
 				w.Close()
 
-				message := utils.ConstructEmailMessage(testCluster, contactsList)
+				message, err := utils.ConstructEmailMessage(testCluster, contactsList, utils.DefaultEmailSubjectTemplate)
+				Expect(err).ToNot(HaveOccurred())
 				expectedMessage := `To: contact1@example.com contact2@example.org
 Subject: gpbackup 20170101010101 on localhost completed
 Content-Type: text/html
 Content-Disposition: inline
 <html>
 <body>
-<pre style=\"font: monospace\">
+<pre style="font: monospace">
 Greenplum Database Backup Report
 
 Timestamp Key: 20170101010101
@@ -251,8 +824,84 @@ Timestamp Key: 20170101010101
 </html>`
 				Expect(message).To(Equal(expectedMessage))
 			})
+			It("fills in a custom subject template", func() {
+				w.Write([]byte("Greenplum Database Backup Report\n\nBackup Status: Failure"))
+				w.Close()
+
+				message, err := utils.ConstructEmailMessage(testCluster, contactsList, "[PROD] gpbackup {{.Timestamp}} {{.Status}} on {{.Hostname}}")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(message).To(ContainSubstring("\nSubject: [PROD] gpbackup 20170101010101 Failure on localhost\n"))
+			})
+			It("gives a status of Success for a successful backup", func() {
+				w.Write([]byte("Greenplum Database Backup Report\n\nBackup Status: Success"))
+				w.Close()
+
+				message, err := utils.ConstructEmailMessage(testCluster, contactsList, "gpbackup {{.Status}}")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(message).To(ContainSubstring("\nSubject: gpbackup Success\n"))
+			})
+			It("returns an error for a subject template with an unknown field", func() {
+				w.Write(reportFileContents)
+				w.Close()
+
+				_, err := utils.ConstructEmailMessage(testCluster, contactsList, "gpbackup {{.Database}}")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+		Context("ConstructEmailMessageWithAttachment", func() {
+			failedReport := "Greenplum Database Backup Report\n\nBackup Status: Failure\nBackup Error: ERROR: could not write to file \"/data/gpseg0/backups\": No space left on device\n"
+			It("attaches the whole report file, including its error, with a short body", func() {
+				w.Write([]byte(failedReport))
+				w.Close()
+
+				message, err := utils.ConstructEmailMessageWithAttachment(testCluster, contactsList, utils.DefaultEmailSubjectTemplate)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(message).To(HavePrefix(`To: contact1@example.com contact2@example.org
+Subject: gpbackup 20170101010101 on localhost completed
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary=gpbackup-report-20170101010101
+
+--gpbackup-report-20170101010101
+Content-Type: text/plain; charset=utf-8
+
+Backup 20170101010101 on localhost finished with status Failure.
+The full report is attached.
+
+--gpbackup-report-20170101010101
+Content-Type: text/plain; charset=utf-8
+Content-Disposition: attachment; filename=gpbackup_20170101010101_report.txt
+Content-Transfer-Encoding: base64
+
+`))
+				Expect(message).To(HaveSuffix("\n--gpbackup-report-20170101010101--"))
+				Expect(message).ToNot(ContainSubstring(`"`))
+
+				parts := strings.Split(message, "Content-Transfer-Encoding: base64\n\n")
+				encoded := strings.TrimSuffix(parts[1], "\n--gpbackup-report-20170101010101--")
+				for _, line := range strings.Split(encoded, "\n") {
+					Expect(len(line)).To(BeNumerically("<=", 76))
+				}
+				decoded, err := base64.StdEncoding.DecodeString(strings.Replace(encoded, "\n", "", -1))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(decoded)).To(Equal(failedReport))
+			})
 		})
 		Context("EmailReport", func() {
+			emailConfig := utils.EmailConfig{SubjectTemplate: utils.DefaultEmailSubjectTemplate, Transport: utils.EmailTransportSendmail}
+			// Files are closed after being read, so the report is read from its own empty pipe
+			openContactsOrReport := func(name string) (utils.ReadCloserAt, error) {
+				if strings.HasSuffix(name, "_report") {
+					reportR, reportW, _ := os.Pipe()
+					reportW.Close()
+					return reportR, nil
+				}
+				return r, nil
+			}
+			BeforeEach(func() {
+				utils.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (utils.ReadCloserAt, error) {
+					return openContactsOrReport(name)
+				}
+			})
 			var (
 				expectedHomeCmd   = "test -f home/mail_contacts"
 				expectedGpHomeCmd = "test -f gphome/bin/mail_contacts"
@@ -274,7 +923,7 @@ Content-Disposition: inline
 
 				testExecutor.LocalError = errors.Errorf("exit status 2")
 
-				utils.EmailReport(testCluster)
+				utils.EmailReport(testCluster, emailConfig)
 				Expect(testExecutor.NumExecutions).To(Equal(2))
 				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd, expectedGpHomeCmd}))
 				Expect(stdout).To(gbytes.Say("Found neither gphome/bin/mail_contacts nor home/mail_contacts"))
@@ -286,7 +935,7 @@ Content-Disposition: inline
 				testExecutor.ErrorOnExecNum = 2 // Shouldn't hit this case, as it shouldn't be executed a second time
 				testExecutor.LocalError = errors.Errorf("exit status 2")
 
-				utils.EmailReport(testCluster)
+				utils.EmailReport(testCluster, emailConfig)
 				Expect(testExecutor.NumExecutions).To(Equal(2))
 				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd, expectedMessage}))
 				Expect(logfile).To(gbytes.Say("Sending email report to the following addresses: contact1@example.com contact2@example.org"))
@@ -298,20 +947,224 @@ Content-Disposition: inline
 				testExecutor.ErrorOnExecNum = 1
 				testExecutor.LocalError = errors.Errorf("exit status 2")
 
-				utils.EmailReport(testCluster)
+				utils.EmailReport(testCluster, emailConfig)
 				Expect(testExecutor.NumExecutions).To(Equal(3))
 				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd, expectedGpHomeCmd, expectedMessage}))
 				Expect(logfile).To(gbytes.Say("Sending email report to the following addresses: contact1@example.com contact2@example.org"))
 			})
+			It("retries reading the contacts file if the first read fails", func() {
+				w.Write(contactsFileContents)
+				w.Close()
+				readAttempts := 0
+				utils.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (utils.ReadCloserAt, error) {
+					if name == "home/mail_contacts" {
+						readAttempts++
+					}
+					if readAttempts == 1 {
+						return nil, errors.New("stale NFS file handle")
+					}
+					return openContactsOrReport(name)
+				}
+				sleeps := 0
+				utils.System.Sleep = func(d time.Duration) { sleeps++ }
+
+				utils.EmailReport(testCluster, emailConfig)
+				Expect(readAttempts).To(Equal(2))
+				Expect(sleeps).To(Equal(1))
+				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd, expectedMessage}))
+				Expect(logfile).To(gbytes.Say("Attempt 1 of 3 to read home/mail_contacts failed: stale NFS file handle"))
+				Expect(logfile).To(gbytes.Say("Reading email contacts from home/mail_contacts, attempt 2 of 3"))
+				Expect(logfile).To(gbytes.Say("Sending email report to the following addresses: contact1@example.com contact2@example.org"))
+			})
+			It("sends no email and raises a warning if every read of the contacts file fails", func() {
+				utils.SetContactsFileReadAttempts(2)
+				defer utils.SetContactsFileReadAttempts(3)
+				utils.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (utils.ReadCloserAt, error) {
+					return nil, errors.New("stale NFS file handle")
+				}
+				sleeps := 0
+				utils.System.Sleep = func(d time.Duration) { sleeps++ }
+
+				utils.EmailReport(testCluster, emailConfig)
+				Expect(sleeps).To(Equal(1))
+				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd}))
+				Expect(stdout).To(gbytes.Say("Unable to read home/mail_contacts: stale NFS file handle"))
+				Expect(stdout).To(gbytes.Say("Unable to send backup email notification"))
+			})
+			It("escapes the characters that are special to echo in double quotes", func() {
+				w.Write(contactsFileContents)
+				w.Close()
+				utils.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (utils.ReadCloserAt, error) {
+					if strings.HasSuffix(name, "_report") {
+						reportR, reportW, _ := os.Pipe()
+						reportW.Write([]byte("Backup Error: ERROR: relation \"$t`x`\\\" does not exist\n"))
+						reportW.Close()
+						return reportR, nil
+					}
+					return r, nil
+				}
+
+				utils.EmailReport(testCluster, emailConfig)
+				Expect(testExecutor.LocalCommands[1]).To(ContainSubstring("Backup Error: ERROR: relation \\\"\\$t\\`x\\`\\\\\\\" does not exist"))
+			})
 			It("sends an email to contacts in $HOME/mail_contacts if a file exists in both $HOME and $GPHOME/bin", func() {
 				w.Write(contactsFileContents)
 				w.Close()
 
-				utils.EmailReport(testCluster)
+				utils.EmailReport(testCluster, emailConfig)
 				Expect(testExecutor.NumExecutions).To(Equal(2))
 				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd, expectedMessage}))
 				Expect(logfile).To(gbytes.Say("Sending email report to the following addresses: contact1@example.com contact2@example.org"))
 			})
+			Context("with a contacts file path", func() {
+				var contactsFileConfig utils.EmailConfig
+				BeforeEach(func() {
+					contactsFileConfig = utils.EmailConfig{SubjectTemplate: utils.DefaultEmailSubjectTemplate, Transport: utils.EmailTransportSendmail, ContactsFile: "/etc/gpbackup/contacts"}
+				})
+				It("sends an email to contacts in the given file without checking $HOME or $GPHOME/bin", func() {
+					w.Write(contactsFileContents)
+					w.Close()
+					var openedFiles []string
+					utils.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (utils.ReadCloserAt, error) {
+						openedFiles = append(openedFiles, name)
+						return openContactsOrReport(name)
+					}
+
+					utils.EmailReport(testCluster, contactsFileConfig)
+					Expect(testExecutor.LocalCommands).To(Equal([]string{"test -f /etc/gpbackup/contacts", expectedMessage}))
+					Expect(openedFiles[0]).To(Equal("/etc/gpbackup/contacts"))
+					Expect(logfile).To(gbytes.Say("Sending email report to the following addresses: contact1@example.com contact2@example.org"))
+				})
+				It("sends no email and raises a warning naming the given file if it is not found", func() {
+					testExecutor.LocalError = errors.Errorf("exit status 2")
+
+					utils.EmailReport(testCluster, contactsFileConfig)
+					Expect(testExecutor.LocalCommands).To(Equal([]string{"test -f /etc/gpbackup/contacts"}))
+					Expect(stdout).To(gbytes.Say("Found no contacts file at /etc/gpbackup/contacts"))
+					Expect(stdout).To(gbytes.Say("Unable to send backup email notification"))
+				})
+			})
+			It("sends the report as an attachment if configured", func() {
+				w.Write(contactsFileContents)
+				w.Close()
+
+				utils.EmailReport(testCluster, utils.EmailConfig{SubjectTemplate: utils.DefaultEmailSubjectTemplate, Transport: utils.EmailTransportSendmail, AttachReport: true})
+				Expect(testExecutor.LocalCommands).To(HaveLen(2))
+				Expect(testExecutor.LocalCommands[1]).To(ContainSubstring("Content-Type: multipart/mixed; boundary=gpbackup-report-20170101010101"))
+				Expect(testExecutor.LocalCommands[1]).To(HaveSuffix(`--gpbackup-report-20170101010101--" | sendmail -t`))
+			})
+			Context("when only sending email on failure", func() {
+				var failureConfig utils.EmailConfig
+				BeforeEach(func() {
+					failureConfig = utils.EmailConfig{SubjectTemplate: utils.DefaultEmailSubjectTemplate, Transport: utils.EmailTransportSendmail, OnlyOnFailure: true}
+				})
+				openReportWithStatus := func(status string) {
+					utils.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (utils.ReadCloserAt, error) {
+						if strings.HasSuffix(name, "mail_contacts") {
+							return r, nil
+						}
+						reportR, reportW, _ := os.Pipe()
+						reportW.Write([]byte(fmt.Sprintf("Greenplum Database Backup Report\n\nBackup Status: %s\n", status)))
+						reportW.Close()
+						return reportR, nil
+					}
+				}
+				It("sends no email and logs that it was skipped if the backup succeeded", func() {
+					openReportWithStatus("Success")
+
+					utils.EmailReport(testCluster, failureConfig)
+					Expect(testExecutor.NumExecutions).To(Equal(0))
+					Expect(logfile).To(gbytes.Say("Backup succeeded, so no email report will be sent"))
+				})
+				It("sends an email if the backup failed", func() {
+					w.Write(contactsFileContents)
+					w.Close()
+					openReportWithStatus("Failure")
+
+					utils.EmailReport(testCluster, failureConfig)
+					Expect(testExecutor.NumExecutions).To(Equal(2))
+					Expect(testExecutor.LocalCommands[1]).To(ContainSubstring("Backup Status: Failure"))
+					Expect(testExecutor.LocalCommands[1]).To(HaveSuffix("| sendmail -t"))
+				})
+			})
+			Context("with the smtp transport", func() {
+				var (
+					client     *fakeSMTPClient
+					dialedAddr string
+					smtpConfig utils.EmailConfig
+				)
+				BeforeEach(func() {
+					client = &fakeSMTPClient{}
+					dialedAddr = ""
+					utils.System.DialSMTP = func(address string) (utils.SMTPClient, error) {
+						dialedAddr = address
+						return client, nil
+					}
+					utils.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "gpadmin"}, nil }
+					smtpConfig = utils.EmailConfig{SubjectTemplate: utils.DefaultEmailSubjectTemplate, Transport: utils.EmailTransportSMTP, SMTPAddress: "smtp.example.com:587"}
+				})
+				It("sends the email to contacts in $HOME/mail_contacts through the SMTP server instead of sendmail", func() {
+					w.Write(contactsFileContents)
+					w.Close()
+
+					utils.EmailReport(testCluster, smtpConfig)
+					Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd}))
+					Expect(dialedAddr).To(Equal("smtp.example.com:587"))
+					Expect(client.calls).To(Equal([]string{"MAIL gpadmin@localhost", "RCPT contact1@example.com", "RCPT contact2@example.org", "DATA", "QUIT"}))
+					Expect(client.message.String()).To(HavePrefix("To: contact1@example.com contact2@example.org\nSubject: gpbackup 20170101010101 on localhost completed\n"))
+					Expect(client.message.String()).To(ContainSubstring(`<pre style="font: monospace">`))
+				})
+				It("sends the report contents unchanged", func() {
+					w.Write(contactsFileContents)
+					w.Close()
+					utils.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (utils.ReadCloserAt, error) {
+						if strings.HasSuffix(name, "_report") {
+							reportR, reportW, _ := os.Pipe()
+							reportW.Write([]byte(`Backup Error: ERROR: relation \"quoted\" does not exist` + "\n"))
+							reportW.Close()
+							return reportR, nil
+						}
+						return r, nil
+					}
+
+					utils.EmailReport(testCluster, smtpConfig)
+					Expect(client.message.String()).To(ContainSubstring(`Backup Error: ERROR: relation \"quoted\" does not exist`))
+				})
+				It("skips blank lines in the contacts file", func() {
+					w.Write([]byte("contact1@example.com\n\n   \ncontact2@example.org\n"))
+					w.Close()
+
+					utils.EmailReport(testCluster, smtpConfig)
+					Expect(client.calls).To(Equal([]string{"MAIL gpadmin@localhost", "RCPT contact1@example.com", "RCPT contact2@example.org", "DATA", "QUIT"}))
+					Expect(client.message.String()).To(HavePrefix("To: contact1@example.com contact2@example.org\n"))
+				})
+				It("upgrades the connection with STARTTLS and authenticates if configured", func() {
+					w.Write(contactsFileContents)
+					w.Close()
+					smtpConfig.StartTLS = true
+					smtpConfig.Username = "mailuser"
+					smtpConfig.Password = "secret"
+
+					utils.EmailReport(testCluster, smtpConfig)
+					Expect(client.calls[:3]).To(Equal([]string{"STARTTLS smtp.example.com", "AUTH", "MAIL gpadmin@localhost"}))
+				})
+				It("raises a warning if the SMTP server rejects a recipient", func() {
+					w.Write(contactsFileContents)
+					w.Close()
+					client.rcptErr = errors.New("550 mailbox unavailable")
+
+					utils.EmailReport(testCluster, smtpConfig)
+					Expect(client.calls).To(Equal([]string{"MAIL gpadmin@localhost", "RCPT contact1@example.com"}))
+					Expect(stdout).To(gbytes.Say("Unable to send email report: 550 mailbox unavailable"))
+				})
+				It("sends no email and raises a warning if no mail_contacts file is found", func() {
+					testExecutor.LocalError = errors.Errorf("exit status 2")
+
+					utils.EmailReport(testCluster, smtpConfig)
+					Expect(dialedAddr).To(Equal(""))
+					Expect(stdout).To(gbytes.Say("Found neither gphome/bin/mail_contacts nor home/mail_contacts"))
+				})
+			})
 		})
 	})
 })
@@ -0,0 +1,97 @@
+package utils
+
+/*
+ * This file contains functions used to find and remove old backups according
+ * to a retention period.
+ */
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var timestampPattern = regexp.MustCompile(`^\d{14}$`)
+
+/*
+ * The dir argument is a backups directory as created by gpbackup, containing
+ * one directory per date with one directory per backup timestamp beneath it,
+ * e.g. <master data directory>/backups.  A backup's age is determined by the
+ * timestamp in its report file rather than by file modification times, which
+ * can change if backups are copied; backups without a readable report, such as
+ * those still in progress, are never considered expired.  The timestamps of
+ * expired backups are returned in ascending order.
+ */
+func FindExpiredBackups(dir string, olderThan time.Duration) []string {
+	expired := make([]string, 0)
+	dateDirs, err := Storage.List(dir)
+	if err != nil {
+		logger.Verbose("Unable to list backups in %s: %s", dir, err.Error())
+		return expired
+	}
+	now := System.Now()
+	for _, dateDir := range dateDirs {
+		timestampDirs, err := Storage.List(dateDir)
+		if err != nil {
+			continue
+		}
+		for _, timestampDir := range timestampDirs {
+			timestamp := path.Base(timestampDir)
+			if !timestampPattern.MatchString(timestamp) {
+				continue
+			}
+			reportFilename := path.Join(timestampDir, fmt.Sprintf("gpbackup_%s_report", timestamp))
+			reportTimestamp, err := ReadReportTimestamp(reportFilename)
+			if err != nil {
+				logger.Verbose("Skipping backup %s: %s", timestamp, err.Error())
+				continue
+			}
+			backupTime, err := time.ParseInLocation("20060102150405", reportTimestamp, time.Local)
+			if err != nil {
+				logger.Verbose("Skipping backup %s: invalid report timestamp %s", timestamp, reportTimestamp)
+				continue
+			}
+			if now.Sub(backupTime) > olderThan {
+				expired = append(expired, timestamp)
+			}
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+/*
+ * This removes all files for the given backup timestamp in the given backups
+ * directory, then the timestamp directory itself, then the date directory if no
+ * other backups remain in it.
+ */
+func PurgeBackup(dir string, timestamp string) error {
+	if !timestampPattern.MatchString(timestamp) {
+		return errors.Errorf("Invalid timestamp %s", timestamp)
+	}
+	dateDir := path.Join(dir, timestamp[0:8])
+	timestampDir := path.Join(dateDir, timestamp)
+	filenames, err := Storage.List(timestampDir)
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		err = Storage.Remove(filename)
+		if err != nil {
+			return err
+		}
+	}
+	err = Storage.Remove(timestampDir)
+	if err != nil {
+		return err
+	}
+	remaining, err := Storage.List(dateDir)
+	if err == nil && len(remaining) == 0 {
+		return Storage.Remove(dateDir)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package utils_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/retention tests", func() {
+	var backupsDir string
+	day := 24 * time.Hour
+	createBackup := func(timestamp string, withReport bool) string {
+		timestampDir := path.Join(backupsDir, timestamp[0:8], timestamp)
+		Expect(os.MkdirAll(timestampDir, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(path.Join(timestampDir, fmt.Sprintf("gpbackup_%s_predata.sql", timestamp)), []byte("CREATE SCHEMA schema1;\n"), 0644)).To(Succeed())
+		if withReport {
+			report := fmt.Sprintf("Greenplum Database Backup Report\n\nTimestamp Key: %s\nGPDB Version: 5.1.0\n", timestamp)
+			Expect(ioutil.WriteFile(path.Join(timestampDir, fmt.Sprintf("gpbackup_%s_report", timestamp)), []byte(report), 0444)).To(Succeed())
+		}
+		return timestampDir
+	}
+	BeforeEach(func() {
+		backupsDir, _ = ioutil.TempDir("", "backups")
+		utils.System.Now = func() time.Time { return time.Date(2017, time.January, 31, 12, 0, 0, 0, time.Local) }
+	})
+	AfterEach(func() {
+		os.RemoveAll(backupsDir)
+		utils.System = utils.InitializeSystemFunctions()
+	})
+	Describe("FindExpiredBackups", func() {
+		It("returns backups older than the retention period based on their report timestamps", func() {
+			createBackup("20170101010101", true)
+			createBackup("20170115010101", true)
+			createBackup("20170130010101", true)
+			createBackup("20170131010101", true)
+
+			Expect(utils.FindExpiredBackups(backupsDir, 7*day)).To(Equal([]string{"20170101010101", "20170115010101"}))
+			Expect(utils.FindExpiredBackups(backupsDir, 20*day)).To(Equal([]string{"20170101010101"}))
+			Expect(utils.FindExpiredBackups(backupsDir, 60*day)).To(BeEmpty())
+		})
+		It("uses the report timestamp rather than the directory name", func() {
+			timestampDir := createBackup("20170130010101", false)
+			report := "Greenplum Database Backup Report\n\nTimestamp Key: 20161201010101\n"
+			Expect(ioutil.WriteFile(path.Join(timestampDir, "gpbackup_20170130010101_report"), []byte(report), 0444)).To(Succeed())
+
+			Expect(utils.FindExpiredBackups(backupsDir, 7*day)).To(Equal([]string{"20170130010101"}))
+		})
+		It("does not return backups without a report file", func() {
+			createBackup("20170101010101", false)
+
+			Expect(utils.FindExpiredBackups(backupsDir, 7*day)).To(BeEmpty())
+		})
+		It("returns no backups for a nonexistent directory", func() {
+			Expect(utils.FindExpiredBackups(path.Join(backupsDir, "nonexistent"), 7*day)).To(BeEmpty())
+		})
+	})
+	Describe("PurgeBackup", func() {
+		It("removes all files for a backup and its now-empty date directory", func() {
+			timestampDir := createBackup("20170101010101", true)
+
+			Expect(utils.PurgeBackup(backupsDir, "20170101010101")).To(Succeed())
+
+			_, err := os.Stat(timestampDir)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+			_, err = os.Stat(path.Join(backupsDir, "20170101"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+		It("leaves other backups taken on the same date", func() {
+			createBackup("20170101010101", true)
+			otherDir := createBackup("20170101020202", true)
+
+			Expect(utils.PurgeBackup(backupsDir, "20170101010101")).To(Succeed())
+
+			_, err := os.Stat(path.Join(otherDir, "gpbackup_20170101020202_report"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(utils.FindExpiredBackups(backupsDir, 7*day)).To(Equal([]string{"20170101020202"}))
+		})
+		It("returns an error for an invalid timestamp", func() {
+			Expect(utils.PurgeBackup(backupsDir, "../../etc")).To(MatchError("Invalid timestamp ../../etc"))
+		})
+	})
+})
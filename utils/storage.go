@@ -0,0 +1,74 @@
+package utils
+
+/*
+ * This file contains the interface through which backup artifacts are written
+ * and read, so that backup files can be stored somewhere other than the local
+ * filesystem by providing a different implementation.
+ */
+
+import (
+	"io"
+	"os"
+	"path"
+)
+
+var (
+	Storage BackupStorage = LocalStorage{}
+)
+
+type BackupStorage interface {
+	Create(filename string) (io.WriteCloser, error)
+	Open(filename string) (ReadCloserAt, error)
+	Exists(filename string) bool
+	List(dirname string) ([]string, error)
+	Remove(filename string) error
+	Rename(oldname string, newname string) error
+	FreeSpace(dirname string) (uint64, error)
+}
+
+func SetStorage(storage BackupStorage) {
+	Storage = storage
+}
+
+/*
+ * LocalStorage is the default BackupStorage implementation.  It goes through
+ * System so that file operations can still be mocked out in unit tests.
+ */
+type LocalStorage struct{}
+
+func (storage LocalStorage) Create(filename string) (io.WriteCloser, error) {
+	return System.OpenFileWrite(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+func (storage LocalStorage) Open(filename string) (ReadCloserAt, error) {
+	return System.OpenFileRead(filename, os.O_RDONLY, 0644)
+}
+
+func (storage LocalStorage) Exists(filename string) bool {
+	_, err := System.Stat(filename)
+	if err != nil {
+		return false
+	}
+	fileHandle, err := System.OpenFileRead(filename, os.O_RDONLY, 0644)
+	if err != nil {
+		return false
+	}
+	fileHandle.Close()
+	return true
+}
+
+func (storage LocalStorage) List(dirname string) ([]string, error) {
+	return System.Glob(path.Join(dirname, "*"))
+}
+
+func (storage LocalStorage) Remove(filename string) error {
+	return System.Remove(filename)
+}
+
+func (storage LocalStorage) Rename(oldname string, newname string) error {
+	return System.Rename(oldname, newname)
+}
+
+func (storage LocalStorage) FreeSpace(dirname string) (uint64, error) {
+	return System.FreeSpace(dirname)
+}
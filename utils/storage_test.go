@@ -0,0 +1,177 @@
+package utils_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type memoryFile struct {
+	*bytes.Buffer
+}
+
+func (file memoryFile) Close() error {
+	return nil
+}
+
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (reader memoryReader) Close() error {
+	return nil
+}
+
+type memoryStorage struct {
+	files map[string]*bytes.Buffer
+}
+
+func (storage memoryStorage) Create(filename string) (io.WriteCloser, error) {
+	if _, ok := storage.files[filename]; !ok {
+		storage.files[filename] = &bytes.Buffer{}
+	}
+	return memoryFile{storage.files[filename]}, nil
+}
+
+func (storage memoryStorage) Open(filename string) (utils.ReadCloserAt, error) {
+	contents, ok := storage.files[filename]
+	if !ok {
+		return nil, errors.New("file does not exist")
+	}
+	return memoryReader{bytes.NewReader(contents.Bytes())}, nil
+}
+
+func (storage memoryStorage) Exists(filename string) bool {
+	_, ok := storage.files[filename]
+	return ok
+}
+
+func (storage memoryStorage) List(dirname string) ([]string, error) {
+	filenames := make([]string, 0)
+	for filename := range storage.files {
+		if path.Dir(filename) == dirname {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+func (storage memoryStorage) Remove(filename string) error {
+	if _, ok := storage.files[filename]; !ok {
+		return errors.New("file does not exist")
+	}
+	delete(storage.files, filename)
+	return nil
+}
+
+func (storage memoryStorage) Rename(oldname string, newname string) error {
+	contents, ok := storage.files[oldname]
+	if !ok {
+		return errors.New("file does not exist")
+	}
+	storage.files[newname] = contents
+	delete(storage.files, oldname)
+	return nil
+}
+
+func (storage memoryStorage) FreeSpace(dirname string) (uint64, error) {
+	return 1024, nil
+}
+
+var _ = Describe("utils/storage tests", func() {
+	var storage memoryStorage
+	BeforeEach(func() {
+		storage = memoryStorage{files: make(map[string]*bytes.Buffer, 0)}
+		utils.SetStorage(storage)
+		utils.System.Chmod = func(name string, mode os.FileMode) error { return nil }
+	})
+	AfterEach(func() {
+		utils.SetStorage(utils.LocalStorage{})
+		utils.System = utils.InitializeSystemFunctions()
+	})
+	Describe("alternate storage backends", func() {
+		It("writes metadata files to the storage backend", func() {
+			file := utils.NewFileWithByteCountFromFile("/backups/gpbackup_predata.sql")
+			file.MustPrintf("CREATE SCHEMA schema1;\n")
+			file.Close()
+			Expect(storage.files["/backups/gpbackup_predata.sql"].String()).To(Equal("CREATE SCHEMA schema1;\n"))
+			Expect(file.ByteCount).To(Equal(uint64(23)))
+		})
+		It("writes and reads back a TOC file using the storage backend", func() {
+			toc := &utils.TOC{DataEntries: []utils.DataEntry{{Schema: "public", Name: "foo", Oid: 1, AttributeString: "(i)"}}}
+			toc.WriteToFile("/backups/gpbackup_toc.yaml")
+			result := utils.NewTOC("/backups/gpbackup_toc.yaml")
+			Expect(result.DataEntries).To(Equal(toc.DataEntries))
+		})
+		It("writes and reads back a config file using the storage backend", func() {
+			report := utils.Report{BackupConfig: utils.BackupConfig{DatabaseName: "testdb", Compressed: true}}
+			report.WriteConfigFile("/backups/gpbackup_config.yaml")
+			config := utils.ReadConfigFile("/backups/gpbackup_config.yaml")
+			Expect(*config).To(Equal(report.BackupConfig))
+		})
+		It("writes a report file to the storage backend", func() {
+			report := utils.Report{BackupType: "Unfiltered Full Backup", BackupConfig: utils.BackupConfig{DatabaseName: "testdb"}}
+			report.WriteReportFile("/backups/gpbackup_report", "20170101010101", map[string]int{}, "")
+			Expect(strings.HasPrefix(storage.files["/backups/gpbackup_report"].String(), "Greenplum Database Backup Report")).To(BeTrue())
+		})
+		It("writes a file under a temporary name and renames it into place once it is complete", func() {
+			storage.files["/backups/gpbackup_toc.yaml.tmp"] = bytes.NewBufferString("partial contents from an earlier run")
+			utils.MustWriteFileAtomically("/backups/gpbackup_toc.yaml", func(file io.Writer) {
+				utils.MustPrintf(file, "dataentries: []\n")
+				Expect(storage.files).To(HaveKey("/backups/gpbackup_toc.yaml.tmp"))
+				Expect(storage.files).ToNot(HaveKey("/backups/gpbackup_toc.yaml"))
+			})
+			Expect(storage.files).ToNot(HaveKey("/backups/gpbackup_toc.yaml.tmp"))
+			Expect(storage.files["/backups/gpbackup_toc.yaml"].String()).To(Equal("dataentries: []\n"))
+		})
+		It("leaves no final file if writing is interrupted", func() {
+			Expect(func() {
+				utils.MustWriteFileAtomically("/backups/gpbackup_report", func(file io.Writer) {
+					utils.MustPrintf(file, "Greenplum Database Backup Report\n")
+					panic("killed")
+				})
+			}).To(Panic())
+			Expect(storage.files).ToNot(HaveKey("/backups/gpbackup_report"))
+		})
+		It("checks for file existence using the storage backend", func() {
+			storage.files["/backups/gpbackup_predata.sql"] = &bytes.Buffer{}
+			Expect(utils.FileExistsAndIsReadable("/backups/gpbackup_predata.sql")).To(BeTrue())
+			Expect(utils.FileExistsAndIsReadable("/backups/gpbackup_postdata.sql")).To(BeFalse())
+		})
+		It("lists files using the storage backend", func() {
+			storage.files["/backups/gpbackup_predata.sql"] = &bytes.Buffer{}
+			storage.files["/backups/gpbackup_global.sql"] = &bytes.Buffer{}
+			storage.files["/other/gpbackup_postdata.sql"] = &bytes.Buffer{}
+			filenames, err := utils.Storage.List("/backups")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filenames).To(Equal([]string{"/backups/gpbackup_global.sql", "/backups/gpbackup_predata.sql"}))
+		})
+		It("writes a latest pointer file instead of a symlink to the storage backend", func() {
+			storage.files["/data/gpseg-1/backups/latest"] = bytes.NewBufferString("20160101/20160101010101\n")
+			cluster := utils.NewCluster([]utils.SegConfig{{ContentID: -1, Hostname: "localhost", DataDir: "/data/gpseg-1"}}, "", "20170101010101", "gpseg")
+			Expect(cluster.WriteLatestBackupPointer()).To(Succeed())
+			Expect(storage.files["/data/gpseg-1/backups/latest"].String()).To(Equal("20170101/20170101010101\n"))
+		})
+	})
+	Describe("LocalStorage", func() {
+		It("lists files in a directory", func() {
+			utils.System.Glob = func(pattern string) ([]string, error) {
+				Expect(pattern).To(Equal("/backups/*"))
+				return []string{"/backups/gpbackup_predata.sql"}, nil
+			}
+			filenames, err := utils.LocalStorage{}.List("/backups")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filenames).To(Equal([]string{"/backups/gpbackup_predata.sql"}))
+		})
+	})
+})
@@ -6,10 +6,14 @@ package utils
  */
 
 import (
+	"crypto/tls"
 	"io"
+	"log/syslog"
+	"net/smtp"
 	"os"
 	"os/user"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -57,6 +61,57 @@ func OpenFileWrite(name string, flag int, perm os.FileMode) (io.WriteCloser, err
 	return writer, err
 }
 
+/*
+ * SyslogWriter holds the methods of *syslog.Writer used by the Logger, so that
+ * a syslog connection can be mocked out in tests.
+ */
+type SyslogWriter interface {
+	Crit(m string) error
+	Err(m string) error
+	Warning(m string) error
+	Info(m string) error
+	Debug(m string) error
+}
+
+func DialSyslog(network string, raddr string, priority syslog.Priority, tag string) (SyslogWriter, error) {
+	writer, err := syslog.Dial(network, raddr, priority, tag)
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+/*
+ * SMTPClient holds the methods of *smtp.Client used to send the email report,
+ * so that an SMTP connection can be mocked out in tests.
+ */
+type SMTPClient interface {
+	StartTLS(config *tls.Config) error
+	Auth(auth smtp.Auth) error
+	Mail(from string) error
+	Rcpt(to string) error
+	Data() (io.WriteCloser, error)
+	Quit() error
+	Close() error
+}
+
+func DialSMTP(address string) (SMTPClient, error) {
+	client, err := smtp.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func FreeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
 /*
  * SystemFunctions holds function pointers for built-in functions that will need
  * to be mocked out for unit testing.  All built-in functions manipulating the
@@ -67,12 +122,17 @@ func OpenFileWrite(name string, flag int, perm os.FileMode) (io.WriteCloser, err
  * All function pointers in SystemFunctions refer directly to built-in functions
  * except for OpenFileRead and OpenFileWrite, which both refer to os.OpenFile but
  * return either an io.ReadCloser or io.WriteCloser instead of an *os.File, to make
- * mocking file opening in tests easier.
+ * mocking file opening in tests easier, and DialSyslog and DialSMTP, which
+ * likewise return a SyslogWriter instead of a *syslog.Writer and an SMTPClient
+ * instead of an *smtp.Client.
  */
 
 type SystemFunctions struct {
 	Chmod         func(name string, mode os.FileMode) error
 	CurrentUser   func() (*user.User, error)
+	DialSMTP      func(address string) (SMTPClient, error)
+	DialSyslog    func(network string, raddr string, priority syslog.Priority, tag string) (SyslogWriter, error)
+	FreeSpace     func(path string) (uint64, error)
 	Getenv        func(key string) string
 	Getpid        func() int
 	Glob          func(pattern string) (matches []string, err error)
@@ -82,13 +142,21 @@ type SystemFunctions struct {
 	Now           func() time.Time
 	OpenFileRead  func(name string, flag int, perm os.FileMode) (ReadCloserAt, error)
 	OpenFileWrite func(name string, flag int, perm os.FileMode) (io.WriteCloser, error)
+	Remove        func(name string) error
+	Rename        func(oldname string, newname string) error
+	Sleep         func(d time.Duration)
 	Stat          func(name string) (os.FileInfo, error)
+	Symlink       func(oldname string, newname string) error
+	Tick          func(d time.Duration) <-chan time.Time
 }
 
 func InitializeSystemFunctions() *SystemFunctions {
 	return &SystemFunctions{
 		Chmod:         os.Chmod,
 		CurrentUser:   user.Current,
+		DialSMTP:      DialSMTP,
+		DialSyslog:    DialSyslog,
+		FreeSpace:     FreeSpace,
 		Getenv:        os.Getenv,
 		Getpid:        os.Getpid,
 		Glob:          filepath.Glob,
@@ -98,6 +166,11 @@ func InitializeSystemFunctions() *SystemFunctions {
 		Now:           time.Now,
 		OpenFileRead:  OpenFileRead,
 		OpenFileWrite: OpenFileWrite,
+		Remove:        os.Remove,
+		Rename:        os.Rename,
+		Sleep:         time.Sleep,
 		Stat:          os.Stat,
+		Symlink:       os.Symlink,
+		Tick:          time.Tick,
 	}
 }
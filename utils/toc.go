@@ -3,8 +3,8 @@ package utils
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
 	"regexp"
+	"sort"
 
 	yaml "gopkg.in/yaml.v2"
 )
@@ -35,21 +35,23 @@ type DataEntry struct {
 
 func NewTOC(filename string) *TOC {
 	toc := &TOC{}
-	contents, err := ioutil.ReadFile(filename)
-	CheckError(err)
-	err = yaml.Unmarshal(contents, toc)
+	contents := MustReadFile(filename)
+	err := yaml.Unmarshal(contents, toc)
 	CheckError(err)
 	return toc
 }
 
 func (toc *TOC) WriteToFile(filename string) {
 	defer System.Chmod(filename, 0444)
-	tocFile := MustOpenFileForWriting(filename)
 	tocContents, _ := yaml.Marshal(toc)
-	MustPrintBytes(tocFile, tocContents)
+	MustWriteFileAtomically(filename, func(tocFile io.Writer) {
+		MustPrintBytes(tocFile, tocContents)
+	})
 }
 
 type StatementWithType struct {
+	Schema     string
+	Name       string
 	ObjectType string
 	Statement  string
 }
@@ -66,7 +68,7 @@ func (toc *TOC) GetSQLStatementForObjectTypes(filename string, metadataFile io.R
 			contents := make([]byte, entry.EndByte-entry.StartByte)
 			_, err := metadataFile.ReadAt(contents, int64(entry.StartByte))
 			CheckError(err)
-			statements = append(statements, StatementWithType{ObjectType: entry.ObjectType, Statement: string(contents)})
+			statements = append(statements, StatementWithType{Schema: entry.Schema, Name: entry.Name, ObjectType: entry.ObjectType, Statement: string(contents)})
 		}
 	}
 	return statements
@@ -79,11 +81,64 @@ func (toc *TOC) GetAllSQLStatements(filename string, metadataFile io.ReaderAt) [
 		contents := make([]byte, entry.EndByte-entry.StartByte)
 		_, err := metadataFile.ReadAt(contents, int64(entry.StartByte))
 		CheckError(err)
-		statements = append(statements, StatementWithType{ObjectType: entry.ObjectType, Statement: string(contents)})
+		statements = append(statements, StatementWithType{Schema: entry.Schema, Name: entry.Name, ObjectType: entry.ObjectType, Statement: string(contents)})
 	}
 	return statements
 }
 
+func (toc *TOC) allMetadataEntries() [][]MetadataEntry {
+	return [][]MetadataEntry{toc.GlobalEntries, toc.PredataEntries, toc.PostdataEntries, toc.StatisticsEntries}
+}
+
+// This returns the sorted, distinct object types of all metadata entries in the TOC.
+func (toc *TOC) ObjectTypes() []string {
+	typeSet := make(map[string]bool, 0)
+	for _, entries := range toc.allMetadataEntries() {
+		for _, entry := range entries {
+			typeSet[entry.ObjectType] = true
+		}
+	}
+	objectTypes := make([]string, 0, len(typeSet))
+	for objectType := range typeSet {
+		objectTypes = append(objectTypes, objectType)
+	}
+	sort.Strings(objectTypes)
+	return objectTypes
+}
+
+/*
+ * This returns the number of metadata entries in the TOC and the total number
+ * of bytes their statements span across the global, predata, postdata, and
+ * statistics files.  Data entries are not included, as they record no offsets.
+ */
+func (toc *TOC) Summary() (entryCount int, totalBytes uint64) {
+	for _, entries := range toc.allMetadataEntries() {
+		for _, entry := range entries {
+			entryCount++
+			totalBytes += entry.EndByte - entry.StartByte
+		}
+	}
+	return entryCount, totalBytes
+}
+
+/*
+ * This returns the metadata entries of the given object type, in the order in
+ * which they appear in the global, predata, postdata, and statistics files.
+ * All entries of a given type are written to the same file, so the start and
+ * end bytes of the entries can be used to read the statements from that file.
+ */
+func (toc *TOC) EntriesForType(objectType string) []MetadataEntry {
+	matchingEntries := make([]MetadataEntry, 0)
+	for _, entries := range toc.allMetadataEntries() {
+		for _, entry := range entries {
+			if entry.ObjectType == objectType {
+				matchingEntries = append(matchingEntries, entry)
+			}
+		}
+	}
+	return matchingEntries
+}
+
 func SubstituteRedirectDatabaseInStatements(statements []StatementWithType, oldName string, newName string) []StatementWithType {
 	shouldReplace := map[string]bool{"DATABASE GUC": true, "DATABASE": true, "DATABASE METADATA": true}
 	originalDatabase := regexp.QuoteMeta(oldName)
@@ -114,6 +169,7 @@ func (toc *TOC) InitializeEntryMapFromCluster(cluster Cluster) {
 }
 
 func (toc *TOC) AddMetadataEntry(schema string, name string, objectType string, start uint64, file *FileWithByteCount) {
+	file.TransformAndFlush(objectType, FQN(schema, name), start)
 	*toc.metadataEntryMap[file.Filename] = append(*toc.metadataEntryMap[file.Filename], MetadataEntry{schema, name, objectType, start, file.ByteCount})
 }
 
@@ -2,6 +2,7 @@ package utils_test
 
 import (
 	"bytes"
+	"strings"
 
 	"github.com/greenplum-db/gpbackup/testutils"
 	"github.com/greenplum-db/gpbackup/utils"
@@ -10,13 +11,13 @@ import (
 )
 
 var _ = Describe("utils/toc tests", func() {
-	comment := utils.StatementWithType{"COMMENT", "-- This is a comment\n"}
+	comment := utils.StatementWithType{ObjectType: "COMMENT", Statement: "-- This is a comment\n"}
 	commentLen := uint64(len(comment.Statement))
-	create := utils.StatementWithType{"DATABASE", "CREATE DATABASE somedatabase;\n"}
+	create := utils.StatementWithType{Name: "somedatabase", ObjectType: "DATABASE", Statement: "CREATE DATABASE somedatabase;\n"}
 	createLen := uint64(len(create.Statement))
-	role1 := utils.StatementWithType{"ROLE", "CREATE ROLE somerole1;\n"}
+	role1 := utils.StatementWithType{Name: "somerole1", ObjectType: "ROLE", Statement: "CREATE ROLE somerole1;\n"}
 	role1Len := uint64(len(role1.Statement))
-	role2 := utils.StatementWithType{"ROLE", "CREATE ROLE somerole2;\n"}
+	role2 := utils.StatementWithType{Name: "somerole2", ObjectType: "ROLE", Statement: "CREATE ROLE somerole2;\n"}
 	role2Len := uint64(len(role2.Statement))
 	BeforeEach(func() {
 		toc, backupfile = testutils.InitializeTestTOC(buffer, "global")
@@ -54,6 +55,108 @@ var _ = Describe("utils/toc tests", func() {
 			Expect(statements).To(Equal([]utils.StatementWithType{}))
 		})
 	})
+	Context("AddMetadataEntry with a statement transform", func() {
+		AfterEach(func() {
+			utils.SetStatementTransform(nil)
+		})
+		It("writes the transformed statement and records its byte range", func() {
+			utils.SetStatementTransform(func(objectType string, name string, statement string) string {
+				Expect(objectType).To(Equal("TABLE"))
+				Expect(name).To(Equal("public.foo"))
+				return strings.Replace(statement, " WITH OIDS", "", -1)
+			})
+			backupfile.MustPrintf("-- header\n")
+			start := backupfile.ByteCount
+			backupfile.MustPrintf("CREATE TABLE public.foo (i int) WITH OIDS;\n")
+			toc.AddMetadataEntry("public", "foo", "TABLE", start, backupfile)
+
+			Expect(string(buffer.Contents())).To(Equal("-- header\nCREATE TABLE public.foo (i int);\n"))
+			Expect(toc.GlobalEntries).To(Equal([]utils.MetadataEntry{{Schema: "public", Name: "foo", ObjectType: "TABLE", StartByte: 10, EndByte: 43}}))
+			Expect(backupfile.ByteCount).To(Equal(uint64(43)))
+		})
+		It("passes the transform the quoted name of the object", func() {
+			names := make([]string, 0)
+			utils.SetStatementTransform(func(objectType string, name string, statement string) string {
+				names = append(names, name)
+				return statement
+			})
+			backupfile.MustPrintf("CREATE ROLE \"Some Role\";\n")
+			toc.AddMetadataEntry("", "Some Role", "ROLE", 0, backupfile)
+			start := backupfile.ByteCount
+			backupfile.MustPrintf("CREATE TABLE \"Some Schema\".foo (i int);\n")
+			toc.AddMetadataEntry("Some Schema", "foo", "TABLE", start, backupfile)
+
+			Expect(names).To(Equal([]string{`"Some Role"`, `"Some Schema".foo`}))
+		})
+		It("does not write output until the TOC entry is added", func() {
+			utils.SetStatementTransform(func(objectType string, name string, statement string) string {
+				return statement
+			})
+			backupfile.MustPrintf("CREATE ROLE somerole1;\n")
+			Expect(buffer.Contents()).To(BeEmpty())
+			toc.AddMetadataEntry("", "somerole1", "ROLE", 0, backupfile)
+			Expect(string(buffer.Contents())).To(Equal("CREATE ROLE somerole1;\n"))
+		})
+	})
+	Context("ObjectTypes", func() {
+		It("returns the distinct object types in sorted order", func() {
+			toc.AddMetadataEntry("", "", "SESSION GUCS", 0, backupfile)
+			toc.AddMetadataEntry("", "somedatabase", "DATABASE", 0, backupfile)
+			toc.AddMetadataEntry("", "somerole1", "ROLE", 0, backupfile)
+			toc.AddMetadataEntry("", "somerole2", "ROLE", 0, backupfile)
+			toc.AddMetadataEntry("", "sometablespace", "TABLESPACE", 0, backupfile)
+			toc.PredataEntries = []utils.MetadataEntry{{Schema: "public", Name: "foo", ObjectType: "TABLE"}}
+
+			Expect(toc.ObjectTypes()).To(Equal([]string{"DATABASE", "ROLE", "SESSION GUCS", "TABLE", "TABLESPACE"}))
+		})
+		It("returns an empty list for an empty TOC", func() {
+			Expect(toc.ObjectTypes()).To(BeEmpty())
+		})
+	})
+	Context("Summary", func() {
+		It("counts the entries and the bytes they span across all metadata files", func() {
+			backupfile.ByteCount = createLen
+			toc.AddMetadataEntry("", "somedatabase", "DATABASE", 0, backupfile)
+			backupfile.ByteCount += role1Len
+			toc.AddMetadataEntry("", "somerole1", "ROLE", createLen, backupfile)
+			toc.PredataEntries = []utils.MetadataEntry{{Schema: "public", Name: "sometable", ObjectType: "TABLE", StartByte: 10, EndByte: 50}}
+			toc.AddDataEntry("public", "sometable", 1, "(i)")
+
+			entryCount, totalBytes := toc.Summary()
+
+			Expect(entryCount).To(Equal(3))
+			Expect(totalBytes).To(Equal(createLen + role1Len + 40))
+		})
+		It("returns zero for an empty TOC", func() {
+			entryCount, totalBytes := toc.Summary()
+
+			Expect(entryCount).To(Equal(0))
+			Expect(totalBytes).To(Equal(uint64(0)))
+		})
+	})
+	Context("EntriesForType", func() {
+		BeforeEach(func() {
+			backupfile.ByteCount = createLen
+			toc.AddMetadataEntry("", "somedatabase", "DATABASE", 0, backupfile)
+			backupfile.ByteCount += role1Len
+			toc.AddMetadataEntry("", "somerole1", "ROLE", createLen, backupfile)
+			backupfile.ByteCount += role2Len
+			toc.AddMetadataEntry("", "somerole2", "ROLE", createLen+role1Len, backupfile)
+		})
+		It("returns all entries of a type with their byte ranges", func() {
+			entries := toc.EntriesForType("ROLE")
+
+			Expect(entries).To(Equal([]utils.MetadataEntry{
+				{Schema: "", Name: "somerole1", ObjectType: "ROLE", StartByte: createLen, EndByte: createLen + role1Len},
+				{Schema: "", Name: "somerole2", ObjectType: "ROLE", StartByte: createLen + role1Len, EndByte: createLen + role1Len + role2Len},
+			}))
+			globalFile := []byte(create.Statement + role1.Statement + role2.Statement)
+			Expect(string(globalFile[entries[1].StartByte:entries[1].EndByte])).To(Equal(role2.Statement))
+		})
+		It("returns no entries for a type that is not in the TOC", func() {
+			Expect(toc.EntriesForType("TABLESPACE")).To(BeEmpty())
+		})
+	})
 	Context("GetAllSqlStatements", func() {
 		It("returns statement for a single object type", func() {
 			backupfile.ByteCount = createLen
@@ -86,10 +189,10 @@ var _ = Describe("utils/toc tests", func() {
 	})
 	Context("SubstituteRedirectDatabaseInStatements", func() {
 		var toc utils.TOC
-		wrongCreate := utils.StatementWithType{"TABLE", "CREATE DATABASE somedatabase;\n"}
-		gucs := utils.StatementWithType{"DATABASE GUC", "ALTER DATABASE somedatabase SET fsync TO off;\n"}
-		metadata := utils.StatementWithType{"DATABASE METADATA", "ALTER DATABASE somedatabase OWNER TO testrole;\n"}
-		oldSpecial := utils.StatementWithType{"DATABASE", `CREATE DATABASE "db-special-chär$";
+		wrongCreate := utils.StatementWithType{ObjectType: "TABLE", Statement: "CREATE DATABASE somedatabase;\n"}
+		gucs := utils.StatementWithType{ObjectType: "DATABASE GUC", Statement: "ALTER DATABASE somedatabase SET fsync TO off;\n"}
+		metadata := utils.StatementWithType{ObjectType: "DATABASE METADATA", Statement: "ALTER DATABASE somedatabase OWNER TO testrole;\n"}
+		oldSpecial := utils.StatementWithType{ObjectType: "DATABASE", Statement: `CREATE DATABASE "db-special-chär$";
 `}
 
 		BeforeEach(func() {
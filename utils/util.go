@@ -12,8 +12,9 @@ import (
 
 /*
  * Abort() is for handling critical errors.  It panic()s to unwind the call stack
- * until the panic is caught by the recover() in DoTeardown() in backup.go, at
- * which point any necessary cleanup is performed.
+ * until the panic is caught by the recover() in DoTeardown() in backup.go or
+ * restore.go and passed to HandleFatalPanic(), at which point any necessary
+ * cleanup is performed.
  *
  * log.Fatal() calls Abort() after logging its arguments, so generally that function
  * should be used instead of calling Abort() directly.
@@ -30,6 +31,38 @@ func Abort(output ...interface{}) {
 	panic(errStr)
 }
 
+/*
+ * If set, WriteExitReport is called by HandleFatalPanic() with the error
+ * message of the recovered panic, or with an empty string if there was no
+ * panic, so that a report is written however the program ends.
+ */
+var WriteExitReport func(errMsg string)
+
+/*
+ * HandleFatalPanic() converts the value recovered at the top level of a program
+ * into the exit code for the program, printing the error and writing a report
+ * via WriteExitReport along the way.  A nil value means there was no panic.
+ */
+func HandleFatalPanic(recovered interface{}) int {
+	errStr := ""
+	if recovered != nil {
+		switch err := recovered.(type) {
+		case error:
+			errStr = err.Error()
+		case string:
+			errStr = err
+		default:
+			errStr = fmt.Sprintf("%v", err)
+		}
+		fmt.Println(errStr)
+	}
+	errMsg, exitCode := ParseErrorMessage(errStr)
+	if WriteExitReport != nil {
+		WriteExitReport(errMsg)
+	}
+	return exitCode
+}
+
 func CheckError(err error) {
 	if err != nil {
 		logger.Fatal(err, "")
@@ -70,3 +103,26 @@ func DollarQuoteString(literal string) string {
 func MakeFQN(schema string, object string) string {
 	return fmt.Sprintf("%s.%s", schema, object)
 }
+
+/*
+ * Names retrieved from the database are generally already quoted with
+ * quote_ident(), so identifiers that are already quoted are left unchanged.
+ */
+func quoteIdentIfUnquoted(ident string) string {
+	if ident == "" || QuotedIdentifier.MatchString(ident) {
+		return ident
+	}
+	return QuoteIdent(ident)
+}
+
+/*
+ * Unlike MakeFQN, this quotes each identifier as necessary and omits the schema
+ * for objects that do not belong to one (roles, tablespaces, databases, etc.),
+ * so it should be used wherever an object name is displayed to the user.
+ */
+func FQN(schema string, name string) string {
+	if schema == "" {
+		return quoteIdentIfUnquoted(name)
+	}
+	return MakeFQN(quoteIdentIfUnquoted(schema), quoteIdentIfUnquoted(name))
+}
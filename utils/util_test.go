@@ -3,6 +3,8 @@ package utils_test
 import (
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/greenplum-db/gpbackup/utils"
 
 	. "github.com/onsi/ginkgo"
@@ -18,6 +20,54 @@ var _ = Describe("utils/util tests", func() {
 			Expect(actual).To(Equal(expected))
 		})
 	})
+	Context("HandleFatalPanic", func() {
+		var reportedMsgs []string
+		BeforeEach(func() {
+			reportedMsgs = []string{}
+			utils.WriteExitReport = func(errMsg string) {
+				reportedMsgs = append(reportedMsgs, errMsg)
+			}
+		})
+		AfterEach(func() {
+			utils.WriteExitReport = nil
+		})
+		It("returns exit code 0 and writes a report if there was no panic", func() {
+			exitCode := utils.HandleFatalPanic(nil)
+			Expect(exitCode).To(Equal(0))
+			Expect(reportedMsgs).To(Equal([]string{""}))
+		})
+		It("returns exit code 1 and reports the message of a panic from Logger.Fatal", func() {
+			exitCode := utils.HandleFatalPanic("gpbackup:testUser:testHost:000000-[CRITICAL]:-Error Message")
+			Expect(exitCode).To(Equal(1))
+			Expect(reportedMsgs).To(Equal([]string{"Error Message"}))
+		})
+		It("reports the message of a panic from Logger.Fatal with a non-default prefix separator", func() {
+			logger.SetPrefixSeparator("|")
+			exitCode := func() (exitCode int) {
+				defer func() {
+					exitCode = utils.HandleFatalPanic(recover())
+				}()
+				logger.Fatal(errors.New("Error Message"), "")
+				return 0
+			}()
+			Expect(exitCode).To(Equal(1))
+			Expect(reportedMsgs).To(Equal([]string{"Error Message"}))
+		})
+		It("returns exit code 1 and reports the message of an error panic", func() {
+			exitCode := utils.HandleFatalPanic(errors.New("runtime error: index out of range"))
+			Expect(exitCode).To(Equal(1))
+			Expect(reportedMsgs).To(Equal([]string{"runtime error: index out of range"}))
+		})
+		It("returns exit code 1 for a panic with a value of another type", func() {
+			exitCode := utils.HandleFatalPanic(42)
+			Expect(exitCode).To(Equal(1))
+			Expect(reportedMsgs).To(Equal([]string{"42"}))
+		})
+		It("returns the exit code if no report writer is set", func() {
+			utils.WriteExitReport = nil
+			Expect(utils.HandleFatalPanic("Error Message")).To(Equal(1))
+		})
+	})
 	Context("DollarQuoteString", func() {
 		It("uses $$ if the string contains no dollar signs", func() {
 			testStr := "message"
@@ -44,4 +94,19 @@ var _ = Describe("utils/util tests", func() {
 			Expect(actual).To(Equal(expected))
 		})
 	})
+	Context("FQN", func() {
+		It("joins a schema and a name", func() {
+			Expect(utils.FQN("public", "foo")).To(Equal("public.foo"))
+		})
+		It("omits the schema for schema-less objects", func() {
+			Expect(utils.FQN("", "testrole")).To(Equal("testrole"))
+		})
+		It("quotes a schema and a name that need quoting", func() {
+			Expect(utils.FQN("My Schema", "Foo")).To(Equal(`"My Schema"."Foo"`))
+			Expect(utils.FQN("", "Test Role")).To(Equal(`"Test Role"`))
+		})
+		It("does not double-quote names that are already quoted", func() {
+			Expect(utils.FQN(`"My Schema"`, `"Foo"`)).To(Equal(`"My Schema"."Foo"`))
+		})
+	})
 })
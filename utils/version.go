@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/blang/semver"
+	"github.com/pkg/errors"
 )
 
 type GPDBVersion struct {
@@ -13,16 +14,40 @@ type GPDBVersion struct {
 }
 
 func (dbversion *GPDBVersion) Initialize(dbconn *DBConn) {
-	err := dbconn.Get(dbversion, "SELECT version() AS versionstring")
+	versionString := ""
+	err := dbconn.Get(&versionString, "SELECT version() AS versionstring")
 	CheckError(err)
-	versionStart := strings.Index(dbversion.VersionString, "(Greenplum Database ") + len("(Greenplum Database ")
-	versionEnd := strings.Index(dbversion.VersionString, ")")
-	dbversion.VersionString = dbversion.VersionString[versionStart:versionEnd]
+	*dbversion = NewVersion(versionString)
+}
+
+/*
+ * This accepts either the full output of version(), from which the part inside
+ * "(Greenplum Database ...)" is extracted, or a version string that has already
+ * been extracted, as stored in the backup config.  Only the major, minor, and
+ * patch numbers are kept in the SemVer, so that e.g. 6.0.0-beta.1 is treated as
+ * 6.0.0 rather than as a prerelease that comes before it, and so that the fourth
+ * number of 4.3.x.y versions is ignored.
+ */
+func NewVersion(versionString string) GPDBVersion {
+	dbversion := GPDBVersion{VersionString: versionString}
+	prefix := "(Greenplum Database "
+	if versionStart := strings.Index(versionString, prefix); versionStart != -1 {
+		versionString = versionString[versionStart+len(prefix):]
+		if versionEnd := strings.Index(versionString, ")"); versionEnd != -1 {
+			versionString = versionString[:versionEnd]
+		}
+		dbversion.VersionString = versionString
+	}
 
 	pattern := regexp.MustCompile(`\d+\.\d+\.\d+`)
-	threeDigitVersion := pattern.FindStringSubmatch(dbversion.VersionString)[0]
+	threeDigitVersion := pattern.FindString(dbversion.VersionString)
+	if threeDigitVersion == "" {
+		logger.Fatal(errors.Errorf("Unable to parse GPDB version %s", dbversion.VersionString), "")
+	}
+	var err error
 	dbversion.SemVer, err = semver.Make(threeDigitVersion)
 	CheckError(err)
+	return dbversion
 }
 
 func StringToSemVerRange(versionStr string) semver.Range {
@@ -13,9 +13,36 @@ var _ = Describe("utils/version tests", func() {
 	fake43 := utils.GPDBVersion{"4.3.0.0", semver.MustParse("4.3.0")}
 	fake50 := utils.GPDBVersion{"5.0.0", semver.MustParse("5.0.0")}
 	fake51 := utils.GPDBVersion{"5.1.0", semver.MustParse("5.1.0")}
+	fake60 := utils.GPDBVersion{"6.0.0-beta.9+dev.129.g4bd4e41 build dev", semver.MustParse("6.0.0")}
 	BeforeEach(func() {
 		connection, mock = testutils.CreateAndConnectMockDB()
 	})
+	Describe("NewVersion", func() {
+		It("parses a GPDB 4.3 version string, ignoring the fourth number", func() {
+			version := utils.NewVersion("4.3.17.1+dev.83.ga57d1b7 build 1")
+			Expect(version.VersionString).To(Equal("4.3.17.1+dev.83.ga57d1b7 build 1"))
+			Expect(version.SemVer).To(Equal(semver.MustParse("4.3.17")))
+		})
+		It("parses a GPDB 5 beta version string", func() {
+			version := utils.NewVersion("5.1.0-beta.5+dev.65.g2a47ec9bfa build dev")
+			Expect(version.VersionString).To(Equal("5.1.0-beta.5+dev.65.g2a47ec9bfa build dev"))
+			Expect(version.SemVer).To(Equal(semver.MustParse("5.1.0")))
+		})
+		It("parses a GPDB 6 beta version string", func() {
+			version := utils.NewVersion("6.0.0-beta.9+dev.129.g4bd4e41 build dev")
+			Expect(version.VersionString).To(Equal("6.0.0-beta.9+dev.129.g4bd4e41 build dev"))
+			Expect(version.SemVer).To(Equal(semver.MustParse("6.0.0")))
+		})
+		It("extracts the GPDB version from the output of version()", func() {
+			version := utils.NewVersion(" PostgreSQL 8.4.23 (Greenplum Database 6.0.0-beta.9+dev.129.g4bd4e41 build dev) on x86_64-apple-darwin14.5.0, compiled by GCC Apple LLVM version 6.0 (clang-600.0.57) (based on LLVM 3.5svn) compiled on Sep  1 2017 16:57:41")
+			Expect(version.VersionString).To(Equal("6.0.0-beta.9+dev.129.g4bd4e41 build dev"))
+			Expect(version.SemVer).To(Equal(semver.MustParse("6.0.0")))
+		})
+		It("panics if the version string does not contain a version", func() {
+			defer testutils.ShouldPanicWithMessage("Unable to parse GPDB version not a version")
+			utils.NewVersion("not a version")
+		})
+	})
 	Describe("StringToSemVerRange", func() {
 		v400 := semver.MustParse("4.0.0")
 		v500 := semver.MustParse("5.0.0")
@@ -59,6 +86,21 @@ var _ = Describe("utils/version tests", func() {
 			result := connection.Version.Before("5")
 			Expect(result).To(BeFalse())
 		})
+		It("returns true when comparing 5.1 to 6", func() {
+			connection.Version = fake51
+			result := connection.Version.Before("6")
+			Expect(result).To(BeTrue())
+		})
+		It("returns false when comparing 6 beta to 6", func() {
+			connection.Version = fake60
+			result := connection.Version.Before("6")
+			Expect(result).To(BeFalse())
+		})
+		It("returns false when comparing 6 beta to 5", func() {
+			connection.Version = fake60
+			result := connection.Version.Before("5")
+			Expect(result).To(BeFalse())
+		})
 	})
 	Describe("AtLeast", func() {
 		It("returns true when comparing 5 to 4.3", func() {
@@ -86,6 +128,21 @@ var _ = Describe("utils/version tests", func() {
 			result := connection.Version.AtLeast("5.1")
 			Expect(result).To(BeFalse())
 		})
+		It("returns true when comparing 6 beta to 5", func() {
+			connection.Version = fake60
+			result := connection.Version.AtLeast("5")
+			Expect(result).To(BeTrue())
+		})
+		It("returns true when comparing 6 beta to 6", func() {
+			connection.Version = fake60
+			result := connection.Version.AtLeast("6")
+			Expect(result).To(BeTrue())
+		})
+		It("returns false when comparing 5.1 to 6", func() {
+			connection.Version = fake51
+			result := connection.Version.AtLeast("6")
+			Expect(result).To(BeFalse())
+		})
 	})
 	Describe("Is", func() {
 		It("returns true when comparing 5 to 5", func() {
@@ -108,5 +165,15 @@ var _ = Describe("utils/version tests", func() {
 			result := connection.Version.Is("5")
 			Expect(result).To(BeFalse())
 		})
+		It("returns true when comparing 6 beta to 6", func() {
+			connection.Version = fake60
+			result := connection.Version.Is("6")
+			Expect(result).To(BeTrue())
+		})
+		It("returns false when comparing 6 beta to 5", func() {
+			connection.Version = fake60
+			result := connection.Version.Is("5")
+			Expect(result).To(BeFalse())
+		})
 	})
 })